@@ -0,0 +1,124 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package amasstest provides in-process DNS, HTTP and proxy stand-ins that allow the Amass tests to run without network access.
+package amasstest
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/owasp-amass/amass/v4/zonefile"
+)
+
+// DefaultQPS is the rate limit applied to the resolver pools built for a DNSServer.
+const DefaultQPS = zonefile.DefaultQPS
+
+// DNSServer is an in-process DNS server answering the queries of the tests from the zones loaded
+// into it, and counting the queries received over each network.
+type DNSServer struct {
+	*zonefile.Zones
+	sync.Mutex
+	addr    string
+	udp     *dns.Server
+	tcp     *dns.Server
+	queries map[string]int
+}
+
+// NewDNSServer returns a DNSServer listening for UDP and TCP queries on the same loopback port.
+func NewDNSServer() (*DNSServer, error) {
+	s := &DNSServer{
+		Zones:   zonefile.NewZones(),
+		queries: make(map[string]int),
+	}
+
+	var err error
+	// The port selected for UDP may already be in use for TCP
+	for i := 0; i < 10; i++ {
+		if err = s.listen(); err == nil {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to start the DNS server: %v", err)
+}
+
+func (s *DNSServer) listen() error {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", pc.LocalAddr().String())
+	if err != nil {
+		pc.Close()
+		return err
+	}
+
+	s.addr = pc.LocalAddr().String()
+	s.udp = s.start(&dns.Server{PacketConn: pc, Handler: s})
+	s.tcp = s.start(&dns.Server{Listener: ln, Handler: s})
+	return nil
+}
+
+func (s *DNSServer) start(srv *dns.Server) *dns.Server {
+	started := make(chan struct{})
+
+	srv.NotifyStartedFunc = func() { close(started) }
+	go func() { _ = srv.ActivateAndServe() }()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+	}
+	return srv
+}
+
+// Addr returns the address, including the port, that the DNSServer is listening on.
+func (s *DNSServer) Addr() string {
+	return s.addr
+}
+
+// Close stops the DNSServer.
+func (s *DNSServer) Close() {
+	_ = s.udp.Shutdown()
+	_ = s.tcp.Shutdown()
+}
+
+// Pool returns a resolver pool that sends all queries to the DNSServer and uses it for wildcard detection.
+func (s *DNSServer) Pool() *resolvers.Pool {
+	p := resolvers.NewPool()
+
+	_ = p.AddResolvers(DefaultQPS, s.addr)
+	p.SetDetectionResolver(DefaultQPS, s.addr)
+	p.SetTimeout(time.Second)
+	return p
+}
+
+// Queries returns the number of queries received by the DNSServer over the network ("udp" or "tcp").
+func (s *DNSServer) Queries(network string) int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.queries[network]
+}
+
+// ServeDNS implements the miekg/dns Handler interface.
+func (s *DNSServer) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	resp := s.Answer(req)
+
+	network := "tcp"
+	if _, udp := w.RemoteAddr().(*net.UDPAddr); udp {
+		network = "udp"
+		resp.Truncate(zonefile.MaxUDPSize(req))
+	}
+
+	s.Lock()
+	s.queries[network]++
+	s.Unlock()
+	_ = w.WriteMsg(resp)
+}
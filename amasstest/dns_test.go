@@ -0,0 +1,48 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package amasstest_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/amasstest"
+	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSServer(t *testing.T) {
+	srv, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	defer srv.Close()
+
+	var records []string
+	for i := 1; i <= 50; i++ {
+		records = append(records, fmt.Sprintf("many IN A 127.0.1.%d", i))
+	}
+	require.NoError(t, srv.LoadZoneString("example.com", exampleZone+strings.Join(records, "\n")))
+
+	resp, err := dns.Exchange(resolve.QueryMsg("web.example.com", dns.TypeA), srv.Addr())
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+	require.Equal(t, 1, srv.Queries("udp"))
+
+	// The responses that do not fit in the datagram are truncated, and complete over TCP
+	msg := resolve.QueryMsg("many.example.com", dns.TypeA)
+	msg.SetEdns0(dns.MinMsgSize, false)
+	resp, err = dns.Exchange(msg, srv.Addr())
+	require.NoError(t, err)
+	require.True(t, resp.Truncated)
+
+	c := &dns.Client{Net: "tcp"}
+	resp, _, err = c.Exchange(msg, srv.Addr())
+	require.NoError(t, err)
+	require.False(t, resp.Truncated)
+	require.Len(t, resp.Answer, 50)
+	require.Equal(t, 2, srv.Queries("udp"))
+	require.Equal(t, 1, srv.Queries("tcp"))
+}
@@ -0,0 +1,189 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package amasstest
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+)
+
+const (
+	socksVersion     byte = 5
+	socksNoAuth      byte = 0
+	socksConnect     byte = 1
+	socksIPv4        byte = 1
+	socksDomain      byte = 3
+	socksIPv6        byte = 4
+	socksSucceeded   byte = 0
+	socksUnreachable byte = 4
+	socksNotAllowed  byte = 7
+)
+
+// SOCKS5Server is an in-process SOCKS5 proxy that supports the CONNECT command without
+// authentication, and keeps the destinations requested through it.
+type SOCKS5Server struct {
+	sync.Mutex
+	ln    net.Listener
+	dests []string
+	wg    sync.WaitGroup
+}
+
+// NewSOCKS5Server returns a SOCKS5Server listening on the loopback interface.
+func NewSOCKS5Server() (*SOCKS5Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start the SOCKS5 server: %v", err)
+	}
+
+	s := &SOCKS5Server{ln: ln}
+	s.wg.Add(1)
+	go s.serve()
+	return s, nil
+}
+
+// Addr returns the address, including the port, that the SOCKS5Server is listening on.
+func (s *SOCKS5Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// URL returns the proxy URL for the SOCKS5Server.
+func (s *SOCKS5Server) URL() string {
+	return "socks5://" + s.Addr()
+}
+
+// Destinations returns the addresses that connections were requested for, in order.
+func (s *SOCKS5Server) Destinations() []string {
+	s.Lock()
+	defer s.Unlock()
+
+	return append([]string(nil), s.dests...)
+}
+
+// Close stops the SOCKS5Server.
+func (s *SOCKS5Server) Close() {
+	_ = s.ln.Close()
+	s.wg.Wait()
+}
+
+func (s *SOCKS5Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *SOCKS5Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	dest, err := s.handshake(conn)
+	if err != nil {
+		return
+	}
+
+	s.Lock()
+	s.dests = append(s.dests, dest)
+	s.Unlock()
+
+	remote, err := net.Dial("tcp", dest)
+	if err != nil {
+		_ = socksReply(conn, socksUnreachable)
+		return
+	}
+	defer remote.Close()
+
+	if err := socksReply(conn, socksSucceeded); err != nil {
+		return
+	}
+
+	done := make(chan struct{}, 2)
+	relay := func(dst, src net.Conn) {
+		_, _ = io.Copy(dst, src)
+		if tc, ok := dst.(*net.TCPConn); ok {
+			_ = tc.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go relay(remote, conn)
+	go relay(conn, remote)
+	<-done
+	<-done
+}
+
+// handshake negotiates the method and reads the CONNECT request, returning the destination.
+func (s *SOCKS5Server) handshake(conn net.Conn) (string, error) {
+	hdr := make([]byte, 2)
+	if _, err := io.ReadFull(conn, hdr); err != nil {
+		return "", err
+	}
+	if hdr[0] != socksVersion {
+		return "", errors.New("unsupported SOCKS version")
+	}
+
+	methods := make([]byte, int(hdr[1]))
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", err
+	}
+	if _, err := conn.Write([]byte{socksVersion, socksNoAuth}); err != nil {
+		return "", err
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return "", err
+	}
+	if req[1] != socksConnect {
+		_ = socksReply(conn, socksNotAllowed)
+		return "", errors.New("unsupported SOCKS command")
+	}
+
+	var host string
+	switch req[3] {
+	case socksIPv4, socksIPv6:
+		size := net.IPv4len
+		if req[3] == socksIPv6 {
+			size = net.IPv6len
+		}
+
+		ip := make([]byte, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case socksDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return "", err
+		}
+
+		name := make([]byte, int(l[0]))
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		return "", errors.New("unsupported SOCKS address type")
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
+
+func socksReply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{socksVersion, code, 0, socksIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
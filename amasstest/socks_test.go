@@ -0,0 +1,83 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package amasstest_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/amasstest"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	amasshttp "github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+const exampleZone = `
+@	IN	NS	ns1.example.com.
+ns1	IN	A	127.0.0.53
+www	IN	CNAME	web
+web	IN	A	127.0.0.80
+`
+
+func TestSOCKS5Proxy(t *testing.T) {
+	proxy, err := amasstest.NewSOCKS5Server()
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	srv, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	defer srv.Close()
+	require.NoError(t, srv.LoadZoneString("example.com", exampleZone))
+
+	ts := amasstest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("proxied"))
+	}))
+	defer ts.Close()
+
+	d := amassnet.NewDialer()
+	require.Error(t, d.SetProxy("http://"+proxy.Addr()))
+	require.NoError(t, d.SetProxy(proxy.URL()))
+	require.True(t, d.Proxied())
+	// The other Dialers are not affected by the proxy
+	require.False(t, amassnet.NewDialer().Proxied())
+
+	ctx := context.Background()
+	// The DNS queries must be sent over TCP through the proxy
+	p := resolvers.NewPool()
+	defer p.Stop()
+	p.SetDialer(d)
+	require.NoError(t, p.AddResolvers(amasstest.DefaultQPS, srv.Addr()))
+	resp, err := p.QueryBlocking(ctx, resolve.QueryMsg("web.example.com", dns.TypeA))
+	require.NoError(t, err)
+	ans := resolve.ExtractAnswers(resp)
+	require.Len(t, ans, 1)
+	require.Equal(t, "127.0.0.80", ans[0].Data)
+	require.Contains(t, proxy.Destinations(), srv.Addr())
+	require.Zero(t, srv.Queries("udp"))
+	require.NotZero(t, srv.Queries("tcp"))
+
+	c, err := amasshttp.NewClient(nil, d)
+	require.NoError(t, err)
+	page, err := c.RequestWebPage(ctx, &amasshttp.Request{URL: ts.URL})
+	require.NoError(t, err)
+	require.Equal(t, "proxied", page.Body)
+	require.Contains(t, proxy.Destinations(), ts.Listener.Addr().String())
+
+	_, err = d.DialContext(ctx, "udp", srv.Addr())
+	require.True(t, errors.Is(err, amassnet.ErrProxyUDP))
+
+	require.NoError(t, d.SetProxy(""))
+	require.False(t, d.Proxied())
+	before := len(proxy.Destinations())
+	conn, err := d.DialContext(ctx, "tcp", srv.Addr())
+	require.NoError(t, err)
+	conn.Close()
+	require.Len(t, proxy.Destinations(), before)
+}
@@ -0,0 +1,101 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package amasstest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+// NewCertificate returns a self-signed certificate for the provided names. Entries that are IP
+// addresses are added to the certificate as such, and the first name is used as the common name.
+func NewCertificate(names ...string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate the certificate key: %v", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate the certificate serial number: %v", err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	if len(names) > 0 {
+		tmpl.Subject = pkix.Name{CommonName: names[0]}
+	}
+	for _, name := range names {
+		if ip := net.ParseIP(name); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, name)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create the certificate: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to parse the certificate: %v", err)
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
+
+// NewTLSServer starts an httptest server on the loopback interface that presents the provided
+// certificates. The default httptest certificate is used when none are provided.
+func NewTLSServer(handler http.Handler, certs ...tls.Certificate) *httptest.Server {
+	ts := httptest.NewUnstartedServer(handler)
+
+	if len(certs) > 0 {
+		ts.TLS = &tls.Config{Certificates: certs}
+	}
+	ts.StartTLS()
+	return ts
+}
+
+// CertPool returns a pool containing the certificates, so clients are able to verify servers presenting them.
+func CertPool(certs ...tls.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+
+	for _, cert := range certs {
+		if cert.Leaf != nil {
+			pool.AddCert(cert.Leaf)
+		}
+	}
+	return pool
+}
+
+// ServerPort returns the port number that the httptest server is listening on.
+func ServerPort(ts *httptest.Server) int {
+	if addr, ok := ts.Listener.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
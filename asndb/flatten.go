@@ -0,0 +1,168 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package asndb
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/owasp-amass/amass/v4/resources"
+)
+
+type span struct {
+	first  [16]byte
+	last   [16]byte
+	asn    uint32
+	info   uint32
+	extras []*span
+}
+
+// flatten converts the ranges into sorted records that do not overlap, where the most specific
+// range takes precedence over the ranges containing it. Identical ranges with different origin
+// ASNs are kept as adjacent records, so the multiple origins of a prefix are preserved.
+func flatten(ranges []*resources.IP2ASN) ([]*record, []*info) {
+	var infos []*info
+	idx := make(map[info]uint32)
+
+	var spans []*span
+	for _, r := range ranges {
+		first, last := r.FirstIP.To16(), r.LastIP.To16()
+		if first == nil || last == nil || bytes.Compare(first, last) > 0 {
+			continue
+		}
+
+		key := info{CC: r.CC, Description: r.Description}
+		i, found := idx[key]
+		if !found {
+			i = uint32(len(infos))
+			idx[key] = i
+			infos = append(infos, &info{CC: r.CC, Description: r.Description})
+		}
+
+		s := &span{asn: uint32(r.ASN), info: i}
+		copy(s.first[:], first)
+		copy(s.last[:], last)
+		spans = append(spans, s)
+	}
+	// Order by the first address, with the larger ranges ahead of the ranges they contain
+	sort.SliceStable(spans, func(i, j int) bool {
+		if c := bytes.Compare(spans[i].first[:], spans[j].first[:]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(spans[i].last[:], spans[j].last[:]) > 0
+	})
+	spans = groupOrigins(spans)
+
+	var recs []*record
+	var grouped bool
+	emit := func(s *span, first, last [16]byte) {
+		if bytes.Compare(first[:], last[:]) > 0 {
+			return
+		}
+		// Merge with the previous record when they are contiguous and identical
+		if n := len(recs); n > 0 && !grouped && len(s.extras) == 0 {
+			prev := recs[n-1]
+
+			if prev.ASN == s.asn && prev.Info == s.info {
+				if next, ok := inc(prev.Last); ok && next == first {
+					prev.Last = last
+					return
+				}
+			}
+		}
+
+		grouped = len(s.extras) > 0
+		recs = append(recs, &record{First: first, Last: last, ASN: s.asn, Info: s.info})
+		for _, e := range s.extras {
+			recs = append(recs, &record{First: first, Last: last, ASN: e.asn, Info: e.info})
+		}
+	}
+
+	var cur [16]byte
+	var exhausted bool
+	var stack []*span
+	pop := func() {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if !exhausted && bytes.Compare(cur[:], top.last[:]) <= 0 {
+			emit(top, cur, top.last)
+			cur, exhausted = incExhausted(top.last)
+		}
+	}
+
+	for _, s := range spans {
+		for len(stack) > 0 && bytes.Compare(stack[len(stack)-1].last[:], s.first[:]) < 0 {
+			pop()
+		}
+		if n := len(stack); n > 0 && !exhausted && bytes.Compare(cur[:], s.first[:]) < 0 {
+			if prev, ok := dec(s.first); ok {
+				emit(stack[n-1], cur, prev)
+			}
+		}
+
+		cur, exhausted = s.first, false
+		stack = append(stack, s)
+	}
+	for len(stack) > 0 {
+		pop()
+	}
+	return recs, infos
+}
+
+// groupOrigins folds the sorted spans covering identical ranges into a single span.
+func groupOrigins(spans []*span) []*span {
+	var grouped []*span
+
+	for _, s := range spans {
+		if n := len(grouped); n > 0 {
+			if prev := grouped[n-1]; prev.first == s.first && prev.last == s.last {
+				if !hasOrigin(prev, s.asn) {
+					prev.extras = append(prev.extras, s)
+				}
+				continue
+			}
+		}
+		grouped = append(grouped, s)
+	}
+	return grouped
+}
+
+func hasOrigin(s *span, asn uint32) bool {
+	if s.asn == asn {
+		return true
+	}
+	for _, e := range s.extras {
+		if e.asn == asn {
+			return true
+		}
+	}
+	return false
+}
+
+func incExhausted(b [16]byte) ([16]byte, bool) {
+	next, ok := inc(b)
+	return next, !ok
+}
+
+func inc(b [16]byte) ([16]byte, bool) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return b, true
+		}
+	}
+	return b, false
+}
+
+func dec(b [16]byte) ([16]byte, bool) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]--
+		if b[i] != 0xff {
+			return b, true
+		}
+	}
+	return b, false
+}
@@ -0,0 +1,139 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package asndb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/resources"
+)
+
+// The data formats that can be imported into the ASN database.
+const (
+	FormatIP2ASN = "ip2asn"
+	FormatPfx2AS = "pfx2as"
+	FormatMRT    = "mrt"
+)
+
+// ReadRanges parses the address ranges provided by the reader in the specified format.
+// Compressed data is detected and decompressed, and an empty format is detected from the data.
+func ReadRanges(r io.Reader, format string) ([]*resources.IP2ASN, error) {
+	reader, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		if format, err = detectFormat(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	switch strings.ToLower(format) {
+	case FormatIP2ASN:
+		return resources.ReadIP2ASNData(reader)
+	case FormatPfx2AS:
+		return readPfx2AS(reader)
+	case FormatMRT:
+		return readMRT(reader)
+	}
+	return nil, fmt.Errorf("unsupported ASN data format: %s", format)
+}
+
+func decompress(r io.Reader) (*bufio.Reader, error) {
+	reader := bufio.NewReaderSize(r, 1<<20)
+
+	magic, err := reader.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress the gzip data: %v", err)
+		}
+		return bufio.NewReaderSize(zr, 1<<20), nil
+	}
+	if bytes.Equal(magic, []byte("BZh")) {
+		return bufio.NewReaderSize(bzip2.NewReader(reader), 1<<20), nil
+	}
+	return reader, nil
+}
+
+func detectFormat(r *bufio.Reader) (string, error) {
+	head, err := r.Peek(512)
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	if len(head) >= 6 && binary.BigEndian.Uint16(head[4:6]) == mrtTableDumpV2 {
+		return FormatMRT, nil
+	}
+
+	for _, line := range strings.Split(string(head), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		switch len(strings.Split(line, "\t")) {
+		case 5:
+			return FormatIP2ASN, nil
+		case 3:
+			return FormatPfx2AS, nil
+		}
+		break
+	}
+	return "", fmt.Errorf("unable to detect the format of the ASN data")
+}
+
+// readPfx2AS parses the CAIDA RouteViews prefix to AS mapping format.
+func readPfx2AS(r io.Reader) ([]*resources.IP2ASN, error) {
+	var ranges []*resources.IP2ASN
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Fields(line)
+		if len(parts) != 3 {
+			continue
+		}
+
+		_, ipnet, err := net.ParseCIDR(parts[0] + "/" + parts[1])
+		if err != nil {
+			continue
+		}
+		first, last := amassnet.FirstLast(ipnet)
+		// Multi-origin and AS set entries are separated by underscores and commas
+		for _, origin := range strings.FieldsFunc(parts[2], func(c rune) bool { return c == '_' || c == ',' }) {
+			asn, err := strconv.Atoi(origin)
+			if err != nil || asn == 0 {
+				continue
+			}
+
+			ranges = append(ranges, &resources.IP2ASN{
+				FirstIP: first,
+				LastIP:  last,
+				ASN:     asn,
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the pfx2as data: %v", err)
+	}
+	return ranges, nil
+}
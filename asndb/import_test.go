@@ -0,0 +1,113 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package asndb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testIP2ASN = "1.0.0.0\t1.0.0.255\t13335\tUS\tCLOUDFLARENET\n" +
+	"1.0.4.0\t1.0.7.255\t38803\tAU\tGTELECOM-AUSTRALIA Gtelecom Pty Ltd\n"
+
+const testPfx2AS = "1.0.0.0\t24\t13335\n" +
+	"1.0.4.0\t22\t38803\n" +
+	"1.0.64.0\t18\t18144_2519\n" +
+	"2001:200::\t32\t2500\n"
+
+func TestReadIP2ASN(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	_, _ = zw.Write([]byte(testIP2ASN))
+	require.NoError(t, zw.Close())
+
+	ranges, err := ReadRanges(&buf, "")
+	require.NoError(t, err)
+	require.Len(t, ranges, 2)
+	require.Equal(t, 38803, ranges[1].ASN)
+	require.Equal(t, "AU", ranges[1].CC)
+	require.Equal(t, "1.0.7.255", ranges[1].LastIP.String())
+}
+
+func TestReadPfx2AS(t *testing.T) {
+	ranges, err := ReadRanges(strings.NewReader(testPfx2AS), "")
+	require.NoError(t, err)
+	require.Len(t, ranges, 5)
+	require.Equal(t, "1.0.7.255", ranges[1].LastIP.String())
+	require.Equal(t, 18144, ranges[2].ASN)
+	require.Equal(t, 2519, ranges[3].ASN)
+	require.Equal(t, ranges[2].FirstIP, ranges[3].FirstIP)
+	require.Equal(t, 2500, ranges[4].ASN)
+	require.Equal(t, "2001:200::", ranges[4].FirstIP.String())
+}
+
+func TestReadMRT(t *testing.T) {
+	var buf bytes.Buffer
+
+	writeMRT(&buf, 1, nil)
+	writeMRT(&buf, mrtRIBIPv4Unicast, ribEntry(net.ParseIP("1.0.4.0").To4(), 22,
+		[]uint32{3356, 38803}, []uint32{174, 38803}))
+	writeMRT(&buf, mrtRIBIPv4Unicast, ribEntry(net.ParseIP("1.0.64.0").To4(), 18,
+		[]uint32{2914, 18144}, []uint32{2914, 2519}))
+	writeMRT(&buf, mrtRIBIPv6Unicast, ribEntry(net.ParseIP("2001:200::"), 32, []uint32{2914, 2500}))
+
+	ranges, err := ReadRanges(&buf, "")
+	require.NoError(t, err)
+	require.Len(t, ranges, 4)
+	require.Equal(t, 38803, ranges[0].ASN)
+	require.Equal(t, "1.0.4.0", ranges[0].FirstIP.String())
+	require.Equal(t, "1.0.7.255", ranges[0].LastIP.String())
+	require.Equal(t, 18144, ranges[1].ASN)
+	require.Equal(t, 2519, ranges[2].ASN)
+	require.Equal(t, 2500, ranges[3].ASN)
+}
+
+func TestUnknownFormat(t *testing.T) {
+	_, err := ReadRanges(strings.NewReader("not asn data\n"), "")
+	require.Error(t, err)
+	_, err = ReadRanges(strings.NewReader(testPfx2AS), "csv")
+	require.Error(t, err)
+}
+
+func writeMRT(buf *bytes.Buffer, subtype uint16, body []byte) {
+	hdr := make([]byte, mrtHeaderSize)
+	binary.BigEndian.PutUint16(hdr[4:], mrtTableDumpV2)
+	binary.BigEndian.PutUint16(hdr[6:], subtype)
+	binary.BigEndian.PutUint32(hdr[8:], uint32(len(body)))
+	buf.Write(hdr)
+	buf.Write(body)
+}
+
+func ribEntry(prefix net.IP, bits int, paths ...[]uint32) []byte {
+	var b bytes.Buffer
+
+	_ = binary.Write(&b, binary.BigEndian, uint32(1))
+	b.WriteByte(byte(bits))
+	b.Write(prefix[:(bits+7)/8])
+	_ = binary.Write(&b, binary.BigEndian, uint16(len(paths)))
+
+	for i, path := range paths {
+		// The ORIGIN attribute precedes the AS_PATH
+		attrs := []byte{0x40, 1, 1, 0}
+		seg := []byte{bgpSegmentSequence, byte(len(path))}
+		for _, asn := range path {
+			seg = binary.BigEndian.AppendUint32(seg, asn)
+		}
+		attrs = append(attrs, 0x40, bgpAttrASPath, byte(len(seg)))
+		attrs = append(attrs, seg...)
+
+		_ = binary.Write(&b, binary.BigEndian, uint16(i))
+		_ = binary.Write(&b, binary.BigEndian, uint32(0))
+		_ = binary.Write(&b, binary.BigEndian, uint16(len(attrs)))
+		b.Write(attrs)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,184 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package asndb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/resources"
+)
+
+// MRT values from RFC 6396 and BGP attribute values from RFC 4271.
+const (
+	mrtTableDumpV2     uint16 = 13
+	mrtRIBIPv4Unicast  uint16 = 2
+	mrtRIBIPv6Unicast  uint16 = 4
+	bgpAttrExtLength   byte   = 0x10
+	bgpAttrASPath      byte   = 2
+	bgpSegmentASSet    byte   = 1
+	bgpSegmentSequence byte   = 2
+	mrtHeaderSize             = 12
+)
+
+var errMRTTruncated = errors.New("the MRT record is truncated")
+
+// readMRT parses the origin ASNs for each prefix in a TABLE_DUMP_V2 RIB dump.
+func readMRT(r io.Reader) ([]*resources.IP2ASN, error) {
+	var ranges []*resources.IP2ASN
+
+	hdr := make([]byte, mrtHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, hdr); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read the MRT header: %v", err)
+		}
+
+		mtype := binary.BigEndian.Uint16(hdr[4:6])
+		subtype := binary.BigEndian.Uint16(hdr[6:8])
+		body := make([]byte, binary.BigEndian.Uint32(hdr[8:12]))
+		if _, err := io.ReadFull(r, body); err != nil {
+			return nil, fmt.Errorf("failed to read the MRT record: %v", err)
+		}
+
+		if mtype != mrtTableDumpV2 || (subtype != mrtRIBIPv4Unicast && subtype != mrtRIBIPv6Unicast) {
+			continue
+		}
+
+		size := net.IPv4len
+		if subtype == mrtRIBIPv6Unicast {
+			size = net.IPv6len
+		}
+
+		ipnet, origins, err := parseRIB(body, size)
+		if err != nil {
+			return nil, err
+		}
+		if ipnet == nil {
+			continue
+		}
+
+		first, last := amassnet.FirstLast(ipnet)
+		for _, asn := range origins {
+			ranges = append(ranges, &resources.IP2ASN{
+				FirstIP: first,
+				LastIP:  last,
+				ASN:     asn,
+			})
+		}
+	}
+	return ranges, nil
+}
+
+// parseRIB returns the prefix and the distinct origin ASNs seen by the peers, in the order first seen.
+func parseRIB(body []byte, size int) (*net.IPNet, []int, error) {
+	// Skip the sequence number
+	if len(body) < 5 {
+		return nil, nil, errMRTTruncated
+	}
+
+	bits := int(body[4])
+	if bits > size*8 {
+		return nil, nil, fmt.Errorf("invalid MRT prefix length: %d", bits)
+	}
+
+	plen := (bits + 7) / 8
+	body = body[5:]
+	if len(body) < plen+2 {
+		return nil, nil, errMRTTruncated
+	}
+
+	ip := make(net.IP, size)
+	copy(ip, body[:plen])
+	ipnet := &net.IPNet{
+		IP:   ip.Mask(net.CIDRMask(bits, size*8)),
+		Mask: net.CIDRMask(bits, size*8),
+	}
+
+	var origins []int
+	count := int(binary.BigEndian.Uint16(body[plen:]))
+	body = body[plen+2:]
+	for i := 0; i < count; i++ {
+		// Peer index and originated time precede the attribute length
+		if len(body) < 8 {
+			return nil, nil, errMRTTruncated
+		}
+
+		alen := int(binary.BigEndian.Uint16(body[6:8]))
+		if len(body) < 8+alen {
+			return nil, nil, errMRTTruncated
+		}
+
+		attrs := body[8 : 8+alen]
+		body = body[8+alen:]
+		if asn := originAS(attrs); asn != 0 && !containsASN(origins, asn) {
+			origins = append(origins, asn)
+		}
+	}
+	return ipnet, origins, nil
+}
+
+func originAS(attrs []byte) int {
+	for len(attrs) >= 3 {
+		flags, atype := attrs[0], attrs[1]
+
+		var alen, hlen int
+		if flags&bgpAttrExtLength != 0 {
+			if len(attrs) < 4 {
+				return 0
+			}
+			alen, hlen = int(binary.BigEndian.Uint16(attrs[2:4])), 4
+		} else {
+			alen, hlen = int(attrs[2]), 3
+		}
+		if len(attrs) < hlen+alen {
+			return 0
+		}
+
+		value := attrs[hlen : hlen+alen]
+		attrs = attrs[hlen+alen:]
+		if atype == bgpAttrASPath {
+			return lastASN(value)
+		}
+	}
+	return 0
+}
+
+// lastASN returns the origin of an AS_PATH, which TABLE_DUMP_V2 always encodes with 4-byte AS numbers.
+func lastASN(path []byte) int {
+	var origin int
+
+	for len(path) >= 2 {
+		stype, count := path[0], int(path[1])
+		if len(path) < 2+count*4 {
+			break
+		}
+
+		if count > 0 {
+			switch stype {
+			case bgpSegmentSequence:
+				origin = int(binary.BigEndian.Uint32(path[2+(count-1)*4:]))
+			case bgpSegmentASSet:
+				origin = int(binary.BigEndian.Uint32(path[2:]))
+			}
+		}
+		path = path[2+count*4:]
+	}
+	return origin
+}
+
+func containsASN(asns []int, asn int) bool {
+	for _, a := range asns {
+		if a == asn {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,539 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package asndb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caffix/stringset"
+	"github.com/owasp-amass/amass/v4/format"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resources"
+)
+
+// Filename is the name of the ASN database file kept in the output directory.
+const Filename = "asn.db"
+
+const (
+	version    uint32 = 1
+	headerSize int64  = 256
+	recordSize int64  = 40
+	maxSource  int    = 192
+)
+
+var magic = []byte("AMASSASN")
+
+// Store is a compact, file-backed database of address ranges and their origin autonomous systems.
+// Records are sorted by address and searched on disk, so the data is never loaded into memory.
+type Store struct {
+	file       *os.File
+	count      int64
+	stringsOff int64
+	numStrings int64
+	created    time.Time
+	source     string
+}
+
+type record struct {
+	First [16]byte
+	Last  [16]byte
+	ASN   uint32
+	Info  uint32
+}
+
+type info struct {
+	CC          string
+	Description string
+}
+
+// Build writes a new database file at path containing the provided ranges.
+// Overlapping ranges are flattened so the most specific range wins.
+func Build(path, source string, ranges []*resources.IP2ASN) error {
+	recs, infos := flatten(ranges)
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, ".asndb-*")
+	if err != nil {
+		return fmt.Errorf("failed to create the ASN database file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp, source, recs, infos); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write the ASN database file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func write(f *os.File, source string, recs []*record, infos []*info) error {
+	w := bufio.NewWriter(f)
+
+	if len(source) > maxSource {
+		source = source[:maxSource]
+	}
+	stringsOff := headerSize + int64(len(recs))*recordSize
+
+	hdr := make([]byte, headerSize)
+	copy(hdr, magic)
+	binary.BigEndian.PutUint32(hdr[8:], version)
+	binary.BigEndian.PutUint64(hdr[16:], uint64(time.Now().Unix()))
+	binary.BigEndian.PutUint64(hdr[24:], uint64(len(recs)))
+	binary.BigEndian.PutUint64(hdr[32:], uint64(stringsOff))
+	binary.BigEndian.PutUint64(hdr[40:], uint64(len(infos)))
+	binary.BigEndian.PutUint16(hdr[48:], uint16(len(source)))
+	copy(hdr[50:], source)
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+
+	buf := make([]byte, recordSize)
+	for _, r := range recs {
+		copy(buf[0:16], r.First[:])
+		copy(buf[16:32], r.Last[:])
+		binary.BigEndian.PutUint32(buf[32:], r.ASN)
+		binary.BigEndian.PutUint32(buf[36:], r.Info)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+	// The string section starts with a table of offsets for each entry
+	offset := int64(len(infos)) * 8
+	for _, i := range infos {
+		if err := binary.Write(w, binary.BigEndian, uint64(offset)); err != nil {
+			return err
+		}
+		offset += int64(4 + len(i.CC) + len(i.Description))
+	}
+	for _, i := range infos {
+		if err := writeString(w, i.CC); err != nil {
+			return err
+		}
+		if err := writeString(w, i.Description); err != nil {
+			return err
+		}
+	}
+	return w.Flush()
+}
+
+func writeString(w io.Writer, s string) error {
+	if len(s) > 0xffff {
+		s = s[:0xffff]
+	}
+	if err := binary.Write(w, binary.BigEndian, uint16(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+// Open returns the Store for the database file at path.
+func Open(path string) (*Store, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := make([]byte, headerSize)
+	if _, err := f.ReadAt(hdr, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to read the ASN database header: %v", err)
+	}
+	if !bytes.Equal(hdr[:8], magic) {
+		f.Close()
+		return nil, errors.New("the file is not an ASN database")
+	}
+	if v := binary.BigEndian.Uint32(hdr[8:]); v != version {
+		f.Close()
+		return nil, fmt.Errorf("the ASN database has version %d, expected version %d", v, version)
+	}
+
+	slen := int(binary.BigEndian.Uint16(hdr[48:]))
+	if slen > maxSource {
+		slen = maxSource
+	}
+	s := &Store{
+		file:       f,
+		created:    time.Unix(int64(binary.BigEndian.Uint64(hdr[16:])), 0),
+		count:      int64(binary.BigEndian.Uint64(hdr[24:])),
+		stringsOff: int64(binary.BigEndian.Uint64(hdr[32:])),
+		numStrings: int64(binary.BigEndian.Uint64(hdr[40:])),
+		source:     string(hdr[50 : 50+slen]),
+	}
+	if s.stringsOff != headerSize+s.count*recordSize {
+		f.Close()
+		return nil, errors.New("the ASN database file is corrupt")
+	}
+	return s, nil
+}
+
+// Close releases the file held by the Store.
+func (s *Store) Close() error {
+	return s.file.Close()
+}
+
+// Len returns the number of address ranges in the Store.
+func (s *Store) Len() int {
+	return int(s.count)
+}
+
+// Created returns the time the database file was built.
+func (s *Store) Created() time.Time {
+	return s.created
+}
+
+// Source returns the description of the data the database was built from.
+func (s *Store) Source() string {
+	return s.source
+}
+
+// Lookup returns the ranges containing the provided IP address, one for each origin ASN
+// with the primary origin first, or nil when not found.
+func (s *Store) Lookup(ip net.IP) ([]*resources.IP2ASN, error) {
+	key := ip.To16()
+	if key == nil {
+		return nil, errors.New("invalid IP address")
+	}
+
+	var err error
+	// Find the first record that starts after the address
+	idx := sort.Search(int(s.count), func(i int) bool {
+		r, e := s.readRecord(int64(i))
+		if e != nil {
+			err = e
+			return true
+		}
+		return bytes.Compare(r.First[:], key) > 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	if idx == 0 {
+		return nil, nil
+	}
+
+	last, err := s.readRecord(int64(idx - 1))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Compare(last.Last[:], key) < 0 {
+		return nil, nil
+	}
+
+	recs := []*record{last}
+	// Records for the other origins of the range are adjacent
+	for i := int64(idx - 2); i >= 0; i-- {
+		r, err := s.readRecord(i)
+		if err != nil {
+			return nil, err
+		}
+		if r.First != last.First || r.Last != last.Last {
+			break
+		}
+		recs = append([]*record{r}, recs...)
+	}
+
+	var ranges []*resources.IP2ASN
+	for _, r := range recs {
+		rng, err := s.toRange(r)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, rng)
+	}
+	return ranges, nil
+}
+
+// Ranges calls the callback for every range in the Store, in address order, until it returns false.
+func (s *Store) Ranges(callback func(*resources.IP2ASN) bool) error {
+	r := bufio.NewReaderSize(io.NewSectionReader(s.file, headerSize, s.count*recordSize), 1<<20)
+	infos := make(map[uint32]*info)
+
+	buf := make([]byte, recordSize)
+	for i := int64(0); i < s.count; i++ {
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return err
+		}
+
+		rec := decodeRecord(buf)
+		in, found := infos[rec.Info]
+		if !found {
+			var err error
+			if in, err = s.readInfo(rec.Info); err != nil {
+				return err
+			}
+			infos[rec.Info] = in
+		}
+		if !callback(newRange(rec, in)) {
+			break
+		}
+	}
+	return nil
+}
+
+// Annotate fills in the country codes and descriptions missing from the ranges using the Store data.
+func (s *Store) Annotate(ranges []*resources.IP2ASN) error {
+	asns := make(map[int]*resources.IP2ASN)
+	for _, r := range ranges {
+		if r.CC == "" || r.Description == "" {
+			asns[r.ASN] = nil
+		}
+	}
+	if len(asns) == 0 {
+		return nil
+	}
+
+	err := s.Ranges(func(r *resources.IP2ASN) bool {
+		if a, found := asns[r.ASN]; found && (a == nil || a.Description == "") {
+			asns[r.ASN] = r
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, r := range ranges {
+		if a := asns[r.ASN]; a != nil {
+			if r.CC == "" {
+				r.CC = a.CC
+			}
+			if r.Description == "" {
+				r.Description = a.Description
+			}
+		}
+	}
+	return nil
+}
+
+// AddrSearch implements the requests.ASNStore interface.
+func (s *Store) AddrSearch(addr string) *requests.ASNRequest {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil
+	}
+
+	ranges, err := s.Lookup(ip)
+	if err != nil || len(ranges) == 0 {
+		return nil
+	}
+
+	var prefix string
+	var netblocks []string
+	// The range is split into the exact set of CIDRs that it covers
+	for _, cidr := range amassnet.Range2CIDRs(ranges[0].FirstIP, ranges[0].LastIP) {
+		if ones, _ := cidr.Mask.Size(); ones == 0 {
+			continue
+		}
+		if cidr.Contains(ip) {
+			prefix = cidr.String()
+		}
+		netblocks = append(netblocks, cidr.String())
+	}
+	if prefix == "" {
+		return nil
+	}
+
+	var reqs []*requests.ASNRequest
+	for _, r := range ranges {
+		reqs = append(reqs, &requests.ASNRequest{
+			Address:     addr,
+			ASN:         r.ASN,
+			CC:          r.CC,
+			Prefix:      prefix,
+			Netblocks:   append([]string(nil), netblocks...),
+			Description: r.Description,
+		})
+	}
+	// Additional origins of the prefix are provided as alternates
+	reqs[0].Alternates = reqs[1:]
+	return reqs[0]
+}
+
+// ASNSearch implements the requests.ASNStore interface.
+func (s *Store) ASNSearch(asn int) *requests.ASNRequest {
+	if asn == 0 {
+		return nil
+	}
+
+	reqs := s.search(func(r *resources.IP2ASN) bool { return r.ASN == asn })
+	if len(reqs) == 0 {
+		return nil
+	}
+	return reqs[0]
+}
+
+// DescriptionSearch implements the requests.ASNStore interface.
+func (s *Store) DescriptionSearch(str string) []*requests.ASNRequest {
+	return s.search(func(r *resources.IP2ASN) bool {
+		return r.ASN != 0 && strings.Contains(r.Description, str)
+	})
+}
+
+func (s *Store) search(match func(*resources.IP2ASN) bool) []*requests.ASNRequest {
+	var results []*requests.ASNRequest
+	asns := make(map[int]*requests.ASNRequest)
+	netblocks := make(map[int]*stringset.Set)
+
+	_ = s.Ranges(func(r *resources.IP2ASN) bool {
+		if !match(r) {
+			return true
+		}
+
+		cidrs := amassnet.Range2CIDRs(r.FirstIP, r.LastIP)
+		if len(cidrs) == 0 {
+			return true
+		}
+
+		req, found := asns[r.ASN]
+		if !found {
+			req = &requests.ASNRequest{
+				Address:     r.FirstIP.String(),
+				ASN:         r.ASN,
+				CC:          r.CC,
+				Prefix:      cidrs[0].String(),
+				Description: r.Description,
+			}
+			asns[r.ASN] = req
+			netblocks[r.ASN] = stringset.New()
+			results = append(results, req)
+		}
+		for _, cidr := range cidrs {
+			netblocks[r.ASN].Insert(cidr.String())
+		}
+		return true
+	})
+
+	for _, req := range results {
+		set := netblocks[req.ASN]
+
+		req.Netblocks = set.Slice()
+		sort.Strings(req.Netblocks)
+		set.Close()
+	}
+	return results
+}
+
+func (s *Store) readRecord(idx int64) (*record, error) {
+	buf := make([]byte, recordSize)
+
+	if _, err := s.file.ReadAt(buf, headerSize+idx*recordSize); err != nil {
+		return nil, err
+	}
+	return decodeRecord(buf), nil
+}
+
+func decodeRecord(buf []byte) *record {
+	r := new(record)
+
+	copy(r.First[:], buf[0:16])
+	copy(r.Last[:], buf[16:32])
+	r.ASN = binary.BigEndian.Uint32(buf[32:])
+	r.Info = binary.BigEndian.Uint32(buf[36:])
+	return r
+}
+
+func (s *Store) readInfo(idx uint32) (*info, error) {
+	if int64(idx) >= s.numStrings {
+		return nil, errors.New("the ASN database file is corrupt")
+	}
+
+	ob := make([]byte, 8)
+	if _, err := s.file.ReadAt(ob, s.stringsOff+int64(idx)*8); err != nil {
+		return nil, err
+	}
+
+	r := bufio.NewReader(io.NewSectionReader(s.file,
+		s.stringsOff+int64(binary.BigEndian.Uint64(ob)), 2*(0xffff+2)))
+	cc, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	desc, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	return &info{CC: cc, Description: desc}, nil
+}
+
+func readString(r io.Reader) (string, error) {
+	var l uint16
+
+	if err := binary.Read(r, binary.BigEndian, &l); err != nil {
+		return "", err
+	}
+
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func (s *Store) toRange(r *record) (*resources.IP2ASN, error) {
+	in, err := s.readInfo(r.Info)
+	if err != nil {
+		return nil, err
+	}
+	return newRange(r, in), nil
+}
+
+func newRange(r *record, in *info) *resources.IP2ASN {
+	return &resources.IP2ASN{
+		FirstIP:     toIP(r.First),
+		LastIP:      toIP(r.Last),
+		ASN:         int(r.ASN),
+		CC:          in.CC,
+		Description: in.Description,
+	}
+}
+
+func toIP(b [16]byte) net.IP {
+	ip := net.IP(append([]byte(nil), b[:]...))
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+// EmbeddedSource returns the source recorded for databases built from the data shipped with this release.
+func EmbeddedSource() string {
+	return "embedded " + format.Version
+}
+
+// OpenOrBuild returns the Store for the database file at path. When the file is missing, unreadable
+// or was built from the data shipped with a different release, it is first rebuilt from the embedded data.
+func OpenOrBuild(path string) (*Store, error) {
+	if s, err := Open(path); err == nil {
+		if src := s.Source(); !strings.HasPrefix(src, "embedded") || src == EmbeddedSource() {
+			return s, nil
+		}
+		s.Close()
+	}
+
+	ranges, err := resources.GetIP2ASNData()
+	if err != nil {
+		return nil, err
+	}
+	if err := Build(path, EmbeddedSource(), ranges); err != nil {
+		return nil, err
+	}
+	return Open(path)
+}
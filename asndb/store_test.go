@@ -0,0 +1,185 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package asndb
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/owasp-amass/amass/v4/resources"
+	"github.com/stretchr/testify/require"
+)
+
+func testRange(first, last string, asn int, cc, desc string) *resources.IP2ASN {
+	return &resources.IP2ASN{
+		FirstIP:     net.ParseIP(first),
+		LastIP:      net.ParseIP(last),
+		ASN:         asn,
+		CC:          cc,
+		Description: desc,
+	}
+}
+
+func testStore(t *testing.T, ranges []*resources.IP2ASN) *Store {
+	path := filepath.Join(t.TempDir(), Filename)
+
+	require.NoError(t, Build(path, "test", ranges))
+	s, err := Open(path)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestLookup(t *testing.T) {
+	s := testStore(t, []*resources.IP2ASN{
+		testRange("72.237.4.0", "72.237.4.255", 26808, "US", "UTICA-COLLEGE"),
+		testRange("104.16.0.0", "104.23.255.255", 13335, "US", "CLOUDFLARENET"),
+		testRange("2606:4700::", "2606:4700:ffff:ffff:ffff:ffff:ffff:ffff", 13335, "US", "CLOUDFLARENET"),
+	})
+	require.Equal(t, "test", s.Source())
+	require.Equal(t, 3, s.Len())
+
+	tests := []struct {
+		addr string
+		asn  int
+	}{
+		{"72.237.4.0", 26808},
+		{"72.237.4.113", 26808},
+		{"72.237.4.255", 26808},
+		{"72.237.5.0", 0},
+		{"104.20.1.1", 13335},
+		{"2606:4700::6810:84e5", 13335},
+		{"1.1.1.1", 0},
+		{"::1", 0},
+	}
+
+	for _, test := range tests {
+		r, err := s.Lookup(net.ParseIP(test.addr))
+		require.NoError(t, err)
+		if test.asn == 0 {
+			require.Nil(t, r, test.addr)
+			continue
+		}
+		require.Len(t, r, 1, test.addr)
+		require.Equal(t, test.asn, r[0].ASN, test.addr)
+		require.Equal(t, "US", r[0].CC, test.addr)
+	}
+}
+
+func TestMostSpecificRangeWins(t *testing.T) {
+	s := testStore(t, []*resources.IP2ASN{
+		testRange("10.0.0.0", "10.255.255.255", 1, "US", "OUTER"),
+		testRange("10.1.0.0", "10.1.255.255", 2, "CA", "INNER"),
+		testRange("10.1.2.0", "10.1.2.255", 3, "MX", "INNERMOST"),
+	})
+	require.Equal(t, 5, s.Len())
+
+	for addr, asn := range map[string]int{
+		"10.0.0.1":       1,
+		"10.1.0.1":       2,
+		"10.1.2.1":       3,
+		"10.1.3.1":       2,
+		"10.2.0.1":       1,
+		"10.255.255.255": 1,
+	} {
+		r, err := s.Lookup(net.ParseIP(addr))
+		require.NoError(t, err)
+		require.Len(t, r, 1, addr)
+		require.Equal(t, asn, r[0].ASN, addr)
+	}
+}
+
+func TestMultipleOrigins(t *testing.T) {
+	s := testStore(t, []*resources.IP2ASN{
+		testRange("1.0.0.0", "1.0.255.255", 100, "US", "OUTER"),
+		testRange("1.0.64.0", "1.0.127.255", 18144, "JP", "ENERGIA-COMMUNICATIONS"),
+		testRange("1.0.64.0", "1.0.127.255", 2519, "JP", "VECTANT"),
+		testRange("1.0.64.0", "1.0.127.255", 18144, "JP", "ENERGIA-COMMUNICATIONS"),
+	})
+
+	r, err := s.Lookup(net.ParseIP("1.0.100.1"))
+	require.NoError(t, err)
+	require.Len(t, r, 2)
+	require.Equal(t, 18144, r[0].ASN)
+	require.Equal(t, 2519, r[1].ASN)
+
+	r, err = s.Lookup(net.ParseIP("1.0.128.1"))
+	require.NoError(t, err)
+	require.Len(t, r, 1)
+	require.Equal(t, 100, r[0].ASN)
+
+	req := s.AddrSearch("1.0.100.1")
+	require.NotNil(t, req)
+	require.Equal(t, "1.0.64.0/18", req.Prefix)
+	require.Len(t, req.Alternates, 1)
+	require.Equal(t, 2519, req.Alternates[0].ASN)
+	require.Equal(t, "VECTANT", req.Alternates[0].Description)
+}
+
+func TestSearches(t *testing.T) {
+	s := testStore(t, []*resources.IP2ASN{
+		testRange("72.237.4.0", "72.237.4.255", 26808, "US", "UTICA-COLLEGE"),
+		testRange("8.24.68.0", "8.24.69.255", 26808, "US", "UTICA-COLLEGE"),
+		testRange("104.16.0.0", "104.23.255.255", 13335, "US", "CLOUDFLARENET"),
+	})
+
+	req := s.AddrSearch("72.237.4.113")
+	require.NotNil(t, req)
+	require.Equal(t, 26808, req.ASN)
+	require.Equal(t, "72.237.4.0/24", req.Prefix)
+	require.Equal(t, "UTICA-COLLEGE", req.Description)
+	require.Empty(t, req.Alternates)
+	require.Nil(t, s.AddrSearch("1.1.1.1"))
+
+	unaligned := testStore(t, []*resources.IP2ASN{
+		testRange("1.0.0.0", "1.0.2.255", 13335, "US", "CLOUDFLARENET"),
+	})
+	req = unaligned.AddrSearch("1.0.2.1")
+	require.NotNil(t, req)
+	require.Equal(t, "1.0.2.0/24", req.Prefix)
+	require.Equal(t, []string{"1.0.0.0/23", "1.0.2.0/24"}, req.Netblocks)
+
+	req = s.ASNSearch(26808)
+	require.NotNil(t, req)
+	require.Equal(t, []string{"72.237.4.0/24", "8.24.68.0/23"}, req.Netblocks)
+	require.Nil(t, s.ASNSearch(65000))
+
+	reqs := s.DescriptionSearch("CLOUDFLARE")
+	require.Len(t, reqs, 1)
+	require.Equal(t, 13335, reqs[0].ASN)
+	require.Equal(t, "104.16.0.0/13", reqs[0].Prefix)
+}
+
+func TestAnnotate(t *testing.T) {
+	s := testStore(t, []*resources.IP2ASN{
+		testRange("104.16.0.0", "104.23.255.255", 13335, "US", "CLOUDFLARENET"),
+	})
+
+	ranges := []*resources.IP2ASN{
+		testRange("1.1.1.0", "1.1.1.255", 13335, "", ""),
+		testRange("8.8.8.0", "8.8.8.255", 15169, "", ""),
+	}
+	require.NoError(t, s.Annotate(ranges))
+	require.Equal(t, "CLOUDFLARENET", ranges[0].Description)
+	require.Equal(t, "US", ranges[0].CC)
+	require.Equal(t, "", ranges[1].Description)
+}
+
+func TestOpenInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), Filename)
+
+	_, err := Open(path)
+	require.Error(t, err)
+
+	require.NoError(t, Build(path, "test", nil))
+	s, err := Open(path)
+	require.NoError(t, err)
+	require.Equal(t, 0, s.Len())
+	r, err := s.Lookup(net.ParseIP("1.1.1.1"))
+	require.NoError(t, err)
+	require.Empty(t, r)
+	require.NoError(t, s.Close())
+}
@@ -0,0 +1,165 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/owasp-amass/amass/v4/asndb"
+	"github.com/owasp-amass/amass/v4/format"
+	"github.com/owasp-amass/amass/v4/resources"
+	"github.com/owasp-amass/config/config"
+)
+
+const (
+	asndbUsageMsg = "asndb [options] [-import FILE [-format ip2asn|pfx2as|mrt]] [-reset]"
+)
+
+type asndbArgs struct {
+	Format    string
+	Imports   format.ParseStrings
+	Merge     bool
+	Reset     bool
+	Filepaths struct {
+		ConfigFile string
+		Directory  string
+	}
+}
+
+func runASNDBCommand(clArgs []string) {
+	var args asndbArgs
+	var help1, help2 bool
+	asndbCommand := flag.NewFlagSet("asndb", flag.ContinueOnError)
+
+	asndbBuf := new(bytes.Buffer)
+	asndbCommand.SetOutput(asndbBuf)
+
+	asndbCommand.BoolVar(&help1, "h", false, "Show the program usage message")
+	asndbCommand.BoolVar(&help2, "help", false, "Show the program usage message")
+	asndbCommand.StringVar(&args.Format, "format", "", "Format of the imported files: ip2asn, pfx2as or mrt (default: detected)")
+	asndbCommand.Var(&args.Imports, "import", "Path to an ip2asn, RouteViews pfx2as or MRT RIB file (can be used multiple times)")
+	asndbCommand.BoolVar(&args.Merge, "merge", false, "Merge the imported files with the current database contents")
+	asndbCommand.BoolVar(&args.Reset, "reset", false, "Rebuild the database from the data shipped with this release")
+	asndbCommand.StringVar(&args.Filepaths.ConfigFile, "config", "", "Path to the YAML configuration file. Additional details below")
+	asndbCommand.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the output files")
+
+	if err := asndbCommand.Parse(clArgs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if help1 || help2 {
+		commandUsage(asndbUsageMsg, asndbCommand, asndbBuf)
+		return
+	}
+	if args.Reset && len(args.Imports) > 0 {
+		r.Fprintln(color.Error, "The reset and import options cannot be used together")
+		os.Exit(1)
+	}
+
+	cfg := config.NewConfig()
+	// Check if a configuration file was provided, and if so, load the settings
+	if err := acquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, cfg); err != nil && args.Filepaths.ConfigFile != "" {
+		r.Fprintf(color.Error, "Failed to load the configuration file: %v\n", err)
+		os.Exit(1)
+	}
+	if args.Filepaths.Directory != "" {
+		cfg.Dir = args.Filepaths.Directory
+	}
+
+	createOutputDirectory(cfg)
+	path := filepath.Join(config.OutputDirectory(cfg.Dir), asndb.Filename)
+
+	if args.Reset {
+		ranges, err := resources.GetIP2ASNData()
+		if err == nil {
+			err = asndb.Build(path, asndb.EmbeddedSource(), ranges)
+		}
+		if err != nil {
+			r.Fprintf(color.Error, "Failed to rebuild the ASN database: %v\n", err)
+			os.Exit(1)
+		}
+	} else if len(args.Imports) > 0 {
+		if err := importASNData(path, &args); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	store, err := asndb.OpenOrBuild(path)
+	if err != nil {
+		r.Fprintf(color.Error, "Failed to open the ASN database: %v\n", err)
+		os.Exit(1)
+	}
+	defer store.Close()
+
+	g.Fprintf(color.Output, "%-10s %s\n", "Database:", path)
+	g.Fprintf(color.Output, "%-10s %s\n", "Source:", store.Source())
+	g.Fprintf(color.Output, "%-10s %s\n", "Built:", store.Created().Format("2006-01-02 15:04:05 MST"))
+	g.Fprintf(color.Output, "%-10s %d\n", "Ranges:", store.Len())
+}
+
+func importASNData(path string, args *asndbArgs) error {
+	var ranges []*resources.IP2ASN
+
+	for _, name := range args.Imports {
+		f, err := os.Open(name)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %v", name, err)
+		}
+
+		rs, err := asndb.ReadRanges(f, args.Format)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %v", name, err)
+		}
+		if len(rs) == 0 {
+			return fmt.Errorf("no address ranges were found in %s", name)
+		}
+		ranges = append(ranges, rs...)
+	}
+	// The current database provides missing country codes and descriptions
+	if store, err := asndb.OpenOrBuild(path); err == nil {
+		err = store.Annotate(ranges)
+		if err == nil && args.Merge {
+			ranges, err = mergeASNData(store, ranges)
+		}
+		store.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read the current ASN database: %v", err)
+		}
+	}
+
+	var names []string
+	for _, name := range args.Imports {
+		names = append(names, filepath.Base(name))
+	}
+	if err := asndb.Build(path, "import "+strings.Join(names, ","), ranges); err != nil {
+		return fmt.Errorf("failed to build the ASN database: %v", err)
+	}
+	return nil
+}
+
+// mergeASNData adds the current database ranges that the imported ranges do not replace.
+func mergeASNData(store *asndb.Store, ranges []*resources.IP2ASN) ([]*resources.IP2ASN, error) {
+	imported := make(map[string]struct{}, len(ranges))
+	for _, r := range ranges {
+		imported[r.FirstIP.String()+"-"+r.LastIP.String()] = struct{}{}
+	}
+
+	merged := ranges
+	err := store.Ranges(func(r *resources.IP2ASN) bool {
+		if _, found := imported[r.FirstIP.String()+"-"+r.LastIP.String()]; !found {
+			merged = append(merged, r)
+		}
+		return true
+	})
+	return merged, err
+}
@@ -0,0 +1,136 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/caffix/stringset"
+	"github.com/fatih/color"
+	"github.com/owasp-amass/amass/v4/importer"
+	"github.com/owasp-amass/amass/v4/systems"
+	"github.com/owasp-amass/config/config"
+)
+
+const (
+	dbUsageMsg = "db import [options] FILE..."
+)
+
+type dbImportArgs struct {
+	Domains   *stringset.Set
+	Format    string
+	Filepaths struct {
+		ConfigFile string
+		Directory  string
+	}
+}
+
+func runDBCommand(clArgs []string) {
+	var help1, help2 bool
+	dbCommand := flag.NewFlagSet("db", flag.ContinueOnError)
+
+	dbBuf := new(bytes.Buffer)
+	dbCommand.SetOutput(dbBuf)
+
+	dbCommand.BoolVar(&help1, "h", false, "Show the program usage message")
+	dbCommand.BoolVar(&help2, "help", false, "Show the program usage message")
+
+	if err := dbCommand.Parse(clArgs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if help1 || help2 || dbCommand.NArg() == 0 {
+		commandUsage(dbUsageMsg, dbCommand, dbBuf)
+		return
+	}
+
+	switch dbCommand.Arg(0) {
+	case "import":
+		runDBImportCommand(dbCommand.Args()[1:])
+	default:
+		commandUsage(dbUsageMsg, dbCommand, dbBuf)
+		os.Exit(1)
+	}
+}
+
+func runDBImportCommand(clArgs []string) {
+	args := dbImportArgs{Domains: stringset.New()}
+	defer args.Domains.Close()
+
+	var help1, help2 bool
+	importCommand := flag.NewFlagSet("import", flag.ContinueOnError)
+
+	importBuf := new(bytes.Buffer)
+	importCommand.SetOutput(importBuf)
+
+	importCommand.BoolVar(&help1, "h", false, "Show the program usage message")
+	importCommand.BoolVar(&help2, "help", false, "Show the program usage message")
+	importCommand.Var(args.Domains, "d", "Domain names separated by commas limiting the imported names (can be used multiple times)")
+	importCommand.StringVar(&args.Format, "format", "", "Format of the imported files: "+strings.Join(importer.Formats, ", ")+" (default: detected)")
+	importCommand.StringVar(&args.Filepaths.ConfigFile, "config", "", "Path to the YAML configuration file. Additional details below")
+	importCommand.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the graph database")
+
+	if err := importCommand.Parse(clArgs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if help1 || help2 || importCommand.NArg() == 0 {
+		commandUsage(dbUsageMsg, importCommand, importBuf)
+		return
+	}
+
+	cfg := config.NewConfig()
+	// Check if a configuration file was provided, and if so, load the settings
+	if err := acquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, cfg); err != nil && args.Filepaths.ConfigFile != "" {
+		r.Fprintf(color.Error, "Failed to load the configuration file: %v\n", err)
+		os.Exit(1)
+	}
+	if args.Filepaths.Directory != "" {
+		cfg.Dir = args.Filepaths.Directory
+	}
+	cfg.AddDomains(args.Domains.Slice()...)
+
+	createOutputDirectory(cfg)
+	graph, err := systems.PrimaryGraph(cfg)
+	if err != nil {
+		r.Fprintf(color.Error, "Failed to open the graph database: %v\n", err)
+		os.Exit(1)
+	}
+	sources, err := systems.PrimaryProvenance(cfg)
+	if err != nil {
+		r.Fprintf(color.Error, "Failed to open the graph database: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = sources.Close() }()
+
+	var failed bool
+	for _, path := range importCommand.Args() {
+		res, err := importer.ReadFile(path, args.Format)
+		if err != nil {
+			r.Fprintf(color.Error, "Failed to import %s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		// Without domain names, all the findings in the file are imported
+		if len(cfg.Domains()) > 0 {
+			res = res.Scope(cfg)
+		}
+
+		if err := res.Store(context.Background(), graph, sources); err != nil {
+			r.Fprintf(color.Error, "%s: %v\n", path, err)
+			failed = true
+			continue
+		}
+		g.Fprintf(color.Output, "%s: imported %d names and %d addresses (%s)\n", path, len(res.Names), len(res.Addrs), res.Format)
+	}
+	if failed {
+		_ = sources.Close()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/owasp-amass/amass/v4/datasrcs"
+	"github.com/owasp-amass/amass/v4/engine"
+	"github.com/owasp-amass/amass/v4/format"
+	"github.com/owasp-amass/config/config"
+)
+
+const (
+	engineUsageMsg = "engine [options]"
+	// the environment variable providing the API token when the flag is not used
+	engineTokenEnv = "AMASS_ENGINE_TOKEN"
+)
+
+type engineArgs struct {
+	Addr        string
+	MaxJobs     int
+	MaxDoneJobs int
+	Token       string
+	Options     struct {
+		NoColor bool
+		Silent  bool
+	}
+	Filepaths struct {
+		ConfigFile string
+		Directory  string
+		LogFile    string
+		ZoneFiles  format.ParseStrings
+	}
+}
+
+func runEngineCommand(clArgs []string) {
+	var args engineArgs
+	var help1, help2 bool
+	engineCommand := flag.NewFlagSet("engine", flag.ContinueOnError)
+
+	engineBuf := new(bytes.Buffer)
+	engineCommand.SetOutput(engineBuf)
+
+	engineCommand.BoolVar(&help1, "h", false, "Show the program usage message")
+	engineCommand.BoolVar(&help2, "help", false, "Show the program usage message")
+	engineCommand.StringVar(&args.Addr, "addr", "127.0.0.1:4000", "Address and port the REST API listens on")
+	engineCommand.IntVar(&args.MaxJobs, "max-jobs", engine.DefaultMaxJobs, "Number of enumerations performed at the same time")
+	engineCommand.IntVar(&args.MaxDoneJobs, "keep-jobs", engine.DefaultMaxDoneJobs, "Number of completed jobs kept, while the oldest are forgotten")
+	engineCommand.StringVar(&args.Token, "token", "", "Bearer token required by the REST API (default: "+engineTokenEnv+")")
+	engineCommand.BoolVar(&args.Options.NoColor, "nocolor", false, "Disable colorized output")
+	engineCommand.BoolVar(&args.Options.Silent, "silent", false, "Disable all output during execution")
+	engineCommand.StringVar(&args.Filepaths.ConfigFile, "config", "", "Path to the YAML configuration file. Additional details below")
+	engineCommand.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the output files")
+	engineCommand.StringVar(&args.Filepaths.LogFile, "log", "", "Path to the log file where the errors are appended")
+	engineCommand.Var(&args.Filepaths.ZoneFiles, "zonefile", "Path to a zone file answering all DNS queries in place of the resolvers (can be used multiple times)")
+
+	if err := engineCommand.Parse(clArgs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if help1 || help2 {
+		commandUsage(engineUsageMsg, engineCommand, engineBuf)
+		return
+	}
+	if args.Options.NoColor {
+		color.NoColor = true
+	}
+	if args.Options.Silent {
+		color.Output = io.Discard
+		color.Error = io.Discard
+	}
+	if args.Token == "" {
+		args.Token = os.Getenv(engineTokenEnv)
+	}
+	// Anyone on the network could run enumerations through an engine without a token
+	if args.Token == "" && !loopbackAddr(args.Addr) {
+		r.Fprintf(color.Error, "A token is required when the engine listens beyond the local host (see -token or %s)\n", engineTokenEnv)
+		os.Exit(1)
+	}
+
+	cfg := config.NewConfig()
+	// Check if a configuration file was provided, and if so, load the settings
+	if err := acquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, cfg); err != nil && args.Filepaths.ConfigFile != "" {
+		r.Fprintf(color.Error, "Failed to load the configuration file: %v\n", err)
+		os.Exit(1)
+	}
+	if args.Filepaths.Directory != "" {
+		cfg.Dir = args.Filepaths.Directory
+	}
+	createOutputDirectory(cfg)
+
+	logfile := filepath.Join(config.OutputDirectory(cfg.Dir), "amass_engine.log")
+	if args.Filepaths.LogFile != "" {
+		logfile = args.Filepaths.LogFile
+	}
+	// The engine runs for a long time, so the messages are appended to the previous ones
+	f, err := os.OpenFile(logfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		r.Fprintf(color.Error, "Failed to open the log file: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = f.Close() }()
+	cfg.Log = log.New(f, "", log.Lmicroseconds)
+
+	ln, err := net.Listen("tcp", args.Addr)
+	if err != nil {
+		r.Fprintf(color.Error, "Failed to listen on %s: %v\n", args.Addr, err)
+		os.Exit(1)
+	}
+
+	start := time.Now()
+	// The System is kept for the lifetime of the engine, so each job finds the resolvers
+	// and the ASN database ready
+	sys, err := newEnumSystem(cfg, nil, args.Filepaths.ZoneFiles, nil, "")
+	if err != nil {
+		_ = ln.Close()
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = sys.Shutdown() }()
+
+	eng := engine.New(sys, engine.Settings{
+		MaxJobs:     args.MaxJobs,
+		MaxDoneJobs: args.MaxDoneJobs,
+		Sources:     datasrcs.GetAllSources,
+		Token:       args.Token,
+	})
+	srv := &http.Server{
+		Handler:           eng.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ErrorLog:          cfg.Log,
+	}
+
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	g.Fprintf(color.Error, "The engine was ready in %s and is listening on http://%s%s\n",
+		time.Since(start).Round(time.Millisecond), ln.Addr().String(), engine.APIPrefix)
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	var failed bool
+	select {
+	case <-quit:
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			r.Fprintf(color.Error, "The REST API failed: %v\n", err)
+			failed = true
+		}
+	}
+
+	fmt.Fprintf(color.Error, "%s\n", green("The engine is shutting down"))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(ctx)
+	eng.Shutdown()
+
+	if failed {
+		_ = sys.Shutdown()
+		os.Exit(1)
+	}
+}
@@ -17,19 +17,28 @@ import (
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
 
 	"github.com/caffix/netmap"
+	"github.com/caffix/service"
 	"github.com/caffix/stringset"
 	"github.com/fatih/color"
 	"github.com/owasp-amass/amass/v4/datasrcs"
 	"github.com/owasp-amass/amass/v4/enum"
 	"github.com/owasp-amass/amass/v4/format"
+	"github.com/owasp-amass/amass/v4/importer"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/notify"
+	"github.com/owasp-amass/amass/v4/provenance"
+	"github.com/owasp-amass/amass/v4/resolvers"
 	"github.com/owasp-amass/amass/v4/resources"
 	"github.com/owasp-amass/amass/v4/systems"
+	"github.com/owasp-amass/amass/v4/worker"
+	"github.com/owasp-amass/amass/v4/zonefile"
 	"github.com/owasp-amass/config/config"
 )
 
@@ -46,30 +55,40 @@ type enumArgs struct {
 	Blacklist         *stringset.Set
 	Domains           *stringset.Set
 	Excluded          *stringset.Set
+	HTTPSources       *stringset.Set
+	ImportFormat      string
 	Included          *stringset.Set
 	Interface         string
-	MaxDNSQueries     int
-	ResolverQPS       int
-	TrustedQPS        int
-	MaxDepth          int
-	MinForRecursive   int
-	Names             *stringset.Set
-	Ports             format.ParseInts
-	Resolvers         *stringset.Set
-	Trusted           *stringset.Set
-	Timeout           int
-	Options           struct {
-		Active       bool
-		Alterations  bool
-		BruteForcing bool
-		DemoMode     bool
-		ListSources  bool
-		NoAlts       bool
-		NoColor      bool
-		NoRecursive  bool
-		Passive      bool
-		Silent       bool
-		Verbose      bool
+	Proxy             string
+	// Dialer makes the connections of the enumeration using the interface and proxy options
+	Dialer          *amassnet.Dialer
+	MaxDNSQueries   int
+	ResolverQPS     int
+	TrustedQPS      int
+	AuthQPS         int
+	MaxDepth        int
+	MinForRecursive int
+	Names           *stringset.Set
+	Ports           format.ParseInts
+	Resolvers       *stringset.Set
+	Trusted         *stringset.Set
+	Timeout         int
+	Workers         format.ParseStrings
+	WorkerToken     string
+	Options         struct {
+		Active        bool
+		Alterations   bool
+		Authoritative bool
+		BruteForcing  bool
+		DemoMode      bool
+		ListSources   bool
+		NoAlts        bool
+		NoColor       bool
+		NoRecursive   bool
+		Passive       bool
+		Silent        bool
+		Sources       bool
+		Verbose       bool
 	}
 	Filepaths struct {
 		AllFilePrefix    string
@@ -80,6 +99,10 @@ type enumArgs struct {
 		Directory        string
 		Domains          format.ParseStrings
 		ExcludedSrcs     string
+		Expected         string
+		HTTPRecord       string
+		HTTPReplay       string
+		Imports          format.ParseStrings
 		IncludedSrcs     string
 		JSONOutput       string
 		LogFile          string
@@ -88,6 +111,7 @@ type enumArgs struct {
 		Trusted          format.ParseStrings
 		ScriptsDirectory string
 		TermOut          string
+		ZoneFiles        format.ParseStrings
 	}
 }
 
@@ -95,13 +119,17 @@ func defineEnumArgumentFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.Var(&args.Addresses, "addr", "IPs and ranges (192.168.1.1-254) separated by commas")
 	enumFlags.Var(args.AltWordListMask, "awm", "\"hashcat-style\" wordlist masks for name alterations")
 	enumFlags.Var(&args.ASNs, "asn", "ASNs separated by commas (can be used multiple times)")
+	enumFlags.IntVar(&args.AuthQPS, "aqps", 0, "Maximum number of DNS queries per second for each authoritative name server")
 	enumFlags.Var(&args.CIDRs, "cidr", "CIDRs separated by commas (can be used multiple times)")
 	enumFlags.Var(args.Blacklist, "bl", "Blacklist of subdomain names that will not be investigated")
 	enumFlags.Var(args.BruteWordListMask, "wm", "\"hashcat-style\" wordlist masks for DNS brute forcing")
 	enumFlags.Var(args.Domains, "d", "Domain names separated by commas (can be used multiple times)")
 	enumFlags.Var(args.Excluded, "exclude", "Data source names separated by commas to be excluded")
+	enumFlags.Var(args.HTTPSources, "http-src", "Data source names separated by commas limiting the HTTP recording or replay")
+	enumFlags.StringVar(&args.ImportFormat, "import-format", "", "Format of the imported files: "+strings.Join(importer.Formats, ", ")+" (default: detected)")
 	enumFlags.Var(args.Included, "include", "Data source names separated by commas to be included")
 	enumFlags.StringVar(&args.Interface, "iface", "", "Provide the network interface to send traffic through")
+	enumFlags.StringVar(&args.Proxy, "proxy", "", "SOCKS5 proxy URL that all traffic, including DNS over TCP, is routed through")
 	enumFlags.IntVar(&args.MaxDNSQueries, "max-dns-queries", 0, "Deprecated flag to be replaced by dns-qps in version 4.0")
 	enumFlags.IntVar(&args.MaxDNSQueries, "dns-qps", 0, "Maximum number of DNS queries per second across all resolvers")
 	enumFlags.IntVar(&args.ResolverQPS, "rqps", 0, "Maximum number of DNS queries per second for each untrusted resolver")
@@ -109,13 +137,16 @@ func defineEnumArgumentFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.IntVar(&args.MaxDepth, "max-depth", 0, "Maximum number of subdomain labels for brute forcing")
 	enumFlags.IntVar(&args.MinForRecursive, "min-for-recursive", 1, "Subdomain labels seen before recursive brute forcing (Default: 1)")
 	enumFlags.Var(&args.Ports, "p", "Ports separated by commas (default: 80, 443)")
-	enumFlags.Var(args.Resolvers, "r", "IP addresses of untrusted DNS resolvers (can be used multiple times)")
-	enumFlags.Var(args.Resolvers, "tr", "IP addresses of trusted DNS resolvers (can be used multiple times)")
+	enumFlags.Var(args.Resolvers, "r", "Addresses or DoH / DoT URIs of untrusted DNS resolvers (can be used multiple times)")
+	enumFlags.Var(args.Trusted, "tr", "Addresses or DoH / DoT URIs of trusted DNS resolvers (can be used multiple times)")
 	enumFlags.IntVar(&args.Timeout, "timeout", 0, "Number of minutes to let enumeration run before quitting")
+	enumFlags.Var(&args.Workers, "worker", "Address of an amass worker resolving the untrusted DNS queries (can be used multiple times)")
+	enumFlags.StringVar(&args.WorkerToken, "worker-token", "", "Token shared with the amass workers (default: "+workerTokenEnv+")")
 }
 
 func defineEnumOptionFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.BoolVar(&args.Options.Active, "active", false, "Attempt zone transfers and certificate name grabs")
+	enumFlags.BoolVar(&args.Options.Authoritative, "auth", false, "Send queries for in-scope zones directly to the authoritative name servers")
 	enumFlags.BoolVar(&args.Options.BruteForcing, "brute", false, "Execute brute forcing after searches")
 	enumFlags.BoolVar(&args.Options.DemoMode, "demo", false, "Censor output to make it suitable for demonstrations")
 	enumFlags.BoolVar(&args.Options.ListSources, "list", false, "Print the names of all available data sources")
@@ -124,6 +155,7 @@ func defineEnumOptionFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.BoolVar(&args.Options.NoRecursive, "norecursive", false, "Turn off recursive brute forcing")
 	enumFlags.BoolVar(&args.Options.Passive, "passive", false, "Deprecated since passive is the default setting")
 	enumFlags.BoolVar(&args.Options.Silent, "silent", false, "Disable all output during execution")
+	enumFlags.BoolVar(&args.Options.Sources, "src", false, "Print the data sources that discovered each name and address")
 	enumFlags.BoolVar(&args.Options.Verbose, "v", false, "Output status / debug / troubleshooting info")
 }
 
@@ -136,6 +168,10 @@ func defineEnumFilepathFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the output files")
 	enumFlags.Var(&args.Filepaths.Domains, "df", "Path to a file providing root domain names")
 	enumFlags.StringVar(&args.Filepaths.ExcludedSrcs, "ef", "", "Path to a file providing data sources to exclude")
+	enumFlags.StringVar(&args.Filepaths.Expected, "expect", "", "Path to a file providing the exact set of names the enumeration must discover")
+	enumFlags.StringVar(&args.Filepaths.HTTPRecord, "http-record", "", "Path to the directory where the HTTP exchanges will be recorded")
+	enumFlags.StringVar(&args.Filepaths.HTTPReplay, "http-replay", "", "Path to a directory of recorded HTTP exchanges replayed without network access")
+	enumFlags.Var(&args.Filepaths.Imports, "import", "Path to the output of another tool providing names and records to validate (can be used multiple times)")
 	enumFlags.StringVar(&args.Filepaths.IncludedSrcs, "if", "", "Path to a file providing data sources to include")
 	enumFlags.StringVar(&args.Filepaths.LogFile, "log", "", "Path to the log file where errors will be written")
 	enumFlags.Var(&args.Filepaths.Names, "nf", "Path to a file providing already known subdomain names (from other tools/sources)")
@@ -143,6 +179,7 @@ func defineEnumFilepathFlags(enumFlags *flag.FlagSet, args *enumArgs) {
 	enumFlags.Var(&args.Filepaths.Trusted, "trf", "Path to a file providing trusted DNS resolvers")
 	enumFlags.StringVar(&args.Filepaths.ScriptsDirectory, "scripts", "", "Path to a directory containing ADS scripts")
 	enumFlags.StringVar(&args.Filepaths.TermOut, "o", "", "Path to the text file containing terminal stdout/stderr")
+	enumFlags.Var(&args.Filepaths.ZoneFiles, "zonefile", "Path to a zone file answering all DNS queries in place of the resolvers (can be used multiple times)")
 }
 
 func runEnumCommand(clArgs []string) {
@@ -152,6 +189,18 @@ func runEnumCommand(clArgs []string) {
 		return
 	}
 	createOutputDirectory(cfg)
+	summary := format.NewSummary(time.Now())
+
+	var redact *format.Redactor
+	if args.Options.DemoMode {
+		var err error
+
+		if redact, err = demoRedactor(cfg); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+		summary.SetRedactor(redact)
+	}
 
 	rLog, wLog := io.Pipe()
 	dir := config.OutputDirectory(cfg.Dir)
@@ -162,16 +211,30 @@ func runEnumCommand(clArgs []string) {
 		logfile = args.Filepaths.LogFile
 	}
 	// Start handling the log messages
-	go writeLogsAndMessages(rLog, logfile, args.Options.Verbose)
+	go writeLogsAndMessages(rLog, logfile, args.Options.Verbose, redact)
 	// Create the System that will provide architecture to this enumeration
-	sys, err := systems.NewLocalSystem(cfg)
+	sys, err := newEnumSystem(cfg, args.Dialer, args.Filepaths.ZoneFiles, args.Workers, args.WorkerToken)
 	if err != nil {
 		r.Fprintf(color.Error, "%v\n", err)
 		os.Exit(1)
 	}
 	defer func() { _ = sys.Shutdown() }()
 
-	if err := sys.SetDataSources(datasrcs.GetAllSources(sys)); err != nil {
+	notifier, err := newNotifier(cfg, sys.Dialer())
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+
+	srcs := datasrcs.GetAllSources(sys)
+	if len(args.Filepaths.ZoneFiles) > 0 {
+		srcs = zoneFileSources(cfg, srcs)
+	}
+	if err := setupHTTPFixtures(cfg, sys, args.Filepaths.HTTPRecord, args.Filepaths.HTTPReplay, args.HTTPSources.Slice(), srcs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := sys.SetDataSources(srcs); err != nil {
 		r.Fprintf(color.Error, "%v\n", err)
 		os.Exit(1)
 	}
@@ -182,6 +245,23 @@ func runEnumCommand(clArgs []string) {
 		r.Fprintf(color.Error, "%s\n", "Failed to setup the enumeration")
 		os.Exit(1)
 	}
+	for _, path := range args.Filepaths.Imports {
+		res, err := importer.ReadFile(path, args.ImportFormat)
+		if err != nil {
+			r.Fprintf(color.Error, "Failed to import %s: %v\n", path, err)
+			os.Exit(1)
+		}
+
+		res = res.Scope(cfg)
+		e.ImportFindings(res.Names, res.Addrs)
+	}
+	if args.Options.Authoritative {
+		qps := args.AuthQPS
+		if qps <= 0 {
+			qps = resolvers.DefaultAuthQPS
+		}
+		e.UseAuthoritativeServers(qps)
+	}
 
 	var wg sync.WaitGroup
 	var outChans []chan string
@@ -211,8 +291,13 @@ func runEnumCommand(clArgs []string) {
 	}
 	defer cancel()
 
+	var sources *provenance.Store
+	if args.Options.Sources {
+		sources = sys.Provenance()
+	}
+
 	wg.Add(1)
-	go processOutput(ctx, sys.GraphDatabases()[0], e, outChans, done, &wg)
+	go processOutput(ctx, sys.GraphDatabases()[0], sources, redact, notifier, e, outChans, done, &wg)
 	// Monitor for cancellation by the user
 	go func(d chan struct{}, c context.Context, f context.CancelFunc) {
 		quit := make(chan os.Signal, 1)
@@ -227,14 +312,119 @@ func runEnumCommand(clArgs []string) {
 		}
 	}(done, ctx, cancel)
 	// Start the enumeration process
+	started := time.Now()
+	summary.AddPhase("Setup", summary.Start, started)
 	if err := e.Start(ctx); err != nil {
 		r.Println(err)
 		os.Exit(1)
 	}
+	finished := time.Now()
+	summary.AddPhase("Enumeration", started, finished)
+	for _, stage := range e.Stages() {
+		summary.AddPhase(stage.Name, stage.Start, stage.End)
+	}
 	// Let all the output goroutines know that the enumeration has finished
 	close(done)
 	wg.Wait()
+	// Send the findings remaining in the webhook batches
+	notifier.Close()
+	summary.AddPhase("Output", finished, time.Now())
 	fmt.Fprintf(color.Error, "\n%s\n", green("The enumeration has finished"))
+
+	summary.End = time.Now()
+	enumSummary(context.Background(), sys.GraphDatabases()[0], e, summary)
+	format.PrintSummary(summary)
+	if err := summary.Save(summaryPath(cfg, args)); err != nil {
+		r.Fprintf(color.Error, "Failed to save the enumeration summary: %v\n", err)
+	}
+	format.PrintResolverSummary(sys.Resolvers().Stats())
+	if ds, ok := sys.(*systems.DistributedSystem); ok {
+		format.PrintWorkerSummary(ds.Cluster().Stats())
+	}
+
+	if args.Filepaths.Expected != "" {
+		if err := checkExpectedNames(e, args.Filepaths.Expected); err != nil {
+			r.Fprintf(color.Error, "%s\n", redact.Text(err.Error()))
+			_ = sys.Shutdown()
+			os.Exit(1)
+		}
+		fmt.Fprintf(color.Error, "%s\n", green("The discovered names match the expected names"))
+	}
+}
+
+// newEnumSystem returns the System for the enumeration.
+// When zone files are provided, the DNS queries are answered from the zones instead of the resolvers,
+// and when workers are provided, the untrusted DNS queries are sent to the workers.
+func newEnumSystem(cfg *config.Config, dialer *amassnet.Dialer, zonefiles, workers []string, token string) (systems.System, error) {
+	if len(workers) > 0 {
+		cluster, err := worker.NewCluster(workers, token, dialer, cfg.Log)
+		if err != nil {
+			return nil, err
+		}
+
+		sys, err := systems.NewDistributedSystem(cfg, dialer, cluster)
+		if err != nil {
+			return nil, err
+		}
+		return sys, nil
+	}
+	if len(zonefiles) == 0 {
+		sys, err := systems.NewLocalSystem(cfg, dialer)
+		if err != nil {
+			return nil, err
+		}
+		return sys, nil
+	}
+
+	zones := zonefile.NewZones()
+	for _, f := range zonefiles {
+		if err := zones.LoadZoneFile("", f); err != nil {
+			return nil, err
+		}
+	}
+
+	sys, err := systems.NewIsolatedSystem(cfg, zones.Pool(), zones.Pool())
+	if err != nil {
+		return nil, err
+	}
+	return sys, nil
+}
+
+// checkExpectedNames returns an error listing the differences when the names discovered
+// by the enumeration are not exactly the names provided in the file.
+func checkExpectedNames(e *enum.Enumeration, path string) error {
+	list, err := config.GetListFromFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the expected names: %v", err)
+	}
+
+	expected := stringset.New(list...)
+	defer expected.Close()
+	found := stringset.New(e.DiscoveredNames()...)
+	defer found.Close()
+
+	missing := stringset.New(expected.Slice()...)
+	defer missing.Close()
+	missing.Subtract(found)
+
+	unexpected := stringset.New(found.Slice()...)
+	defer unexpected.Close()
+	unexpected.Subtract(expected)
+
+	if missing.Len() == 0 && unexpected.Len() == 0 {
+		return nil
+	}
+
+	var msgs []string
+	if m := missing.Slice(); len(m) > 0 {
+		sort.Strings(m)
+		msgs = append(msgs, "missing: "+strings.Join(m, ", "))
+	}
+	if u := unexpected.Slice(); len(u) > 0 {
+		sort.Strings(u)
+		msgs = append(msgs, "unexpected: "+strings.Join(u, ", "))
+	}
+	return fmt.Errorf("the discovered names do not match the expected names; %s", strings.Join(msgs, "; "))
 }
 
 func argsAndConfig(clArgs []string) (*config.Config, *enumArgs) {
@@ -246,10 +436,12 @@ func argsAndConfig(clArgs []string) (*config.Config, *enumArgs) {
 		Blacklist:         stringset.New(),
 		Domains:           stringset.New(),
 		Excluded:          stringset.New(),
+		HTTPSources:       stringset.New(),
 		Included:          stringset.New(),
 		Names:             stringset.New(),
 		Resolvers:         stringset.New(),
 		Trusted:           stringset.New(),
+		Dialer:            amassnet.NewDialer(),
 	}
 	var help1, help2 bool
 	enumCommand := flag.NewFlagSet("enum", flag.ContinueOnError)
@@ -282,7 +474,13 @@ func argsAndConfig(clArgs []string) (*config.Config, *enumArgs) {
 			fmt.Fprint(color.Output, format.InterfaceInfo())
 			os.Exit(1)
 		}
-		if err := assignNetInterface(iface); err != nil {
+		if err := assignNetInterface(args.Dialer, iface); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	if args.Proxy != "" {
+		if err := args.Dialer.SetProxy(args.Proxy); err != nil {
 			r.Fprintf(color.Error, "%v\n", err)
 			os.Exit(1)
 		}
@@ -313,7 +511,7 @@ func argsAndConfig(clArgs []string) (*config.Config, *enumArgs) {
 
 	cfg := config.NewConfig()
 	// Check if a configuration file was provided, and if so, load the settings
-	if err := config.AcquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, cfg); err == nil {
+	if err := acquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, cfg); err == nil {
 		// Check if a config file was provided that has DNS resolvers specified
 		if len(cfg.Resolvers) > 0 && args.Resolvers.Len() == 0 {
 			args.Resolvers = stringset.New(cfg.Resolvers...)
@@ -343,6 +541,21 @@ func argsAndConfig(clArgs []string) (*config.Config, *enumArgs) {
 		r.Fprintln(color.Error, "Configuration error: No root domain names were provided")
 		os.Exit(1)
 	}
+	if args.Options.Authoritative && len(args.Filepaths.ZoneFiles) > 0 {
+		r.Fprintln(color.Error, "The authoritative name servers cannot be used with zone files")
+		os.Exit(1)
+	}
+	if args.Proxy != "" && len(args.Filepaths.ZoneFiles) > 0 {
+		r.Fprintln(color.Error, "The proxy cannot be used with zone files")
+		os.Exit(1)
+	}
+	if len(args.Workers) > 0 && len(args.Filepaths.ZoneFiles) > 0 {
+		r.Fprintln(color.Error, "The workers cannot be used with zone files")
+		os.Exit(1)
+	}
+	if args.WorkerToken == "" {
+		args.WorkerToken = os.Getenv(workerTokenEnv)
+	}
 	return cfg, &args
 }
 
@@ -395,7 +608,7 @@ func saveTextOutput(e *enum.Enumeration, args *enumArgs, output chan string, wg
 	}
 }
 
-func processOutput(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, outputs []chan string, done chan struct{}, wg *sync.WaitGroup) {
+func processOutput(ctx context.Context, g *netmap.Graph, sources *provenance.Store, redact *format.Redactor, notifier *notify.Notifier, e *enum.Enumeration, outputs []chan string, done chan struct{}, wg *sync.WaitGroup) {
 	defer wg.Done()
 	defer func() {
 		// Signal all the other output goroutines to terminate
@@ -407,13 +620,19 @@ func processOutput(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, ou
 	// This filter ensures that we only get new names
 	known := stringset.New()
 	defer known.Close()
+	// This filter ensures that the webhooks are notified once about each name
+	notified := stringset.New()
+	defer notified.Close()
 	// The function that obtains output from the enum and puts it on the channel
-	extract := func(since time.Time) {
-		for _, o := range NewOutput(ctx, g, e, known, since) {
+	extract := func(since time.Time, final bool) {
+		for _, o := range NewOutput(ctx, g, e, known, since, sources, redact) {
 			for _, ch := range outputs {
 				ch <- o
 			}
 		}
+		if notifier != nil {
+			notifier.Notify(NewFindings(ctx, g, e, notified, since, final, redact)...)
+		}
 	}
 
 	t := time.NewTimer(10 * time.Second)
@@ -422,21 +641,21 @@ func processOutput(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, ou
 	for {
 		select {
 		case <-ctx.Done():
-			extract(last)
+			extract(last, true)
 			return
 		case <-done:
-			extract(last)
+			extract(last, true)
 			return
 		case <-t.C:
 			next := time.Now()
-			extract(last)
+			extract(last, false)
 			t.Reset(10 * time.Second)
 			last = next
 		}
 	}
 }
 
-func writeLogsAndMessages(logs *io.PipeReader, logfile string, verbose bool) {
+func writeLogsAndMessages(logs *io.PipeReader, logfile string, verbose bool, redact *format.Redactor) {
 	wildcard := regexp.MustCompile("DNS wildcard")
 	queries := regexp.MustCompile("Querying")
 
@@ -459,7 +678,7 @@ func writeLogsAndMessages(logs *io.PipeReader, logfile string, verbose bool) {
 
 	scanner := bufio.NewScanner(logs)
 	for scanner.Scan() {
-		line := scanner.Text()
+		line := redact.Text(scanner.Text())
 		if err := scanner.Err(); err != nil {
 			fmt.Fprintf(color.Error, "Error reading the Amass logs: %v\n", err)
 			break
@@ -561,14 +780,33 @@ func processEnumInputFiles(args *enumArgs) error {
 		for _, f := range args.Filepaths.Resolvers {
 			list, err := config.GetListFromFile(f)
 			if err != nil {
-				return fmt.Errorf("failed to parse the esolver file: %v", err)
+				return fmt.Errorf("failed to parse the resolver file: %v", err)
 			}
 			args.Resolvers.InsertMany(list...)
 		}
 	}
+	if len(args.Filepaths.Trusted) > 0 {
+		for _, f := range args.Filepaths.Trusted {
+			list, err := config.GetListFromFile(f)
+			if err != nil {
+				return fmt.Errorf("failed to parse the trusted resolver file: %v", err)
+			}
+			args.Trusted.InsertMany(list...)
+		}
+	}
 	return nil
 }
 
+// zoneFileSources returns the data sources of an enumeration answered from zone files. The data
+// sources send requests across the Internet, so only those included by name are run, which keeps
+// the results compared with -expect from depending on the network.
+func zoneFileSources(cfg *config.Config, srcs []service.Service) []service.Service {
+	if !cfg.SourceFilter.Include || len(cfg.SourceFilter.Sources) == 0 {
+		return nil
+	}
+	return datasrcs.SelectedDataSources(cfg, srcs)
+}
+
 // Setup the amass enumeration settings
 func (e enumArgs) OverrideConfig(conf *config.Config) error {
 	if len(e.Addresses) > 0 {
@@ -633,11 +871,21 @@ func (e enumArgs) OverrideConfig(conf *config.Config) error {
 		conf.SetResolvers(e.Resolvers.Slice()...)
 	}
 	if e.Trusted.Len() > 0 {
-		conf.SetTrustedResolvers(e.Trusted.Slice()...)
+		conf.TrustedResolvers = []string{}
+		conf.AddTrustedResolvers(e.Trusted.Slice()...)
 	}
 	if e.MaxDNSQueries > 0 {
 		conf.MaxDNSQueries = e.MaxDNSQueries
 	}
+
+	if e.Included.Len() > 0 {
+		conf.SourceFilter.Include = true
+		conf.SourceFilter.Sources = e.Included.Slice()
+	} else if e.Excluded.Len() > 0 {
+		conf.SourceFilter.Include = false
+		conf.SourceFilter.Sources = e.Excluded.Slice()
+	}
+
 	// Attempt to add the provided domains to the configuration
 	conf.AddDomains(e.Domains.Slice()...)
 	return nil
@@ -23,6 +23,16 @@ func runHelpCommand(clArgs []string) {
 		runEnumCommand(help)
 	case "intel":
 		runIntelCommand(help)
+	case "engine":
+		runEngineCommand(help)
+	case "worker":
+		runWorkerCommand(help)
+	case "asndb":
+		runASNDBCommand(help)
+	case "db":
+		runDBCommand(help)
+	case "script":
+		runScriptCommand(help)
 	default:
 		commandUsage(mainUsageMsg, helpCommand, helpBuf)
 		return
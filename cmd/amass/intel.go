@@ -23,6 +23,7 @@ import (
 	"github.com/owasp-amass/amass/v4/datasrcs"
 	"github.com/owasp-amass/amass/v4/format"
 	"github.com/owasp-amass/amass/v4/intel"
+	amassnet "github.com/owasp-amass/amass/v4/net"
 	"github.com/owasp-amass/amass/v4/systems"
 	"github.com/owasp-amass/config/config"
 )
@@ -38,12 +39,16 @@ type intelArgs struct {
 	OrganizationName string
 	Domains          *stringset.Set
 	Excluded         *stringset.Set
+	HTTPSources      *stringset.Set
 	Included         *stringset.Set
 	MaxDNSQueries    int
 	Ports            format.ParseInts
-	Resolvers        *stringset.Set
-	Timeout          int
-	Options          struct {
+	Proxy            string
+	// Dialer makes the connections of the collection using the proxy option
+	Dialer    *amassnet.Dialer
+	Resolvers *stringset.Set
+	Timeout   int
+	Options   struct {
 		Active       bool
 		DemoMode     bool
 		IPs          bool
@@ -58,6 +63,8 @@ type intelArgs struct {
 		Directory    string
 		Domains      format.ParseStrings
 		ExcludedSrcs string
+		HTTPRecord   string
+		HTTPReplay   string
 		IncludedSrcs string
 		LogFile      string
 		Resolvers    format.ParseStrings
@@ -72,10 +79,12 @@ func defineIntelArgumentFlags(intelFlags *flag.FlagSet, args *intelArgs) {
 	intelFlags.StringVar(&args.OrganizationName, "org", "", "Search string provided against AS description information")
 	intelFlags.Var(args.Domains, "d", "Domain names separated by commas (can be used multiple times)")
 	intelFlags.Var(args.Excluded, "exclude", "Data source names separated by commas to be excluded")
+	intelFlags.Var(args.HTTPSources, "http-src", "Data source names separated by commas limiting the HTTP recording or replay")
 	intelFlags.Var(args.Included, "include", "Data source names separated by commas to be included")
 	intelFlags.IntVar(&args.MaxDNSQueries, "max-dns-queries", 0, "Maximum number of concurrent DNS queries")
 	intelFlags.Var(&args.Ports, "p", "Ports separated by commas (default: 80, 443)")
-	intelFlags.Var(args.Resolvers, "r", "IP addresses of preferred DNS resolvers (can be used multiple times)")
+	intelFlags.StringVar(&args.Proxy, "proxy", "", "SOCKS5 proxy URL that all traffic, including DNS over TCP, is routed through")
+	intelFlags.Var(args.Resolvers, "r", "Addresses or DoH / DoT URIs of preferred DNS resolvers (can be used multiple times)")
 	intelFlags.IntVar(&args.Timeout, "timeout", 0, "Number of minutes to let enumeration run before quitting")
 }
 
@@ -95,6 +104,8 @@ func defineIntelFilepathFlags(intelFlags *flag.FlagSet, args *intelArgs) {
 	intelFlags.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the output files")
 	intelFlags.Var(&args.Filepaths.Domains, "df", "Path to a file providing root domain names")
 	intelFlags.StringVar(&args.Filepaths.ExcludedSrcs, "ef", "", "Path to a file providing data sources to exclude")
+	intelFlags.StringVar(&args.Filepaths.HTTPRecord, "http-record", "", "Path to the directory where the HTTP exchanges will be recorded")
+	intelFlags.StringVar(&args.Filepaths.HTTPReplay, "http-replay", "", "Path to a directory of recorded HTTP exchanges replayed without network access")
 	intelFlags.StringVar(&args.Filepaths.IncludedSrcs, "if", "", "Path to a file providing data sources to include")
 	intelFlags.StringVar(&args.Filepaths.LogFile, "log", "", "Path to the log file where errors will be written")
 	intelFlags.Var(&args.Filepaths.Resolvers, "rf", "Path to a file providing preferred DNS resolvers")
@@ -103,10 +114,12 @@ func defineIntelFilepathFlags(intelFlags *flag.FlagSet, args *intelArgs) {
 
 func runIntelCommand(clArgs []string) {
 	args := intelArgs{
-		Domains:   stringset.New(),
-		Excluded:  stringset.New(),
-		Included:  stringset.New(),
-		Resolvers: stringset.New(),
+		Domains:     stringset.New(),
+		Excluded:    stringset.New(),
+		HTTPSources: stringset.New(),
+		Included:    stringset.New(),
+		Resolvers:   stringset.New(),
+		Dialer:      amassnet.NewDialer(),
 	}
 	var help1, help2 bool
 	intelCommand := flag.NewFlagSet("intel", flag.ContinueOnError)
@@ -132,6 +145,12 @@ func runIntelCommand(clArgs []string) {
 		commandUsage(intelUsageMsg, intelCommand, intelBuf)
 		return
 	}
+	if args.Proxy != "" {
+		if err := args.Dialer.SetProxy(args.Proxy); err != nil {
+			r.Fprintf(color.Error, "%v\n", err)
+			os.Exit(1)
+		}
+	}
 	if (args.Excluded.Len() > 0 || args.Filepaths.ExcludedSrcs != "") &&
 		(args.Included.Len() > 0 || args.Filepaths.IncludedSrcs != "") {
 		commandUsage(intelUsageMsg, intelCommand, intelBuf)
@@ -144,7 +163,7 @@ func runIntelCommand(clArgs []string) {
 
 	cfg := config.NewConfig()
 	// Check if a configuration file was provided, and if so, load the settings
-	if err := config.AcquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, cfg); err == nil {
+	if err := acquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, cfg); err == nil {
 		// Check if a config file was provided that has DNS resolvers specified
 		if len(cfg.Resolvers) > 0 && args.Resolvers.Len() == 0 {
 			args.Resolvers = stringset.New(cfg.Resolvers...)
@@ -187,14 +206,19 @@ func runIntelCommand(clArgs []string) {
 	}
 
 	createOutputDirectory(cfg)
-	go writeLogsAndMessages(rLog, logfile, args.Options.Verbose)
+	go writeLogsAndMessages(rLog, logfile, args.Options.Verbose, nil)
 
-	sys, err := systems.NewLocalSystem(cfg)
+	sys, err := systems.NewLocalSystem(cfg, args.Dialer)
 	if err != nil {
 		return
 	}
 
-	if err := sys.SetDataSources(datasrcs.GetAllSources(sys)); err != nil {
+	srcs := datasrcs.GetAllSources(sys)
+	if err := setupHTTPFixtures(cfg, sys, args.Filepaths.HTTPRecord, args.Filepaths.HTTPReplay, args.HTTPSources.Slice(), srcs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if err := sys.SetDataSources(srcs); err != nil {
 		return
 	}
 
@@ -8,12 +8,15 @@ import (
 	"context"
 	"fmt"
 	"net"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/caffix/netmap"
 	"github.com/caffix/stringset"
 	"github.com/owasp-amass/amass/v4/enum"
+	"github.com/owasp-amass/amass/v4/format"
+	"github.com/owasp-amass/amass/v4/notify"
+	"github.com/owasp-amass/amass/v4/provenance"
 	"github.com/owasp-amass/amass/v4/requests"
 	"github.com/owasp-amass/asset-db/types"
 	oam "github.com/owasp-amass/open-asset-model"
@@ -22,7 +25,7 @@ import (
 	"golang.org/x/net/publicsuffix"
 )
 
-func NewOutput(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, filter *stringset.Set, since time.Time) []string {
+func NewOutput(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, filter *stringset.Set, since time.Time, sources *provenance.Store, redact *format.Redactor) []string {
 	var output []string
 
 	// Make sure a filter has been created
@@ -33,15 +36,30 @@ func NewOutput(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, filter
 
 	var assets []*types.Asset
 	for _, atype := range []oam.AssetType{oam.FQDN, oam.IPAddress, oam.Netblock, oam.ASN, oam.RIROrg} {
-		if a, err := g.DB.FindByType(atype, since.UTC()); err == nil {
+		if a, err := g.DB.FindByType(atype, lastSeenSince(since)); err == nil {
 			assets = append(assets, a...)
 		}
 	}
 
+	// The data sources are shown after the names and addresses when requested
+	names := make(map[string]string)
+	name := func(a *types.Asset) string {
+		if n, found := names[a.ID]; found {
+			return n
+		}
+
+		n := extractAssetName(a, redact)
+		if sources != nil {
+			n += assetSources(sources, a)
+		}
+		names[a.ID] = n
+		return n
+	}
+
 	arrow := white("-->")
-	start := e.Config.CollectionStartTime.UTC()
+	start := lastSeenSince(e.Config.CollectionStartTime)
 	for _, from := range assets {
-		fromstr := extractAssetName(from)
+		fromstr := name(from)
 
 		if rels, err := g.DB.OutgoingRelations(from, start); err == nil {
 			for _, rel := range rels {
@@ -50,7 +68,7 @@ func NewOutput(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, filter
 					continue
 				}
 				if to, err := g.DB.FindById(rel.ToAsset.ID, start); err == nil {
-					tostr := extractAssetName(to)
+					tostr := name(to)
 
 					output = append(output, fmt.Sprintf("%s %s %s %s %s", fromstr, arrow, magenta(rel.Type), arrow, tostr))
 					filter.Insert(lineid)
@@ -62,29 +80,135 @@ func NewOutput(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, filter
 	return output
 }
 
-func extractAssetName(a *types.Asset) string {
+// NewFindings returns the in-scope names last seen since the provided time, along with their DNS records,
+// to be sent to the webhooks. Unless final is true, the names without records are held back, since their
+// records may not have been stored yet. The filter is updated by NewFindings. The names and record values
+// are censored by the redactor, like the other output of the enumeration.
+func NewFindings(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, filter *stringset.Set, since time.Time, final bool, redact *format.Redactor) []*notify.Finding {
+	var findings []*notify.Finding
+
+	assets, err := g.DB.FindByType(oam.FQDN, lastSeenSince(since))
+	if err != nil {
+		return findings
+	}
+
+	start := lastSeenSince(e.Config.CollectionStartTime)
+	for _, a := range assets {
+		fqdn, ok := a.Asset.(domain.FQDN)
+		if !ok || filter.Has(fqdn.Name) || !e.Config.IsDomainInScope(fqdn.Name) {
+			continue
+		}
+
+		f := &notify.Finding{
+			Name:   redact.Domain(fqdn.Name),
+			Domain: redact.Domain(e.Config.WhichDomain(fqdn.Name)),
+			// Names created during this enumeration were not seen by the previous enumerations
+			New:        a.CreatedAt.After(start),
+			Discovered: a.CreatedAt,
+		}
+		if rels, err := g.DB.OutgoingRelations(a, start); err == nil {
+			for _, rel := range rels {
+				if to, err := g.DB.FindById(rel.ToAsset.ID, start); err == nil {
+					f.Records = append(f.Records, notify.Record{
+						Type:  notify.RecordType(rel.Type),
+						Value: assetValue(to, redact),
+					})
+				}
+			}
+		}
+		if len(f.Records) == 0 && !final {
+			continue
+		}
+
+		if sources := e.Sys.Provenance(); sources != nil {
+			if attrs, err := sources.Sources(fqdn.Name); err == nil {
+				for _, attr := range attrs {
+					f.Sources = append(f.Sources, attr.Source)
+				}
+			}
+		}
+		findings = append(findings, f)
+		filter.Insert(fqdn.Name)
+	}
+	return findings
+}
+
+// assetValue returns the name or address identifying the asset, censored by the redactor.
+func assetValue(a *types.Asset, redact *format.Redactor) string {
+	switch v := a.Asset.(type) {
+	case domain.FQDN:
+		return redact.Domain(v.Name)
+	case network.IPAddress:
+		return redact.IP(v.Address.String())
+	case network.Netblock:
+		return redact.Netblock(v.Cidr.String())
+	case network.AutonomousSystem:
+		return redact.ASN(v.Number)
+	case network.RIROrganization:
+		return redact.Org(v.RIRId + v.Name)
+	}
+	return ""
+}
+
+// assetSources returns the data sources that discovered the name or address, in the order
+// of their discoveries.
+func assetSources(sources *provenance.Store, a *types.Asset) string {
+	var key string
+
+	switch v := a.Asset.(type) {
+	case domain.FQDN:
+		key = v.Name
+	case network.IPAddress:
+		key = v.Address.String()
+	default:
+		return ""
+	}
+
+	attrs, err := sources.Sources(key)
+	if err != nil || len(attrs) == 0 {
+		return ""
+	}
+
+	var list []string
+	for _, attr := range attrs {
+		list = append(list, attr.Source)
+	}
+	return yellow(" [" + strings.Join(list, ", ") + "]")
+}
+
+// lastSeenSince returns the time used to select the graph entries last seen at or after the provided time.
+// The graph database records the last seen times with a precision of one second, so entries seen within
+// the same second would otherwise be missed.
+func lastSeenSince(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC().Truncate(time.Second).Add(-time.Nanosecond)
+}
+
+func extractAssetName(a *types.Asset, redact *format.Redactor) string {
 	var result string
 
 	switch a.Asset.AssetType() {
 	case oam.FQDN:
 		if fqdn, ok := a.Asset.(domain.FQDN); ok {
-			result = green(fqdn.Name) + blue(" (FQDN)")
+			result = green(redact.Domain(fqdn.Name)) + blue(" (FQDN)")
 		}
 	case oam.IPAddress:
 		if ip, ok := a.Asset.(network.IPAddress); ok {
-			result = green(ip.Address.String()) + blue(" (IPAddress)")
+			result = green(redact.IP(ip.Address.String())) + blue(" (IPAddress)")
 		}
 	case oam.ASN:
 		if asn, ok := a.Asset.(network.AutonomousSystem); ok {
-			result = green(strconv.Itoa(asn.Number)) + blue(" (ASN)")
+			result = green(redact.ASN(asn.Number)) + blue(" (ASN)")
 		}
 	case oam.RIROrg:
 		if rir, ok := a.Asset.(network.RIROrganization); ok {
-			result = green(rir.RIRId+rir.Name) + blue(" (RIROrganization)")
+			result = green(redact.Org(rir.RIRId+rir.Name)) + blue(" (RIROrganization)")
 		}
 	case oam.Netblock:
 		if nb, ok := a.Asset.(network.Netblock); ok {
-			result = green(nb.Cidr.String()) + blue(" (Netblock)")
+			result = green(redact.Netblock(nb.Cidr.String())) + blue(" (Netblock)")
 		}
 	}
 
@@ -93,7 +217,7 @@ func extractAssetName(a *types.Asset) string {
 
 // ExtractOutput is a convenience method for obtaining new discoveries made by the enumeration process.
 func ExtractOutput(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, filter *stringset.Set, asinfo bool) []*requests.Output {
-	return EventOutput(ctx, g, e.Config.Domains(), e.Config.CollectionStartTime, filter, asinfo, e.Sys.Cache())
+	return EventOutput(ctx, g, e.Config.Domains(), lastSeenSince(e.Config.CollectionStartTime), filter, asinfo, e.Sys.Cache())
 }
 
 type outLookup map[string]*requests.Output
@@ -187,19 +311,9 @@ func addInfrastructureInfo(lookup outLookup, filter *stringset.Set, cache *reque
 		var newaddrs []requests.AddressInfo
 
 		for _, a := range o.Addresses {
-			i := cache.AddrSearch(a.Address.String())
-			if i == nil {
-				continue
+			if info, found := infrastructureInfo(a.Address, cache); found {
+				newaddrs = append(newaddrs, info)
 			}
-
-			_, netblock, _ := net.ParseCIDR(i.Prefix)
-			newaddrs = append(newaddrs, requests.AddressInfo{
-				Address:     a.Address,
-				ASN:         i.ASN,
-				CIDRStr:     i.Prefix,
-				Netblock:    netblock,
-				Description: i.Description,
-			})
 		}
 
 		o.Addresses = newaddrs
@@ -211,6 +325,23 @@ func addInfrastructureInfo(lookup outLookup, filter *stringset.Set, cache *reque
 	return output
 }
 
+// infrastructureInfo returns the address along with the ASN and netblock that contain it.
+func infrastructureInfo(addr net.IP, cache *requests.ASNCache) (requests.AddressInfo, bool) {
+	i := cache.AddrSearch(addr.String())
+	if i == nil {
+		return requests.AddressInfo{Address: addr}, false
+	}
+
+	_, netblock, _ := net.ParseCIDR(i.Prefix)
+	return requests.AddressInfo{
+		Address:     addr,
+		ASN:         i.ASN,
+		CIDRStr:     i.Prefix,
+		Netblock:    netblock,
+		Description: i.Description,
+	}, true
+}
+
 // EventNames returns findings within the receiver Graph within the scope identified by the provided domain names.
 // The filter is updated by EventNames.
 func EventNames(ctx context.Context, g *netmap.Graph, domains []string, since time.Time, f *stringset.Set) []*requests.Output {
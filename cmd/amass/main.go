@@ -24,23 +24,32 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"net"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/caffix/service"
+	"github.com/caffix/stringset"
 	"github.com/fatih/color"
 	"github.com/owasp-amass/amass/v4/datasrcs"
+	"github.com/owasp-amass/amass/v4/datasrcs/scripting"
 	"github.com/owasp-amass/amass/v4/format"
 	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/notify"
+	"github.com/owasp-amass/amass/v4/resolvers"
 	"github.com/owasp-amass/amass/v4/systems"
 	"github.com/owasp-amass/config/config"
+	"gopkg.in/yaml.v3"
 )
 
 const (
-	mainUsageMsg         = "intel|enum [options]"
+	mainUsageMsg         = "intel|enum|engine|worker|asndb|db|script [options]"
 	exampleConfigFileURL = "https://github.com/owasp-amass/amass/blob/master/examples/config.yaml"
 	userGuideURL         = "https://github.com/owasp-amass/amass/blob/master/doc/user_guide.md"
 	tutorialURL          = "https://github.com/owasp-amass/amass/blob/master/doc/tutorial.md"
@@ -67,8 +76,12 @@ func commandUsage(msg string, cmdFlagSet *flag.FlagSet, errBuf *bytes.Buffer) {
 
 	if msg == mainUsageMsg {
 		g.Fprintf(color.Error, "\nSubcommands: \n\n")
-		g.Fprintf(color.Error, "\t%-11s - Discover targets for enumerations\n", "amass intel")
-		g.Fprintf(color.Error, "\t%-11s - Perform enumerations and network mapping\n", "amass enum")
+		g.Fprintf(color.Error, "\t%-12s - Discover targets for enumerations\n", "amass intel")
+		g.Fprintf(color.Error, "\t%-12s - Perform enumerations and network mapping\n", "amass enum")
+		g.Fprintf(color.Error, "\t%-12s - Serve enumeration jobs over a REST API\n", "amass engine")
+		g.Fprintf(color.Error, "\t%-12s - Resolve the DNS queries of enumerations on other hosts\n", "amass worker")
+		g.Fprintf(color.Error, "\t%-12s - Import the output of other tools into the graph database\n", "amass db")
+		g.Fprintf(color.Error, "\t%-12s - Test data source scripts against fixtures\n", "amass script")
 	}
 
 	g.Fprintln(color.Error)
@@ -110,6 +123,16 @@ func main() {
 		runEnumCommand(os.Args[2:])
 	case "intel":
 		runIntelCommand(os.Args[2:])
+	case "engine":
+		runEngineCommand(os.Args[2:])
+	case "worker":
+		runWorkerCommand(os.Args[2:])
+	case "asndb":
+		runASNDBCommand(os.Args[2:])
+	case "db":
+		runDBCommand(os.Args[2:])
+	case "script":
+		runScriptCommand(os.Args[2:])
 	case "help":
 		runHelpCommand(os.Args[2:])
 	default:
@@ -124,7 +147,7 @@ func GetAllSourceInfo(cfg *config.Config) []string {
 		cfg = config.NewConfig()
 	}
 
-	sys, err := systems.NewLocalSystem(cfg)
+	sys, err := systems.NewLocalSystem(cfg, nil)
 	if err != nil {
 		return []string{}
 	}
@@ -180,7 +203,283 @@ func createOutputDirectory(cfg *config.Config) {
 	}
 }
 
-func assignNetInterface(iface *net.Interface) error {
+// setupHTTPFixtures records or replays the HTTP exchanges of the named data sources,
+// or of the entire run when no data sources are named.
+func setupHTTPFixtures(cfg *config.Config, sys systems.System, record, replay string, names []string, srcs []service.Service) error {
+	if record == "" && replay == "" {
+		if len(names) > 0 {
+			return errors.New("The http-src option requires the http-record or http-replay option")
+		}
+		return nil
+	}
+	if record != "" && replay != "" {
+		return errors.New("The HTTP record and replay options cannot be used together")
+	}
+
+	dir, mode := record, http.FixturesRecord
+	if replay != "" {
+		dir, mode = replay, http.FixturesReplay
+	}
+
+	f, err := http.NewFixtures(dir, mode, dataSourceSecrets(cfg)...)
+	if err != nil {
+		return err
+	}
+	selected := stringset.New(names...)
+	defer selected.Close()
+
+	var found int
+	for _, src := range srcs {
+		s, ok := src.(*scripting.Script)
+		if !ok || (len(names) > 0 && !selected.Has(s.String())) {
+			continue
+		}
+		// Data sources with their own HTTP client keep using it, while the others use the client of the System
+		if c, ok := s.WebClient().(*http.Client); ok {
+			s.SetWebClient(f.Wrap(c))
+		}
+		found++
+	}
+	if len(names) > 0 && found == 0 {
+		return fmt.Errorf("None of the data sources selected for the HTTP fixtures were found: %s", strings.Join(names, ", "))
+	}
+	return nil
+}
+
+// dataSourceSecrets returns the credentials in the configuration that must not be written to the HTTP fixtures.
+func dataSourceSecrets(cfg *config.Config) []string {
+	if cfg.DataSrcConfigs == nil {
+		return nil
+	}
+
+	var secrets []string
+	for _, src := range cfg.DataSrcConfigs.Datasources {
+		for _, creds := range src.Creds {
+			secrets = append(secrets, creds.Password, creds.Apikey, creds.Secret)
+		}
+	}
+	return secrets
+}
+
+// acquireConfig loads the configuration file and accepts the DNS-over-HTTPS and DNS-over-TLS
+// resolver entries, and the QPS of each entry, that the configuration package rejects in the
+// resolvers option. The file selected by the configuration package is loaded without the option,
+// so the errors of the other settings are still reported, and the resolvers are then loaded here.
+func acquireConfig(dir, file string, cfg *config.Config) error {
+	// The configuration package selects the file, which is first loaded into a scratch Config
+	probe := config.NewConfig()
+	if err := config.AcquireConfig(dir, file, probe); err == nil {
+		return cfg.LoadSettings(probe.Filepath)
+	}
+	path := probe.Filepath
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg.LoadSettings(path)
+	}
+
+	entries, stripped, err := splitResolverOption(data, filepath.Dir(path))
+	if err != nil || entries == nil {
+		return cfg.LoadSettings(path)
+	}
+
+	tmp, err := os.CreateTemp("", "amass-config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("failed to copy the configuration file: %v", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	_, err = tmp.Write(stripped)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to copy the configuration file: %v", err)
+	}
+
+	if err := cfg.LoadSettings(tmp.Name()); err != nil {
+		return err
+	}
+	cfg.Filepath = path
+	if cfg.Options == nil {
+		cfg.Options = make(map[string]interface{})
+	}
+	cfg.Options["resolvers"] = entries
+	return loadResolverOptions(cfg)
+}
+
+// splitResolverOption returns the resolvers option of the configuration file and the file data
+// without the option. The relative paths of the other options are made absolute using the
+// directory of the file, so the data can be loaded from another directory. The returned
+// entries are nil when the file has no resolvers option.
+func splitResolverOption(data []byte, dir string) (interface{}, []byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if doc.Kind != yaml.DocumentNode || len(doc.Content) == 0 {
+		return nil, nil, nil
+	}
+
+	options := mappingValue(doc.Content[0], "options")
+	if options == nil || options.Kind != yaml.MappingNode {
+		return nil, nil, nil
+	}
+
+	var entries interface{}
+	for i := 0; i+1 < len(options.Content); i += 2 {
+		if options.Content[i].Value != "resolvers" {
+			continue
+		}
+
+		if err := options.Content[i+1].Decode(&entries); err != nil {
+			return nil, nil, err
+		}
+		options.Content = append(options.Content[:i], options.Content[i+2:]...)
+		break
+	}
+	if entries == nil {
+		return nil, nil, nil
+	}
+
+	absPath(mappingValue(options, "datasources"), dir)
+	for _, key := range []string{"bruteforce", "alterations"} {
+		if opt := mappingValue(options, key); opt != nil {
+			if lists := mappingValue(opt, "wordlists"); lists != nil && lists.Kind == yaml.SequenceNode {
+				for _, n := range lists.Content {
+					absPath(n, dir)
+				}
+			}
+		}
+	}
+
+	stripped, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, nil, err
+	}
+	return entries, stripped, nil
+}
+
+// absPath joins the relative path in the scalar node to the directory.
+func absPath(node *yaml.Node, dir string) {
+	if node != nil && node.Kind == yaml.ScalarNode && node.Value != "" && !filepath.IsAbs(node.Value) {
+		node.Value = filepath.Join(dir, filepath.Clean(node.Value))
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func loadResolverOptions(cfg *config.Config) error {
+	raw, found := cfg.Options["resolvers"]
+	if !found {
+		return nil
+	}
+
+	entries, ok := raw.([]interface{})
+	if !ok {
+		return errors.New("resolvers section is not a list")
+	}
+
+	var list []string
+	for _, e := range entries {
+		entry, ok := e.(string)
+		if !ok {
+			return fmt.Errorf("resolver entry %v is not a string", e)
+		}
+		if _, _, err := resolvers.ParseResolver(entry); err == nil {
+			list = append(list, entry)
+			continue
+		}
+		// The entry is not a resolver address, so it must be a file path
+		path, err := cfg.AbsPathFromConfigDir(entry)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path for resolver file: %v", err)
+		}
+
+		addrs, err := config.GetListFromFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to load resolvers from file: %v", err)
+		}
+		for _, addr := range addrs {
+			if _, _, err := resolvers.ParseResolver(addr); err != nil {
+				return err
+			}
+		}
+		list = append(list, addrs...)
+	}
+	if len(list) == 0 {
+		return errors.New("no valid resolvers were found")
+	}
+
+	cfg.Resolvers = stringset.Deduplicate(list)
+	return nil
+}
+
+// demoRedactor returns the Redactor that censors the output in demo mode, using the rules
+// provided by the demo option of the configuration file.
+func demoRedactor(cfg *config.Config) (*format.Redactor, error) {
+	var rules format.RedactionRules
+
+	if raw, found := cfg.Options["demo"]; found {
+		data, err := yaml.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the demo option: %v", err)
+		}
+		if err := yaml.Unmarshal(data, &rules); err != nil {
+			return nil, fmt.Errorf("failed to parse the demo option: %v", err)
+		}
+	}
+	return format.NewRedactor(rules)
+}
+
+// newNotifier returns the Notifier sending the findings to the webhooks provided by the notifications
+// option of the configuration file, or nil when no webhooks were provided.
+func newNotifier(cfg *config.Config, dialer *amassnet.Dialer) (*notify.Notifier, error) {
+	raw, found := cfg.Options["notifications"]
+	if !found {
+		return nil, nil
+	}
+
+	data, err := yaml.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the notifications option: %v", err)
+	}
+
+	var settings []*notify.Settings
+	if err := yaml.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse the notifications option: %v", err)
+	}
+	if len(settings) == 0 {
+		return nil, nil
+	}
+
+	for _, s := range settings {
+		if s.HTTP == nil {
+			continue
+		}
+		for _, p := range []*string{&s.HTTP.CABundle, &s.HTTP.ClientCert, &s.HTTP.ClientKey} {
+			if *p != "" {
+				if abs, err := cfg.AbsPathFromConfigDir(*p); err == nil {
+					*p = abs
+				}
+			}
+		}
+	}
+	return notify.NewNotifier(settings, dialer, cfg.Log)
+}
+
+func assignNetInterface(dialer *amassnet.Dialer, iface *net.Interface) error {
 	addrs, err := iface.Addrs()
 	if err != nil {
 		return fmt.Errorf("network interface '%s' has no assigned addresses", iface.Name)
@@ -203,6 +502,6 @@ func assignNetInterface(iface *net.Interface) error {
 		return fmt.Errorf("network interface '%s' does not have assigned IP addresses", iface.Name)
 	}
 
-	amassnet.LocalAddr = best
+	dialer.SetLocalAddr(best)
 	return nil
 }
@@ -0,0 +1,128 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/owasp-amass/amass/v4/datasrcs/scripting"
+)
+
+const (
+	scriptUsageMsg = "script test [options] FILE..."
+)
+
+type scriptTestArgs struct {
+	Verbose bool
+}
+
+func runScriptCommand(clArgs []string) {
+	var help1, help2 bool
+	scriptCommand := flag.NewFlagSet("script", flag.ContinueOnError)
+
+	scriptBuf := new(bytes.Buffer)
+	scriptCommand.SetOutput(scriptBuf)
+
+	scriptCommand.BoolVar(&help1, "h", false, "Show the program usage message")
+	scriptCommand.BoolVar(&help2, "help", false, "Show the program usage message")
+
+	if err := scriptCommand.Parse(clArgs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if help1 || help2 || scriptCommand.NArg() == 0 {
+		commandUsage(scriptUsageMsg, scriptCommand, scriptBuf)
+		return
+	}
+
+	switch scriptCommand.Arg(0) {
+	case "test":
+		runScriptTestCommand(scriptCommand.Args()[1:])
+	default:
+		commandUsage(scriptUsageMsg, scriptCommand, scriptBuf)
+		os.Exit(1)
+	}
+}
+
+func runScriptTestCommand(clArgs []string) {
+	var args scriptTestArgs
+	var help1, help2 bool
+	testCommand := flag.NewFlagSet("test", flag.ContinueOnError)
+
+	testBuf := new(bytes.Buffer)
+	testCommand.SetOutput(testBuf)
+
+	testCommand.BoolVar(&help1, "h", false, "Show the program usage message")
+	testCommand.BoolVar(&help2, "help", false, "Show the program usage message")
+	testCommand.BoolVar(&args.Verbose, "v", false, "Output the script log messages and requests missing fixtures")
+
+	if err := testCommand.Parse(clArgs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if help1 || help2 || testCommand.NArg() == 0 {
+		commandUsage(scriptUsageMsg, testCommand, testBuf)
+		return
+	}
+
+	var logger *log.Logger
+	if args.Verbose {
+		logger = log.New(color.Error, "", 0)
+	}
+
+	var failed bool
+	for _, path := range testCommand.Args() {
+		if !runScriptTestFile(path, logger, args.Verbose) {
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+func runScriptTestFile(path string, logger *log.Logger, verbose bool) bool {
+	tf, err := scripting.LoadTestFile(path)
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		return false
+	}
+
+	results, err := tf.Run(context.Background(), logger)
+	if err != nil {
+		r.Fprintf(color.Error, "%s: %v\n", path, err)
+		return false
+	}
+
+	var failures int
+	for _, res := range results {
+		if res.Passed() {
+			g.Fprintf(color.Output, "PASS  %s (%.2fs)\n", res.Name, res.Duration.Seconds())
+		} else {
+			failures++
+			r.Fprintf(color.Output, "FAIL  %s (%.2fs)\n", res.Name, res.Duration.Seconds())
+			for _, f := range res.Failures {
+				fgR.Fprintf(color.Output, "      %s\n", f)
+			}
+		}
+		if verbose || !res.Passed() {
+			for _, u := range res.Unmatched {
+				fgY.Fprintf(color.Output, "      no HTTP fixture: %s\n", u)
+			}
+		}
+	}
+
+	if failures > 0 {
+		r.Fprintf(color.Output, "%s: %d of %d tests failed\n", path, failures, len(results))
+		return false
+	}
+	g.Fprintf(color.Output, "%s: %d tests passed\n", path, len(results))
+	return true
+}
@@ -0,0 +1,53 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/caffix/netmap"
+	"github.com/owasp-amass/amass/v4/enum"
+	"github.com/owasp-amass/amass/v4/format"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/config/config"
+)
+
+// enumSummary adds the findings of the enumeration, the data sources that contributed them
+// and the DNS queries sent to the resolvers to the summary.
+func enumSummary(ctx context.Context, g *netmap.Graph, e *enum.Enumeration, s *format.Summary) {
+	for _, o := range ExtractOutput(ctx, g, e, nil, false) {
+		var addrs []requests.AddressInfo
+
+		if cache := e.Sys.Cache(); cache != nil {
+			for _, a := range o.Addresses {
+				info, _ := infrastructureInfo(a.Address, cache)
+				addrs = append(addrs, info)
+			}
+			o.Addresses = addrs
+		}
+		s.AddOutput(o, e.Config.WhichDomain(o.Name))
+	}
+
+	if sources := e.Sys.Provenance(); sources != nil {
+		if attrs, err := sources.Attributions(lastSeenSince(e.Config.CollectionStartTime)); err == nil {
+			s.AddAttributions(attrs)
+		}
+	}
+
+	s.AddResolverStats(e.Sys.Resolvers().Stats())
+	// The trusted resolvers are often the same pool, which should not be counted twice
+	if trusted := e.Sys.TrustedResolvers(); trusted != nil && trusted != e.Sys.Resolvers() {
+		s.AddResolverStats(trusted.Stats())
+	}
+}
+
+// summaryPath returns the path of the JSON file storing the enumeration summary.
+func summaryPath(cfg *config.Config, args *enumArgs) string {
+	if args.Filepaths.AllFilePrefix != "" {
+		return args.Filepaths.AllFilePrefix + "_summary.json"
+	}
+	return filepath.Join(config.OutputDirectory(cfg.Dir), format.SummaryFilename)
+}
@@ -0,0 +1,218 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/caffix/stringset"
+	"github.com/fatih/color"
+	"github.com/owasp-amass/amass/v4/format"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/owasp-amass/amass/v4/systems"
+	"github.com/owasp-amass/amass/v4/worker"
+	"github.com/owasp-amass/amass/v4/zonefile"
+	"github.com/owasp-amass/config/config"
+)
+
+const (
+	workerUsageMsg = "worker [options]"
+	// the environment variable providing the worker token when the flag is not used
+	workerTokenEnv = "AMASS_WORKER_TOKEN"
+)
+
+type workerArgs struct {
+	Addr          string
+	Token         string
+	MaxDNSQueries int
+	ResolverQPS   int
+	Resolvers     *stringset.Set
+	Options       struct {
+		NoColor bool
+		Silent  bool
+	}
+	Filepaths struct {
+		ConfigFile string
+		Directory  string
+		LogFile    string
+		Resolvers  format.ParseStrings
+		ZoneFiles  format.ParseStrings
+	}
+}
+
+func runWorkerCommand(clArgs []string) {
+	var help1, help2 bool
+	args := workerArgs{Resolvers: stringset.New()}
+	defer args.Resolvers.Close()
+	workerCommand := flag.NewFlagSet("worker", flag.ContinueOnError)
+
+	workerBuf := new(bytes.Buffer)
+	workerCommand.SetOutput(workerBuf)
+
+	workerCommand.BoolVar(&help1, "h", false, "Show the program usage message")
+	workerCommand.BoolVar(&help2, "help", false, "Show the program usage message")
+	workerCommand.StringVar(&args.Addr, "addr", ":"+strconv.Itoa(worker.DefaultPort), "Address and port the worker listens on")
+	workerCommand.StringVar(&args.Token, "token", "", "Token shared with the enumerations sending queries (default: "+workerTokenEnv+")")
+	workerCommand.IntVar(&args.MaxDNSQueries, "dns-qps", 0, "Maximum number of DNS queries per second across all resolvers")
+	workerCommand.IntVar(&args.ResolverQPS, "rqps", 0, "Maximum number of DNS queries per second for each resolver")
+	workerCommand.Var(args.Resolvers, "r", "Addresses or DoH / DoT URIs of DNS resolvers (can be used multiple times)")
+	workerCommand.BoolVar(&args.Options.NoColor, "nocolor", false, "Disable colorized output")
+	workerCommand.BoolVar(&args.Options.Silent, "silent", false, "Disable all output during execution")
+	workerCommand.StringVar(&args.Filepaths.ConfigFile, "config", "", "Path to the YAML configuration file. Additional details below")
+	workerCommand.StringVar(&args.Filepaths.Directory, "dir", "", "Path to the directory containing the output files")
+	workerCommand.StringVar(&args.Filepaths.LogFile, "log", "", "Path to the log file where the errors are appended")
+	workerCommand.Var(&args.Filepaths.Resolvers, "rf", "Path to a file providing DNS resolvers")
+	workerCommand.Var(&args.Filepaths.ZoneFiles, "zonefile", "Path to a zone file answering all DNS queries in place of the resolvers (can be used multiple times)")
+
+	if err := workerCommand.Parse(clArgs); err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	if help1 || help2 {
+		commandUsage(workerUsageMsg, workerCommand, workerBuf)
+		return
+	}
+	if args.Options.NoColor {
+		color.NoColor = true
+	}
+	if args.Options.Silent {
+		color.Output = io.Discard
+		color.Error = io.Discard
+	}
+	if args.Token == "" {
+		args.Token = os.Getenv(workerTokenEnv)
+	}
+	// A worker resolving names for anyone on the network would be an open resolver
+	if args.Token == "" && !loopbackAddr(args.Addr) {
+		r.Fprintf(color.Error, "A token is required when the worker listens beyond the local host (see -token or %s)\n", workerTokenEnv)
+		os.Exit(1)
+	}
+	for _, f := range args.Filepaths.Resolvers {
+		list, err := config.GetListFromFile(f)
+		if err != nil {
+			r.Fprintf(color.Error, "Failed to parse the resolvers file: %v\n", err)
+			os.Exit(1)
+		}
+		args.Resolvers.InsertMany(list...)
+	}
+
+	cfg := config.NewConfig()
+	// Check if a configuration file was provided, and if so, load the settings
+	if err := acquireConfig(args.Filepaths.Directory, args.Filepaths.ConfigFile, cfg); err != nil && args.Filepaths.ConfigFile != "" {
+		r.Fprintf(color.Error, "Failed to load the configuration file: %v\n", err)
+		os.Exit(1)
+	}
+	if args.Filepaths.Directory != "" {
+		cfg.Dir = args.Filepaths.Directory
+	}
+	if args.ResolverQPS > 0 {
+		cfg.ResolversQPS = args.ResolverQPS
+	}
+	if args.Resolvers.Len() > 0 {
+		cfg.SetResolvers(args.Resolvers.Slice()...)
+	}
+	if args.MaxDNSQueries > 0 {
+		cfg.MaxDNSQueries = args.MaxDNSQueries
+	}
+	createOutputDirectory(cfg)
+
+	logfile := filepath.Join(config.OutputDirectory(cfg.Dir), "amass_worker.log")
+	if args.Filepaths.LogFile != "" {
+		logfile = args.Filepaths.LogFile
+	}
+	// The worker runs for a long time, so the messages are appended to the previous ones
+	f, err := os.OpenFile(logfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		r.Fprintf(color.Error, "Failed to open the log file: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = f.Close() }()
+	cfg.Log = log.New(f, "", log.Lmicroseconds)
+
+	start := time.Now()
+	pool, err := newWorkerPool(cfg, args.Filepaths.ZoneFiles)
+	if err != nil {
+		r.Fprintf(color.Error, "%v\n", err)
+		os.Exit(1)
+	}
+	defer pool.Stop()
+
+	ln, err := net.Listen("tcp", args.Addr)
+	if err != nil {
+		r.Fprintf(color.Error, "Failed to listen on %s: %v\n", args.Addr, err)
+		os.Exit(1)
+	}
+
+	srv := worker.NewServer(pool, args.Token, cfg.Log)
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(ln) }()
+
+	g.Fprintf(color.Error, "The worker was ready in %s and is resolving %d queries per second on %s\n",
+		time.Since(start).Round(time.Millisecond), pool.QPS(), ln.Addr().String())
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(quit)
+
+	var failed bool
+	select {
+	case <-quit:
+	case err := <-served:
+		if err != nil {
+			r.Fprintf(color.Error, "The worker failed: %v\n", err)
+			failed = true
+		}
+	}
+
+	fmt.Fprintf(color.Error, "%s\n", green("The worker is shutting down"))
+	_ = srv.Close()
+	format.PrintResolverSummary(pool.Stats())
+
+	if failed {
+		pool.Stop()
+		os.Exit(1)
+	}
+}
+
+// newWorkerPool returns the resolvers of the worker.
+// When zone files are provided, the DNS queries are answered from the zones instead of the resolvers.
+func newWorkerPool(cfg *config.Config, zonefiles []string) (*resolvers.Pool, error) {
+	if len(zonefiles) == 0 {
+		return systems.NewWorkerPool(cfg, nil)
+	}
+
+	zones := zonefile.NewZones()
+	for _, f := range zonefiles {
+		if err := zones.LoadZoneFile("", f); err != nil {
+			return nil, err
+		}
+	}
+	return zones.Pool(), nil
+}
+
+// loopbackAddr returns true when the listening address only accepts connections from the local host.
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
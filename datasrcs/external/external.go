@@ -0,0 +1,361 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package external
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/caffix/service"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/systems"
+)
+
+// The events that can be sent to the external programs.
+const (
+	EventVertical = "vertical"
+	EventResolved = "resolved"
+	EventAddress  = "address"
+)
+
+// The default settings for the external programs.
+const (
+	DefaultTimeout  = 60
+	DefaultRestarts = 3
+)
+
+// Settings describe the external program run by a data source.
+type Settings struct {
+	// Command is the path of the program, or the name of a program in the PATH
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args,omitempty"`
+	// Env holds KEY=value pairs added to the environment of the amass process
+	Env []string `yaml:"env,omitempty"`
+	Dir string   `yaml:"dir,omitempty"`
+	// Timeout is the number of seconds the program has to handle each event
+	Timeout int `yaml:"timeout,omitempty"`
+	// Restarts is the number of times the program is restarted after crashing or timing out.
+	// A negative value disables the restarts.
+	Restarts int `yaml:"restarts,omitempty"`
+	// Events are the events sent to the program, which are all the events by default
+	Events []string `yaml:"events,omitempty"`
+}
+
+// Validate checks that the settings describe a program and only contain known events.
+func (s *Settings) Validate() error {
+	if strings.TrimSpace(s.Command) == "" {
+		return errors.New("the external program command was not provided")
+	}
+	if s.Timeout < 0 {
+		return errors.New("the external program timeout cannot be negative")
+	}
+	for _, e := range s.Events {
+		if e != EventVertical && e != EventResolved && e != EventAddress {
+			return fmt.Errorf("the %s event is not known", e)
+		}
+	}
+	return nil
+}
+
+// Record is a DNS resource record exchanged with the external programs.
+type Record struct {
+	Name string `json:"rrname"`
+	Type int    `json:"rrtype"`
+	Data string `json:"rrdata"`
+}
+
+// Message is a line of the JSON protocol used to communicate with the external programs.
+type Message struct {
+	ID      int      `json:"id,omitempty"`
+	Type    string   `json:"type"`
+	Name    string   `json:"name,omitempty"`
+	Domain  string   `json:"domain,omitempty"`
+	Address string   `json:"address,omitempty"`
+	Assoc   string   `json:"assoc,omitempty"`
+	Records []Record `json:"records,omitempty"`
+}
+
+// Source is the Service that sends events to an external program and reads back its findings.
+type Source struct {
+	service.BaseService
+	SourceType string
+	sys        systems.System
+	settings   Settings
+	events     map[string]struct{}
+	proc       *process
+	nextID     int
+	failures   int
+	abandoned  bool
+	ctx        context.Context
+	cancel     context.CancelFunc
+	finished   chan struct{}
+}
+
+// NewSource returns the data source running the external program, initialized but not yet started.
+func NewSource(name string, settings *Settings, sys systems.System) (*Source, error) {
+	if settings == nil {
+		return nil, errors.New("the external program settings were not provided")
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+
+	s := &Source{
+		SourceType: "ext",
+		sys:        sys,
+		settings:   *settings,
+		events:     make(map[string]struct{}),
+		finished:   make(chan struct{}),
+	}
+	if s.settings.Timeout == 0 {
+		s.settings.Timeout = DefaultTimeout
+	}
+	if s.settings.Restarts == 0 {
+		s.settings.Restarts = DefaultRestarts
+	}
+
+	events := s.settings.Events
+	if len(events) == 0 {
+		events = []string{EventVertical, EventResolved, EventAddress}
+	}
+	for _, e := range events {
+		s.events[e] = struct{}{}
+	}
+
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.BaseService = *service.NewBaseService(s, name)
+	return s, nil
+}
+
+// Description implements the Service interface.
+func (s *Source) Description() string {
+	return s.SourceType
+}
+
+// OnStart implements the Service interface.
+func (s *Source) OnStart() error {
+	p, err := s.startProcess()
+	if err != nil {
+		// The service is left running by a failed start, so OnStop must not wait
+		close(s.finished)
+		return fmt.Errorf("%s: %v", s.String(), err)
+	}
+
+	s.proc = p
+	go s.requests()
+	return nil
+}
+
+// OnStop implements the Service interface.
+func (s *Source) OnStop() error {
+	s.cancel()
+	<-s.finished
+	return nil
+}
+
+// HandlesReq implements the Service interface.
+func (s *Source) HandlesReq(req interface{}) bool {
+	switch t := req.(type) {
+	case *requests.DNSRequest:
+		return s.sends(EventVertical) && t != nil && t.Domain != ""
+	case *requests.ResolvedRequest:
+		return s.sends(EventResolved) && t != nil && t.Name != "" && len(t.Records) > 0
+	case *requests.AddrRequest:
+		return s.sends(EventAddress) && t != nil && t.Address != ""
+	}
+	return false
+}
+
+func (s *Source) sends(event string) bool {
+	_, found := s.events[event]
+	return found
+}
+
+func (s *Source) requests() {
+	defer close(s.finished)
+
+	for {
+		var lines <-chan []byte
+		if s.proc != nil {
+			lines = s.proc.lines
+		}
+
+		select {
+		case <-s.Done():
+			s.stopProcess()
+			return
+		case <-s.ctx.Done():
+			s.stopProcess()
+			return
+		case in := <-s.Input():
+			s.dispatch(in)
+		// The program can provide findings between the events
+		case line, ok := <-lines:
+			if !ok {
+				s.crashed()
+				continue
+			}
+			s.handleLine(line)
+		}
+	}
+}
+
+func (s *Source) dispatch(in interface{}) {
+	var msg *Message
+
+	switch req := in.(type) {
+	case *requests.DNSRequest:
+		if s.sends(EventVertical) && req != nil && req.Domain != "" {
+			msg = &Message{Type: EventVertical, Domain: req.Domain}
+		}
+	case *requests.ResolvedRequest:
+		if s.sends(EventResolved) && req != nil && req.Name != "" && len(req.Records) > 0 {
+			msg = &Message{Type: EventResolved, Name: req.Name, Domain: req.Domain}
+			for _, rr := range req.Records {
+				msg.Records = append(msg.Records, Record{Name: rr.Name, Type: rr.Type, Data: rr.Data})
+			}
+		}
+	case *requests.AddrRequest:
+		if s.sends(EventAddress) && req != nil && req.Address != "" {
+			msg = &Message{Type: EventAddress, Address: req.Address, Domain: req.Domain}
+		}
+	}
+
+	if msg != nil {
+		s.sendEvent(msg)
+	}
+}
+
+// sendEvent provides the event to the program and handles the findings until the program
+// reports that it is done with the event.
+func (s *Source) sendEvent(msg *Message) {
+	if s.proc == nil && !s.restart() {
+		return
+	}
+
+	s.nextID++
+	msg.ID = s.nextID
+	if err := s.proc.send(msg); err != nil {
+		s.sys.Config().Log.Printf("%s: Failed to send the %s event: %v", s.String(), msg.Type, err)
+		s.crashed()
+		return
+	}
+
+	timer := time.NewTimer(time.Duration(s.settings.Timeout) * time.Second)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-s.Done():
+			return
+		case <-timer.C:
+			s.sys.Config().Log.Printf("%s: The program did not handle the %s event within %d seconds",
+				s.String(), msg.Type, s.settings.Timeout)
+			s.killProcess()
+			s.failures++
+			return
+		case line, ok := <-s.proc.lines:
+			if !ok {
+				s.crashed()
+				return
+			}
+			if done := s.handleLine(line); done == msg.ID {
+				return
+			}
+		}
+	}
+}
+
+// handleLine sends the finding in the line to Amass and returns the ID of the event
+// when the line reports that the program is done with the event.
+func (s *Source) handleLine(line []byte) int {
+	var msg Message
+	if err := json.Unmarshal(line, &msg); err != nil {
+		s.sys.Config().Log.Printf("%s: Failed to parse the program output: %v", s.String(), err)
+		return 0
+	}
+
+	switch msg.Type {
+	case "done":
+		return msg.ID
+	case "name":
+		s.newName(msg.Name, nil)
+	case "records":
+		var records []requests.DNSAnswer
+		for _, rr := range msg.Records {
+			if rr.Name != "" && rr.Type != 0 && rr.Data != "" {
+				records = append(records, requests.DNSAnswer{Name: rr.Name, Type: rr.Type, Data: rr.Data})
+			}
+		}
+		s.newName(msg.Name, records)
+	case "address":
+		s.newAddr(msg.Address, msg.Name)
+	case "association":
+		s.associated(msg.Domain, msg.Assoc)
+	default:
+		s.sys.Config().Log.Printf("%s: The program provided an unknown message type: %s", s.String(), msg.Type)
+	}
+	return 0
+}
+
+func (s *Source) newName(name string, records []requests.DNSAnswer) {
+	name = http.CleanName(name)
+	if name == "" {
+		return
+	}
+
+	if domain := s.sys.Config().WhichDomain(name); domain != "" {
+		s.output(&requests.DNSRequest{
+			Name:    name,
+			Domain:  domain,
+			Records: records,
+		})
+	}
+}
+
+func (s *Source) newAddr(addr, name string) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return
+	}
+	if reserved, _ := amassnet.IsReservedAddress(ip.String()); reserved {
+		return
+	}
+
+	if domain := s.sys.Config().WhichDomain(http.CleanName(name)); domain != "" {
+		s.output(&requests.AddrRequest{
+			Address: ip.String(),
+			Domain:  domain,
+		})
+	}
+}
+
+func (s *Source) associated(domain, assoc string) {
+	domain, assoc = http.CleanName(domain), http.CleanName(assoc)
+
+	if domain != "" && assoc != "" && domain != assoc {
+		s.output(&requests.WhoisRequest{
+			Domain:     domain,
+			NewDomains: []string{assoc},
+		})
+	}
+}
+
+func (s *Source) output(req interface{}) {
+	select {
+	case <-s.ctx.Done():
+	case <-s.Done():
+	case s.Output() <- req:
+	}
+}
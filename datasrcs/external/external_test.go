@@ -0,0 +1,191 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package external
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/systems"
+	"github.com/owasp-amass/config/config"
+	"github.com/stretchr/testify/require"
+)
+
+const helperEnv = "AMASS_EXT_HELPER"
+
+// TestMain runs the test binary as the external program when the helper variable is set.
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(helperEnv); mode != "" {
+		helperProgram(mode)
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func helperProgram(mode string) {
+	fmt.Fprintln(os.Stderr, "helper started")
+
+	out := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var msg Message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			os.Exit(2)
+		}
+
+		switch mode {
+		case "crash":
+			fmt.Fprintln(os.Stderr, "helper crashed")
+			os.Exit(1)
+		case "hang":
+			time.Sleep(time.Hour)
+		}
+
+		switch msg.Type {
+		case EventVertical:
+			_ = out.Encode(&Message{Type: "name", Name: "www." + msg.Domain})
+			_ = out.Encode(&Message{Type: "records", Name: "mail." + msg.Domain,
+				Records: []Record{{Name: "mail." + msg.Domain, Type: 1, Data: "8.8.8.8"}}})
+			_ = out.Encode(&Message{Type: "name", Name: "www.example.com"})
+			_ = out.Encode(&Message{Type: "association", Domain: msg.Domain, Assoc: "owasp.net"})
+		case EventResolved:
+			_ = out.Encode(&Message{Type: "address", Address: msg.Records[0].Data, Name: msg.Name})
+			_ = out.Encode(&Message{Type: "address", Address: "10.0.0.1", Name: msg.Name})
+		case EventAddress:
+			_ = out.Encode(&Message{Type: "name", Name: "ptr." + msg.Domain})
+		}
+		_ = out.Encode(&Message{Type: "done", ID: msg.ID})
+	}
+}
+
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+func startHelper(t *testing.T, mode string, settings *Settings) (*Source, *syncBuffer) {
+	logs := new(syncBuffer)
+	cfg := config.NewConfig()
+	cfg.Log = log.New(logs, "", 0)
+	cfg.AddDomain("owasp.org")
+	sys := systems.NewSimpleSystem(cfg, nil, nil)
+
+	settings.Command = os.Args[0]
+	settings.Env = []string{helperEnv + "=" + mode}
+	s, err := NewSource("Helper", settings, sys)
+	require.NoError(t, err)
+	require.NoError(t, sys.AddAndStart(s))
+	t.Cleanup(func() { _ = sys.Shutdown() })
+	return s, logs
+}
+
+func collect(t *testing.T, s *Source, num int) []interface{} {
+	var results []interface{}
+
+	timer := time.NewTimer(10 * time.Second)
+	defer timer.Stop()
+	for len(results) < num {
+		select {
+		case req := <-s.Output():
+			results = append(results, req)
+		case <-timer.C:
+			t.Fatalf("The program only provided %d of the %d findings", len(results), num)
+		}
+	}
+	return results
+}
+
+func TestExternalFindings(t *testing.T) {
+	s, logs := startHelper(t, "echo", &Settings{})
+	require.Equal(t, "ext", s.Description())
+
+	s.Input() <- &requests.DNSRequest{Name: "owasp.org", Domain: "owasp.org"}
+	results := collect(t, s, 3)
+	require.Equal(t, &requests.DNSRequest{Name: "www.owasp.org", Domain: "owasp.org"}, results[0])
+	require.Equal(t, &requests.DNSRequest{Name: "mail.owasp.org", Domain: "owasp.org",
+		Records: []requests.DNSAnswer{{Name: "mail.owasp.org", Type: 1, Data: "8.8.8.8"}}}, results[1])
+	// The out of scope name was not sent
+	require.Equal(t, &requests.WhoisRequest{Domain: "owasp.org", NewDomains: []string{"owasp.net"}}, results[2])
+
+	s.Input() <- &requests.ResolvedRequest{Name: "www.owasp.org", Domain: "owasp.org",
+		Records: []requests.DNSAnswer{{Name: "www.owasp.org", Type: 1, Data: "8.8.8.8"}}}
+	s.Input() <- &requests.AddrRequest{Address: "8.8.8.8", Domain: "owasp.org"}
+	results = collect(t, s, 2)
+	// The reserved address was not sent
+	require.Equal(t, &requests.AddrRequest{Address: "8.8.8.8", Domain: "owasp.org"}, results[0])
+	require.Equal(t, &requests.DNSRequest{Name: "ptr.owasp.org", Domain: "owasp.org"}, results[1])
+
+	require.Contains(t, logs.String(), "Helper: helper started")
+}
+
+func TestExternalEvents(t *testing.T) {
+	s, _ := startHelper(t, "echo", &Settings{Events: []string{EventAddress}})
+
+	require.False(t, s.HandlesReq(&requests.DNSRequest{Domain: "owasp.org"}))
+	require.True(t, s.HandlesReq(&requests.AddrRequest{Address: "8.8.8.8"}))
+	require.False(t, s.HandlesReq(&requests.ASNRequest{ASN: 15169}))
+
+	require.Error(t, (&Settings{Command: "prog", Events: []string{"horizontal"}}).Validate())
+	require.Error(t, (&Settings{}).Validate())
+}
+
+func TestExternalRestarts(t *testing.T) {
+	s, logs := startHelper(t, "crash", &Settings{Restarts: 2})
+
+	for i := 0; i < 4; i++ {
+		s.Input() <- &requests.DNSRequest{Domain: "owasp.org"}
+	}
+	require.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "will not be restarted")
+	}, 10*time.Second, 50*time.Millisecond)
+
+	out := logs.String()
+	require.Equal(t, 3, strings.Count(out, "helper crashed"))
+	require.Equal(t, 2, strings.Count(out, "Restarted the program"))
+	require.Contains(t, out, "The program exited unexpectedly: exit status 1")
+}
+
+func TestExternalTimeout(t *testing.T) {
+	s, logs := startHelper(t, "hang", &Settings{Timeout: 1, Restarts: -1})
+
+	s.Input() <- &requests.DNSRequest{Domain: "owasp.org"}
+	s.Input() <- &requests.DNSRequest{Domain: "owasp.org"}
+	require.Eventually(t, func() bool {
+		return strings.Contains(logs.String(), "will not be restarted")
+	}, 10*time.Second, 50*time.Millisecond)
+	require.Contains(t, logs.String(), "did not handle the vertical event within 1 seconds")
+}
+
+func TestExternalMissingProgram(t *testing.T) {
+	cfg := config.NewConfig()
+	sys := systems.NewSimpleSystem(cfg, nil, nil)
+	defer func() { _ = sys.Shutdown() }()
+
+	s, err := NewSource("Missing", &Settings{Command: "/nonexistent/amass-ext-program"}, sys)
+	require.NoError(t, err)
+	require.Error(t, s.Start())
+	require.NoError(t, s.Stop())
+}
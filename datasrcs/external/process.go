@@ -0,0 +1,189 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package external
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// The maximum length of a line written by the program to stdout or stderr.
+const maxLineLength = 1024 * 1024
+
+// The time given to the program to exit after stdin has been closed.
+const stopGracePeriod = 5 * time.Second
+
+type process struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan []byte
+	quit   chan struct{}
+	exited chan struct{}
+	err    error
+}
+
+func (s *Source) startProcess() (*process, error) {
+	cmd := exec.Command(s.settings.Command, s.settings.Args...)
+	cmd.Env = append(os.Environ(), s.settings.Env...)
+	cmd.Dir = s.settings.Dir
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain the program stdin: %v", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain the program stdout: %v", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain the program stderr: %v", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start the program: %v", err)
+	}
+
+	p := &process{
+		cmd:    cmd,
+		stdin:  stdin,
+		lines:  make(chan []byte),
+		quit:   make(chan struct{}),
+		exited: make(chan struct{}),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go s.readStdout(p, stdout, &wg)
+	go s.readStderr(stderr, &wg)
+	go func() {
+		// The pipes must be read completely before waiting on the program
+		wg.Wait()
+		p.err = cmd.Wait()
+		close(p.exited)
+	}()
+	return p, nil
+}
+
+func (s *Source) readStdout(p *process, stdout io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+	defer close(p.lines)
+
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+
+		select {
+		case <-p.quit:
+			return
+		case p.lines <- append([]byte(nil), line...):
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		s.sys.Config().Log.Printf("%s: Failed to read the program output: %v", s.String(), err)
+	}
+}
+
+// readStderr captures the program diagnostics in the Amass log.
+func (s *Source) readStderr(stderr io.Reader, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			s.sys.Config().Log.Printf("%s: %s", s.String(), line)
+		}
+	}
+}
+
+func (p *process) send(msg *Message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = p.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// stop closes stdin and kills the program if it has not exited within the grace period.
+func (p *process) stop(grace time.Duration) {
+	close(p.quit)
+	_ = p.stdin.Close()
+
+	t := time.NewTimer(grace)
+	defer t.Stop()
+
+	select {
+	case <-p.exited:
+	case <-t.C:
+		_ = p.cmd.Process.Kill()
+		<-p.exited
+	}
+}
+
+func (s *Source) stopProcess() {
+	if s.proc != nil {
+		s.proc.stop(stopGracePeriod)
+		s.proc = nil
+	}
+}
+
+func (s *Source) killProcess() {
+	if s.proc != nil {
+		s.proc.stop(0)
+		s.proc = nil
+	}
+}
+
+// crashed cleans up after the program exited without being asked to.
+func (s *Source) crashed() {
+	if s.proc == nil {
+		return
+	}
+
+	p := s.proc
+	p.stop(time.Second)
+	s.proc = nil
+	s.failures++
+
+	if p.err != nil {
+		s.sys.Config().Log.Printf("%s: The program exited unexpectedly: %v", s.String(), p.err)
+	} else {
+		s.sys.Config().Log.Printf("%s: The program exited unexpectedly", s.String())
+	}
+}
+
+// restart runs the program again, unless it has already failed too many times.
+func (s *Source) restart() bool {
+	if s.settings.Restarts < 0 || s.failures > s.settings.Restarts {
+		if !s.abandoned {
+			s.abandoned = true
+			s.sys.Config().Log.Printf("%s: The program failed %d times and will not be restarted", s.String(), s.failures)
+		}
+		return false
+	}
+
+	p, err := s.startProcess()
+	if err != nil {
+		s.sys.Config().Log.Printf("%s: %v", s.String(), err)
+		s.failures++
+		return false
+	}
+
+	s.sys.Config().Log.Printf("%s: Restarted the program", s.String())
+	s.proc = p
+	return true
+}
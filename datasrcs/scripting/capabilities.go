@@ -0,0 +1,256 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package scripting
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+)
+
+// The capabilities that scripts declare in the capabilities table to access the resources of the host.
+const (
+	// CapHTTP allows the request, scrape, crawl and rdap functions
+	CapHTTP = "net.http"
+	// CapSocket allows the socket module and the whois function
+	CapSocket = "net.socket"
+	// CapDNS allows the resolve, reverse_sweep, zone_walk and zone_transfer functions
+	CapDNS = "dns"
+	// CapFSRead allows files to be opened for reading, the mtime function and loading Lua files
+	CapFSRead = "fs.read"
+	// CapFSWrite allows files to be opened for writing and removed or renamed
+	CapFSWrite = "fs.write"
+	// CapExec provides the complete os and io libraries, including the execution of programs
+	CapExec = "exec"
+)
+
+// Capabilities are all the capabilities that can be declared by scripts.
+var Capabilities = []string{CapHTTP, CapSocket, CapDNS, CapFSRead, CapFSWrite, CapExec}
+
+// CapabilityPolicy changes the capabilities granted to a script. Capabilities in Allow are granted
+// even when the script does not declare them, and the capabilities in Deny are never granted.
+type CapabilityPolicy struct {
+	Allow []string `yaml:"allow,omitempty"`
+	Deny  []string `yaml:"deny,omitempty"`
+}
+
+// Validate checks that the policy only contains known capabilities.
+func (p *CapabilityPolicy) Validate() error {
+	for _, c := range append(append([]string(nil), p.Allow...), p.Deny...) {
+		if !knownCapability(c) {
+			return fmt.Errorf("the %s capability is not known", c)
+		}
+	}
+	return nil
+}
+
+func knownCapability(c string) bool {
+	for _, known := range Capabilities {
+		if c == known {
+			return true
+		}
+	}
+	return false
+}
+
+// The parts of the Lua standard library that are only provided when granted.
+type restrictedLibs struct {
+	os       *lua.LTable
+	io       *lua.LTable
+	debug    lua.LValue
+	dofile   lua.LValue
+	loadfile lua.LValue
+	path     lua.LValue
+}
+
+// restrictLuaState removes the access to the host from the Lua standard library, keeping the
+// removed parts so they can be provided when the capabilities are granted.
+func (s *Script) restrictLuaState(L *lua.LState) {
+	pkg, _ := L.GetGlobal("package").(*lua.LTable)
+
+	s.libs = &restrictedLibs{
+		os:       L.GetGlobal("os").(*lua.LTable),
+		io:       L.GetGlobal("io").(*lua.LTable),
+		debug:    L.GetGlobal("debug"),
+		dofile:   L.GetGlobal("dofile"),
+		loadfile: L.GetGlobal("loadfile"),
+	}
+	if pkg != nil {
+		s.libs.path = L.GetField(pkg, "path")
+	}
+	s.installLibs(L, make(map[string]struct{}))
+}
+
+// installLibs provides the parts of the standard library allowed by the granted capabilities.
+func (s *Script) installLibs(L *lua.LState, granted map[string]struct{}) {
+	has := func(c string) bool {
+		_, found := granted[c]
+		return found
+	}
+
+	var osLib, ioLib lua.LValue = lua.LNil, lua.LNil
+	if has(CapExec) {
+		osLib, ioLib = s.libs.os, s.libs.io
+	} else {
+		// The time functions do not provide access to the host
+		tb := L.NewTable()
+		for _, name := range []string{"clock", "date", "difftime", "time"} {
+			tb.RawSetString(name, s.libs.os.RawGetString(name))
+		}
+		if has(CapFSWrite) {
+			for _, name := range []string{"remove", "rename", "tmpname"} {
+				tb.RawSetString(name, s.libs.os.RawGetString(name))
+			}
+		}
+		osLib = tb
+
+		if has(CapFSRead) || has(CapFSWrite) {
+			tb := L.NewTable()
+
+			if open, ok := s.libs.io.RawGetString("open").(*lua.LFunction); ok {
+				tb.RawSetString("open", L.NewFunction(s.ioOpen(open)))
+			}
+			for _, name := range []string{"close", "type"} {
+				tb.RawSetString(name, s.libs.io.RawGetString(name))
+			}
+			if has(CapFSRead) {
+				tb.RawSetString("lines", s.libs.io.RawGetString("lines"))
+			}
+			ioLib = tb
+		}
+	}
+
+	L.SetGlobal("os", osLib)
+	L.SetGlobal("io", ioLib)
+	// The debug library can reach the values removed from the environment
+	L.SetGlobal("debug", lua.LNil)
+	if has(CapExec) {
+		L.SetGlobal("debug", s.libs.debug)
+	}
+
+	var dofile, loadfile, path lua.LValue = lua.LNil, lua.LNil, lua.LString("")
+	if has(CapFSRead) {
+		dofile, loadfile, path = s.libs.dofile, s.libs.loadfile, s.libs.path
+	}
+	L.SetGlobal("dofile", dofile)
+	L.SetGlobal("loadfile", loadfile)
+
+	if pkg, ok := L.GetGlobal("package").(*lua.LTable); ok {
+		L.SetField(pkg, "path", path)
+		L.SetField(pkg, "cpath", lua.LString(""))
+		// The libraries can also be obtained using require
+		if loaded, ok := L.GetField(pkg, "loaded").(*lua.LTable); ok {
+			L.SetField(loaded, "os", osLib)
+			L.SetField(loaded, "io", ioLib)
+			L.SetField(loaded, "debug", L.GetGlobal("debug"))
+		}
+	}
+}
+
+// ioOpen only opens the files in the modes allowed by the granted capabilities.
+func (s *Script) ioOpen(open *lua.LFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		need := CapFSRead
+		if mode := L.OptString(2, "r"); strings.ContainsAny(mode, "wa+") {
+			need = CapFSWrite
+		}
+		if !s.allowed(need) {
+			L.RaiseError("the %s capability was not granted to %s", need, s.String())
+			return 0
+		}
+
+		top := L.GetTop()
+		L.Push(open)
+		for i := 1; i <= top; i++ {
+			L.Push(L.Get(i))
+		}
+		L.Call(top, lua.MultRet)
+		return L.GetTop() - top
+	}
+}
+
+// gated returns a function that raises an error unless the capability was granted to the script.
+func (s *Script) gated(capability string, fn lua.LGFunction) lua.LGFunction {
+	return func(L *lua.LState) int {
+		if !s.allowed(capability) {
+			L.RaiseError("the %s capability was not granted to %s", capability, s.String())
+			return 0
+		}
+		return fn(L)
+	}
+}
+
+func (s *Script) allowed(capability string) bool {
+	s.capsLock.Lock()
+	defer s.capsLock.Unlock()
+
+	_, found := s.granted[capability]
+	return found
+}
+
+// scriptCapabilities returns the capabilities declared by the script in the capabilities table.
+func (s *Script) scriptCapabilities() ([]string, error) {
+	var caps []string
+
+	lv := s.luaState.GetGlobal("capabilities")
+	if lv.Type() == lua.LTNil {
+		return caps, nil
+	}
+
+	tb, ok := lv.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("the capabilities field must be a table")
+	}
+
+	var err error
+	tb.ForEach(func(_, v lua.LValue) {
+		c := strings.ToLower(strings.TrimSpace(v.String()))
+		if !knownCapability(c) {
+			err = fmt.Errorf("the %s capability is not known", v.String())
+			return
+		}
+		caps = append(caps, c)
+	})
+	return caps, err
+}
+
+// SetCapabilityPolicy changes the capabilities granted to the script from those it declares.
+// It must be called before the script begins handling requests.
+func (s *Script) SetCapabilityPolicy(p *CapabilityPolicy) {
+	granted := make(map[string]struct{})
+
+	for _, c := range s.declared {
+		granted[c] = struct{}{}
+	}
+	if p != nil {
+		for _, c := range p.Allow {
+			granted[c] = struct{}{}
+		}
+		for _, c := range p.Deny {
+			delete(granted, c)
+		}
+	}
+
+	s.capsLock.Lock()
+	s.granted = granted
+	s.capsLock.Unlock()
+
+	s.installLibs(s.luaState, granted)
+}
+
+// Capabilities returns the capabilities granted to the script.
+func (s *Script) Capabilities() []string {
+	s.capsLock.Lock()
+	defer s.capsLock.Unlock()
+
+	var caps []string
+	for c := range s.granted {
+		caps = append(caps, c)
+	}
+	sort.Strings(caps)
+	return caps
+}
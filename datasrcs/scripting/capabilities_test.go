@@ -0,0 +1,153 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package scripting
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/owasp-amass/config/config"
+	"github.com/stretchr/testify/require"
+)
+
+func newCapabilityScript(t *testing.T, declared string) *Script {
+	sys := newMockSystem(config.NewConfig())
+	require.NotNil(t, sys)
+	t.Cleanup(func() { _ = sys.Shutdown() })
+
+	s := NewScript(`
+		name="capabilities"
+		type="testing"
+		`+declared+`
+	`, sys)
+	require.NotNil(t, s)
+	return s
+}
+
+func TestCapabilitiesDeniedByDefault(t *testing.T) {
+	s := newCapabilityScript(t, "")
+	L := s.luaState
+	require.Empty(t, s.Capabilities())
+
+	for _, code := range []string{
+		`assert(io == nil and debug == nil and dofile == nil and loadfile == nil)`,
+		`assert(os.execute == nil and os.getenv == nil and os.remove == nil and os.exit == nil)`,
+		`assert(os.time() > 0 and os.date ~= nil and os.clock ~= nil and os.difftime ~= nil)`,
+		`assert(require("os").execute == nil and package.loaded.io == nil)`,
+		`assert(package.path == "")`,
+	} {
+		require.NoError(t, L.DoString(code), code)
+	}
+
+	for code, capability := range map[string]string{
+		`request(nil, {url="http://127.0.0.1/"})`:                   CapHTTP,
+		`crawl(nil, "http://127.0.0.1/", 1)`:                        CapHTTP,
+		`rdap(nil, "8.8.8.8")`:                                      CapHTTP,
+		`socket.connect(nil, "127.0.0.1", 43, "tcp")`:               CapSocket,
+		`whois(nil, "owasp.org")`:                                   CapSocket,
+		`resolve(nil, "owasp.org", "A")`:                            CapDNS,
+		`zone_transfer(nil, "owasp.org", "owasp.org", "127.0.0.1")`: CapDNS,
+		`mtime("/etc/hosts")`:                                       CapFSRead,
+	} {
+		err := L.DoString(code)
+		require.Error(t, err, code)
+		require.Contains(t, err.Error(), "the "+capability+" capability was not granted", code)
+	}
+}
+
+func TestCapabilitiesFiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	require.NoError(t, os.WriteFile(path, []byte("owasp"), 0600))
+
+	read := `
+		local f = assert(io.open("` + path + `", "r"))
+		assert(f:read("*a") == "owasp")
+		f:close()
+	`
+	write := `
+		local f = assert(io.open("` + path + `", "w"))
+		f:write("amass")
+		f:close()
+	`
+
+	s := newCapabilityScript(t, `capabilities={"fs.read"}`)
+	require.Equal(t, []string{CapFSRead}, s.Capabilities())
+	require.NoError(t, s.luaState.DoString(read))
+	require.Error(t, s.luaState.DoString(write))
+	require.Error(t, s.luaState.DoString(`os.remove("`+path+`")`))
+
+	// The policy can grant the capabilities that were not declared
+	s.SetCapabilityPolicy(&CapabilityPolicy{Allow: []string{CapFSWrite}})
+	require.NoError(t, s.luaState.DoString(write))
+	require.NoError(t, s.luaState.DoString(`assert(os.execute == nil and io.popen == nil)`))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "amass", string(data))
+
+	// The policy can deny the capabilities that were declared
+	s.SetCapabilityPolicy(&CapabilityPolicy{Deny: []string{CapFSRead}})
+	require.Empty(t, s.Capabilities())
+	require.Error(t, s.luaState.DoString(read))
+}
+
+func TestCapabilitiesExec(t *testing.T) {
+	s := newCapabilityScript(t, `capabilities = {"exec", "net.http"}`)
+
+	require.Equal(t, []string{CapExec, CapHTTP}, s.Capabilities())
+	require.NoError(t, s.luaState.DoString(`assert(os.execute ~= nil and io.popen ~= nil and os.getenv ~= nil)`))
+}
+
+func TestCapabilitiesUnknown(t *testing.T) {
+	sys := newMockSystem(config.NewConfig())
+	require.NotNil(t, sys)
+	defer func() { _ = sys.Shutdown() }()
+
+	require.Nil(t, NewScript(`
+		name="capabilities"
+		type="testing"
+		capabilities={"net.raw"}
+	`, sys))
+	require.Nil(t, NewScript(`
+		name="capabilities"
+		type="testing"
+		capabilities="net.http"
+	`, sys))
+
+	require.NoError(t, (&CapabilityPolicy{Allow: []string{CapDNS}, Deny: []string{CapExec}}).Validate())
+	require.Error(t, (&CapabilityPolicy{Deny: []string{"net.raw"}}).Validate())
+}
+
+func TestScriptExternal(t *testing.T) {
+	s := newCapabilityScript(t, `
+		capabilities={"exec"}
+		ext={
+			command="/usr/local/bin/tool",
+			args={"-silent", "-json"},
+			timeout=30,
+			events={"vertical", "address"},
+		}
+	`)
+	defer s.Release()
+
+	ext := s.External()
+	require.NotNil(t, ext)
+	require.Equal(t, "/usr/local/bin/tool", ext.Command)
+	require.Equal(t, []string{"-silent", "-json"}, ext.Args)
+	require.Equal(t, 30, ext.Timeout)
+	require.Equal(t, []string{"vertical", "address"}, ext.Events)
+
+	require.Nil(t, newCapabilityScript(t, "").External())
+
+	sys := newMockSystem(config.NewConfig())
+	require.NotNil(t, sys)
+	defer func() { _ = sys.Shutdown() }()
+	require.Nil(t, NewScript(`
+		name="capabilities"
+		type="ext"
+		ext={args={"-json"}}
+	`, sys))
+}
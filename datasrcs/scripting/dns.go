@@ -10,15 +10,15 @@ import (
 	"fmt"
 	"net"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/miekg/dns"
 	amassnet "github.com/owasp-amass/amass/v4/net"
 	amassdns "github.com/owasp-amass/amass/v4/net/dns"
 	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/owasp-amass/amass/v4/systems"
 	"github.com/owasp-amass/resolve"
-	bf "github.com/tylertreat/BoomFilters"
 	lua "github.com/yuin/gopher-lua"
 	"golang.org/x/net/publicsuffix"
 )
@@ -26,21 +26,8 @@ import (
 const (
 	defaultSweepSize = 250
 	activeSweepSize  = 500
-	maxSweepSize     = 1000
 )
 
-var (
-	sweepLock   sync.Mutex
-	sweepMaxCh  chan struct{}         = make(chan struct{}, maxSweepSize)
-	sweepFilter *bf.StableBloomFilter = bf.NewDefaultStableBloomFilter(1000000, 0.01)
-)
-
-func init() {
-	for i := 0; i < maxSweepSize; i++ {
-		sweepMaxCh <- struct{}{}
-	}
-}
-
 // Wrapper so that scripts can make DNS queries.
 func (s *Script) resolve(L *lua.LState) int {
 	ctx, err := extractContext(L.CheckUserData(1))
@@ -108,7 +95,7 @@ func (s *Script) fwdQuery(ctx context.Context, name string, qtype uint16) (*dns.
 	return resp, err
 }
 
-func (s *Script) dnsQuery(ctx context.Context, msg *dns.Msg, r *resolve.Resolvers, attempts int) (*dns.Msg, error) {
+func (s *Script) dnsQuery(ctx context.Context, msg *dns.Msg, r *resolvers.Pool, attempts int) (*dns.Msg, error) {
 	for num := 0; num < attempts; num++ {
 		select {
 		case <-ctx.Done():
@@ -198,6 +185,8 @@ func (s *Script) reverseSweep(L *lua.LState) int {
 	}
 
 	var count int
+	// The addresses swept by the other data sources of the System are skipped
+	sweeps := s.sys.Sweeps()
 	for _, ip := range amassnet.CIDRSubset(cidr, addr, size) {
 		select {
 		case <-ctx.Done():
@@ -206,21 +195,19 @@ func (s *Script) reverseSweep(L *lua.LState) int {
 		default:
 		}
 
-		sweepLock.Lock()
-		if a := ip.String(); !sweepFilter.TestAndAdd([]byte(a)) {
+		if a := ip.String(); sweeps.Add(a) {
 			count++
-			<-sweepMaxCh
-			go s.getPTR(ctx, a, sweepMaxCh)
+			sweeps.Acquire()
+			go s.getPTR(ctx, a, sweeps)
 		}
-		sweepLock.Unlock()
 	}
 
 	L.Push(lua.LNil)
 	return 1
 }
 
-func (s *Script) getPTR(ctx context.Context, addr string, ch chan struct{}) {
-	defer func() { ch <- struct{}{} }()
+func (s *Script) getPTR(ctx context.Context, addr string, sweeps *systems.Sweeps) {
+	defer sweeps.Release()
 
 	if reserved, _ := amassnet.IsReservedAddress(addr); reserved {
 		return
@@ -270,8 +257,10 @@ func (s *Script) zoneWalk(L *lua.LState) int {
 		return 1
 	}
 
-	r := resolve.NewResolvers()
+	r := resolvers.NewPool()
 	r.SetLogger(s.sys.Config().Log)
+	// The queries are sent over TCP through the proxy when the System uses one
+	r.SetDialer(s.sys.Dialer())
 	_ = r.AddResolvers(15, server)
 	defer r.Stop()
 
@@ -326,7 +315,7 @@ func (s *Script) wrapZoneTransfer(L *lua.LState) int {
 	}
 
 	tb := L.NewTable()
-	if reqs, err := ZoneTransfer(ctx, name, domain, server); err == nil && len(reqs) > 0 {
+	if reqs, err := ZoneTransfer(ctx, s.sys.Dialer(), name, domain, server); err == nil && len(reqs) > 0 {
 		for _, req := range reqs {
 			for _, rr := range req.Records {
 				entry := L.NewTable()
@@ -354,7 +343,8 @@ func (s *Script) wrapZoneTransfer(L *lua.LState) int {
 
 // ZoneTransfer attempts a DNS zone transfer using the provided server.
 // The returned slice contains all the records discovered from the zone transfer.
-func ZoneTransfer(ctx context.Context, sub, domain, server string) ([]*requests.DNSRequest, error) {
+// The connection to the server is made by the provided Dialer.
+func ZoneTransfer(ctx context.Context, d *amassnet.Dialer, sub, domain, server string) ([]*requests.DNSRequest, error) {
 	timeout := 15 * time.Second
 	var results []*requests.DNSRequest
 
@@ -363,7 +353,7 @@ func ZoneTransfer(ctx context.Context, sub, domain, server string) ([]*requests.
 	defer cancel()
 
 	addr := net.JoinHostPort(server, "53")
-	conn, err := amassnet.DialContext(tctx, "tcp", addr)
+	conn, err := d.DialContext(tctx, "tcp", addr)
 	if err != nil {
 		return results, fmt.Errorf("zone xfr error: Failed to obtain TCP connection to [%s]: %v", addr, err)
 	}
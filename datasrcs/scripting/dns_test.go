@@ -5,10 +5,15 @@
 package scripting
 
 import (
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/owasp-amass/amass/v4/amasstest"
+	amassnet "github.com/owasp-amass/amass/v4/net"
 	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/config/config"
+	"github.com/stretchr/testify/require"
 )
 
 func TestResolve(t *testing.T) {
@@ -16,6 +21,7 @@ func TestResolve(t *testing.T) {
 	script, sys := setupMockScriptEnv(`
 		name="resolve"
 		type="testing"
+		capabilities={"dns"}
 
 		function vertical(ctx, domain)
 			local tests = {
@@ -47,7 +53,7 @@ func TestResolve(t *testing.T) {
 	sys.Config().AddDomain("owasp.org")
 	script.Input() <- &requests.DNSRequest{Domain: "owasp.org"}
 
-	timer := time.NewTimer(2 * time.Minute)
+	timer := time.NewTimer(30 * time.Second)
 	defer timer.Stop()
 loop:
 	for _, name := range expected {
@@ -57,8 +63,72 @@ loop:
 			break loop
 		case req := <-script.Output():
 			if ans, ok := req.(*requests.DNSRequest); !ok || ans.Name != name {
-				t.Error("Failed")
+				t.Errorf("Failed: %v", req)
 			}
 		}
 	}
 }
+
+func TestZoneWalkProxied(t *testing.T) {
+	target, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	defer target.Close()
+	require.NoError(t, target.LoadZoneString("owasp.org", `
+@	IN	NSEC	mail.owasp.org. A NSEC
+mail	IN	NSEC	www.owasp.org. A NSEC
+www	IN	NSEC	owasp.org. A NSEC
+`))
+
+	proxy, err := amasstest.NewSOCKS5Server()
+	require.NoError(t, err)
+	defer proxy.Close()
+
+	sys, ok := newMockSystem(config.NewConfig()).(*mockSystem)
+	require.True(t, ok)
+	defer func() { _ = sys.Shutdown() }()
+	sys.Dial = amassnet.NewDialer()
+	require.NoError(t, sys.Dial.SetProxy(proxy.URL()))
+
+	script := NewScript(fmt.Sprintf(`
+		name="walk"
+		type="testing"
+		capabilities={"dns"}
+
+		function vertical(ctx, domain)
+			local err = zone_walk(ctx, domain, "%s")
+			if (err ~= nil and err ~= "") then
+				log(ctx, err)
+			end
+			new_name(ctx, "done." .. domain)
+		end
+	`, target.Addr()), sys)
+	require.NotNil(t, script)
+	require.NoError(t, sys.AddAndStart(script))
+
+	sys.Config().AddDomain("owasp.org")
+	script.Input() <- &requests.DNSRequest{Domain: "owasp.org"}
+
+	var names []string
+	timer := time.NewTimer(15 * time.Second)
+	defer timer.Stop()
+loop:
+	for {
+		select {
+		case <-timer.C:
+			t.Fatal("the test timed out")
+		case msg := <-script.Output():
+			if req, ok := msg.(*requests.DNSRequest); ok {
+				if req.Name == "done.owasp.org" {
+					break loop
+				}
+				names = append(names, req.Name)
+			}
+		}
+	}
+
+	require.ElementsMatch(t, []string{"mail.owasp.org", "www.owasp.org", "owasp.org"}, names)
+	// The walk must not send any datagram around the proxy
+	require.Zero(t, target.Queries("udp"))
+	require.NotZero(t, target.Queries("tcp"))
+	require.Contains(t, proxy.Destinations(), target.Addr())
+}
@@ -0,0 +1,78 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package scripting
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/owasp-amass/amass/v4/datasrcs/external"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// scriptExternal returns the external program declared by the script in the ext table.
+func (s *Script) scriptExternal() (*external.Settings, error) {
+	L := s.luaState
+
+	lv := L.GetGlobal("ext")
+	if lv.Type() == lua.LTNil {
+		return nil, nil
+	}
+
+	tb, ok := lv.(*lua.LTable)
+	if !ok {
+		return nil, errors.New("the ext field must be a table")
+	}
+
+	var settings external.Settings
+	settings.Command, _ = getStringField(L, tb, "command")
+	settings.Dir, _ = getStringField(L, tb, "dir")
+	if n, ok := getNumberField(L, tb, "timeout"); ok {
+		settings.Timeout = int(n)
+	}
+	if n, ok := getNumberField(L, tb, "restarts"); ok {
+		settings.Restarts = int(n)
+	}
+
+	for key, list := range map[string]*[]string{
+		"args":   &settings.Args,
+		"env":    &settings.Env,
+		"events": &settings.Events,
+	} {
+		lv := L.GetField(tb, key)
+		if lv.Type() == lua.LTNil {
+			continue
+		}
+
+		t, ok := lv.(*lua.LTable)
+		if !ok {
+			return nil, fmt.Errorf("the ext %s field must be a table", key)
+		}
+		t.ForEach(func(_, v lua.LValue) {
+			*list = append(*list, v.String())
+		})
+	}
+
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// External returns the external program declared by the script, or nil when the
+// script handles the events itself.
+func (s *Script) External() *external.Settings {
+	return s.ext
+}
+
+// Release frees the resources of a script that will not be started.
+func (s *Script) Release() {
+	s.cancel()
+
+	if s.luaState != nil {
+		s.luaState.Close()
+		s.luaState = nil
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package scripting
+
+import (
+	"context"
+	"fmt"
+	nethttp "net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/owasp-amass/amass/v4/net/http"
+)
+
+// HTTPFixture is a recorded HTTP exchange that is replayed in place of a web request.
+type HTTPFixture struct {
+	Method       string      `yaml:"method,omitempty"`
+	URL          string      `yaml:"url"`
+	Body         string      `yaml:"body,omitempty"`
+	Status       int         `yaml:"status,omitempty"`
+	Header       http.Header `yaml:"header,omitempty"`
+	Response     string      `yaml:"response,omitempty"`
+	ResponseFile string      `yaml:"response_file,omitempty"`
+}
+
+// FixtureClient is a WebClient that answers script requests from HTTP fixtures without network access.
+type FixtureClient struct {
+	sync.Mutex
+	fixtures  []*HTTPFixture
+	recorded  *http.Fixtures
+	unmatched []string
+}
+
+// NewFixtureClient returns a FixtureClient replaying the provided fixtures. The response body of
+// each fixture must already be loaded into the Response field. Requests not matching any of the
+// fixtures are served from the recorded exchanges, when they are provided.
+func NewFixtureClient(fixtures []*HTTPFixture, recorded *http.Fixtures) *FixtureClient {
+	return &FixtureClient{
+		fixtures: fixtures,
+		recorded: recorded,
+	}
+}
+
+// Unmatched returns the requests made since the last call that no fixture was available for.
+func (fc *FixtureClient) Unmatched() []string {
+	fc.Lock()
+	defer fc.Unlock()
+
+	reqs := fc.unmatched
+	fc.unmatched = nil
+	return reqs
+}
+
+func (fc *FixtureClient) find(method, u, body string) *HTTPFixture {
+	for _, f := range fc.fixtures {
+		m := f.Method
+		if m == "" {
+			m = "GET"
+		}
+
+		if strings.EqualFold(m, method) && f.URL == u && (f.Body == "" || f.Body == body) {
+			return f
+		}
+	}
+	return nil
+}
+
+// RequestWebPage implements the WebClient interface.
+func (fc *FixtureClient) RequestWebPage(ctx context.Context, r *http.Request) (*http.Response, error) {
+	method := r.Method
+	if method == "" {
+		method = "GET"
+	}
+
+	if f := fc.find(method, r.URL, r.Body); f != nil {
+		return fixtureResponse(f), nil
+	}
+	if fc.recorded != nil {
+		if resp, err := fc.recorded.RequestWebPage(ctx, r); err == nil {
+			return resp, nil
+		}
+	}
+
+	fc.Lock()
+	fc.unmatched = append(fc.unmatched, method+" "+r.URL)
+	fc.Unlock()
+	return nil, fmt.Errorf("no HTTP fixture matches the %s request for %s", method, r.URL)
+}
+
+func fixtureResponse(f *HTTPFixture) *http.Response {
+	code := f.Status
+	if code == 0 {
+		code = 200
+	}
+
+	hdr := make(http.Header)
+	raw := make(map[string][]string)
+	for k, v := range f.Header {
+		hdr[k] = v
+		raw[k] = []string{v}
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%d %s", code, nethttp.StatusText(code)),
+		StatusCode: code,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     hdr,
+		RawHeader:  raw,
+		Body:       f.Response,
+		Length:     int64(len(f.Response)),
+		URL:        f.URL,
+	}
+}
+
+// Crawl implements the WebClient interface by following the links found in the fixture
+// responses, beginning with the provided URL and staying within the scope.
+func (fc *FixtureClient) Crawl(ctx context.Context, u string, scope []string, max int, callback func(*http.Request, *http.Response)) error {
+	queue := []string{u}
+	visited := map[string]struct{}{u: {}}
+
+	// Be sure the crawl does not exceed the maximum links to be followed
+	for count := 0; len(queue) > 0 && (max <= 0 || count < max); count++ {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the context expired")
+		default:
+		}
+
+		cur := queue[0]
+		queue = queue[1:]
+
+		resp, err := fc.RequestWebPage(ctx, &http.Request{URL: cur, Method: "GET"})
+		if err != nil {
+			continue
+		}
+
+		for _, link := range fixtureLinks(cur, resp.Body) {
+			if _, found := visited[link]; found {
+				continue
+			}
+			if lu, err := url.Parse(link); err != nil || !inCrawlScope(lu.Hostname(), scope) {
+				continue
+			}
+
+			visited[link] = struct{}{}
+			queue = append(queue, link)
+		}
+
+		callback(&http.Request{URL: cur, Method: "GET"}, resp)
+	}
+	return nil
+}
+
+func fixtureLinks(base, body string) []string {
+	bu, err := url.Parse(base)
+	if err != nil {
+		return nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(body))
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	doc.Find("a, area, form, frame, iframe, link, script, img").Each(func(i int, s *goquery.Selection) {
+		for _, attr := range []string{"href", "src", "action"} {
+			if v, ok := s.Attr(attr); ok {
+				if u, err := bu.Parse(v); err == nil {
+					u.Fragment = ""
+					links = append(links, u.String())
+				}
+			}
+		}
+	})
+	return links
+}
+
+func inCrawlScope(host string, scope []string) bool {
+	host = strings.ToLower(host)
+
+	for _, d := range scope {
+		d = strings.ToLower(d)
+
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
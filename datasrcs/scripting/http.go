@@ -6,6 +6,7 @@ package scripting
 
 import (
 	"context"
+	"errors"
 	"net/url"
 	"strings"
 	"time"
@@ -15,6 +16,27 @@ import (
 	lua "github.com/yuin/gopher-lua"
 )
 
+// WebClient performs the HTTP requests and crawls made on behalf of a script.
+type WebClient interface {
+	RequestWebPage(ctx context.Context, r *http.Request) (*http.Response, error)
+	Crawl(ctx context.Context, u string, scope []string, max int, callback func(*http.Request, *http.Response)) error
+}
+
+// SetWebClient replaces the client used for the HTTP requests and crawls made by the script.
+// The web client of the System is used when the client is nil. It must be called before the
+// script begins handling requests.
+func (s *Script) SetWebClient(c WebClient) {
+	s.web = c
+}
+
+// WebClient returns the client used for the HTTP requests and crawls made by the script.
+func (s *Script) WebClient() WebClient {
+	if s.web == nil {
+		return s.sys.WebClient()
+	}
+	return s.web
+}
+
 // Wrapper that allows scripts to make HTTP client requests.
 func (s *Script) request(L *lua.LState) int {
 	ctx, err := extractContext(L.CheckUserData(1))
@@ -31,37 +53,14 @@ func (s *Script) request(L *lua.LState) int {
 		return 2
 	}
 
-	url, found := getStringField(L, opt, "url")
-	if !found {
+	r, err := requestFromTable(L, opt)
+	if err != nil {
 		L.Push(lua.LNil)
-		L.Push(lua.LString("No URL found in the parameters"))
+		L.Push(lua.LString(err.Error()))
 		return 2
 	}
 
-	var hdr http.Header
-	if lv := L.GetField(opt, "header"); lv != nil {
-		if tbl, ok := lv.(*lua.LTable); ok {
-			hdr = make(http.Header)
-			tbl.ForEach(func(k, v lua.LValue) {
-				hdr[k.String()] = v.String()
-			})
-		}
-	}
-
-	var body string
-	if method, ok := getStringField(L, opt, "method"); ok && strings.ToLower(method) == "post" {
-		if d, ok := getStringField(L, opt, "body"); ok {
-			body = d
-		}
-	}
-
-	id, _ := getStringField(L, opt, "id")
-	pass, _ := getStringField(L, opt, "pass")
-	resp, err := s.req(ctx, url, body, hdr, &http.BasicAuth{
-		Username: id,
-		Password: pass,
-	})
-
+	resp, err := s.req(ctx, r)
 	if err != nil || resp == nil {
 		L.Push(lua.LNil)
 		estr := "no HTTP response"
@@ -91,8 +90,21 @@ func responseToTable(L *lua.LState, resp *http.Response) *lua.LTable {
 	}
 	r.RawSetString("header", hdrs)
 
+	raw := L.NewTable()
+	for k, vals := range resp.RawHeader {
+		values := L.NewTable()
+
+		for _, v := range vals {
+			values.Append(lua.LString(v))
+		}
+		raw.RawSetString(k, values)
+	}
+	r.RawSetString("headers", raw)
+
 	r.RawSetString("body", lua.LString(resp.Body))
+	r.RawSetString("truncated", lua.LBool(resp.Truncated))
 	r.RawSetString("length", lua.LNumber(resp.Length))
+	r.RawSetString("url", lua.LString(resp.URL))
 
 	if resp.TLS != nil {
 		tls := L.NewTable()
@@ -144,37 +156,14 @@ func (s *Script) scrape(L *lua.LState) int {
 		return 1
 	}
 
-	url, found := getStringField(L, opt, "url")
-	if !found {
+	r, err := requestFromTable(L, opt)
+	if err != nil {
 		L.Push(lua.LFalse)
 		return 1
 	}
 
-	var hdr http.Header
-	if lv := L.GetField(opt, "header"); lv != nil {
-		if tbl, ok := lv.(*lua.LTable); ok {
-			hdr = make(http.Header)
-			tbl.ForEach(func(k, v lua.LValue) {
-				hdr[k.String()] = v.String()
-			})
-		}
-	}
-
-	var body string
-	if method, ok := getStringField(L, opt, "method"); ok && strings.ToLower(method) == "post" {
-		if d, ok := getStringField(L, opt, "body"); ok {
-			body = d
-		}
-	}
-
-	id, _ := getStringField(L, opt, "id")
-	pass, _ := getStringField(L, opt, "pass")
-
 	sucess := lua.LFalse
-	if resp, err := s.req(ctx, url, body, hdr, &http.BasicAuth{
-		Username: id,
-		Password: pass,
-	}); err == nil {
+	if resp, err := s.req(ctx, r); err == nil {
 		if resp != nil && resp.StatusCode >= 200 && resp.StatusCode < 400 {
 			if num := s.internalSendNames(ctx, resp.Body); num > 0 {
 				sucess = lua.LTrue
@@ -188,28 +177,73 @@ func (s *Script) scrape(L *lua.LState) int {
 	return 1
 }
 
-func (s *Script) req(ctx context.Context, url, data string, hdr http.Header, auth *http.BasicAuth) (*http.Response, error) {
-	method := "GET"
-	if data != "" {
-		method = "POST"
+// Builds the HTTP request from the table of options provided by the script.
+func requestFromTable(L *lua.LState, opt *lua.LTable) (*http.Request, error) {
+	u, found := getStringField(L, opt, "url")
+	if !found || u == "" {
+		return nil, errors.New("No URL found in the parameters")
 	}
 
+	r := &http.Request{URL: u}
+	// The 'headers' and 'data' field names are accepted for compatibility with older scripts
+	for _, field := range []string{"header", "headers"} {
+		if tbl, ok := L.GetField(opt, field).(*lua.LTable); ok {
+			r.Header = make(http.Header)
+			tbl.ForEach(func(k, v lua.LValue) {
+				r.Header[k.String()] = v.String()
+			})
+			break
+		}
+	}
+
+	if body, ok := getStringField(L, opt, "body"); ok {
+		r.Body = body
+	} else if data, ok := getStringField(L, opt, "data"); ok {
+		r.Body = data
+	}
+	if method, ok := getStringField(L, opt, "method"); ok && method != "" {
+		r.Method = strings.ToUpper(method)
+	} else if r.Body != "" {
+		r.Method = "POST"
+	} else {
+		r.Method = "GET"
+	}
+
+	id, _ := getStringField(L, opt, "id")
+	pass, _ := getStringField(L, opt, "pass")
+	r.Auth = &http.BasicAuth{
+		Username: id,
+		Password: pass,
+	}
+
+	if follow, ok := getBoolField(L, opt, "follow_redirects"); ok {
+		r.NoRedirects = !follow
+	}
+	if max, ok := getNumberField(L, opt, "max_body"); ok && max > 0 {
+		r.MaxBodySize = int64(max)
+	}
+	if secs, ok := getNumberField(L, opt, "timeout"); ok && secs > 0 {
+		r.Timeout = time.Duration(secs * float64(time.Second))
+	}
+	return r, nil
+}
+
+func (s *Script) req(ctx context.Context, r *http.Request) (*http.Response, error) {
 	numRateLimitChecks(s, s.seconds)
-	ctx, cancel := context.WithTimeout(ctx, 20*time.Second)
+
+	timeout := 20 * time.Second
+	if r.Timeout > 0 {
+		timeout = r.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	resp, err := http.RequestWebPage(ctx, &http.Request{
-		URL:    url,
-		Method: method,
-		Header: hdr,
-		Body:   data,
-		Auth:   auth,
-	})
+	resp, err := s.WebClient().RequestWebPage(ctx, r)
 	if err != nil {
 		cfg := s.sys.Config()
 
 		if cfg.Verbose {
-			cfg.Log.Printf("%s: %s: %v", s.String(), url, err)
+			cfg.Log.Printf("%s: %s: %v", s.String(), r.URL, err)
 		}
 	}
 	return resp, err
@@ -232,7 +266,7 @@ func (s *Script) crawl(L *lua.LState) int {
 	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
 	defer cancel()
 
-	err = http.Crawl(ctx, u, cfg.Domains(), max, func(req *http.Request, resp *http.Response) {
+	err = s.WebClient().Crawl(ctx, u, cfg.Domains(), max, func(req *http.Request, resp *http.Response) {
 		if u, err := url.Parse(req.URL); err == nil {
 			s.newNameWithContext(ctx, http.CleanName(u.Hostname()))
 		}
@@ -0,0 +1,116 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package scripting
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caffix/stringset"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/put":
+			body, _ := io.ReadAll(r.Body)
+			if r.Method != http.MethodPut || string(body) != `{"q":"owasp.org"}` {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Add("Link", `<https://api.owasp.org/?page=2>; rel="next"`)
+			w.Header().Add("Link", `<https://api.owasp.org/?page=9>; rel="last"`)
+			w.WriteHeader(http.StatusCreated)
+		case "/search":
+			body, _ := io.ReadAll(r.Body)
+			if r.Method == http.MethodGet && string(body) == `{"q":"owasp.org"}` {
+				fmt.Fprint(w, "found")
+			}
+		case "/redirect":
+			http.Redirect(w, r, "/final", http.StatusFound)
+		case "/big":
+			fmt.Fprint(w, strings.Repeat("a", 100))
+		default:
+			fmt.Fprint(w, "final")
+		}
+	}))
+	defer ts.Close()
+
+	s, sys := setupMockScriptEnv(`
+		name="request"
+		type="testing"
+		capabilities={"net.http"}
+
+		function vertical(ctx, domain)
+			local base = "` + ts.URL + `"
+			local resp, err = request(ctx, {
+				['url']=base .. "/put",
+				['method']="put",
+				['body']='{"q":"owasp.org"}',
+				['header']={['Content-Type']="application/json"},
+			})
+			if (err == nil and resp.status_code == 201 and #resp.headers['Link'] == 2) then
+				new_name(ctx, "put." .. domain)
+			end
+
+			resp, err = request(ctx, {['url']=base .. "/search", ['method']="GET", ['body']='{"q":"owasp.org"}'})
+			if (err == nil and resp.body == "found") then
+				new_name(ctx, "get.body." .. domain)
+			end
+
+			resp, err = request(ctx, {['url']=base .. "/redirect", ['follow_redirects']=false})
+			if (err == nil and resp.status_code == 302) then
+				new_name(ctx, "noredirect." .. domain)
+			end
+
+			resp, err = request(ctx, {['url']=base .. "/redirect", ['timeout']=5})
+			if (err == nil and resp.url == base .. "/final" and resp.body == "final") then
+				new_name(ctx, "final." .. domain)
+			end
+
+			resp, err = request(ctx, {['url']=base .. "/big", ['max_body']=10})
+			if (err == nil and resp.truncated and #resp.body == 10) then
+				new_name(ctx, "truncated." .. domain)
+			end
+
+			resp, err = request(ctx, {['url']=base .. "/final", ['method']="HEAD"})
+			if (err == nil and resp.status_code == 200 and resp.body == "") then
+				new_name(ctx, "head." .. domain)
+			end
+		end
+	`)
+	require.NotNil(t, s, "Failed to initialize the scripting environment")
+	defer func() { _ = sys.Shutdown() }()
+
+	domain := "owasp.org"
+	sys.Config().AddDomain(domain)
+	s.Input() <- &requests.DNSRequest{Domain: domain}
+
+	expected := []string{"put.owasp.org", "get.body.owasp.org", "noredirect.owasp.org",
+		"final.owasp.org", "truncated.owasp.org", "head.owasp.org"}
+	got := stringset.New()
+	defer got.Close()
+
+	timer := time.NewTimer(10 * time.Second)
+	defer timer.Stop()
+	for got.Len() < len(expected) {
+		select {
+		case req := <-s.Output():
+			if dns, ok := req.(*requests.DNSRequest); ok {
+				got.Insert(dns.Name)
+			}
+		case <-timer.C:
+			t.Fatalf("The script only provided the following names: %v", got.Slice())
+		}
+	}
+	require.ElementsMatch(t, expected, got.Slice())
+}
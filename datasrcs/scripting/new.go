@@ -8,7 +8,6 @@ import (
 	"context"
 	"net"
 	"strings"
-	"time"
 
 	"github.com/miekg/dns"
 	amassnet "github.com/owasp-amass/amass/v4/net"
@@ -227,16 +226,17 @@ func (s *Script) newASN(L *lua.LState) int {
 
 			cc, _ := getStringField(L, params, "cc")
 			registry, _ := getStringField(L, params, "registry")
-			s.sys.Cache().Update(&requests.ASNRequest{
-				Address:        addr,
-				ASN:            int(asn),
-				Prefix:         prefix,
-				CC:             cc,
-				Registry:       registry,
-				AllocationDate: time.Now(),
-				Description:    desc,
-				Netblocks:      netblocks,
-			})
+			req := &requests.ASNRequest{
+				Address:     addr,
+				ASN:         int(asn),
+				Prefix:      prefix,
+				CC:          cc,
+				Registry:    registry,
+				Description: desc,
+				Netblocks:   netblocks,
+			}
+			s.sys.Cache().Update(req)
+			s.registerASN(req)
 		}
 	}
 	return 0
@@ -0,0 +1,244 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package scripting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/net/rdap"
+	"github.com/owasp-amass/amass/v4/net/whois"
+	"github.com/owasp-amass/amass/v4/requests"
+	lua "github.com/yuin/gopher-lua"
+)
+
+// registrationTimeout limits the lookups made to complete the ASN information provided by scripts.
+const registrationTimeout = 30 * time.Second
+
+// scriptRequester sends the RDAP requests of a script with its rate limit and web client.
+type scriptRequester struct {
+	s *Script
+}
+
+func (r scriptRequester) RequestWebPage(ctx context.Context, req *http.Request) (*http.Response, error) {
+	return r.s.req(ctx, req)
+}
+
+// Wrapper so that scripts can obtain registration data using RDAP.
+func (s *Script) rdap(L *lua.LState) int {
+	ctx, err := extractContext(L.CheckUserData(1))
+	query := L.CheckString(2)
+	if err != nil || query == "" {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("Proper parameters were not provided"))
+		return 2
+	}
+
+	c, err := rdap.NewClient(scriptRequester{s: s}, nil)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	resp, err := c.Query(ctx, query)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	L.Push(rdapToTable(L, resp))
+	L.Push(lua.LNil)
+	return 2
+}
+
+func rdapToTable(L *lua.LState, resp *rdap.Response) *lua.LTable {
+	tb := L.NewTable()
+
+	tb.RawSetString("url", lua.LString(resp.URL))
+	tb.RawSetString("object_class", lua.LString(resp.ObjectClass))
+	tb.RawSetString("handle", lua.LString(resp.Handle))
+	tb.RawSetString("name", lua.LString(resp.Name))
+	tb.RawSetString("type", lua.LString(resp.Type))
+	tb.RawSetString("country", lua.LString(resp.Country))
+	tb.RawSetString("status", stringsToTable(L, resp.Status))
+	tb.RawSetString("port43", lua.LString(resp.Port43))
+	tb.RawSetString("registry", lua.LString(resp.Registry))
+	tb.RawSetString("start_address", lua.LString(resp.StartAddress))
+	tb.RawSetString("end_address", lua.LString(resp.EndAddress))
+	tb.RawSetString("cidrs", stringsToTable(L, resp.CIDRs))
+	tb.RawSetString("start_autnum", lua.LNumber(resp.StartAutnum))
+	tb.RawSetString("end_autnum", lua.LNumber(resp.EndAutnum))
+	tb.RawSetString("nameservers", stringsToTable(L, resp.Nameservers))
+	tb.RawSetString("remarks", stringsToTable(L, resp.Remarks))
+	tb.RawSetString("raw", lua.LString(resp.Raw))
+
+	events := L.NewTable()
+	for action, t := range resp.Events {
+		events.RawSetString(action, lua.LString(t.UTC().Format(time.RFC3339)))
+	}
+	tb.RawSetString("events", events)
+
+	entities := L.NewTable()
+	for _, e := range resp.Entities {
+		etb := L.NewTable()
+
+		etb.RawSetString("handle", lua.LString(e.Handle))
+		etb.RawSetString("roles", stringsToTable(L, e.Roles))
+		etb.RawSetString("name", lua.LString(e.Name))
+		etb.RawSetString("org", lua.LString(e.Org))
+		etb.RawSetString("email", lua.LString(e.Email))
+		entities.Append(etb)
+	}
+	tb.RawSetString("entities", entities)
+	return tb
+}
+
+// Wrapper so that scripts can obtain registration data using WHOIS on port 43.
+func (s *Script) whois(L *lua.LState) int {
+	ctx, err := extractContext(L.CheckUserData(1))
+	query := L.CheckString(2)
+	if err != nil || query == "" {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("Proper parameters were not provided"))
+		return 2
+	}
+
+	numRateLimitChecks(s, s.seconds)
+	// The referrals are followed from IANA unless the script selects the server
+	c := &whois.Client{
+		Server: L.OptString(3, ""),
+		Dialer: s.sys.Dialer(),
+	}
+	resp, err := c.Query(ctx, query)
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(err.Error()))
+		return 2
+	}
+
+	tb := L.NewTable()
+	tb.RawSetString("server", lua.LString(resp.Server))
+	tb.RawSetString("raw", lua.LString(resp.Raw))
+
+	fields := L.NewTable()
+	for key, values := range resp.Fields {
+		fields.RawSetString(key, stringsToTable(L, values))
+	}
+	tb.RawSetString("fields", fields)
+
+	L.Push(tb)
+	L.Push(lua.LNil)
+	return 2
+}
+
+func stringsToTable(L *lua.LState, values []string) *lua.LTable {
+	tb := L.NewTable()
+
+	for _, v := range values {
+		tb.Append(lua.LString(v))
+	}
+	return tb
+}
+
+// registerASN completes the ASN information provided by the script with the registry, allocation
+// date and netblocks obtained using RDAP, or WHOIS when RDAP fails, and updates the ASN cache.
+func (s *Script) registerASN(req *requests.ASNRequest) {
+	if !s.registration {
+		return
+	}
+
+	s.registeredLock.Lock()
+	_, found := s.registered[req.ASN]
+	s.registered[req.ASN] = struct{}{}
+	s.registeredLock.Unlock()
+	if found {
+		return
+	}
+
+	// The request can be modified by the cache once it has been updated
+	update := &requests.ASNRequest{
+		Address:     req.Address,
+		ASN:         req.ASN,
+		Prefix:      req.Prefix,
+		CC:          req.CC,
+		Description: req.Description,
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(s.ctx, registrationTimeout)
+		defer cancel()
+
+		info, err := s.lookupRegistration(ctx, net.ParseIP(update.Address))
+		if err != nil {
+			if cfg := s.sys.Config(); cfg.Verbose {
+				cfg.Log.Printf("%s: failed to obtain the registration data for AS%d: %v", s.String(), update.ASN, err)
+			}
+			return
+		}
+
+		update.Registry = info.Registry
+		update.AllocationDate = info.AllocationDate
+		update.Netblocks = info.Netblocks
+		s.sys.Cache().Update(update)
+	}()
+}
+
+func (s *Script) lookupRegistration(ctx context.Context, ip net.IP) (*requests.ASNRequest, error) {
+	if ip == nil {
+		return nil, errors.New("the address is not valid")
+	}
+
+	if c, err := rdap.NewClient(s.WebClient(), nil); err == nil {
+		if resp, err := c.IP(ctx, ip); err == nil {
+			return &requests.ASNRequest{
+				Registry:       resp.Registry,
+				AllocationDate: resp.Registered(),
+				Netblocks:      registeredNetblocks(ip, resp.CIDRs),
+			}, nil
+		}
+	}
+
+	resp, err := (&whois.Client{Dialer: s.sys.Dialer()}).Query(ctx, ip.String())
+	if err != nil {
+		return nil, err
+	}
+
+	info := &requests.ASNRequest{
+		Registry:       resp.Registry(),
+		AllocationDate: resp.Created(),
+		Netblocks:      registeredNetblocks(ip, resp.Netblocks()),
+	}
+	if info.Registry == "" && info.AllocationDate.IsZero() && len(info.Netblocks) == 0 {
+		return nil, fmt.Errorf("the WHOIS response from %s did not describe the network", resp.Server)
+	}
+	return info, nil
+}
+
+// registeredNetblocks returns the CIDRs containing the address, ignoring the blocks that
+// are too large to belong to a single organization.
+func registeredNetblocks(ip net.IP, cidrs []string) []string {
+	var results []string
+
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil || !ipnet.Contains(ip) {
+			continue
+		}
+
+		min := 8
+		if ip.To4() == nil {
+			min = 12
+		}
+		if ones, _ := ipnet.Mask.Size(); ones >= min {
+			results = append(results, ipnet.String())
+		}
+	}
+	return results
+}
@@ -0,0 +1,168 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package scripting
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caffix/stringset"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/config/config"
+	"github.com/stretchr/testify/require"
+)
+
+const testRDAPNetwork = `{
+  "objectClassName": "ip network",
+  "handle": "NET-8-8-0-0-1",
+  "name": "GOGL",
+  "cidr0_cidrs": [{"v4prefix": "8.8.0.0", "length": 16}],
+  "events": [{"eventAction": "registration", "eventDate": "2014-03-14T16:52:05-04:00"}],
+  "entities": [{"handle": "GOGL", "roles": ["registrant"],
+    "vcardArray": ["vcard", [["fn", {}, "text", "Google LLC"]]]}]
+}`
+
+// rdapTestClient answers the RDAP requests for IP networks without network access.
+type rdapTestClient struct{}
+
+func (rdapTestClient) RequestWebPage(ctx context.Context, r *http.Request) (*http.Response, error) {
+	if !strings.Contains(r.URL, "/ip/8.8.") {
+		return &http.Response{Status: "404 Not Found", StatusCode: 404, URL: r.URL}, nil
+	}
+	return &http.Response{Status: "200 OK", StatusCode: 200, URL: r.URL, Body: testRDAPNetwork}, nil
+}
+
+func (rdapTestClient) Crawl(ctx context.Context, u string, scope []string, max int, callback func(*http.Request, *http.Response)) error {
+	return errors.New("crawling is not supported")
+}
+
+func startRegistrationScript(t *testing.T, script string) (*Script, func()) {
+	sys := newMockSystem(config.NewConfig())
+	require.NotNil(t, sys)
+
+	s := NewScript(script, sys)
+	require.NotNil(t, s)
+	s.SetWebClient(rdapTestClient{})
+	require.NoError(t, sys.AddAndStart(s))
+
+	return s, func() {
+		_ = s.Stop()
+		_ = sys.Shutdown()
+	}
+}
+
+func TestRDAPAndWhois(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			if _, err := bufio.NewReader(conn).ReadString('\n'); err == nil {
+				_, _ = io.WriteString(conn, "% comment\nNetName: GOGL\nCIDR: 8.8.8.0/24\n")
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	s, stop := startRegistrationScript(t, `
+		name="registration"
+		type="testing"
+		capabilities={"net.http", "net.socket"}
+
+		function vertical(ctx, domain)
+			local resp, err = rdap(ctx, "8.8.8.8")
+			if (err == nil and resp.registry == "ARIN" and resp.cidrs[1] == "8.8.0.0/16" and
+				resp.events['registration'] == "2014-03-14T20:52:05Z" and resp.entities[1].name == "Google LLC") then
+				new_name(ctx, "rdap." .. domain)
+			end
+
+			resp, err = rdap(ctx, "1.1.1.1")
+			if (err ~= nil and resp == nil) then
+				new_name(ctx, "notfound." .. domain)
+			end
+
+			resp, err = whois(ctx, "8.8.8.8", "`+ln.Addr().String()+`")
+			if (err == nil and resp.fields['netname'][1] == "GOGL" and resp.fields['cidr'][1] == "8.8.8.0/24") then
+				new_name(ctx, "whois." .. domain)
+			end
+		end
+	`)
+	defer stop()
+
+	domain := "owasp.org"
+	s.sys.Config().AddDomain(domain)
+	s.Input() <- &requests.DNSRequest{Domain: domain}
+
+	expected := []string{"rdap.owasp.org", "notfound.owasp.org", "whois.owasp.org"}
+	got := stringset.New()
+	defer got.Close()
+
+	timer := time.NewTimer(10 * time.Second)
+	defer timer.Stop()
+	for got.Len() < len(expected) {
+		select {
+		case req := <-s.Output():
+			if dns, ok := req.(*requests.DNSRequest); ok {
+				got.Insert(dns.Name)
+			}
+		case <-timer.C:
+			t.Fatalf("The script only provided the following names: %v", got.Slice())
+		}
+	}
+	require.ElementsMatch(t, expected, got.Slice())
+}
+
+func TestNewASNRegistration(t *testing.T) {
+	s, stop := startRegistrationScript(t, `
+		name="registration"
+		type="testing"
+
+		function asn(ctx, addr, asn)
+			new_asn(ctx, {
+				['addr']=addr,
+				['asn']=15169,
+				['prefix']="8.8.8.0/24",
+				['desc']="GOOGLE - Google LLC",
+			})
+		end
+	`)
+	defer stop()
+
+	s.Input() <- &requests.ASNRequest{Address: "8.8.8.8"}
+
+	var as *requests.ASNRequest
+	require.Eventually(t, func() bool {
+		as = s.sys.Cache().AddrSearch("8.8.8.8")
+		return as != nil && as.Registry != ""
+	}, 10*time.Second, 50*time.Millisecond)
+
+	require.Equal(t, 15169, as.ASN)
+	require.Equal(t, "ARIN", as.Registry)
+	require.Equal(t, time.Date(2014, 3, 14, 20, 52, 5, 0, time.UTC), as.AllocationDate.UTC())
+	require.ElementsMatch(t, []string{"8.8.8.0/24", "8.8.0.0/16"}, as.Netblocks)
+	// The address outside of the announced prefix is found using the registered netblock
+	require.Equal(t, 15169, s.sys.Cache().AddrSearch("8.8.200.1").ASN)
+}
+
+func TestRegisteredNetblocks(t *testing.T) {
+	ip := net.ParseIP("8.8.8.8")
+
+	require.Equal(t, []string{"8.8.8.0/24", "8.0.0.0/8"},
+		registeredNetblocks(ip, []string{"8.8.8.0/24", "9.9.9.0/24", "8.0.0.0/8", "0.0.0.0/0", "bad"}))
+	require.Empty(t, registeredNetblocks(net.ParseIP("2001:db8::1"), []string{"2000::/3"}))
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/caffix/service"
 	luaurl "github.com/cjoudrey/gluaurl"
+	"github.com/owasp-amass/amass/v4/datasrcs/external"
 	"github.com/owasp-amass/amass/v4/net/dns"
 	"github.com/owasp-amass/amass/v4/requests"
 	"github.com/owasp-amass/amass/v4/systems"
@@ -34,6 +35,9 @@ type callbacks struct {
 	Subdomain  lua.LValue
 }
 
+// flush is closed by the script once the requests received before it have been handled.
+type flush chan struct{}
+
 // Script is the Service that handles access to the Script data source.
 type Script struct {
 	service.BaseService
@@ -42,6 +46,7 @@ type Script struct {
 	stop       chan struct{}
 	SourceType string
 	sys        systems.System
+	web        WebClient
 	luaState   *lua.LState
 	cbs        *callbacks
 	cbsLock    sync.Mutex
@@ -49,6 +54,17 @@ type Script struct {
 	seconds    int
 	ctx        context.Context
 	cancel     context.CancelFunc
+	// registration enables the lookups completing the ASN information provided by the script
+	registration   bool
+	registered     map[int]struct{}
+	registeredLock sync.Mutex
+	// The capabilities declared by the script, and those granted after the policy was applied
+	libs     *restrictedLibs
+	declared []string
+	granted  map[string]struct{}
+	capsLock sync.Mutex
+	// ext is the external program run in place of the script callbacks
+	ext *external.Settings
 }
 
 // NewScript returns the object initialized, but not yet started.
@@ -59,11 +75,13 @@ func NewScript(script string, sys systems.System) *Script {
 	}
 
 	s := &Script{
-		start:    make(chan struct{}, 1),
-		startRet: make(chan error, 1),
-		stop:     make(chan struct{}, 1),
-		sys:      sys,
-		subre:    re,
+		start:        make(chan struct{}, 1),
+		startRet:     make(chan error, 1),
+		stop:         make(chan struct{}, 1),
+		sys:          sys,
+		subre:        re,
+		registration: true,
+		registered:   make(map[int]struct{}),
 	}
 	s.ctx, s.cancel = context.WithCancel(context.Background())
 	L := s.newLuaState(sys.Config())
@@ -86,6 +104,21 @@ func NewScript(script string, sys systems.System) *Script {
 		return nil
 	}
 
+	// Pull the capabilities required by the script
+	s.declared, err = s.scriptCapabilities()
+	if err != nil {
+		sys.Config().Log.Printf("Script: Failed to obtain the %s script capabilities: %v", name, err)
+		return nil
+	}
+	s.SetCapabilityPolicy(nil)
+
+	// Pull the external program declared by the script
+	s.ext, err = s.scriptExternal()
+	if err != nil {
+		sys.Config().Log.Printf("Script: Failed to obtain the %s script external program: %v", name, err)
+		return nil
+	}
+
 	s.BaseService = *service.NewBaseService(s, name)
 	s.assignCallbacks()
 	go s.requests()
@@ -102,8 +135,9 @@ func (s *Script) newLuaState(cfg *config.Config) *lua.LState {
 		RegistryGrowStep:    32,
 	})
 	s.luaState = L
+	s.restrictLuaState(L)
 
-	registerSocketType(L)
+	registerSocketType(L, s.gated(CapSocket, s.connect))
 	L.PreloadModule("url", luaurl.Loader)
 	L.PreloadModule("json", luajson.Loader)
 	L.SetGlobal("config", L.NewFunction(s.config))
@@ -113,7 +147,7 @@ func (s *Script) newLuaState(cfg *config.Config) *lua.LState {
 	L.SetGlobal("log", L.NewFunction(s.log))
 	L.SetGlobal("find", L.NewFunction(s.find))
 	L.SetGlobal("submatch", L.NewFunction(s.submatch))
-	L.SetGlobal("mtime", L.NewFunction(s.modDateTime))
+	L.SetGlobal("mtime", L.NewFunction(s.gated(CapFSRead, s.modDateTime)))
 	L.SetGlobal("new_name", L.NewFunction(s.newName))
 	L.SetGlobal("send_names", L.NewFunction(s.sendNames))
 	L.SetGlobal("send_dns_records", L.NewFunction(s.sendDNSRecords))
@@ -121,13 +155,15 @@ func (s *Script) newLuaState(cfg *config.Config) *lua.LState {
 	L.SetGlobal("new_asn", L.NewFunction(s.newASN))
 	L.SetGlobal("associated", L.NewFunction(s.associated))
 	L.SetGlobal("in_scope", L.NewFunction(s.inScope))
-	L.SetGlobal("request", L.NewFunction(s.request))
-	L.SetGlobal("scrape", L.NewFunction(s.scrape))
-	L.SetGlobal("crawl", L.NewFunction(s.crawl))
-	L.SetGlobal("resolve", L.NewFunction(s.resolve))
-	L.SetGlobal("reverse_sweep", L.NewFunction(s.reverseSweep))
-	L.SetGlobal("zone_walk", L.NewFunction(s.zoneWalk))
-	L.SetGlobal("zone_transfer", L.NewFunction(s.wrapZoneTransfer))
+	L.SetGlobal("request", L.NewFunction(s.gated(CapHTTP, s.request)))
+	L.SetGlobal("scrape", L.NewFunction(s.gated(CapHTTP, s.scrape)))
+	L.SetGlobal("crawl", L.NewFunction(s.gated(CapHTTP, s.crawl)))
+	L.SetGlobal("rdap", L.NewFunction(s.gated(CapHTTP, s.rdap)))
+	L.SetGlobal("whois", L.NewFunction(s.gated(CapSocket, s.whois)))
+	L.SetGlobal("resolve", L.NewFunction(s.gated(CapDNS, s.resolve)))
+	L.SetGlobal("reverse_sweep", L.NewFunction(s.gated(CapDNS, s.reverseSweep)))
+	L.SetGlobal("zone_walk", L.NewFunction(s.gated(CapDNS, s.zoneWalk)))
+	L.SetGlobal("zone_transfer", L.NewFunction(s.gated(CapDNS, s.wrapZoneTransfer)))
 	L.SetGlobal("output_dir", L.NewFunction(s.outputdir))
 	L.SetGlobal("set_rate_limit", L.NewFunction(s.setRateLimit))
 	L.SetGlobal("check_rate_limit", L.NewFunction(s.checkRateLimit))
@@ -235,7 +271,9 @@ func (s *Script) HandlesReq(req interface{}) bool {
 func (s *Script) requests() {
 	for {
 		select {
+		// The service is done before OnStop is called, so either case stops the script
 		case <-s.Done():
+			s.stopScript()
 			return
 		case <-s.ctx.Done():
 			return
@@ -243,6 +281,7 @@ func (s *Script) requests() {
 			s.startScript()
 		case <-s.stop:
 			s.stopScript()
+			return
 		case in := <-s.Input():
 			s.dispatch(in)
 		}
@@ -370,6 +409,9 @@ func (s *Script) dispatch(in interface{}) {
 			s.CheckRateLimit()
 			s.whoisRequest(s.ctx, callback, req)
 		}
+	case flush:
+		s.cbsLock.Unlock()
+		close(req)
 	default:
 		s.cbsLock.Unlock()
 	}
@@ -5,37 +5,64 @@
 package scripting
 
 import (
-	"github.com/caffix/netmap"
 	"github.com/caffix/service"
-	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/amasstest"
 	"github.com/owasp-amass/amass/v4/systems"
 	"github.com/owasp-amass/config/config"
-	"github.com/owasp-amass/resolve"
 )
 
+const owaspZone = `
+@	IN	SOA	ns1.owasp.org. hostmaster.owasp.org. 1 3600 600 86400 300
+@	IN	NS	ns1.owasp.org.
+@	IN	A	104.22.26.77
+@	IN	MX	10 mail.owasp.org.
+@	IN	TXT	"v=spf1 include:_spf.google.com ~all"
+ns1	IN	A	104.22.27.77
+www	IN	A	104.22.27.77
+www	IN	AAAA	2606:4700:10::6816:1b4d
+mail	IN	A	104.22.26.78
+`
+
+type mockSystem struct {
+	*systems.SimpleSystem
+	dns *amasstest.DNSServer
+}
+
+// Shutdown implements the System interface.
+func (m *mockSystem) Shutdown() error {
+	err := m.SimpleSystem.Shutdown()
+
+	m.dns.Close()
+	return err
+}
+
 func setupMockScriptEnv(script string) (service.Service, systems.System) {
 	sys := newMockSystem(config.NewConfig())
+	if sys == nil {
+		return nil, nil
+	}
 
 	if s := NewScript(script, sys); s != nil {
 		if err := sys.AddAndStart(s); err == nil {
 			return s, sys
 		}
 	}
+	_ = sys.Shutdown()
 	return nil, nil
 }
 
 func newMockSystem(cfg *config.Config) systems.System {
-	ss := &systems.SimpleSystem{
-		Cfg:      cfg,
-		Pool:     resolve.NewResolvers(),
-		Trusted:  resolve.NewResolvers(),
-		Graph:    netmap.NewGraph("memory", "", ""),
-		ASNCache: requests.NewASNCache(),
+	srv, err := amasstest.NewDNSServer()
+	if err != nil {
+		return nil
+	}
+	if err := srv.LoadZoneString("owasp.org", owaspZone); err != nil {
+		srv.Close()
+		return nil
 	}
 
-	ss.Pool.SetLogger(cfg.Log)
-	_ = ss.Pool.AddResolvers(20, "8.8.8.8")
-	ss.Trusted.SetLogger(cfg.Log)
-	_ = ss.Trusted.AddResolvers(20, "8.8.8.8")
-	return ss
+	return &mockSystem{
+		SimpleSystem: systems.NewSimpleSystem(cfg, srv.Pool(), srv.Pool()),
+		dns:          srv,
+	}
 }
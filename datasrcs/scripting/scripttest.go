@@ -0,0 +1,468 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package scripting
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caffix/stringset"
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/systems"
+	"github.com/owasp-amass/amass/v4/zonefile"
+	"github.com/owasp-amass/config/config"
+	"golang.org/x/net/publicsuffix"
+	"gopkg.in/yaml.v3"
+)
+
+const defaultTestTimeout = 30 * time.Second
+
+// TestFile contains the unit tests for a single script, as declared in a YAML test file.
+type TestFile struct {
+	Script    string              `yaml:"script"`
+	Domains   []string            `yaml:"domains,omitempty"`
+	Creds     *config.Credentials `yaml:"creds,omitempty"`
+	HTTP      []*HTTPFixture      `yaml:"http,omitempty"`
+	Fixtures  string              `yaml:"fixtures,omitempty"`
+	DNS       []*DNSFixture       `yaml:"dns,omitempty"`
+	ZoneFiles []string            `yaml:"zonefiles,omitempty"`
+	Tests     []*TestCase         `yaml:"tests"`
+}
+
+// DNSFixture provides the canned answers returned when the script resolves the name and type.
+type DNSFixture struct {
+	Name    string   `yaml:"name"`
+	Type    string   `yaml:"type"`
+	Answers []string `yaml:"answers"`
+}
+
+// TestCase invokes a script callback with the arguments and checks the outputs against the expectations.
+type TestCase struct {
+	Name     string        `yaml:"name"`
+	Callback string        `yaml:"callback"`
+	Args     []string      `yaml:"args"`
+	Records  []*DNSFixture `yaml:"records,omitempty"`
+	Timeout  int           `yaml:"timeout,omitempty"`
+	Expect   Expectations  `yaml:"expect"`
+}
+
+// Expectations lists the outputs a TestCase requires from the script. A nil list is not checked,
+// while an empty list requires that the script produced no outputs of that kind.
+type Expectations struct {
+	Names      []string          `yaml:"names"`
+	Addrs      []string          `yaml:"addrs"`
+	ASNs       []*ASNExpectation `yaml:"asns"`
+	Associated []string          `yaml:"associated"`
+}
+
+// ASNExpectation describes the ASN cache entry the script is expected to provide for the address.
+type ASNExpectation struct {
+	Addr   string `yaml:"addr"`
+	ASN    int    `yaml:"asn"`
+	Prefix string `yaml:"prefix,omitempty"`
+	Desc   string `yaml:"desc,omitempty"`
+}
+
+// TestResult is the outcome of a single TestCase.
+type TestResult struct {
+	Name      string
+	Failures  []string
+	Unmatched []string
+	Duration  time.Duration
+}
+
+// Passed returns true when the TestCase produced the expected outputs.
+func (r *TestResult) Passed() bool {
+	return len(r.Failures) == 0
+}
+
+// LoadTestFile reads the script unit tests declared in the YAML file at the provided path.
+// Paths within the file are relative to the directory containing it.
+func LoadTestFile(path string) (*TestFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the test file %s: %v", path, err)
+	}
+
+	var tf TestFile
+	if err := yaml.Unmarshal(data, &tf); err != nil {
+		return nil, fmt.Errorf("failed to parse the test file %s: %v", path, err)
+	}
+	if tf.Script == "" {
+		return nil, fmt.Errorf("the test file %s does not provide the script path", path)
+	}
+	if len(tf.Tests) == 0 {
+		return nil, fmt.Errorf("the test file %s does not contain any tests", path)
+	}
+
+	dir := filepath.Dir(path)
+	rel := func(p string) string {
+		if filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	tf.Script = rel(tf.Script)
+	if tf.Fixtures != "" {
+		tf.Fixtures = rel(tf.Fixtures)
+	}
+	for i, zf := range tf.ZoneFiles {
+		tf.ZoneFiles[i] = rel(zf)
+	}
+	for _, f := range tf.HTTP {
+		if f.ResponseFile == "" {
+			continue
+		}
+
+		body, err := os.ReadFile(rel(f.ResponseFile))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the HTTP fixture for %s: %v", f.URL, err)
+		}
+		f.Response = string(body)
+	}
+	for i, tc := range tf.Tests {
+		if tc.Name == "" {
+			tc.Name = fmt.Sprintf("%s #%d", tc.Callback, i+1)
+		}
+		if _, err := testRequest(tc); err != nil {
+			return nil, fmt.Errorf("the test %s is invalid: %v", tc.Name, err)
+		}
+	}
+	return &tf, nil
+}
+
+// Run executes the test cases in order against a fresh instance of the script for each case.
+// The script log messages are written to the logger when it is not nil.
+func (tf *TestFile) Run(ctx context.Context, logger *log.Logger) ([]*TestResult, error) {
+	script, err := os.ReadFile(tf.Script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the script %s: %v", tf.Script, err)
+	}
+	if logger == nil {
+		logger = log.New(io.Discard, "", 0)
+	}
+
+	srv := zonefile.NewZones()
+	if err := loadDNSFixtures(srv, tf.DNS); err != nil {
+		return nil, err
+	}
+	for _, zf := range tf.ZoneFiles {
+		if err := srv.LoadZoneFile("", zf); err != nil {
+			return nil, err
+		}
+	}
+
+	var recorded *http.Fixtures
+	if tf.Fixtures != "" {
+		recorded, err = http.NewFixtures(tf.Fixtures, http.FixturesReplay)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	web := NewFixtureClient(tf.HTTP, recorded)
+	var results []*TestResult
+	for _, tc := range tf.Tests {
+		start := time.Now()
+		res := tf.runCase(ctx, string(script), tc, srv, web, logger)
+
+		res.Duration = time.Since(start)
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+func loadDNSFixtures(srv *zonefile.Zones, fixtures []*DNSFixture) error {
+	zones := make(map[string][]string)
+
+	for _, f := range fixtures {
+		name := strings.ToLower(strings.TrimSuffix(f.Name, "."))
+		if _, ok := dns.StringToType[strings.ToUpper(f.Type)]; !ok {
+			return fmt.Errorf("the DNS fixture for %s has an unknown type: %s", f.Name, f.Type)
+		}
+
+		domain, err := publicsuffix.EffectiveTLDPlusOne(name)
+		if err != nil {
+			return fmt.Errorf("failed to obtain the registered domain for %s: %v", f.Name, err)
+		}
+
+		for _, ans := range f.Answers {
+			zones[domain] = append(zones[domain], fmt.Sprintf("%s. 300 IN %s %s", name, strings.ToUpper(f.Type), ans))
+		}
+	}
+
+	for domain, records := range zones {
+		if err := srv.LoadZoneString(domain, strings.Join(records, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tf *TestFile) runCase(ctx context.Context, script string, tc *TestCase, srv *zonefile.Zones, web *FixtureClient, logger *log.Logger) *TestResult {
+	res := &TestResult{Name: tc.Name}
+	failed := func(format string, a ...interface{}) *TestResult {
+		res.Failures = append(res.Failures, fmt.Sprintf(format, a...))
+		return res
+	}
+
+	req, _ := testRequest(tc)
+	cfg := config.NewConfig()
+	cfg.Log = logger
+	cfg.AddDomains(tf.Domains...)
+	if tc.Callback == "vertical" || tc.Callback == "horizontal" {
+		cfg.AddDomain(tc.Args[0])
+	}
+
+	sys := systems.NewSimpleSystem(cfg, srv.Pool(), srv.Pool())
+	defer func() { _ = sys.Shutdown() }()
+
+	s := NewScript(script, sys)
+	if s == nil {
+		return failed("failed to load the script %s", tf.Script)
+	}
+	if tf.Creds != nil {
+		creds := *tf.Creds
+		creds.Name = s.String()
+		cfg.DataSrcConfigs = &config.DataSourceConfig{
+			Datasources: []*config.DataSource{{
+				Name:  s.String(),
+				Creds: map[string]*config.Credentials{"test": &creds},
+			}},
+		}
+	}
+
+	s.SetWebClient(web)
+	// The ASN information must come from the fixtures alone
+	s.registration = false
+	_ = web.Unmatched()
+	if err := sys.AddAndStart(s); err != nil {
+		return failed("failed to start the script: %v", err)
+	}
+
+	timeout := defaultTestTimeout
+	if tc.Timeout > 0 {
+		timeout = time.Duration(tc.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	out := newOutputCollector(s.Output())
+	done := make(flush)
+	for _, in := range []interface{}{req, done} {
+		select {
+		case <-ctx.Done():
+		case s.Input() <- in:
+		}
+	}
+	select {
+	case <-ctx.Done():
+		out.stop()
+		return failed("the %s callback did not return within %v", tc.Callback, timeout)
+	case <-done:
+	}
+	out.stop()
+
+	res.Unmatched = web.Unmatched()
+	res.Failures = append(res.Failures, compareSets("name", tc.Expect.Names, out.names)...)
+	res.Failures = append(res.Failures, compareSets("address", tc.Expect.Addrs, out.addrs)...)
+	res.Failures = append(res.Failures, compareSets("associated domain", tc.Expect.Associated, out.assoc)...)
+	for _, a := range tc.Expect.ASNs {
+		res.Failures = append(res.Failures, checkASN(sys.Cache(), a)...)
+	}
+	return res
+}
+
+// testRequest builds the request that causes the script to execute the callback of the TestCase.
+func testRequest(tc *TestCase) (interface{}, error) {
+	arg := func(i int) string {
+		if i < len(tc.Args) {
+			return tc.Args[i]
+		}
+		return ""
+	}
+	domainOf := func(name string) string {
+		if d := arg(1); d != "" {
+			return d
+		}
+		d, _ := publicsuffix.EffectiveTLDPlusOne(name)
+		return d
+	}
+
+	if arg(0) == "" {
+		return nil, errors.New("the callback requires at least one argument")
+	}
+	switch tc.Callback {
+	case "vertical":
+		return &requests.DNSRequest{Name: arg(0), Domain: arg(0)}, nil
+	case "horizontal":
+		return &requests.WhoisRequest{Domain: arg(0)}, nil
+	case "address":
+		return &requests.AddrRequest{Address: arg(0), Domain: arg(1)}, nil
+	case "asn":
+		var asn int
+		if a := arg(1); a != "" {
+			n, err := strconv.Atoi(a)
+			if err != nil {
+				return nil, fmt.Errorf("the ASN argument %s is not a number", a)
+			}
+			asn = n
+		}
+		return &requests.ASNRequest{Address: arg(0), ASN: asn}, nil
+	case "resolved":
+		var records []requests.DNSAnswer
+		for _, r := range tc.Records {
+			qtype, ok := dns.StringToType[strings.ToUpper(r.Type)]
+			if !ok {
+				return nil, fmt.Errorf("the record for %s has an unknown type: %s", r.Name, r.Type)
+			}
+			for _, ans := range r.Answers {
+				records = append(records, requests.DNSAnswer{Name: r.Name, Type: int(qtype), Data: ans})
+			}
+		}
+		if len(records) == 0 {
+			return nil, errors.New("the resolved callback requires DNS records")
+		}
+		return &requests.ResolvedRequest{Name: arg(0), Domain: domainOf(arg(0)), Records: records}, nil
+	case "subdomain":
+		times := 1
+		if t := arg(2); t != "" {
+			n, err := strconv.Atoi(t)
+			if err != nil {
+				return nil, fmt.Errorf("the times argument %s is not a number", t)
+			}
+			times = n
+		}
+		return &requests.SubdomainRequest{Name: arg(0), Domain: domainOf(arg(0)), Times: times}, nil
+	}
+	return nil, fmt.Errorf("the callback %s is not supported", tc.Callback)
+}
+
+type outputCollector struct {
+	sync.Mutex
+	ch    chan interface{}
+	quit  chan struct{}
+	done  chan struct{}
+	names []string
+	addrs []string
+	assoc []string
+}
+
+func newOutputCollector(ch chan interface{}) *outputCollector {
+	c := &outputCollector{
+		ch:   ch,
+		quit: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	go c.collect()
+	return c
+}
+
+func (c *outputCollector) collect() {
+	defer close(c.done)
+
+	for {
+		select {
+		case <-c.quit:
+			// Obtain the outputs still waiting in the channel
+			for {
+				select {
+				case out := <-c.ch:
+					c.add(out)
+				default:
+					return
+				}
+			}
+		case out := <-c.ch:
+			c.add(out)
+		}
+	}
+}
+
+func (c *outputCollector) add(out interface{}) {
+	c.Lock()
+	defer c.Unlock()
+
+	switch v := out.(type) {
+	case *requests.DNSRequest:
+		c.names = append(c.names, v.Name)
+	case *requests.AddrRequest:
+		c.addrs = append(c.addrs, v.Address)
+	case *requests.WhoisRequest:
+		c.assoc = append(c.assoc, v.NewDomains...)
+	}
+}
+
+func (c *outputCollector) stop() {
+	close(c.quit)
+	<-c.done
+}
+
+func compareSets(kind string, expected, actual []string) []string {
+	if expected == nil {
+		return nil
+	}
+
+	want := stringset.New(expected...)
+	defer want.Close()
+	got := stringset.New(actual...)
+	defer got.Close()
+
+	var failures []string
+	missing := stringset.New(want.Slice()...)
+	defer missing.Close()
+	missing.Subtract(got)
+	for _, v := range sortedSlice(missing) {
+		failures = append(failures, fmt.Sprintf("missing %s: %s", kind, v))
+	}
+
+	unexpected := stringset.New(got.Slice()...)
+	defer unexpected.Close()
+	unexpected.Subtract(want)
+	for _, v := range sortedSlice(unexpected) {
+		failures = append(failures, fmt.Sprintf("unexpected %s: %s", kind, v))
+	}
+	return failures
+}
+
+func sortedSlice(set *stringset.Set) []string {
+	s := set.Slice()
+
+	sort.Strings(s)
+	return s
+}
+
+func checkASN(cache *requests.ASNCache, a *ASNExpectation) []string {
+	entry := cache.AddrSearch(a.Addr)
+	if entry == nil || entry.ASN == 0 {
+		return []string{fmt.Sprintf("missing ASN for address: %s", a.Addr)}
+	}
+
+	var failures []string
+	if entry.ASN != a.ASN {
+		failures = append(failures, fmt.Sprintf("ASN for %s: got %d, want %d", a.Addr, entry.ASN, a.ASN))
+	}
+	if a.Prefix != "" && entry.Prefix != a.Prefix {
+		failures = append(failures, fmt.Sprintf("prefix for %s: got %s, want %s", a.Addr, entry.Prefix, a.Prefix))
+	}
+	if a.Desc != "" && entry.Description != a.Desc {
+		failures = append(failures, fmt.Sprintf("description for %s: got %s, want %s", a.Addr, entry.Description, a.Desc))
+	}
+	return failures
+}
@@ -0,0 +1,136 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package scripting
+
+import (
+	"context"
+	"io"
+	nethttp "net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScriptTestFile(t *testing.T) {
+	tf, err := LoadTestFile(filepath.Join("testdata", "example.yaml"))
+	require.NoError(t, err)
+
+	results, err := tf.Run(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, results, len(tf.Tests))
+	for _, res := range results {
+		require.True(t, res.Passed(), "%s: %v", res.Name, res.Failures)
+	}
+	// The crawl followed the link to the blog without a fixture
+	require.Equal(t, []string{"GET https://blog.owasp.org/"}, results[0].Unmatched)
+}
+
+func TestScriptTestFailures(t *testing.T) {
+	dir := t.TempDir()
+	script, err := filepath.Abs(filepath.Join("testdata", "example.ads"))
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "failing.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+script: `+script+`
+creds:
+  apikey: secret
+http:
+  - url: https://api.example.com/related/owasp.org
+    response: '["owasp.com"]'
+tests:
+  - callback: horizontal
+    args: [owasp.org]
+    expect:
+      associated: [owasp.net]
+  - callback: asn
+    args: [72.237.4.113]
+    expect:
+      asns:
+        - addr: 72.237.4.113
+          asn: 26808
+`), 0644))
+
+	tf, err := LoadTestFile(path)
+	require.NoError(t, err)
+
+	results, err := tf.Run(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	require.Equal(t, "horizontal #1", results[0].Name)
+	require.Equal(t, []string{"missing associated domain: owasp.net",
+		"unexpected associated domain: owasp.com"}, results[0].Failures)
+	require.Equal(t, []string{"missing ASN for address: 72.237.4.113"}, results[1].Failures)
+	require.Equal(t, []string{"GET https://api.example.com/asn/72.237.4.113"}, results[1].Unmatched)
+}
+
+type roundTripFunc func(*nethttp.Request) (*nethttp.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *nethttp.Request) (*nethttp.Response, error) { return f(req) }
+
+func TestScriptTestRecordedFixtures(t *testing.T) {
+	dir := t.TempDir()
+	fixtures := filepath.Join(dir, "fixtures")
+
+	rec, err := http.NewFixtures(fixtures, http.FixturesRecord)
+	require.NoError(t, err)
+	c := &nethttp.Client{Transport: rec.Transport(roundTripFunc(func(req *nethttp.Request) (*nethttp.Response, error) {
+		return &nethttp.Response{
+			Status:     "200 OK",
+			StatusCode: 200,
+			Proto:      "HTTP/1.1",
+			Header:     make(nethttp.Header),
+			Body:       io.NopCloser(strings.NewReader(`["owasp.com"]`)),
+			Request:    req,
+		}, nil
+	}))}
+	resp, err := c.Get("https://api.example.com/related/owasp.org")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	script, err := filepath.Abs(filepath.Join("testdata", "example.ads"))
+	require.NoError(t, err)
+	path := filepath.Join(dir, "recorded.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+script: `+script+`
+fixtures: fixtures
+creds:
+  apikey: secret
+tests:
+  - callback: horizontal
+    args: [owasp.org]
+    expect:
+      associated: [owasp.com]
+`), 0644))
+
+	tf, err := LoadTestFile(path)
+	require.NoError(t, err)
+
+	results, err := tf.Run(context.Background(), nil)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.True(t, results[0].Passed(), "%v", results[0].Failures)
+}
+
+func TestLoadTestFileErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, data := range []string{
+		"tests:\n  - callback: vertical\n    args: [owasp.org]\n",
+		"script: example.ads\n",
+		"script: example.ads\ntests:\n  - callback: unknown\n    args: [owasp.org]\n",
+		"script: example.ads\ntests:\n  - callback: resolved\n    args: [www.owasp.org]\n",
+	} {
+		path := filepath.Join(dir, "invalid.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(data), 0644))
+
+		_, err := LoadTestFile(path)
+		require.Error(t, err, data)
+	}
+}
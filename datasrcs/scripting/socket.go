@@ -5,30 +5,59 @@
 package scripting
 
 import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"net"
 	"strconv"
+	"strings"
+	"sync"
+	"time"
 
-	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/net/http"
 	lua "github.com/yuin/gopher-lua"
 )
 
-const luaSocketTypeName = "socket"
+const (
+	luaSocketTypeName = "socket"
+	// defaultSocketTimeout limits each operation on a socket when the script does not provide a timeout
+	defaultSocketTimeout = 30 * time.Second
+)
 
 type socketWrapper struct {
-	Conn net.Conn
+	sync.Mutex
+	Conn    net.Conn
+	reader  *bufio.Reader
+	host    string
+	timeout time.Duration
+	done    chan struct{}
+	closed  bool
+}
+
+// socketOptions are the settings a script can provide when connecting or starting TLS.
+type socketOptions struct {
+	tls        bool
+	serverName string
+	verify     bool
+	timeout    time.Duration
 }
 
 var connectMethods = map[string]lua.LGFunction{
-	"close":    connectClose,
-	"recv":     connectRecv,
-	"recv_all": connectRecvAll,
-	"send":     connectSend,
+	"close":             connectClose,
+	"peer_certificates": connectPeerCertificates,
+	"recv":              connectRecv,
+	"recv_all":          connectRecvAll,
+	"recv_line":         connectRecvLine,
+	"send":              connectSend,
+	"set_timeout":       connectSetTimeout,
+	"starttls":          connectStartTLS,
 }
 
-func registerSocketType(L *lua.LState) {
+func registerSocketType(L *lua.LState, connect lua.LGFunction) {
 	mt := L.NewTypeMetatable(luaSocketTypeName)
 
 	L.SetGlobal(luaSocketTypeName, mt)
@@ -36,8 +65,8 @@ func registerSocketType(L *lua.LState) {
 	L.SetField(mt, "__index", L.SetFuncs(L.NewTable(), connectMethods))
 }
 
-// Wrapper so that scripts can make DNS queries.
-func connect(L *lua.LState) int {
+// Wrapper so that scripts can make socket connections.
+func (s *Script) connect(L *lua.LState) int {
 	ctx, err := extractContext(L.CheckUserData(1))
 	host := L.CheckString(2)
 	port := int(L.CheckNumber(3))
@@ -48,16 +77,50 @@ func connect(L *lua.LState) int {
 		return 2
 	}
 
+	opts := socketOptionsFromTable(L, L.OptTable(5, nil))
+	if opts.tls && !strings.HasPrefix(proto, "tcp") {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("TLS can only be used with TCP connections"))
+		return 2
+	}
+
+	dctx, cancel := context.WithTimeout(ctx, opts.timeout)
+	defer cancel()
+
 	addr := net.JoinHostPort(host, strconv.Itoa(port))
-	conn, err := amassnet.DialContext(ctx, proto, addr)
+	conn, err := s.sys.Dialer().DialContext(dctx, proto, addr)
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(fmt.Sprintf("Failed to establish the connection: %v", err)))
 		return 2
 	}
 
+	sock := &socketWrapper{
+		Conn:    conn,
+		reader:  bufio.NewReader(conn),
+		host:    host,
+		timeout: opts.timeout,
+		done:    make(chan struct{}),
+	}
+	if opts.tls {
+		if err := sock.startTLS(dctx, opts); err != nil {
+			sock.close()
+			L.Push(lua.LNil)
+			L.Push(lua.LString(fmt.Sprintf("Failed to establish the TLS session: %v", err)))
+			return 2
+		}
+	}
+	// The socket is closed when the script does not do it before the context ends
+	go func() {
+		select {
+		case <-ctx.Done():
+			sock.close()
+		case <-sock.done:
+		}
+	}()
+
 	ud := L.NewUserData()
-	ud.Value = &socketWrapper{Conn: conn}
+	ud.Value = sock
 	L.SetMetatable(ud, L.GetTypeMetatable(luaSocketTypeName))
 
 	L.Push(ud)
@@ -65,6 +128,76 @@ func connect(L *lua.LState) int {
 	return 2
 }
 
+func socketOptionsFromTable(L *lua.LState, tb *lua.LTable) *socketOptions {
+	opts := &socketOptions{timeout: defaultSocketTimeout}
+	if tb == nil {
+		return opts
+	}
+
+	if b, ok := getBoolField(L, tb, "tls"); ok {
+		opts.tls = b
+	}
+	if name, ok := getStringField(L, tb, "server_name"); ok {
+		opts.serverName = name
+	}
+	if b, ok := getBoolField(L, tb, "verify"); ok {
+		opts.verify = b
+	}
+	if secs, ok := getNumberField(L, tb, "timeout"); ok && secs > 0 {
+		opts.timeout = time.Duration(secs * float64(time.Second))
+	}
+	return opts
+}
+
+func (s *socketWrapper) startTLS(ctx context.Context, opts *socketOptions) error {
+	if _, ok := s.Conn.(*tls.Conn); ok {
+		return errors.New("the connection is already using TLS")
+	}
+	if s.reader.Buffered() > 0 {
+		return errors.New("data was received before the TLS handshake")
+	}
+
+	name := opts.serverName
+	if name == "" && net.ParseIP(s.host) == nil {
+		name = s.host
+	}
+
+	c := tls.Client(s.Conn, &tls.Config{
+		ServerName:         name,
+		InsecureSkipVerify: !opts.verify,
+	})
+
+	hctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if err := c.HandshakeContext(hctx); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.Conn = c
+	s.reader = bufio.NewReader(c)
+	return nil
+}
+
+// deadline sets the time limit for the next operation on the socket.
+func (s *socketWrapper) deadline() {
+	_ = s.Conn.SetDeadline(time.Now().Add(s.timeout))
+}
+
+func (s *socketWrapper) close() {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+		_ = s.Conn.Close()
+	}
+}
+
 func connectRecv(L *lua.LState) int {
 	s, err := extractSocket(L.CheckUserData(1))
 	num := int(L.CheckNumber(2))
@@ -74,8 +207,9 @@ func connectRecv(L *lua.LState) int {
 		return 2
 	}
 
+	s.deadline()
 	buf := make([]byte, num*10)
-	n, err := io.ReadAtLeast(s.Conn, buf, num)
+	n, err := io.ReadAtLeast(s.reader, buf, num)
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(fmt.Sprintf("Error reading data from the connection: %v", err)))
@@ -95,7 +229,8 @@ func connectRecvAll(L *lua.LState) int {
 		return 2
 	}
 
-	data, err := io.ReadAll(s.Conn)
+	s.deadline()
+	data, err := io.ReadAll(s.reader)
 	if err != nil {
 		L.Push(lua.LNil)
 		L.Push(lua.LString(fmt.Sprintf("Error reading data from the connection: %v", err)))
@@ -107,6 +242,28 @@ func connectRecvAll(L *lua.LState) int {
 	return 2
 }
 
+func connectRecvLine(L *lua.LState) int {
+	s, err := extractSocket(L.CheckUserData(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("Proper parameters were not provided"))
+		return 2
+	}
+
+	s.deadline()
+	line, err := s.reader.ReadString('\n')
+	// The last line does not need to be terminated
+	if err != nil && (err != io.EOF || line == "") {
+		L.Push(lua.LNil)
+		L.Push(lua.LString(fmt.Sprintf("Error reading data from the connection: %v", err)))
+		return 2
+	}
+
+	L.Push(lua.LString(strings.TrimRight(line, "\r\n")))
+	L.Push(lua.LNil)
+	return 2
+}
+
 func connectSend(L *lua.LState) int {
 	s, err := extractSocket(L.CheckUserData(1))
 	data := L.CheckString(2)
@@ -116,6 +273,7 @@ func connectSend(L *lua.LState) int {
 		return 2
 	}
 
+	s.deadline()
 	n, err := io.WriteString(s.Conn, data)
 	if err != nil || n == 0 {
 		L.Push(lua.LNumber(n))
@@ -128,9 +286,84 @@ func connectSend(L *lua.LState) int {
 	return 2
 }
 
+func connectSetTimeout(L *lua.LState) int {
+	s, err := extractSocket(L.CheckUserData(1))
+	secs := float64(L.CheckNumber(2))
+	if err != nil || secs <= 0 {
+		L.Push(lua.LString("Proper parameters were not provided"))
+		return 1
+	}
+
+	s.timeout = time.Duration(secs * float64(time.Second))
+	L.Push(lua.LNil)
+	return 1
+}
+
+func connectStartTLS(L *lua.LState) int {
+	s, err := extractSocket(L.CheckUserData(1))
+	if err != nil {
+		L.Push(lua.LString("Proper parameters were not provided"))
+		return 1
+	}
+
+	opts := socketOptionsFromTable(L, L.OptTable(2, nil))
+	if err := s.startTLS(context.Background(), opts); err != nil {
+		L.Push(lua.LString(fmt.Sprintf("Failed to establish the TLS session: %v", err)))
+		return 1
+	}
+
+	L.Push(lua.LNil)
+	return 1
+}
+
+func connectPeerCertificates(L *lua.LState) int {
+	s, err := extractSocket(L.CheckUserData(1))
+	if err != nil {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("Proper parameters were not provided"))
+		return 2
+	}
+
+	c, ok := s.Conn.(*tls.Conn)
+	if !ok {
+		L.Push(lua.LNil)
+		L.Push(lua.LString("The connection is not using TLS"))
+		return 2
+	}
+
+	tb := L.NewTable()
+	for _, cert := range c.ConnectionState().PeerCertificates {
+		ctb := L.NewTable()
+
+		ctb.RawSetString("subject", lua.LString(cert.Subject.String()))
+		ctb.RawSetString("issuer", lua.LString(cert.Issuer.String()))
+		ctb.RawSetString("serial", lua.LString(cert.SerialNumber.String()))
+		ctb.RawSetString("not_before", lua.LString(cert.NotBefore.UTC().Format(time.RFC3339)))
+		ctb.RawSetString("not_after", lua.LString(cert.NotAfter.UTC().Format(time.RFC3339)))
+
+		names := L.NewTable()
+		for _, name := range http.NamesFromCert(cert) {
+			names.Append(lua.LString(name))
+		}
+		ctb.RawSetString("names", names)
+
+		ips := L.NewTable()
+		for _, ip := range cert.IPAddresses {
+			ips.Append(lua.LString(ip.String()))
+		}
+		ctb.RawSetString("ips", ips)
+		ctb.RawSetString("pem", lua.LString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})))
+		tb.Append(ctb)
+	}
+
+	L.Push(tb)
+	L.Push(lua.LNil)
+	return 2
+}
+
 func connectClose(L *lua.LState) int {
 	if s, err := extractSocket(L.CheckUserData(1)); err == nil {
-		s.Conn.Close()
+		s.close()
 	}
 	return 0
 }
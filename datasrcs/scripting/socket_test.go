@@ -5,18 +5,24 @@
 package scripting
 
 import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
 	"io"
 	"net"
 	"testing"
 	"time"
 
+	"github.com/owasp-amass/amass/v4/amasstest"
 	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSocketRecv(t *testing.T) {
 	script, sys := setupMockScriptEnv(`
 		name="recv"
 		type="testing"
+		capabilities={"net.socket"}
 
 		function vertical(ctx, domain)
 			local conn, err = socket.connect(ctx, "127.0.0.1", 8080, "tcp")
@@ -74,6 +80,7 @@ func TestSocketRecvAll(t *testing.T) {
 	script, sys := setupMockScriptEnv(`
 		name="recv_all"
 		type="testing"
+		capabilities={"net.socket"}
 
 		function vertical(ctx, domain)
 			local conn, err = socket.connect(ctx, "127.0.0.1", 8080, "tcp")
@@ -132,6 +139,7 @@ func TestSocketSend(t *testing.T) {
 	script, sys := setupMockScriptEnv(`
 		name="send"
 		type="testing"
+		capabilities={"net.socket"}
 
 		function vertical(ctx, domain)
 			local conn, err = socket.connect(ctx, "127.0.0.1", 8080, "tcp")
@@ -184,3 +192,234 @@ func TestSocketSend(t *testing.T) {
 		}
 	}
 }
+
+func socketTestNames(t *testing.T, script string, handle func(net.Conn), tlsConfig *tls.Config) []string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		if tlsConfig != nil {
+			conn = tls.Server(conn, tlsConfig)
+		}
+		handle(conn)
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	s, sys := setupMockScriptEnv(fmt.Sprintf(script, port))
+	require.NotNil(t, s)
+	defer func() { _ = sys.Shutdown() }()
+
+	sys.Config().AddDomain("owasp.org")
+	s.Input() <- &requests.DNSRequest{Domain: "owasp.org"}
+
+	var names []string
+	timer := time.NewTimer(10 * time.Second)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			t.Fatal("the test timed out")
+		case msg := <-s.Output():
+			if req, ok := msg.(*requests.DNSRequest); ok {
+				if req.Name == "done.owasp.org" {
+					return names
+				}
+				names = append(names, req.Name)
+			}
+		}
+	}
+}
+
+func TestSocketTLS(t *testing.T) {
+	cert, err := amasstest.NewCertificate("www.owasp.org", "tls.owasp.org")
+	require.NoError(t, err)
+
+	names := socketTestNames(t, `
+		name="tls"
+		type="testing"
+		capabilities={"net.socket"}
+
+		function vertical(ctx, domain)
+			local conn, err = socket.connect(ctx, "127.0.0.1", %d, "tcp", {tls=true, timeout=5})
+			if (err ~= nil and err ~= "") then
+				log(ctx, err)
+				new_name(ctx, "done." .. domain)
+				return
+			end
+
+			local certs
+			certs, err = conn:peer_certificates()
+			if (err == nil) then
+				for _, name in pairs(certs[1].names) do
+					new_name(ctx, name)
+				end
+			end
+
+			local line
+			line, err = conn:recv_line()
+			while (err == nil) do
+				new_name(ctx, line .. "." .. domain)
+				line, err = conn:recv_line()
+			end
+			conn:close()
+			new_name(ctx, "done." .. domain)
+		end
+	`, func(conn net.Conn) {
+		_, _ = io.WriteString(conn, "first\r\nsecond\nthird")
+	}, &tls.Config{Certificates: []tls.Certificate{cert}})
+
+	require.ElementsMatch(t, []string{"www.owasp.org", "tls.owasp.org",
+		"first.owasp.org", "second.owasp.org", "third.owasp.org"}, names)
+}
+
+func TestSocketStartTLS(t *testing.T) {
+	cert, err := amasstest.NewCertificate("mail.owasp.org")
+	require.NoError(t, err)
+
+	names := socketTestNames(t, `
+		name="starttls"
+		type="testing"
+		capabilities={"net.socket"}
+
+		function vertical(ctx, domain)
+			local conn, err = socket.connect(ctx, "127.0.0.1", %d, "tcp")
+			if (err ~= nil and err ~= "") then
+				log(ctx, err)
+				new_name(ctx, "done." .. domain)
+				return
+			end
+
+			local line
+			line, err = conn:recv_line()
+			if (err == nil and line == "220 ready") then
+				conn:send("STARTTLS\r\n")
+				line, err = conn:recv_line()
+				if (err == nil and line == "220 go ahead") then
+					err = conn:starttls({server_name="mail.owasp.org"})
+					if (err == nil) then
+						local certs = conn:peer_certificates()
+						new_name(ctx, certs[1].names[1])
+						line, err = conn:recv_line()
+						if (err == nil) then
+							new_name(ctx, line .. "." .. domain)
+						end
+					end
+				end
+			end
+			conn:close()
+			new_name(ctx, "done." .. domain)
+		end
+	`, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+
+		_, _ = io.WriteString(conn, "220 ready\r\n")
+		if line, err := r.ReadString('\n'); err != nil || line != "STARTTLS\r\n" {
+			return
+		}
+		_, _ = io.WriteString(conn, "220 go ahead\r\n")
+
+		c := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+		_, _ = io.WriteString(c, "secure\r\n")
+		_ = c.Close()
+	}, nil)
+
+	require.ElementsMatch(t, []string{"mail.owasp.org", "secure.owasp.org"}, names)
+}
+
+func TestSocketTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	names := socketTestNames(t, `
+		name="timeout"
+		type="testing"
+		capabilities={"net.socket"}
+
+		function vertical(ctx, domain)
+			local conn, err = socket.connect(ctx, "127.0.0.1", %d, "tcp", {timeout=0.2})
+			if (err ~= nil and err ~= "") then
+				log(ctx, err)
+				new_name(ctx, "done." .. domain)
+				return
+			end
+
+			local data
+			data, err = conn:recv_all()
+			if (err ~= nil) then
+				new_name(ctx, "expired." .. domain)
+			end
+
+			conn:set_timeout(0.1)
+			data, err = conn:recv_line()
+			if (err ~= nil) then
+				new_name(ctx, "again." .. domain)
+			end
+			conn:close()
+			new_name(ctx, "done." .. domain)
+		end
+	`, func(conn net.Conn) {
+		_, _ = io.WriteString(conn, "never finished")
+		<-release
+	}, nil)
+
+	require.ElementsMatch(t, []string{"expired.owasp.org", "again.owasp.org"}, names)
+}
+
+func TestSocketCleanup(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	closed := make(chan struct{})
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		_, _ = io.ReadAll(conn)
+		close(closed)
+	}()
+
+	s, sys := setupMockScriptEnv(fmt.Sprintf(`
+		name="cleanup"
+		type="testing"
+		capabilities={"net.socket"}
+
+		function vertical(ctx, domain)
+			leaked, err = socket.connect(ctx, "127.0.0.1", %d, "tcp")
+			new_name(ctx, "done." .. domain)
+		end
+	`, ln.Addr().(*net.TCPAddr).Port))
+	require.NotNil(t, s)
+	defer func() { _ = sys.Shutdown() }()
+
+	sys.Config().AddDomain("owasp.org")
+	s.Input() <- &requests.DNSRequest{Domain: "owasp.org"}
+	select {
+	case <-s.Output():
+	case <-time.After(10 * time.Second):
+		t.Fatal("the test timed out")
+	}
+
+	select {
+	case <-closed:
+		t.Fatal("the socket was closed before the script stopped")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	_ = s.Stop()
+	select {
+	case <-closed:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the socket was not closed when the script stopped")
+	}
+}
@@ -150,3 +150,12 @@ func getNumberField(L *lua.LState, t lua.LValue, key string) (float64, bool) {
 	}
 	return 0, false
 }
+
+func getBoolField(L *lua.LState, t lua.LValue, key string) (bool, bool) {
+	if lv := L.GetField(t, key); lv != nil {
+		if b, ok := lv.(lua.LBool); ok {
+			return bool(b), true
+		}
+	}
+	return false, false
+}
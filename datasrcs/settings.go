@@ -0,0 +1,136 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package datasrcs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/owasp-amass/amass/v4/datasrcs/external"
+	"github.com/owasp-amass/amass/v4/datasrcs/scripting"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/config/config"
+	"gopkg.in/yaml.v3"
+)
+
+// The configuration package ignores the HTTP, capability and external program settings provided for the data sources.
+type sourceSettingsFile struct {
+	Datasources []struct {
+		Name         string                      `yaml:"name"`
+		HTTP         *http.ClientSettings        `yaml:"http,omitempty"`
+		Capabilities *scripting.CapabilityPolicy `yaml:"capabilities,omitempty"`
+		External     *external.Settings          `yaml:"ext,omitempty"`
+	} `yaml:"datasources"`
+}
+
+func readSourceSettings(cfg *config.Config) (*sourceSettingsFile, error) {
+	raw, found := cfg.Options["datasources"]
+	if !found {
+		return nil, nil
+	}
+
+	path, ok := raw.(string)
+	if !ok {
+		return nil, errors.New("datasources option is not a string")
+	}
+
+	abs, err := cfg.AbsPathFromConfigDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path for the datasources file: %v", err)
+	}
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the datasources file: %v", err)
+	}
+
+	var file sourceSettingsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse the datasources file: %v", err)
+	}
+	return &file, nil
+}
+
+// HTTPSettings returns the HTTP client settings provided for the data sources in the datasources
+// file, keyed by the lowercase data source name. The file paths within the settings are resolved
+// relative to the configuration directory.
+func HTTPSettings(cfg *config.Config) (map[string]*http.ClientSettings, error) {
+	file, err := readSourceSettings(cfg)
+	if file == nil {
+		return nil, err
+	}
+
+	settings := make(map[string]*http.ClientSettings)
+	for _, src := range file.Datasources {
+		if src.HTTP == nil {
+			continue
+		}
+
+		s := src.HTTP
+		for _, p := range []*string{&s.CABundle, &s.ClientCert, &s.ClientKey} {
+			// Missing files are reported when the client is built for the data source
+			if *p != "" {
+				if abs, err := cfg.AbsPathFromConfigDir(*p); err == nil {
+					*p = abs
+				}
+			}
+		}
+		settings[strings.ToLower(src.Name)] = s
+	}
+	return settings, nil
+}
+
+// CapabilityPolicies returns the capabilities allowed and denied to the data sources in the
+// datasources file, keyed by the lowercase data source name. The policies are not validated.
+func CapabilityPolicies(cfg *config.Config) (map[string]*scripting.CapabilityPolicy, error) {
+	file, err := readSourceSettings(cfg)
+	if file == nil {
+		return nil, err
+	}
+
+	policies := make(map[string]*scripting.CapabilityPolicy)
+	for _, src := range file.Datasources {
+		if src.Capabilities == nil {
+			continue
+		}
+		policies[strings.ToLower(src.Name)] = src.Capabilities
+	}
+	return policies, nil
+}
+
+// ExternalSettings returns the external programs declared as data sources in the datasources
+// file, keyed by the data source name. The working directory, and the command when it is a
+// relative path, are resolved relative to the configuration directory. The settings are not validated.
+func ExternalSettings(cfg *config.Config) (map[string]*external.Settings, error) {
+	file, err := readSourceSettings(cfg)
+	if file == nil {
+		return nil, err
+	}
+
+	settings := make(map[string]*external.Settings)
+	for _, src := range file.Datasources {
+		if src.External == nil {
+			continue
+		}
+
+		s := src.External
+		// Commands without a path separator are found using the PATH
+		if strings.ContainsRune(s.Command, filepath.Separator) && !filepath.IsAbs(s.Command) {
+			if abs, err := cfg.AbsPathFromConfigDir(s.Command); err == nil {
+				s.Command = abs
+			}
+		}
+		if s.Dir != "" {
+			if abs, err := cfg.AbsPathFromConfigDir(s.Dir); err == nil {
+				s.Dir = abs
+			}
+		}
+		settings[src.Name] = s
+	}
+	return settings, nil
+}
@@ -0,0 +1,122 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package datasrcs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/owasp-amass/config/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPSettings(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ca.pem"), []byte("ca"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "datasources.yaml"), []byte(`
+datasources:
+  - name: AlienVault
+    creds:
+      account:
+        apikey: key
+  - name: Shodan
+    http:
+      proxy: socks5://127.0.0.1:9050
+      tls_verify: true
+      ca_bundle: ca.pem
+      client_cert: missing.pem
+      user_agent: amass-test
+`), 0600))
+
+	cfg := config.NewConfig()
+	cfg.Filepath = filepath.Join(dir, "config.yaml")
+	settings, err := HTTPSettings(cfg)
+	require.NoError(t, err)
+	require.Empty(t, settings, "no datasources file was configured")
+
+	cfg.Options["datasources"] = "datasources.yaml"
+	settings, err = HTTPSettings(cfg)
+	require.NoError(t, err)
+	require.Len(t, settings, 1)
+
+	s, found := settings["shodan"]
+	require.True(t, found)
+	require.Equal(t, "socks5://127.0.0.1:9050", s.Proxy)
+	require.True(t, s.TLSVerify)
+	require.Equal(t, filepath.Join(dir, "ca.pem"), s.CABundle)
+	require.Equal(t, "missing.pem", s.ClientCert)
+	require.Equal(t, "amass-test", s.UserAgent)
+}
+
+func TestCapabilityPolicies(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "datasources.yaml"), []byte(`
+datasources:
+  - name: BGPTools
+    capabilities:
+      deny:
+        - fs.write
+  - name: Custom
+    capabilities:
+      allow: [net.http, dns]
+  - name: Shodan
+    http:
+      user_agent: amass-test
+`), 0600))
+
+	cfg := config.NewConfig()
+	cfg.Filepath = filepath.Join(dir, "config.yaml")
+	cfg.Options["datasources"] = "datasources.yaml"
+	policies, err := CapabilityPolicies(cfg)
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	require.Equal(t, []string{"fs.write"}, policies["bgptools"].Deny)
+	require.Equal(t, []string{"net.http", "dns"}, policies["custom"].Allow)
+
+	cfg.Options["datasources"] = "missing.yaml"
+	_, err = CapabilityPolicies(cfg)
+	require.Error(t, err)
+}
+
+func TestExternalSettings(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bin"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bin", "tool"), []byte("#!/bin/sh\n"), 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "datasources.yaml"), []byte(`
+datasources:
+  - name: MyTool
+    ext:
+      command: bin/tool
+      args: [--json]
+      dir: bin
+      timeout: 30
+      events: [vertical]
+  - name: Subfinder
+    ext:
+      command: subfinder
+      restarts: -1
+  - name: Shodan
+    http:
+      user_agent: amass-test
+`), 0600))
+
+	cfg := config.NewConfig()
+	cfg.Filepath = filepath.Join(dir, "config.yaml")
+	cfg.Options["datasources"] = "datasources.yaml"
+	exts, err := ExternalSettings(cfg)
+	require.NoError(t, err)
+	require.Len(t, exts, 2)
+
+	tool := exts["MyTool"]
+	require.Equal(t, filepath.Join(dir, "bin", "tool"), tool.Command)
+	require.Equal(t, filepath.Join(dir, "bin"), tool.Dir)
+	require.Equal(t, []string{"--json"}, tool.Args)
+	require.Equal(t, 30, tool.Timeout)
+	require.Equal(t, []string{"vertical"}, tool.Events)
+	// Commands without a path are found using the PATH
+	require.Equal(t, "subfinder", exts["Subfinder"].Command)
+	require.Equal(t, -1, exts["Subfinder"].Restarts)
+}
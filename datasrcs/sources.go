@@ -6,10 +6,13 @@ package datasrcs
 
 import (
 	"sort"
+	"strings"
 
 	"github.com/caffix/service"
 	"github.com/caffix/stringset"
+	"github.com/owasp-amass/amass/v4/datasrcs/external"
 	"github.com/owasp-amass/amass/v4/datasrcs/scripting"
+	"github.com/owasp-amass/amass/v4/net/http"
 	"github.com/owasp-amass/amass/v4/systems"
 	"github.com/owasp-amass/config/config"
 )
@@ -18,20 +21,126 @@ import (
 func GetAllSources(sys systems.System) []service.Service {
 	var srvs []service.Service
 
-	if scripts, err := sys.Config().AcquireScripts(); err == nil {
+	cfg := sys.Config()
+	settings, err := HTTPSettings(cfg)
+	if err != nil {
+		cfg.Log.Printf("Failed to load the data source HTTP settings: %v", err)
+	}
+
+	policies, err := CapabilityPolicies(cfg)
+	if err != nil {
+		cfg.Log.Printf("Failed to load the data source capability policies: %v", err)
+	}
+
+	names := stringset.New()
+	defer names.Close()
+
+	if scripts, err := cfg.AcquireScripts(); err == nil {
 		for _, script := range scripts {
-			if s := scripting.NewScript(script, sys); s != nil {
-				srvs = append(srvs, s)
+			if srv := scriptSource(sys, script, settings, policies); srv != nil {
+				srvs = append(srvs, srv)
+				names.Insert(srv.String())
 			}
 		}
 	}
 
+	srvs = append(srvs, externalSources(sys, names, policies)...)
 	sort.Slice(srvs, func(i, j int) bool {
 		return srvs[i].String() < srvs[j].String()
 	})
 	return srvs
 }
 
+// scriptSource returns the data source running the script. The script is given its own HTTP
+// client when it has HTTP settings in the datasources file, and its capabilities are changed by
+// the policy in the same file. The script is left out when either cannot be applied, so its
+// traffic cannot bypass a proxy. Scripts that declare an external program are run by an
+// external data source.
+func scriptSource(sys systems.System, script string, settings map[string]*http.ClientSettings, policies map[string]*scripting.CapabilityPolicy) service.Service {
+	cfg := sys.Config()
+
+	s := scripting.NewScript(script, sys)
+	if s == nil {
+		return nil
+	}
+
+	if st, found := settings[strings.ToLower(s.String())]; found {
+		c, err := http.NewClient(st, sys.Dialer())
+		if err != nil {
+			cfg.Log.Printf("%s: Failed to build the HTTP client: %v", s.String(), err)
+			s.Release()
+			return nil
+		}
+		s.SetWebClient(c)
+	}
+	if p, found := policies[strings.ToLower(s.String())]; found {
+		if err := p.Validate(); err != nil {
+			cfg.Log.Printf("%s: Failed to apply the capability policy: %v", s.String(), err)
+			s.Release()
+			return nil
+		}
+		s.SetCapabilityPolicy(p)
+	}
+
+	if st := s.External(); st != nil {
+		defer s.Release()
+		return externalSource(sys, s.String(), st, hasCapability(s.Capabilities(), scripting.CapExec))
+	}
+	return s
+}
+
+// externalSources returns the data sources running the external programs in the datasources
+// file, except those named the same as another data source.
+func externalSources(sys systems.System, names *stringset.Set, policies map[string]*scripting.CapabilityPolicy) []service.Service {
+	cfg := sys.Config()
+
+	exts, err := ExternalSettings(cfg)
+	if err != nil {
+		cfg.Log.Printf("Failed to load the external data source settings: %v", err)
+	}
+
+	var srvs []service.Service
+	for name, st := range exts {
+		if names.Has(name) {
+			cfg.Log.Printf("%s: The external program conflicts with another data source", name)
+			continue
+		}
+
+		// The programs in the configuration are granted execution unless the policy denies it
+		granted := true
+		if p, found := policies[strings.ToLower(name)]; found {
+			granted = !hasCapability(p.Deny, scripting.CapExec)
+		}
+		if srv := externalSource(sys, name, st, granted); srv != nil {
+			srvs = append(srvs, srv)
+		}
+	}
+	return srvs
+}
+
+func externalSource(sys systems.System, name string, st *external.Settings, granted bool) service.Service {
+	if !granted {
+		sys.Config().Log.Printf("%s: The %s capability is required to run the external program", name, scripting.CapExec)
+		return nil
+	}
+
+	srv, err := external.NewSource(name, st, sys)
+	if err != nil {
+		sys.Config().Log.Printf("%s: Failed to create the external data source: %v", name, err)
+		return nil
+	}
+	return srv
+}
+
+func hasCapability(caps []string, capability string) bool {
+	for _, c := range caps {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
 // SelectedDataSources uses the config and available data sources to return the selected data sources.
 func SelectedDataSources(cfg *config.Config, avail []service.Service) []service.Service {
 	specified := stringset.New()
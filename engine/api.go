@@ -0,0 +1,208 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package engine
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIPrefix is the path that begins the URLs of the REST API.
+const APIPrefix = "/v1"
+
+// the largest job request body accepted by the REST API
+const maxRequestSize = 1 << 20
+
+// Health is the response of the REST API health endpoint.
+type Health struct {
+	Status  string `json:"status"`
+	Queued  int    `json:"queued"`
+	Running int    `json:"running"`
+	MaxJobs int    `json:"max_jobs"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// Handler returns the HTTP handler serving the REST API of the engine:
+//
+//	GET    /v1/health              the number of queued and running jobs
+//	POST   /v1/jobs                submits a JobRequest and returns the JobStatus
+//	GET    /v1/jobs                the JobStatus of all the jobs
+//	GET    /v1/jobs/{id}           the JobStatus of the job
+//	DELETE /v1/jobs/{id}           forgets the completed job
+//	GET    /v1/jobs/{id}/progress  the Progress of the job
+//	GET    /v1/jobs/{id}/findings  the names discovered by the job
+//	POST   /v1/jobs/{id}/cancel    cancels the queued or running job
+func (e *Engine) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc(APIPrefix+"/health", e.handleHealth)
+	mux.HandleFunc(APIPrefix+"/jobs", e.handleJobs)
+	mux.HandleFunc(APIPrefix+"/jobs/", e.handleJob)
+	return e.authorize(mux)
+}
+
+func (e *Engine) authorize(next http.Handler) http.Handler {
+	if e.settings.Token == "" {
+		return next
+	}
+
+	expected := []byte("Bearer " + e.settings.Token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, errors.New("a valid bearer token is required"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (e *Engine) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet) {
+		return
+	}
+
+	queued, running := e.Counts()
+	writeJSON(w, http.StatusOK, &Health{
+		Status:  "ok",
+		Queued:  queued,
+		Running: running,
+		MaxJobs: e.settings.MaxJobs,
+	})
+}
+
+func (e *Engine) handleJobs(w http.ResponseWriter, r *http.Request) {
+	if !allowMethods(w, r, http.MethodGet, http.MethodPost) {
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		statuses := []*JobStatus{}
+		for _, j := range e.Jobs() {
+			statuses = append(statuses, j.Status())
+		}
+		writeJSON(w, http.StatusOK, statuses)
+		return
+	}
+
+	var req JobRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	// Misspelled options would otherwise be silently ignored
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("failed to parse the job request: %v", err))
+		return
+	}
+
+	j, err := e.Submit(&req)
+	if errors.Is(err, ErrShutdown) {
+		writeError(w, http.StatusServiceUnavailable, err)
+		return
+	} else if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Location", APIPrefix+"/jobs/"+j.ID())
+	writeJSON(w, http.StatusCreated, j.Status())
+}
+
+func (e *Engine) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, APIPrefix+"/jobs/"), "/"), "/")
+	if len(parts) > 2 || parts[0] == "" {
+		writeError(w, http.StatusNotFound, errors.New("the resource was not found"))
+		return
+	}
+
+	j, err := e.Job(parts[0])
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+
+	var action string
+	if len(parts) == 2 {
+		action = parts[1]
+	}
+
+	switch action {
+	case "":
+		if !allowMethods(w, r, http.MethodGet, http.MethodDelete) {
+			return
+		}
+		if r.Method == http.MethodDelete {
+			if err := e.Remove(j.ID()); err != nil {
+				writeError(w, errorStatus(err), err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, j.Status())
+	case "progress":
+		if allowMethods(w, r, http.MethodGet) {
+			writeJSON(w, http.StatusOK, j.Progress(r.Context(), e.graph(), e.sys.Provenance()))
+		}
+	case "findings":
+		if allowMethods(w, r, http.MethodGet) {
+			writeJSON(w, http.StatusOK, j.Findings(r.Context(), e.graph(), e.sys.Provenance()))
+		}
+	case "cancel":
+		if !allowMethods(w, r, http.MethodPost) {
+			return
+		}
+		if err := e.Cancel(j.ID()); err != nil {
+			writeError(w, errorStatus(err), err)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, j.Status())
+	default:
+		writeError(w, http.StatusNotFound, errors.New("the resource was not found"))
+	}
+}
+
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, m := range methods {
+		if r.Method == m {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("the %s method is not allowed", r.Method))
+	return false
+}
+
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrJobDone), errors.Is(err, ErrJobActive):
+		return http.StatusConflict
+	case errors.Is(err, ErrShutdown):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &apiError{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(v)
+}
@@ -0,0 +1,415 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package engine
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/caffix/netmap"
+	"github.com/caffix/service"
+	"github.com/owasp-amass/amass/v4/datasrcs"
+	"github.com/owasp-amass/amass/v4/enum"
+	"github.com/owasp-amass/amass/v4/systems"
+	"github.com/owasp-amass/config/config"
+)
+
+// DefaultMaxJobs is the number of jobs that run at the same time when no limit is provided.
+const DefaultMaxJobs = 1
+
+// DefaultMaxDoneJobs is the number of completed jobs kept when no limit is provided.
+const DefaultMaxDoneJobs = 100
+
+// The errors returned by the engine for the jobs that cannot be acted on.
+var (
+	ErrJobNotFound = errors.New("the job was not found")
+	ErrJobDone     = errors.New("the job has already completed")
+	ErrJobActive   = errors.New("the job has not completed")
+	ErrShutdown    = errors.New("the engine has been shut down")
+)
+
+// Settings configure the engine.
+type Settings struct {
+	// MaxJobs is the number of jobs that run at the same time, while the other jobs are queued
+	MaxJobs int
+	// MaxDoneJobs is the number of completed jobs kept, while the oldest are forgotten
+	MaxDoneJobs int
+	// Sources returns the data sources available to each job. Only DNS is used when not provided.
+	Sources func(sys systems.System) []service.Service
+	// Token is required as the bearer token of the REST API requests when provided
+	Token string
+}
+
+// Engine performs the enumerations submitted as jobs using a long-running System, so the
+// resolvers, ASN cache and graph databases remain ready between the enumerations.
+type Engine struct {
+	sync.Mutex
+	sys      systems.System
+	settings Settings
+	jobs     map[string]*Job
+	order    []*Job
+	queue    []*Job
+	running  int
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// New returns an Engine performing the jobs with the provided System.
+func New(sys systems.System, settings Settings) *Engine {
+	if settings.MaxJobs <= 0 {
+		settings.MaxJobs = DefaultMaxJobs
+	}
+	if settings.MaxDoneJobs <= 0 {
+		settings.MaxDoneJobs = DefaultMaxDoneJobs
+	}
+
+	return &Engine{
+		sys:      sys,
+		settings: settings,
+		jobs:     make(map[string]*Job),
+	}
+}
+
+// Submit validates the request and queues the job that will perform the enumeration.
+func (e *Engine) Submit(req *JobRequest) (*Job, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := e.jobConfig(id, req)
+	if err != nil {
+		return nil, err
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	if e.closed {
+		return nil, ErrShutdown
+	}
+
+	j := newJob(id, req, cfg)
+	e.jobs[id] = j
+	e.order = append(e.order, j)
+	e.queue = append(e.queue, j)
+	e.dispatch()
+	return j, nil
+}
+
+// Job returns the job with the provided identifier.
+func (e *Engine) Job(id string) (*Job, error) {
+	e.Lock()
+	defer e.Unlock()
+
+	j, found := e.jobs[id]
+	if !found {
+		return nil, ErrJobNotFound
+	}
+	return j, nil
+}
+
+// Jobs returns all the jobs known to the engine in the order they were submitted.
+func (e *Engine) Jobs() []*Job {
+	e.Lock()
+	defer e.Unlock()
+
+	return append([]*Job(nil), e.order...)
+}
+
+// Counts returns the number of jobs waiting in the queue and the number of jobs running.
+func (e *Engine) Counts() (int, int) {
+	e.Lock()
+	defer e.Unlock()
+
+	return len(e.queue), e.running
+}
+
+// Cancel removes the job from the queue, or stops the enumeration when the job is running.
+func (e *Engine) Cancel(id string) error {
+	e.Lock()
+	defer e.Unlock()
+
+	j, found := e.jobs[id]
+	if !found {
+		return ErrJobNotFound
+	}
+
+	for i, q := range e.queue {
+		if q == j {
+			e.queue = append(e.queue[:i], e.queue[i+1:]...)
+			j.Lock()
+			j.state = StateCanceled
+			j.finished = time.Now()
+			j.Unlock()
+			e.prune()
+			return nil
+		}
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	if j.state != StateRunning || j.canceled {
+		return ErrJobDone
+	}
+	j.canceled = true
+	j.cancel()
+	return nil
+}
+
+// Remove forgets the completed job with the provided identifier.
+func (e *Engine) Remove(id string) error {
+	e.Lock()
+	defer e.Unlock()
+
+	j, found := e.jobs[id]
+	if !found {
+		return ErrJobNotFound
+	}
+	if !j.Done() {
+		return ErrJobActive
+	}
+
+	e.forget(j)
+	return nil
+}
+
+// forget removes the job from the engine. The engine lock must be held by the caller.
+func (e *Engine) forget(j *Job) {
+	delete(e.jobs, j.id)
+	for i, o := range e.order {
+		if o == j {
+			e.order = append(e.order[:i], e.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// prune forgets the oldest completed jobs beyond the number of completed jobs kept, so an
+// engine running for a long time does not grow without bound. The engine lock must be held
+// by the caller.
+func (e *Engine) prune() {
+	var done []*Job
+	for _, j := range e.order {
+		if j.Done() {
+			done = append(done, j)
+		}
+	}
+
+	for i := 0; i < len(done)-e.settings.MaxDoneJobs; i++ {
+		e.forget(done[i])
+	}
+}
+
+// Shutdown cancels the queued and running jobs, and waits for the enumerations to stop.
+// The System provided to the engine is not shut down.
+func (e *Engine) Shutdown() {
+	e.Lock()
+	e.closed = true
+
+	now := time.Now()
+	for _, j := range e.queue {
+		j.Lock()
+		j.state = StateCanceled
+		j.finished = now
+		j.Unlock()
+	}
+	e.queue = nil
+
+	for _, j := range e.order {
+		j.Lock()
+		if j.state == StateRunning && !j.canceled {
+			j.canceled = true
+			j.cancel()
+		}
+		j.Unlock()
+	}
+	e.Unlock()
+
+	e.wg.Wait()
+}
+
+// dispatch starts the queued jobs while fewer than the maximum number of jobs are running.
+// The engine lock must be held by the caller.
+func (e *Engine) dispatch() {
+	for !e.closed && e.running < e.settings.MaxJobs && len(e.queue) > 0 {
+		j := e.queue[0]
+		e.queue = e.queue[1:]
+
+		var ctx context.Context
+		var cancel context.CancelFunc
+		if j.req.Timeout == 0 {
+			ctx, cancel = context.WithCancel(context.Background())
+		} else {
+			ctx, cancel = context.WithTimeout(context.Background(), time.Duration(j.req.Timeout)*time.Minute)
+		}
+
+		j.Lock()
+		j.state = StateRunning
+		j.started = time.Now()
+		j.cancel = cancel
+		j.cfg.CollectionStartTime = j.started
+		j.Unlock()
+
+		e.running++
+		e.wg.Add(1)
+		go e.run(ctx, j)
+	}
+}
+
+func (e *Engine) run(ctx context.Context, j *Job) {
+	defer e.wg.Done()
+
+	j.cfg.Log.Printf("The enumeration of %v has started", j.cfg.Domains())
+	err := e.execute(ctx, j)
+
+	j.Lock()
+	j.cancel()
+	j.finished = time.Now()
+	switch {
+	case j.canceled:
+		j.state = StateCanceled
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		// The enumeration was limited to the requested duration
+		j.state = StateFinished
+	case err != nil:
+		j.state = StateFailed
+		j.err = err
+	default:
+		j.state = StateFinished
+	}
+	state := j.state
+	j.Unlock()
+	j.cfg.Log.Printf("The enumeration of %v is %s", j.cfg.Domains(), state)
+
+	e.Lock()
+	e.running--
+	e.prune()
+	e.dispatch()
+	e.Unlock()
+}
+
+func (e *Engine) execute(ctx context.Context, j *Job) error {
+	g := e.graph()
+	if g == nil {
+		return errors.New("the system does not provide a graph database")
+	}
+
+	sys := systems.NewJobSystem(j.cfg, e.sys)
+	defer func() { _ = sys.Shutdown() }()
+
+	if e.settings.Sources != nil {
+		avail := e.settings.Sources(sys)
+		srcs := datasrcs.SelectedDataSources(j.cfg, avail)
+		releaseUnselected(avail, srcs)
+
+		if err := sys.SetDataSources(srcs); err != nil {
+			return err
+		}
+	}
+
+	j.Lock()
+	j.sources = len(sys.DataSources())
+	j.Unlock()
+
+	return enum.NewEnumeration(j.cfg, sys, g).Start(ctx)
+}
+
+// graph returns the graph database storing the findings of the jobs.
+func (e *Engine) graph() *netmap.Graph {
+	if graphs := e.sys.GraphDatabases(); len(graphs) > 0 {
+		return graphs[0]
+	}
+	return nil
+}
+
+// jobConfig returns the configuration of the job. The settings shared by all the jobs, such as
+// the data source credentials and wordlists, are taken from the configuration of the System,
+// while the scope and the enumeration options are provided by the job request.
+func (e *Engine) jobConfig(id string, req *JobRequest) (*config.Config, error) {
+	base := e.sys.Config()
+	cfg := config.NewConfig()
+
+	cfg.Options = base.Options
+	cfg.Filepath = base.Filepath
+	cfg.ScriptsDirectory = base.ScriptsDirectory
+	cfg.Dir = base.Dir
+	cfg.GraphDBs = base.GraphDBs
+	cfg.DataSrcConfigs = base.DataSrcConfigs
+	cfg.MaxDNSQueries = base.MaxDNSQueries
+	cfg.Wordlist = base.Wordlist
+	cfg.AltWordlist = base.AltWordlist
+	cfg.Recursive = base.Recursive
+	cfg.MinForRecursive = base.MinForRecursive
+	cfg.MaxDepth = base.MaxDepth
+	cfg.FlipWords = base.FlipWords
+	cfg.FlipNumbers = base.FlipNumbers
+	cfg.AddWords = base.AddWords
+	cfg.AddNumbers = base.AddNumbers
+	cfg.MinForWordFlip = base.MinForWordFlip
+	cfg.EditDistance = base.EditDistance
+	cfg.MinimumTTL = base.MinimumTTL
+	cfg.RecordTypes = base.RecordTypes
+	cfg.Resolvers = base.Resolvers
+	cfg.ResolversQPS = base.ResolversQPS
+	cfg.TrustedResolvers = base.TrustedResolvers
+	cfg.TrustedQPS = base.TrustedQPS
+	cfg.Verbose = base.Verbose
+	if base.Scope != nil {
+		cfg.Scope.Ports = base.Scope.Ports
+	}
+
+	var w io.Writer = io.Discard
+	if base.Log != nil {
+		w = base.Log.Writer()
+	}
+	cfg.Log = log.New(w, "Job "+id+": ", log.Lmicroseconds|log.Lmsgprefix)
+
+	if err := cfg.UpdateConfig(req); err != nil {
+		return nil, fmt.Errorf("failed to configure the job: %v", err)
+	}
+	if err := cfg.CheckSettings(); err != nil {
+		return nil, fmt.Errorf("failed to configure the job: %v", err)
+	}
+	return cfg, nil
+}
+
+// releaseUnselected frees the resources held by the data sources that will not be started.
+func releaseUnselected(avail, selected []service.Service) {
+	used := make(map[service.Service]struct{}, len(selected))
+	for _, src := range selected {
+		used[src] = struct{}{}
+	}
+
+	for _, src := range avail {
+		if _, found := used[src]; found {
+			continue
+		}
+		if r, ok := src.(interface{ Release() }); ok {
+			r.Release()
+		}
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 8)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate the job identifier: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
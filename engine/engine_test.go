@@ -0,0 +1,234 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package engine
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/amass/v4/amasstest"
+	"github.com/owasp-amass/amass/v4/notify"
+	"github.com/owasp-amass/amass/v4/systems"
+	"github.com/owasp-amass/config/config"
+	"github.com/stretchr/testify/require"
+)
+
+const uticaZone = `
+@	IN	SOA	ns1.utica.edu. hostmaster.utica.edu. 1 3600 600 86400 300
+@	IN	NS	ns1.utica.edu.
+@	IN	MX	10 mail.utica.edu.
+@	IN	A	127.0.0.10
+ns1	IN	A	127.0.0.53
+mail	IN	A	127.0.0.25
+www	IN	CNAME	web.utica.edu.
+web	IN	A	127.0.0.80
+`
+
+func setupEngine(t *testing.T, settings Settings) (*Engine, *httptest.Server) {
+	srv, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+	require.NoError(t, srv.LoadZoneString("utica.edu", uticaZone))
+
+	cfg := config.NewConfig()
+	cfg.Dir = t.TempDir()
+	sys, err := systems.NewIsolatedSystem(cfg, srv.Pool(), srv.Pool())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = sys.Shutdown() })
+
+	e := New(sys, settings)
+	ts := httptest.NewServer(e.Handler())
+	t.Cleanup(ts.Close)
+	t.Cleanup(e.Shutdown)
+	return e, ts
+}
+
+func apiRequest(t *testing.T, method, url, body string, out interface{}) int {
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	if out != nil {
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(out))
+	}
+	return resp.StatusCode
+}
+
+func waitForJob(t *testing.T, j *Job) {
+	require.Eventually(t, j.Done, time.Minute, 50*time.Millisecond)
+}
+
+func TestEngineAPI(t *testing.T) {
+	e, ts := setupEngine(t, Settings{})
+
+	var status JobStatus
+	require.Equal(t, http.StatusCreated, apiRequest(t, "POST", ts.URL+"/v1/jobs",
+		`{"domains": ["utica.edu"], "names": ["www.utica.edu"]}`, &status))
+	require.NotEmpty(t, status.ID)
+	require.Equal(t, []string{"utica.edu"}, status.Request.Domains)
+
+	j, err := e.Job(status.ID)
+	require.NoError(t, err)
+	waitForJob(t, j)
+
+	require.Equal(t, http.StatusOK, apiRequest(t, "GET", ts.URL+"/v1/jobs/"+j.ID(), "", &status))
+	require.Equal(t, StateFinished, status.State)
+	require.NotNil(t, status.Started)
+	require.NotNil(t, status.Finished)
+
+	var findings []*notify.Finding
+	require.Equal(t, http.StatusOK, apiRequest(t, "GET", ts.URL+"/v1/jobs/"+j.ID()+"/findings", "", &findings))
+	records := make(map[string][]notify.Record)
+	for _, f := range findings {
+		require.Equal(t, "utica.edu", f.Domain)
+		records[f.Name] = f.Records
+	}
+	require.Len(t, records, 5)
+	require.Contains(t, records["web.utica.edu"], notify.Record{Type: "A", Value: "127.0.0.80"})
+	require.Contains(t, records["www.utica.edu"], notify.Record{Type: "CNAME", Value: "web.utica.edu"})
+
+	var progress Progress
+	require.Equal(t, http.StatusOK, apiRequest(t, "GET", ts.URL+"/v1/jobs/"+j.ID()+"/progress", "", &progress))
+	require.Equal(t, 5, progress.Names)
+	require.Equal(t, 4, progress.Addresses)
+	require.Greater(t, progress.Elapsed, 0.0)
+
+	var list []*JobStatus
+	require.Equal(t, http.StatusOK, apiRequest(t, "GET", ts.URL+"/v1/jobs", "", &list))
+	require.Len(t, list, 1)
+
+	require.Equal(t, http.StatusConflict, apiRequest(t, "POST", ts.URL+"/v1/jobs/"+j.ID()+"/cancel", "", nil))
+	require.Equal(t, http.StatusNoContent, apiRequest(t, "DELETE", ts.URL+"/v1/jobs/"+j.ID(), "", nil))
+	require.Equal(t, http.StatusNotFound, apiRequest(t, "GET", ts.URL+"/v1/jobs/"+j.ID(), "", nil))
+}
+
+func TestEngineQueue(t *testing.T) {
+	e, ts := setupEngine(t, Settings{MaxJobs: 1})
+
+	first, err := e.Submit(&JobRequest{Domains: []string{"utica.edu"}})
+	require.NoError(t, err)
+	second, err := e.Submit(&JobRequest{Domains: []string{"utica.edu"}, Names: []string{"www.utica.edu"}})
+	require.NoError(t, err)
+	third, err := e.Submit(&JobRequest{Domains: []string{"utica.edu"}})
+	require.NoError(t, err)
+
+	// Only a single job runs at a time
+	require.Equal(t, StateRunning, first.State())
+	require.Equal(t, StateQueued, second.State())
+	require.Equal(t, StateQueued, third.State())
+	var health Health
+	require.Equal(t, http.StatusOK, apiRequest(t, "GET", ts.URL+"/v1/health", "", &health))
+	require.Equal(t, Health{Status: "ok", Queued: 2, Running: 1, MaxJobs: 1}, health)
+
+	// The queued jobs are canceled without running
+	require.Equal(t, http.StatusAccepted, apiRequest(t, "POST", ts.URL+"/v1/jobs/"+third.ID()+"/cancel", "", nil))
+	require.Equal(t, StateCanceled, third.State())
+	require.ErrorIs(t, e.Remove(second.ID()), ErrJobActive)
+
+	waitForJob(t, first)
+	waitForJob(t, second)
+	require.Equal(t, StateFinished, first.State())
+	require.Equal(t, StateFinished, second.State())
+	require.True(t, second.Status().Started.After(*first.Status().Finished) ||
+		second.Status().Started.Equal(*first.Status().Finished))
+
+	// The running jobs are stopped by the cancellation
+	fourth, err := e.Submit(&JobRequest{Domains: []string{"utica.edu"}})
+	require.NoError(t, err)
+	require.NoError(t, e.Cancel(fourth.ID()))
+	waitForJob(t, fourth)
+	require.Equal(t, StateCanceled, fourth.State())
+	require.ErrorIs(t, e.Cancel(fourth.ID()), ErrJobDone)
+
+	e.Shutdown()
+	_, err = e.Submit(&JobRequest{Domains: []string{"utica.edu"}})
+	require.ErrorIs(t, err, ErrShutdown)
+}
+
+func TestEngineMaxDoneJobs(t *testing.T) {
+	e, _ := setupEngine(t, Settings{MaxJobs: 1, MaxDoneJobs: 2})
+
+	var jobs []*Job
+	for i := 0; i < 4; i++ {
+		j, err := e.Submit(&JobRequest{Domains: []string{"utica.edu"}})
+		require.NoError(t, err)
+		jobs = append(jobs, j)
+	}
+	// The queued jobs that are canceled also count as completed
+	require.NoError(t, e.Cancel(jobs[3].ID()))
+	for _, j := range jobs {
+		waitForJob(t, j)
+	}
+
+	// Only the most recently submitted completed jobs are kept
+	require.Eventually(t, func() bool { return len(e.Jobs()) == 2 }, 10*time.Second, 10*time.Millisecond)
+	for _, j := range jobs[:2] {
+		_, err := e.Job(j.ID())
+		require.ErrorIs(t, err, ErrJobNotFound)
+	}
+	for _, j := range jobs[2:] {
+		_, err := e.Job(j.ID())
+		require.NoError(t, err)
+	}
+}
+
+func TestEngineAPIErrors(t *testing.T) {
+	_, ts := setupEngine(t, Settings{Token: "secret"})
+
+	var apiErr apiError
+	require.Equal(t, http.StatusUnauthorized, apiRequest(t, "GET", ts.URL+"/v1/jobs", "", &apiErr))
+	require.NotEmpty(t, apiErr.Error)
+
+	authorized := func(method, path, body string) int {
+		req, err := http.NewRequest(method, ts.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer secret")
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, authorized("GET", "/v1/jobs", ""))
+	require.Equal(t, http.StatusBadRequest, authorized("POST", "/v1/jobs", `{"domains": [`))
+	require.Equal(t, http.StatusBadRequest, authorized("POST", "/v1/jobs", `{"domain": ["utica.edu"]}`))
+	require.Equal(t, http.StatusBadRequest, authorized("POST", "/v1/jobs", `{"names": ["www.utica.edu"]}`))
+	require.Equal(t, http.StatusBadRequest, authorized("POST", "/v1/jobs", `{"domains": ["utica.edu"], "cidrs": ["10.0.0.0/33"]}`))
+	require.Equal(t, http.StatusBadRequest, authorized("POST", "/v1/jobs", `{"domains": ["utica.edu"], "include": ["crtsh"], "exclude": ["dnsdumpster"]}`))
+	require.Equal(t, http.StatusNotFound, authorized("GET", "/v1/jobs/unknown", ""))
+	require.Equal(t, http.StatusMethodNotAllowed, authorized("PUT", "/v1/jobs", ""))
+	require.Equal(t, http.StatusMethodNotAllowed, authorized("POST", "/v1/health", ""))
+}
+
+func TestJobRequestConfig(t *testing.T) {
+	req := &JobRequest{
+		Domains:   []string{"utica.edu"},
+		Addresses: []string{"192.0.2.1"},
+		CIDRs:     []string{"198.51.100.0/24"},
+		ASNs:      []int{26808},
+		Blacklist: []string{"mail.utica.edu"},
+		Active:    true,
+		Exclude:   []string{"crtsh"},
+	}
+	require.NoError(t, req.Validate())
+
+	cfg := config.NewConfig()
+	require.NoError(t, cfg.UpdateConfig(req))
+	require.Equal(t, []string{"utica.edu"}, cfg.Domains())
+	require.True(t, cfg.IsAddressInScope("192.0.2.1"))
+	require.True(t, cfg.IsAddressInScope("198.51.100.7"))
+	require.True(t, cfg.Blacklisted("mail.utica.edu"))
+	require.True(t, cfg.Active)
+	require.False(t, cfg.SourceFilter.Include)
+	require.Equal(t, []string{"crtsh"}, cfg.SourceFilter.Sources)
+}
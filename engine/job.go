@@ -0,0 +1,347 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caffix/netmap"
+	"github.com/owasp-amass/amass/v4/notify"
+	"github.com/owasp-amass/amass/v4/provenance"
+	"github.com/owasp-amass/asset-db/types"
+	"github.com/owasp-amass/config/config"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/owasp-amass/open-asset-model/network"
+)
+
+// State is the stage of its life cycle that a job has reached.
+type State string
+
+// The states of the jobs.
+const (
+	StateQueued   State = "queued"
+	StateRunning  State = "running"
+	StateFinished State = "finished"
+	StateFailed   State = "failed"
+	StateCanceled State = "canceled"
+)
+
+// JobRequest describes the scope and options of an enumeration submitted to the engine.
+type JobRequest struct {
+	// Domains are the root domain names of the enumeration
+	Domains []string `json:"domains"`
+	// Names are the known subdomain names provided to seed the enumeration
+	Names []string `json:"names,omitempty"`
+	// Addresses, CIDRs and ASNs identify the infrastructure in scope
+	Addresses []string `json:"addresses,omitempty"`
+	CIDRs     []string `json:"cidrs,omitempty"`
+	ASNs      []int    `json:"asns,omitempty"`
+	// Blacklist contains the subdomain names that will not be investigated
+	Blacklist   []string `json:"blacklist,omitempty"`
+	Active      bool     `json:"active,omitempty"`
+	BruteForce  bool     `json:"brute_force,omitempty"`
+	Alterations bool     `json:"alterations,omitempty"`
+	// Include and Exclude select the data sources used by the enumeration
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Timeout is the number of minutes the enumeration can run, and zero means no limit
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// Validate checks that the request describes an enumeration that can be performed.
+func (r *JobRequest) Validate() error {
+	if len(r.Domains) == 0 {
+		return errors.New("no root domain names were provided")
+	}
+	for _, d := range r.Domains {
+		if strings.Trim(d, ". ") == "" {
+			return errors.New("the root domain names cannot be empty")
+		}
+	}
+	for _, addr := range r.Addresses {
+		if net.ParseIP(addr) == nil {
+			return fmt.Errorf("%s is not a valid IP address", addr)
+		}
+	}
+	for _, cidr := range r.CIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("%s is not a valid CIDR: %v", cidr, err)
+		}
+	}
+	if len(r.Include) > 0 && len(r.Exclude) > 0 {
+		return errors.New("cannot provide both include and exclude data sources")
+	}
+	if r.Timeout < 0 {
+		return errors.New("the timeout cannot be negative")
+	}
+	return nil
+}
+
+// OverrideConfig implements the config.Updater interface.
+func (r *JobRequest) OverrideConfig(conf *config.Config) error {
+	for _, addr := range r.Addresses {
+		conf.Scope.Addresses = append(conf.Scope.Addresses, net.ParseIP(addr))
+	}
+	for _, cidr := range r.CIDRs {
+		if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
+			conf.Scope.CIDRs = append(conf.Scope.CIDRs, ipnet)
+		}
+	}
+	if len(r.ASNs) > 0 {
+		conf.Scope.ASNs = r.ASNs
+	}
+	if len(r.Blacklist) > 0 {
+		conf.Scope.Blacklist = r.Blacklist
+	}
+	if len(r.Names) > 0 {
+		conf.ProvidedNames = r.Names
+	}
+	if r.BruteForce {
+		conf.BruteForcing = true
+	}
+	if r.Alterations {
+		conf.Alterations = true
+	}
+	if r.Active {
+		conf.Active = true
+		conf.Passive = false
+	}
+	if len(r.Include) > 0 {
+		conf.SourceFilter.Include = true
+		conf.SourceFilter.Sources = r.Include
+	} else if len(r.Exclude) > 0 {
+		conf.SourceFilter.Include = false
+		conf.SourceFilter.Sources = r.Exclude
+	}
+	conf.AddDomains(r.Domains...)
+	return nil
+}
+
+// JobStatus is the state of a job reported by the engine.
+type JobStatus struct {
+	ID       string      `json:"id"`
+	State    State       `json:"state"`
+	Error    string      `json:"error,omitempty"`
+	Request  *JobRequest `json:"request"`
+	Created  time.Time   `json:"created"`
+	Started  *time.Time  `json:"started,omitempty"`
+	Finished *time.Time  `json:"finished,omitempty"`
+}
+
+// Progress describes the findings a job has made so far.
+type Progress struct {
+	ID          string  `json:"id"`
+	State       State   `json:"state"`
+	Elapsed     float64 `json:"elapsed_seconds"`
+	Names       int     `json:"names"`
+	Addresses   int     `json:"addresses"`
+	DataSources int     `json:"data_sources"`
+}
+
+// Job is an enumeration submitted to the engine.
+type Job struct {
+	sync.Mutex
+	id       string
+	req      *JobRequest
+	cfg      *config.Config
+	state    State
+	err      error
+	created  time.Time
+	started  time.Time
+	finished time.Time
+	sources  int
+	cancel   context.CancelFunc
+	canceled bool
+}
+
+func newJob(id string, req *JobRequest, cfg *config.Config) *Job {
+	return &Job{
+		id:      id,
+		req:     req,
+		cfg:     cfg,
+		state:   StateQueued,
+		created: time.Now(),
+	}
+}
+
+// ID returns the identifier assigned to the job by the engine.
+func (j *Job) ID() string {
+	return j.id
+}
+
+// State returns the current state of the job.
+func (j *Job) State() State {
+	j.Lock()
+	defer j.Unlock()
+
+	return j.state
+}
+
+// Done returns true when the job will not run anymore.
+func (j *Job) Done() bool {
+	switch j.State() {
+	case StateFinished, StateFailed, StateCanceled:
+		return true
+	}
+	return false
+}
+
+// Status returns the current status of the job.
+func (j *Job) Status() *JobStatus {
+	j.Lock()
+	defer j.Unlock()
+
+	s := &JobStatus{
+		ID:      j.id,
+		State:   j.state,
+		Request: j.req,
+		Created: j.created,
+	}
+	if j.err != nil {
+		s.Error = j.err.Error()
+	}
+	if !j.started.IsZero() {
+		started := j.started
+		s.Started = &started
+	}
+	if !j.finished.IsZero() {
+		finished := j.finished
+		s.Finished = &finished
+	}
+	return s
+}
+
+// Progress returns the number of names and addresses discovered by the job so far.
+func (j *Job) Progress(ctx context.Context, g *netmap.Graph, sources *provenance.Store) *Progress {
+	j.Lock()
+	p := &Progress{
+		ID:          j.id,
+		State:       j.state,
+		DataSources: j.sources,
+	}
+	if !j.started.IsZero() {
+		end := j.finished
+		if end.IsZero() {
+			end = time.Now()
+		}
+		p.Elapsed = end.Sub(j.started).Seconds()
+	}
+	j.Unlock()
+
+	addrs := make(map[string]struct{})
+	for _, f := range j.Findings(ctx, g, sources) {
+		p.Names++
+		for _, rec := range f.Records {
+			if rec.Type == "A" || rec.Type == "AAAA" {
+				addrs[rec.Value] = struct{}{}
+			}
+		}
+	}
+	p.Addresses = len(addrs)
+	return p
+}
+
+// Findings returns the names within the scope of the job that were discovered or confirmed
+// since the job started, together with their DNS records and the data sources that found them.
+func (j *Job) Findings(ctx context.Context, g *netmap.Graph, sources *provenance.Store) []*notify.Finding {
+	j.Lock()
+	started := j.started
+	j.Unlock()
+
+	findings := []*notify.Finding{}
+	if started.IsZero() || g == nil {
+		return findings
+	}
+
+	since := lastSeenSince(started)
+	seen := make(map[string]struct{})
+	for _, d := range j.cfg.Domains() {
+		if ctx.Err() != nil {
+			break
+		}
+
+		assets, err := g.DB.FindByScope([]oam.Asset{domain.FQDN{Name: d}}, since)
+		if err != nil {
+			continue
+		}
+
+		for _, a := range assets {
+			fqdn, ok := a.Asset.(domain.FQDN)
+			if !ok || !j.cfg.IsDomainInScope(fqdn.Name) {
+				continue
+			}
+			if _, found := seen[fqdn.Name]; found {
+				continue
+			}
+			seen[fqdn.Name] = struct{}{}
+
+			f := &notify.Finding{
+				Name:   fqdn.Name,
+				Domain: j.cfg.WhichDomain(fqdn.Name),
+				// Names created during this job were not seen by the previous enumerations
+				New:        a.CreatedAt.After(since),
+				Discovered: a.CreatedAt,
+			}
+			if rels, err := g.DB.OutgoingRelations(a, since); err == nil {
+				for _, rel := range rels {
+					if to, err := g.DB.FindById(rel.ToAsset.ID, since); err == nil {
+						f.Records = append(f.Records, notify.Record{
+							Type:  notify.RecordType(rel.Type),
+							Value: assetValue(to),
+						})
+					}
+				}
+			}
+			if sources != nil {
+				if attrs, err := sources.Sources(fqdn.Name); err == nil {
+					for _, attr := range attrs {
+						f.Sources = append(f.Sources, attr.Source)
+					}
+				}
+			}
+			findings = append(findings, f)
+		}
+	}
+
+	sort.Slice(findings, func(a, b int) bool {
+		return findings[a].Name < findings[b].Name
+	})
+	return findings
+}
+
+// lastSeenSince returns the time to provide the graph queries for the findings made since
+// the provided time, since the graph stores the last seen times with second precision.
+func lastSeenSince(t time.Time) time.Time {
+	if t.IsZero() {
+		return t
+	}
+	return t.UTC().Truncate(time.Second).Add(-time.Nanosecond)
+}
+
+// assetValue returns the name or address identifying the asset.
+func assetValue(a *types.Asset) string {
+	switch v := a.Asset.(type) {
+	case domain.FQDN:
+		return v.Name
+	case network.IPAddress:
+		return v.Address.String()
+	case network.Netblock:
+		return v.Cidr.String()
+	case network.AutonomousSystem:
+		return strconv.Itoa(v.Number)
+	case network.RIROrganization:
+		return v.RIRId + v.Name
+	}
+	return ""
+}
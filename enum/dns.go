@@ -16,6 +16,7 @@ import (
 	"github.com/caffix/queue"
 	"github.com/miekg/dns"
 	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resolvers"
 	"github.com/owasp-amass/resolve"
 )
 
@@ -24,6 +25,8 @@ const (
 	maxRcodeServerFails int           = 3
 	initialBackoffDelay time.Duration = 250 * time.Millisecond
 	maximumBackoffDelay time.Duration = 4 * time.Second
+	// the queries in flight are never decreased below this fraction of the maximum
+	minInFlightDivisor int = 20
 )
 
 // FwdQueryTypes include the DNS record types that are queried for a discovered name.
@@ -54,12 +57,13 @@ type dnsTask struct {
 	trusted   bool
 	enum      *Enumeration
 	done      chan struct{}
-	pool      *resolve.Resolvers
+	pool      *resolvers.Pool
 	params    pipeline.TaskParams
 	reqs      map[string]*req
 	resps     chan *dns.Msg
 	respQueue queue.Queue
-	release   chan struct{}
+	inflight  *inflight
+	ctrl      *resolvers.AIMD
 }
 
 // newDNSTask returns a dNSTask specific to the provided Enumeration.
@@ -73,6 +77,10 @@ func newDNSTask(e *Enumeration, trusted bool) *dnsTask {
 		qps = e.Config.TrustedQPS
 	}
 	plen := pool.Len() * qps
+	// a single resolver of the pool, such as the cluster of workers, can provide more capacity
+	if max := pool.QPS(); max > plen {
+		plen = max
+	}
 
 	dt := &dnsTask{
 		trust:     trust,
@@ -83,15 +91,14 @@ func newDNSTask(e *Enumeration, trusted bool) *dnsTask {
 		reqs:      make(map[string]*req),
 		resps:     make(chan *dns.Msg, plen),
 		respQueue: queue.NewQueue(),
-		release:   make(chan struct{}, plen),
-	}
-
-	for i := 0; i < plen; i++ {
-		dt.release <- struct{}{}
+		inflight:  newInflight(plen),
+		// the queries in flight adapt to the failures of the responses, up to the size of the pool
+		ctrl: resolvers.NewAIMD(plen/minInFlightDivisor, plen),
 	}
 
 	go dt.processResponses()
 	go dt.moveResponsesToQueue()
+	go dt.adaptInFlight()
 	return dt
 }
 
@@ -169,6 +176,11 @@ func (dt *dnsTask) Process(ctx context.Context, data pipeline.Data, tp pipeline.
 	})
 
 	if v, ok := data.(*requests.DNSRequest); ok {
+		// names served by known authoritative servers skip the untrusted resolvers
+		if !dt.trusted && dt.enum.auth != nil && dt.enum.auth.Zone(v.Name) != "" {
+			return data, nil
+		}
+
 		qtype := FwdQueryTypes[0]
 		msg := resolve.QueryMsg(v.Name, qtype)
 		k := key(msg.Id, msg.Question[0].Name)
@@ -180,7 +192,7 @@ func (dt *dnsTask) Process(ctx context.Context, data pipeline.Data, tp pipeline.
 			Attempts:   1,
 			HasRecords: len(v.Records) > 0,
 		}) {
-			dt.pool.Query(ctx, msg, dt.resps)
+			dt.query(ctx, msg)
 		} else {
 			dt.enum.Config.Log.Printf("Failed to enter %s into the request registry on the %s DNS task", msg.Question[0].Name, dt.trust)
 		}
@@ -206,6 +218,15 @@ func (dt *dnsTask) nextStage(ctx context.Context, data pipeline.Data) {
 	pipeline.SendData(ctx, stage, data, params)
 }
 
+// query sends the message to the authoritative servers of the zone when known, and the resolver pool otherwise.
+func (dt *dnsTask) query(ctx context.Context, msg *dns.Msg) {
+	if auth := dt.enum.auth; auth != nil && dt.trusted && auth.Zone(msg.Question[0].Name) != "" {
+		auth.Query(ctx, msg, dt.resps)
+		return
+	}
+	dt.pool.Query(ctx, msg, dt.resps)
+}
+
 func key(id uint16, name string) string {
 	return fmt.Sprintf("%d:%s", id, strings.ToLower(resolve.RemoveLastDot(name)))
 }
@@ -235,7 +256,7 @@ func (dt *dnsTask) addReqWithIncrement(key string, entry *req) bool {
 	added := dt.addReq(key, entry)
 
 	if added {
-		<-dt.release
+		dt.inflight.acquire()
 	}
 	return added
 }
@@ -254,7 +275,7 @@ func (dt *dnsTask) delReq(key string) *req {
 
 func (dt *dnsTask) delReqWithDecrement(key string) {
 	if req := dt.delReq(key); req != nil {
-		dt.release <- struct{}{}
+		dt.inflight.release()
 
 		if !req.Sent && (req.InScope || req.HasRecords) {
 			dt.nextStage(req.Ctx, req.Data)
@@ -285,6 +306,8 @@ func (dt *dnsTask) processResp(resp *dns.Msg) {
 		dt.enum.Config.Log.Printf("Failed to find %s in the request registry on the %s DNS task", resp.Question[0].Name, dt.trust)
 		return
 	}
+	// timeouts, SERVFAIL and REFUSED responses reduce the queries in flight
+	dt.ctrl.Record(resp.Rcode)
 
 	switch resp.Rcode {
 	// check if the response indicates that the name doesn't exist
@@ -325,6 +348,26 @@ func (dt *dnsTask) processResp(resp *dns.Msg) {
 	}
 }
 
+// adaptInFlight resizes the limit of queries in flight using the outcomes measured in processResp.
+func (dt *dnsTask) adaptInFlight() {
+	t := time.NewTicker(resolvers.AdaptInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-dt.done:
+			return
+		case <-t.C:
+		}
+
+		limit := dt.ctrl.Adjust()
+		if prev := dt.inflight.setLimit(limit); limit < prev {
+			dt.enum.Config.Log.Printf("The %s DNS task decreased the queries in flight to %d, while the resolvers send %d queries per second",
+				dt.trust, limit, dt.pool.EffectiveQPS())
+		}
+	}
+}
+
 func (dt *dnsTask) retry(msg *dns.Msg, id uint16, entry *req) {
 	k := key(id, msg.Question[0].Name)
 
@@ -333,7 +376,7 @@ func (dt *dnsTask) retry(msg *dns.Msg, id uint16, entry *req) {
 		dt.delReq(k)
 		dt.addReq(key(msg.Id, msg.Question[0].Name), entry)
 		time.Sleep(resolve.TruncatedExponentialBackoff(entry.Attempts-1, initialBackoffDelay, maximumBackoffDelay))
-		dt.pool.Query(entry.Ctx, msg, dt.resps)
+		dt.query(entry.Ctx, msg)
 	} else {
 		dt.enum.Config.Log.Printf("%s was dropped after failing to resolve %d times on the %s DNS task", msg.Question[0].Name, entry.Attempts-1, dt.trust)
 		dt.delReqWithDecrement(k)
@@ -350,7 +393,7 @@ func (dt *dnsTask) nextType(ctx context.Context, name string, id, qtype uint16,
 		msg := resolve.QueryMsg(name, entry.Qtype)
 		dt.delReq(k)
 		dt.addReq(key(msg.Id, msg.Question[0].Name), entry)
-		dt.pool.Query(ctx, msg, dt.resps)
+		dt.query(ctx, msg)
 	} else {
 		dt.delReqWithDecrement(k)
 	}
@@ -417,6 +460,7 @@ func (dt *dnsTask) queryNS(ctx context.Context, name, domain string, ch chan []r
 	if resp, err := dt.enum.dnsQuery(ctx, name, dns.TypeNS, dt.enum.Sys.TrustedResolvers(), maxDNSQueryAttempts); err == nil {
 		if ans := resolve.ExtractAnswers(resp); len(ans) > 0 {
 			if rr := resolve.AnswersByType(ans, dns.TypeNS); len(rr) > 0 {
+				var servers []string
 				var records []requests.DNSAnswer
 
 				for _, record := range rr {
@@ -425,8 +469,15 @@ func (dt *dnsTask) queryNS(ctx context.Context, name, domain string, ch chan []r
 						Domain: domain,
 						Server: record.Data,
 					}, tp)
+					servers = append(servers, record.Data)
 					records = append(records, convertAnswers([]*resolve.ExtractedAnswer{record})...)
 				}
+				// send the queries for names in this zone directly to the authoritative servers
+				if auth := dt.enum.auth; auth != nil {
+					if err := auth.AddZone(ctx, name, servers...); err != nil {
+						dt.enum.Config.Log.Printf("%v", err)
+					}
+				}
 
 				ch <- records
 				return
@@ -462,6 +513,7 @@ func (dt *dnsTask) querySOA(ctx context.Context, name string, ch chan []requests
 					records = append(records, convertAnswers([]*resolve.ExtractedAnswer{a})...)
 				}
 				ch <- records
+				return
 			}
 		}
 	}
@@ -497,7 +549,7 @@ func (e *Enumeration) fwdQuery(ctx context.Context, name string, qtype uint16) (
 	return resp, err
 }
 
-func (e *Enumeration) dnsQuery(ctx context.Context, name string, qtype uint16, r *resolve.Resolvers, attempts int) (*dns.Msg, error) {
+func (e *Enumeration) dnsQuery(ctx context.Context, name string, qtype uint16, r *resolvers.Pool, attempts int) (*dns.Msg, error) {
 	msg := resolve.QueryMsg(name, qtype)
 
 	for num := 0; num < attempts; num++ {
@@ -6,6 +6,7 @@ package enum
 
 import (
 	"context"
+	"sort"
 	"sync"
 	"time"
 
@@ -13,14 +14,24 @@ import (
 	"github.com/caffix/pipeline"
 	"github.com/caffix/queue"
 	"github.com/caffix/service"
+	"github.com/caffix/stringset"
 	"github.com/owasp-amass/amass/v4/datasrcs"
 	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resolvers"
 	"github.com/owasp-amass/amass/v4/systems"
 	"github.com/owasp-amass/config/config"
 	oam "github.com/owasp-amass/open-asset-model"
 	"github.com/owasp-amass/open-asset-model/domain"
 )
 
+// The names of the sources attributed with the findings made by the enumeration itself.
+const (
+	// UserSource provided the root domain names and the known subdomain names
+	UserSource = "User Input"
+	// DNSSource found the names and addresses in the DNS records of other names
+	DNSSource = "DNS"
+)
+
 // Enumeration is the object type used to execute a DNS enumeration.
 type Enumeration struct {
 	Config   *config.Config
@@ -37,6 +48,15 @@ type Enumeration struct {
 	requests queue.Queue
 	plock    sync.Mutex
 	pending  bool
+	authQPS  int
+	auth     *resolvers.AuthServers
+	// Findings obtained outside of the enumeration, such as the output of other tools
+	importedNames []*requests.DNSRequest
+	importedAddrs []*requests.AddrRequest
+	// Attributes the stored names and addresses to the data sources that discovered them
+	provenance *sourceTracker
+	// Records the time span of each stage performed by the data sources
+	stages *stageTracker
 }
 
 // NewEnumeration returns an initialized Enumeration that has not been started yet.
@@ -47,9 +67,26 @@ func NewEnumeration(cfg *config.Config, sys systems.System, graph *netmap.Graph)
 		graph:    graph,
 		srcs:     datasrcs.SelectedDataSources(cfg, sys.DataSources()),
 		requests: queue.NewQueue(),
+		stages:   newStageTracker(),
 	}
 }
 
+// UseAuthoritativeServers causes queries for names within the in-scope zones to be sent directly
+// to the authoritative name servers, limited to the provided queries per second for each server.
+// This must be called before the enumeration is started.
+func (e *Enumeration) UseAuthoritativeServers(qps int) {
+	e.authQPS = qps
+}
+
+// ImportFindings provides names, with their DNS records, and addresses obtained outside of the
+// enumeration, which are validated the same as the findings of the data sources. The names must
+// be assigned their root domain names, and the addresses must be marked in scope. This must be
+// called before the enumeration is started.
+func (e *Enumeration) ImportFindings(names []*requests.DNSRequest, addrs []*requests.AddrRequest) {
+	e.importedNames = append(e.importedNames, names...)
+	e.importedAddrs = append(e.importedAddrs, addrs...)
+}
+
 // Start begins the vertical domain correlation process.
 func (e *Enumeration) Start(ctx context.Context) error {
 	e.done = make(chan struct{})
@@ -65,8 +102,15 @@ func (e *Enumeration) Start(ctx context.Context) error {
 	defer cancel()
 	go e.manageDataSrcRequests()
 
+	if e.authQPS > 0 {
+		e.auth = resolvers.NewAuthServers(e.authQPS, e.Sys.TrustedResolvers())
+		e.auth.SetLogger(e.Config.Log)
+		defer e.auth.Stop()
+	}
+
 	e.dnsTask = newDNSTask(e, false)
 	e.valTask = newDNSTask(e, true)
+	e.provenance = newSourceTracker(e)
 	e.store = newDataManager(e)
 	e.subTask = newSubdomainTask(e)
 	defer e.subTask.Stop()
@@ -94,6 +138,7 @@ func (e *Enumeration) Start(ctx context.Context) error {
 	 */
 	go e.submitKnownNames()
 	go e.submitProvidedNames()
+	go e.submitImportedFindings()
 
 	err := p.ExecuteBuffered(e.ctx, e.nameSrc, e.makeOutputSink(), 50)
 	// Ensure all data has been stored
@@ -105,8 +150,11 @@ func (e *Enumeration) Start(ctx context.Context) error {
 func (e *Enumeration) submitDomainNames() {
 	for _, domain := range e.Config.Domains() {
 		req := &requests.DNSRequest{
-			Name:   domain,
-			Domain: domain,
+			Name:       domain,
+			Domain:     domain,
+			Tag:        requests.USER,
+			Source:     UserSource,
+			Discovered: time.Now(),
 		}
 
 		e.nameSrc.newName(req)
@@ -204,16 +252,46 @@ func (e *Enumeration) fireRequest(srv service.Service, req interface{}, finished
 	case <-e.ctx.Done():
 	case <-srv.Done():
 	case srv.Input() <- req:
+		e.stages.update(srv.Description(), time.Now())
 	}
 	finished <- srv.String()
 }
 
+// Stages returns the time span of each stage performed by the data sources, such as brute
+// forcing and name alterations, in the order the stages began.
+func (e *Enumeration) Stages() []Stage {
+	return e.stages.list()
+}
+
 func (e *Enumeration) makeOutputSink() pipeline.SinkFunc {
 	return pipeline.SinkFunc(func(ctx context.Context, data pipeline.Data) error {
 		return nil
 	})
 }
 
+// DiscoveredNames returns the sorted names within the enumeration scope that are stored in the graph.
+func (e *Enumeration) DiscoveredNames() []string {
+	names := stringset.New()
+	defer names.Close()
+
+	for _, d := range e.Config.Domains() {
+		assets, err := e.graph.DB.FindByScope([]oam.Asset{domain.FQDN{Name: d}}, time.Time{})
+		if err != nil {
+			continue
+		}
+
+		for _, a := range assets {
+			if fqdn, ok := a.Asset.(domain.FQDN); ok && e.Config.IsDomainInScope(fqdn.Name) {
+				names.Insert(fqdn.Name)
+			}
+		}
+	}
+
+	list := names.Slice()
+	sort.Strings(list)
+	return list
+}
+
 func (e *Enumeration) submitKnownNames() {
 	for _, g := range e.Sys.GraphDatabases() {
 		e.readNamesFromDatabase(g)
@@ -258,9 +336,42 @@ func (e *Enumeration) submitProvidedNames() {
 		}
 		if domain := e.Config.WhichDomain(name); domain != "" {
 			e.nameSrc.newName(&requests.DNSRequest{
-				Name:   name,
-				Domain: domain,
+				Name:       name,
+				Domain:     domain,
+				Tag:        requests.USER,
+				Source:     UserSource,
+				Discovered: time.Now(),
 			})
 		}
 	}
 }
+
+func (e *Enumeration) submitImportedFindings() {
+	for _, req := range e.importedNames {
+		select {
+		case <-e.done:
+			return
+		default:
+		}
+
+		c := req.Clone().(*requests.DNSRequest)
+		if c.Discovered.IsZero() {
+			c.Discovered = time.Now()
+		}
+		e.nameSrc.newName(c)
+	}
+
+	for _, req := range e.importedAddrs {
+		select {
+		case <-e.done:
+			return
+		default:
+		}
+
+		c := req.Clone().(*requests.AddrRequest)
+		if c.Discovered.IsZero() {
+			c.Discovered = time.Now()
+		}
+		e.nameSrc.newAddr(c)
+	}
+}
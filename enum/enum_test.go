@@ -0,0 +1,140 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package enum
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/amass/v4/amasstest"
+	"github.com/owasp-amass/amass/v4/importer"
+	"github.com/owasp-amass/amass/v4/systems"
+	"github.com/owasp-amass/config/config"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/stretchr/testify/require"
+)
+
+const uticaZone = `
+@	IN	SOA	ns1.utica.edu. hostmaster.utica.edu. 1 3600 600 86400 300
+@	IN	NS	ns1.utica.edu.
+@	IN	MX	10 mail.utica.edu.
+@	IN	A	127.0.0.10
+ns1	IN	A	127.0.0.53
+mail	IN	A	127.0.0.25
+www	IN	CNAME	web.utica.edu.
+web	IN	A	127.0.0.80
+`
+
+func TestEnumerationOffline(t *testing.T) {
+	srv, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	defer srv.Close()
+	require.NoError(t, srv.LoadZoneString("utica.edu", uticaZone))
+
+	cfg := config.NewConfig()
+	cfg.AddDomain("utica.edu")
+	cfg.ProvidedNames = []string{"www.utica.edu", "dev.utica.edu"}
+
+	sys := systems.NewSimpleSystem(cfg, srv.Pool(), srv.Pool())
+	defer func() { _ = sys.Shutdown() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	e := NewEnumeration(cfg, sys, sys.GraphDatabases()[0])
+	require.NoError(t, e.Start(ctx))
+
+	assets, err := sys.Graph.DB.FindByScope([]oam.Asset{domain.FQDN{Name: "utica.edu"}}, time.Time{})
+	require.NoError(t, err)
+
+	var names []string
+	for _, a := range assets {
+		if fqdn, ok := a.Asset.(domain.FQDN); ok {
+			names = append(names, fqdn.Name)
+		}
+	}
+	sort.Strings(names)
+	require.Equal(t, []string{"mail.utica.edu", "ns1.utica.edu", "utica.edu", "web.utica.edu", "www.utica.edu"}, names)
+}
+
+func TestEnumerationZoneFiles(t *testing.T) {
+	srv, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	defer srv.Close()
+	require.NoError(t, srv.LoadZoneFile("", filepath.Join("testdata", "utica.edu.zone")))
+	require.NoError(t, srv.LoadZoneFile("", filepath.Join("testdata", "example.net.zone")))
+
+	cfg := config.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.AddDomain("utica.edu")
+	cfg.ProvidedNames = []string{"www.utica.edu", "portal.dev.utica.edu", "a.dev.utica.edu", "b.dev.utica.edu"}
+
+	sys, err := systems.NewIsolatedSystem(cfg, srv.Pool(), srv.Pool())
+	require.NoError(t, err)
+	defer func() { _ = sys.Shutdown() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	e := NewEnumeration(cfg, sys, sys.GraphDatabases()[0])
+	require.NoError(t, e.Start(ctx))
+	require.Equal(t, []string{"edge.utica.edu", "mail.utica.edu", "ns1.utica.edu", "portal.dev.utica.edu",
+		"utica.edu", "web.utica.edu", "www.utica.edu"}, e.DiscoveredNames())
+}
+
+func TestEnumerationImport(t *testing.T) {
+	srv, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	defer srv.Close()
+	require.NoError(t, srv.LoadZoneString("utica.edu", uticaZone))
+
+	cfg := config.NewConfig()
+	cfg.AddDomain("utica.edu")
+	cfg.ProvidedNames = []string{"www.utica.edu"}
+
+	sys := systems.NewSimpleSystem(cfg, srv.Pool(), srv.Pool())
+	defer func() { _ = sys.Shutdown() }()
+
+	// The names imported from the output of other tools are validated before they are stored
+	res, err := importer.Read(strings.NewReader(`
+{"host":"www.utica.edu","input":"utica.edu","source":"crtsh"}
+{"host":"stale.utica.edu","input":"utica.edu","source":"crtsh"}
+{"host":"www.example.com","input":"example.com","source":"crtsh"}
+`), "")
+	require.NoError(t, err)
+	res = res.Scope(cfg)
+	require.Len(t, res.Names, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	e := NewEnumeration(cfg, sys, sys.GraphDatabases()[0])
+	e.ImportFindings(res.Names, res.Addrs)
+	require.NoError(t, e.Start(ctx))
+	require.Equal(t, []string{"mail.utica.edu", "ns1.utica.edu", "utica.edu", "web.utica.edu", "www.utica.edu"}, e.DiscoveredNames())
+
+	// Each source that discovered a stored name is attributed, including the later discoveries
+	sources := func(name string) []string {
+		attrs, err := sys.Provenance().Sources(name)
+		require.NoError(t, err)
+
+		var list []string
+		for _, a := range attrs {
+			list = append(list, a.Source+"/"+a.Tag)
+		}
+		sort.Strings(list)
+		return list
+	}
+	require.Equal(t, []string{"User Input/user", "crtsh/import:subfinder"}, sources("www.utica.edu"))
+	require.Equal(t, []string{"DNS/dns"}, sources("web.utica.edu"))
+	require.Equal(t, []string{"User Input/user"}, sources("utica.edu"))
+	require.Equal(t, []string{"DNS/dns"}, sources("127.0.0.80"))
+	require.Empty(t, sources("stale.utica.edu"))
+}
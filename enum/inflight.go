@@ -0,0 +1,65 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package enum
+
+import "sync"
+
+// inflight limits the requests a DNS task has in flight. The limit can be changed while requests
+// are in flight, and the requests above a decreased limit complete before new requests start.
+type inflight struct {
+	sync.Mutex
+	cond  *sync.Cond
+	limit int
+	count int
+}
+
+func newInflight(limit int) *inflight {
+	if limit < 1 {
+		limit = 1
+	}
+
+	f := &inflight{limit: limit}
+	f.cond = sync.NewCond(&f.Mutex)
+	return f
+}
+
+// acquire blocks until another request can be in flight.
+func (f *inflight) acquire() {
+	f.Lock()
+	defer f.Unlock()
+
+	for f.count >= f.limit {
+		f.cond.Wait()
+	}
+	f.count++
+}
+
+// release allows another request to be in flight, after one acquired has completed.
+func (f *inflight) release() {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.count > 0 {
+		f.count--
+	}
+	f.cond.Signal()
+}
+
+// setLimit changes the number of requests allowed in flight and returns the previous limit.
+func (f *inflight) setLimit(limit int) int {
+	f.Lock()
+	defer f.Unlock()
+
+	if limit < 1 {
+		limit = 1
+	}
+
+	prev := f.limit
+	f.limit = limit
+	if limit > prev {
+		f.cond.Broadcast()
+	}
+	return prev
+}
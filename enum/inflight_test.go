@@ -0,0 +1,58 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package enum
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInflight(t *testing.T) {
+	f := newInflight(2)
+	f.acquire()
+	f.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		f.acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("the request was allowed beyond the limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// raising the limit allows the waiting request
+	require.Equal(t, 2, f.setLimit(3))
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("the request was not allowed after the limit increased")
+	}
+
+	// the requests above a decreased limit complete before another starts
+	require.Equal(t, 3, f.setLimit(1))
+	acquired = make(chan struct{})
+	go func() {
+		f.acquire()
+		close(acquired)
+	}()
+	f.release()
+	f.release()
+	select {
+	case <-acquired:
+		t.Fatal("the request was allowed beyond the decreased limit")
+	case <-time.After(50 * time.Millisecond):
+	}
+	f.release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("the request was not allowed after the requests completed")
+	}
+}
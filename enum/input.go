@@ -23,6 +23,8 @@ type enumSource struct {
 	pipeline *pipeline.Pipeline
 	enum     *Enumeration
 	queue    queue.Queue
+	// The filter is not safe for concurrent use, while the names arrive from several goroutines
+	flock    sync.Mutex
 	filter   *bf.StableBloomFilter
 	done     chan struct{}
 	doneOnce sync.Once
@@ -65,7 +67,9 @@ func newEnumSource(p *pipeline.Pipeline, e *Enumeration) *enumSource {
 func (r *enumSource) Stop() {
 	r.markDone()
 	r.queue.Process(func(e interface{}) {})
+	r.flock.Lock()
 	r.filter.Reset()
+	r.flock.Unlock()
 }
 
 func (r *enumSource) markDone() {
@@ -93,6 +97,8 @@ func (r *enumSource) newName(req *requests.DNSRequest) {
 		return
 	}
 	if !r.accept(req.Name) {
+		// Attribute the name to each data source that discovered it
+		r.enum.provenance.claim(req.Name, req.Source, req.Tag, req.Discovered)
 		r.releaseOutput(1)
 		return
 	}
@@ -106,12 +112,20 @@ func (r *enumSource) newAddr(req *requests.AddrRequest) {
 	default:
 	}
 
-	if req.Valid() && req.InScope && r.accept(req.Address) {
-		r.queue.Append(req)
+	if !req.Valid() || !req.InScope {
+		return
+	}
+	if !r.accept(req.Address) {
+		r.enum.provenance.claim(req.Address, req.Source, req.Tag, req.Discovered)
+		return
 	}
+	r.queue.Append(req)
 }
 
 func (r *enumSource) accept(s string) bool {
+	r.flock.Lock()
+	defer r.flock.Unlock()
+
 	return !r.filter.TestAndAdd([]byte(s))
 }
 
@@ -198,10 +212,24 @@ func (r *enumSource) monitorDataSrcOutput(srv service.Service) {
 			case <-r.release:
 			}
 
+			r.enum.stages.update(srv.Description(), time.Now())
+			// The findings are attributed to the data source that provided them
 			switch req := in.(type) {
 			case *requests.DNSRequest:
+				if req.Source == "" {
+					req.Source, req.Tag = srv.String(), srv.Description()
+				}
+				if req.Discovered.IsZero() {
+					req.Discovered = time.Now()
+				}
 				r.newName(req)
 			case *requests.AddrRequest:
+				if req.Source == "" {
+					req.Source, req.Tag = srv.String(), srv.Description()
+				}
+				if req.Discovered.IsZero() {
+					req.Discovered = time.Now()
+				}
 				r.newAddr(req)
 			}
 		}
@@ -0,0 +1,86 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package enum
+
+import (
+	"sync"
+	"time"
+
+	"github.com/owasp-amass/amass/v4/provenance"
+)
+
+// sourceTracker attributes the names and addresses stored by the enumeration to each data source
+// that discovered them. Only the first discovery of an asset travels through the pipeline, so the
+// later discoveries by other data sources are held until the asset has been stored in the graph.
+type sourceTracker struct {
+	sync.Mutex
+	enum    *Enumeration
+	store   *provenance.Store
+	stored  map[string]struct{}
+	written map[string]struct{}
+	pending map[string][]*provenance.Attribution
+}
+
+func newSourceTracker(e *Enumeration) *sourceTracker {
+	return &sourceTracker{
+		enum:    e,
+		store:   e.Sys.Provenance(),
+		stored:  make(map[string]struct{}),
+		written: make(map[string]struct{}),
+		pending: make(map[string][]*provenance.Attribution),
+	}
+}
+
+// claim records that the source discovered the asset, which may not have been stored yet.
+func (st *sourceTracker) claim(asset, source, tag string, seen time.Time) {
+	if st.store == nil || asset == "" || source == "" {
+		return
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	if _, found := st.stored[asset]; found {
+		st.write(asset, source, tag, seen)
+		return
+	}
+	st.pending[asset] = append(st.pending[asset], &provenance.Attribution{
+		Asset:     asset,
+		Source:    source,
+		Tag:       tag,
+		FirstSeen: seen,
+	})
+}
+
+// confirm records that the asset, discovered by the source, has been stored in the graph.
+func (st *sourceTracker) confirm(asset, source, tag string, seen time.Time) {
+	if st.store == nil || asset == "" {
+		return
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	st.stored[asset] = struct{}{}
+	if source != "" {
+		st.write(asset, source, tag, seen)
+	}
+	for _, a := range st.pending[asset] {
+		st.write(a.Asset, a.Source, a.Tag, a.FirstSeen)
+	}
+	delete(st.pending, asset)
+}
+
+func (st *sourceTracker) write(asset, source, tag string, seen time.Time) {
+	key := asset + "|" + source
+	if _, found := st.written[key]; found {
+		return
+	}
+	st.written[key] = struct{}{}
+
+	if err := st.store.Add(asset, source, tag, seen); err != nil {
+		st.enum.Config.Log.Print(err.Error())
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package enum
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/owasp-amass/amass/v4/requests"
+)
+
+// The stages of an enumeration, performed by the data sources of the matching types.
+const (
+	StageDataSources = "Data Sources"
+	StageBrute       = "Brute Forcing"
+	StageAlterations = "Alterations"
+	StageActiveDNS   = "Active DNS and Sweeps"
+)
+
+// Stage is the time span of an enumeration stage. The stages run at the same time, so each
+// begins with the first request sent to its data sources and ends with their last finding.
+type Stage struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// stageTracker records the time span of each stage while the enumeration is running.
+type stageTracker struct {
+	sync.Mutex
+	stages map[string]*Stage
+}
+
+func newStageTracker() *stageTracker {
+	return &stageTracker{stages: make(map[string]*Stage)}
+}
+
+// stageName returns the stage performed by the data sources of the type.
+func stageName(tag string) string {
+	switch tag {
+	case requests.BRUTE:
+		return StageBrute
+	case requests.ALT:
+		return StageAlterations
+	case requests.DNS, requests.AXFR:
+		return StageActiveDNS
+	}
+	return StageDataSources
+}
+
+// update extends the stage performed by the data sources of the type to the provided time.
+func (st *stageTracker) update(tag string, t time.Time) {
+	st.Lock()
+	defer st.Unlock()
+
+	name := stageName(tag)
+	s, found := st.stages[name]
+	if !found {
+		st.stages[name] = &Stage{Name: name, Start: t, End: t}
+		return
+	}
+	if t.Before(s.Start) {
+		s.Start = t
+	}
+	if t.After(s.End) {
+		s.End = t
+	}
+}
+
+// list returns the stages in the order they began.
+func (st *stageTracker) list() []Stage {
+	st.Lock()
+	defer st.Unlock()
+
+	var stages []Stage
+	for _, s := range st.stages {
+		stages = append(stages, *s)
+	}
+	sort.Slice(stages, func(i, j int) bool {
+		return stages[i].Start.Before(stages[j].Start)
+	})
+	return stages
+}
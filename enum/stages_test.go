@@ -0,0 +1,32 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package enum
+
+import (
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStageTracker(t *testing.T) {
+	start := time.Now()
+	st := newStageTracker()
+	require.Empty(t, st.list())
+
+	st.update(requests.API, start)
+	st.update(requests.BRUTE, start.Add(time.Second))
+	st.update(requests.CERT, start.Add(5*time.Second))
+	st.update(requests.DNS, start.Add(2*time.Second))
+	st.update(requests.BRUTE, start.Add(3*time.Second))
+	st.update(requests.AXFR, start.Add(4*time.Second))
+
+	require.Equal(t, []Stage{
+		{Name: StageDataSources, Start: start, End: start.Add(5 * time.Second)},
+		{Name: StageBrute, Start: start.Add(time.Second), End: start.Add(3 * time.Second)},
+		{Name: StageActiveDNS, Start: start.Add(2 * time.Second), End: start.Add(4 * time.Second)},
+	}, st.list())
+}
@@ -100,7 +100,11 @@ func (dm *dataManager) dnsRequest(ctx context.Context, req *requests.DNSRequest,
 
 		if uint16(r.Type) == dns.TypeCNAME {
 			// Do not enter more than the CNAME record
-			return dm.insertCNAME(ctx, req, i, tp)
+			err := dm.insertCNAME(ctx, req, i, tp)
+			if err == nil {
+				dm.enum.provenance.confirm(req.Name, req.Source, req.Tag, req.Discovered)
+			}
+			return err
 		}
 	}
 
@@ -137,6 +141,9 @@ func (dm *dataManager) dnsRequest(ctx context.Context, req *requests.DNSRequest,
 			err = e
 		}
 	}
+	if err == nil {
+		dm.enum.provenance.confirm(req.Name, req.Source, req.Tag, req.Discovered)
+	}
 	return err
 }
 
@@ -151,10 +158,7 @@ func (dm *dataManager) insertCNAME(ctx context.Context, req *requests.DNSRequest
 		return errors.New("failed to extract a domain name from the FQDN")
 	}
 	// Important - Allows chained CNAME records to be resolved until an A/AAAA record
-	dm.enum.nameSrc.newName(&requests.DNSRequest{
-		Name:   target,
-		Domain: strings.ToLower(domain),
-	})
+	dm.newName(target, strings.ToLower(domain))
 	if err := dm.enum.graph.UpsertCNAME(ctx, req.Name, target); err != nil {
 		return fmt.Errorf("failed to insert CNAME: %v", err)
 	}
@@ -167,11 +171,7 @@ func (dm *dataManager) insertA(ctx context.Context, req *requests.DNSRequest, re
 		return errors.New("failed to extract an IP address from the DNS answer data")
 	}
 	dm.enum.checkForMissedWildcards(addr)
-	dm.enum.nameSrc.newAddr(&requests.AddrRequest{
-		Address: addr,
-		InScope: true,
-		Domain:  req.Domain,
-	})
+	dm.newAddr(addr, req.Domain, true)
 	if err := dm.enum.graph.UpsertA(ctx, req.Name, addr); err != nil {
 		return fmt.Errorf("failed to insert A record: %v", err)
 	}
@@ -184,11 +184,7 @@ func (dm *dataManager) insertAAAA(ctx context.Context, req *requests.DNSRequest,
 		return errors.New("failed to extract an IP address from the DNS answer data")
 	}
 	dm.enum.checkForMissedWildcards(addr)
-	dm.enum.nameSrc.newAddr(&requests.AddrRequest{
-		Address: addr,
-		InScope: true,
-		Domain:  req.Domain,
-	})
+	dm.newAddr(addr, req.Domain, true)
 	if err := dm.enum.graph.UpsertAAAA(ctx, req.Name, addr); err != nil {
 		return fmt.Errorf("failed to insert AAAA record: %v", err)
 	}
@@ -206,10 +202,7 @@ func (dm *dataManager) insertPTR(ctx context.Context, req *requests.DNSRequest,
 		return nil
 	}
 	// Important - Allows the target DNS name to be resolved in the forward direction
-	dm.enum.nameSrc.newName(&requests.DNSRequest{
-		Name:   target,
-		Domain: domain,
-	})
+	dm.newName(target, domain)
 	if err := dm.enum.graph.UpsertPTR(ctx, req.Name, target); err != nil {
 		return fmt.Errorf("failed to insert PTR record: %v", err)
 	}
@@ -223,10 +216,7 @@ func (dm *dataManager) insertSRV(ctx context.Context, req *requests.DNSRequest,
 		return errors.New("failed to extract service info from the DNS answer data")
 	}
 	if domain := dm.enum.Config.WhichDomain(target); domain != "" {
-		dm.enum.nameSrc.newName(&requests.DNSRequest{
-			Name:   target,
-			Domain: domain,
-		})
+		dm.newName(target, domain)
 	}
 	if err := dm.enum.graph.UpsertSRV(ctx, service, target); err != nil {
 		return fmt.Errorf("failed to insert SRV record: %v", err)
@@ -245,10 +235,7 @@ func (dm *dataManager) insertNS(ctx context.Context, req *requests.DNSRequest, r
 		return errors.New("failed to extract a domain name from the FQDN")
 	}
 	if d := strings.ToLower(domain); target != d {
-		dm.enum.nameSrc.newName(&requests.DNSRequest{
-			Name:   target,
-			Domain: d,
-		})
+		dm.newName(target, d)
 	}
 	if err := dm.enum.graph.UpsertNS(ctx, req.Name, target); err != nil {
 		return fmt.Errorf("failed to insert NS record: %v", err)
@@ -267,10 +254,7 @@ func (dm *dataManager) insertMX(ctx context.Context, req *requests.DNSRequest, r
 		return errors.New("failed to extract a domain name from the FQDN")
 	}
 	if d := strings.ToLower(domain); target != d {
-		dm.enum.nameSrc.newName(&requests.DNSRequest{
-			Name:   target,
-			Domain: d,
-		})
+		dm.newName(target, d)
 	}
 	if err := dm.enum.graph.UpsertMX(ctx, req.Name, target); err != nil {
 		return fmt.Errorf("failed to insert MX record: %v", err)
@@ -302,23 +286,40 @@ func (dm *dataManager) insertSPF(ctx context.Context, req *requests.DNSRequest,
 func (dm *dataManager) findNamesAndAddresses(ctx context.Context, data, domain string, tp pipeline.TaskParams) {
 	ipre := regexp.MustCompile(amassnet.IPv4RE)
 	for _, ip := range ipre.FindAllString(data, -1) {
-		dm.enum.nameSrc.newAddr(&requests.AddrRequest{
-			Address: ip,
-			Domain:  domain,
-		})
+		dm.newAddr(ip, domain, false)
 	}
 
 	subre := amassdns.AnySubdomainRegex()
 	for _, name := range subre.FindAllString(data, -1) {
 		if domain := strings.ToLower(dm.enum.Config.WhichDomain(name)); domain != "" {
-			dm.enum.nameSrc.newName(&requests.DNSRequest{
-				Name:   name,
-				Domain: domain,
-			})
+			dm.newName(name, domain)
 		}
 	}
 }
 
+// newName provides a name found in the DNS records to the enumeration.
+func (dm *dataManager) newName(name, domain string) {
+	dm.enum.nameSrc.newName(&requests.DNSRequest{
+		Name:       name,
+		Domain:     domain,
+		Tag:        requests.DNS,
+		Source:     DNSSource,
+		Discovered: time.Now(),
+	})
+}
+
+// newAddr provides an address found in the DNS records to the enumeration.
+func (dm *dataManager) newAddr(addr, domain string, inscope bool) {
+	dm.enum.nameSrc.newAddr(&requests.AddrRequest{
+		Address:    addr,
+		InScope:    inscope,
+		Domain:     domain,
+		Tag:        requests.DNS,
+		Source:     DNSSource,
+		Discovered: time.Now(),
+	})
+}
+
 func (dm *dataManager) addrRequest(ctx context.Context, req *requests.AddrRequest, tp pipeline.TaskParams) error {
 	select {
 	case <-ctx.Done():
@@ -329,6 +330,7 @@ func (dm *dataManager) addrRequest(ctx context.Context, req *requests.AddrReques
 	if req == nil || !req.InScope {
 		return nil
 	}
+	dm.enum.provenance.confirm(req.Address, req.Source, req.Tag, req.Discovered)
 	if yes, prefix := amassnet.IsReservedAddress(req.Address); yes {
 		var err error
 		if e := dm.enum.graph.UpsertInfrastructure(ctx, 0, amassnet.ReservedCIDRDescription, req.Address, prefix); e != nil {
@@ -337,11 +339,7 @@ func (dm *dataManager) addrRequest(ctx context.Context, req *requests.AddrReques
 		return err
 	}
 	if r := dm.enum.Sys.Cache().AddrSearch(req.Address); r != nil {
-		var err error
-		if e := dm.enum.graph.UpsertInfrastructure(ctx, r.ASN, r.Description, req.Address, r.Prefix); e != nil {
-			err = e
-		}
-		return err
+		return dm.upsertInfrastructure(ctx, req.Address, r)
 	}
 
 	dm.queue.Append(req)
@@ -373,7 +371,7 @@ func (dm *dataManager) nextInfraInfo() {
 	ctx := context.Background()
 	req := e.(*requests.AddrRequest)
 	if r := dm.enum.Sys.Cache().AddrSearch(req.Address); r != nil {
-		_ = dm.enum.graph.UpsertInfrastructure(ctx, r.ASN, r.Description, req.Address, r.Prefix)
+		_ = dm.upsertInfrastructure(ctx, req.Address, r)
 		return
 	}
 
@@ -388,7 +386,7 @@ loop:
 
 		time.Sleep(2 * time.Second)
 		if r := dm.enum.Sys.Cache().AddrSearch(req.Address); r != nil {
-			_ = dm.enum.graph.UpsertInfrastructure(ctx, r.ASN, r.Description, req.Address, r.Prefix)
+			_ = dm.upsertInfrastructure(ctx, req.Address, r)
 			return
 		}
 	}
@@ -407,6 +405,21 @@ loop:
 	})
 }
 
+// upsertInfrastructure stores the most specific prefix and every origin ASN announcing it.
+func (dm *dataManager) upsertInfrastructure(ctx context.Context, addr string, r *requests.ASNRequest) error {
+	err := dm.enum.graph.UpsertInfrastructure(ctx, r.ASN, r.Description, addr, r.Prefix)
+
+	for _, alt := range r.Alternates {
+		if alt.Prefix != r.Prefix {
+			continue
+		}
+		if e := dm.enum.graph.UpsertInfrastructure(ctx, alt.ASN, alt.Description, addr, alt.Prefix); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
 func fakePrefix(addr string) string {
 	bits := 24
 	total := 32
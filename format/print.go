@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -53,8 +54,9 @@ var (
 
 // ASNSummaryData stores information related to discovered ASs and netblocks.
 type ASNSummaryData struct {
-	Name      string
-	Netblocks map[string]int
+	Name      string         `json:"name"`
+	Netblocks map[string]int `json:"netblocks"`
+	addrs     map[string]struct{}
 }
 
 // UpdateSummaryData updates the summary maps using the provided requests.Output data.
@@ -72,18 +74,26 @@ func UpdateSummaryData(output *requests.Output, asns map[int]*ASNSummaryData) {
 			}
 			data = asns[addr.ASN]
 		}
-		// Increment how many IPs were in this netblock
-		data.Netblocks[addr.CIDRStr]++
+		if data.addrs == nil {
+			data.addrs = make(map[string]struct{})
+		}
+		// Increment how many distinct IPs were in this netblock
+		key := addr.CIDRStr + "|" + addr.Address.String()
+		if _, found := data.addrs[key]; !found {
+			data.addrs[key] = struct{}{}
+			data.Netblocks[addr.CIDRStr]++
+		}
 	}
 }
 
 // PrintEnumerationSummary outputs the summary information utilized by the command-line tools.
-func PrintEnumerationSummary(total int, asns map[int]*ASNSummaryData, demo bool) {
-	FprintEnumerationSummary(color.Error, total, asns, demo)
+func PrintEnumerationSummary(total int, asns map[int]*ASNSummaryData, redact *Redactor) {
+	FprintEnumerationSummary(color.Error, total, asns, redact)
 }
 
 // FprintEnumerationSummary outputs the summary information utilized by the command-line tools.
-func FprintEnumerationSummary(out io.Writer, total int, asns map[int]*ASNSummaryData, demo bool) {
+// The Redactor censors the ASN information in demo mode, and can be nil.
+func FprintEnumerationSummary(out io.Writer, total int, asns map[int]*ASNSummaryData, redact *Redactor) {
 	pad := func(num int, chr string) {
 		for i := 0; i < num; i++ {
 			b.Fprint(out, chr)
@@ -109,27 +119,34 @@ func FprintEnumerationSummary(out io.Writer, total int, asns map[int]*ASNSummary
 	pad(8, "----------")
 	fmt.Fprintln(out)
 	// Print the ASN and netblock information
-	for asn, data := range asns {
-		asnstr := strconv.Itoa(asn)
-		datastr := data.Name
+	nums := make([]int, 0, len(asns))
+	for asn := range asns {
+		nums = append(nums, asn)
+	}
+	sort.Ints(nums)
 
-		if demo && asn > 0 {
-			asnstr = censorString(asnstr, 0, len(asnstr))
-			datastr = censorString(datastr, 0, len(datastr))
+	for _, asn := range nums {
+		data := asns[asn]
+		asnstr := redact.ASN(asn)
+		datastr := data.Name
+		if asn > 0 {
+			datastr = redact.Org(datastr)
 		}
 		fmt.Fprintf(out, "%s%s %s %s\n", blue("ASN: "), yellow(asnstr), green("-"), green(datastr))
 
-		for cidr, ips := range data.Netblocks {
-			countstr := strconv.Itoa(ips)
-			cidrstr := cidr
-
-			if demo {
-				cidrstr = censorNetBlock(cidrstr)
-			}
+		cidrs := make([]string, 0, len(data.Netblocks))
+		for cidr := range data.Netblocks {
+			cidrs = append(cidrs, cidr)
+		}
+		sort.Strings(cidrs)
 
+		for _, cidr := range cidrs {
+			ips := data.Netblocks[cidr]
+			countstr := strconv.Itoa(ips)
+			cidrstr := redact.Netblock(cidr)
 			countstr = fmt.Sprintf("\t%-4s", countstr)
 			cidrstr = fmt.Sprintf("\t%-18s", cidrstr)
-			fmt.Fprintf(out, "%s%s %s\n", yellow(cidrstr), yellow(countstr), blue("Subdomain Name(s)"))
+			fmt.Fprintf(out, "%s%s %s\n", yellow(cidrstr), yellow(countstr), blue("IP Address(es)"))
 		}
 	}
 }
@@ -0,0 +1,268 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package format
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// The redaction modes that can be selected for each kind of value censored by the demo mode.
+const (
+	// RedactNone leaves the values unchanged.
+	RedactNone = "none"
+	// RedactMask replaces the characters of the values with an 'x'.
+	RedactMask = "mask"
+	// RedactHash replaces the values with pseudonyms derived from the redaction key, so
+	// each value is always replaced the same way and the relationships remain readable.
+	RedactHash = "hash"
+)
+
+// The range of 32-bit ASNs reserved for private use holds the ASN pseudonyms
+const (
+	privateASNStart = 4200000000
+	privateASNCount = 94967294
+)
+
+var (
+	fqdnRegex = regexp.MustCompile(`(?i)\b(?:[a-z0-9_](?:[a-z0-9_-]{0,61}[a-z0-9])?\.)+[a-z][a-z0-9-]{0,61}[a-z0-9]\b`)
+	ipv4Regex = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+	ipv6Regex = regexp.MustCompile(`(?i)(?:[0-9a-f]{0,4}:){2,7}[0-9a-f]{0,4}`)
+)
+
+// RedactionRules selects how the demo mode censors each kind of value.
+type RedactionRules struct {
+	Domains string `yaml:"domains"`
+	IPs     string `yaml:"ips"`
+	ASNs    string `yaml:"asns"`
+	Orgs    string `yaml:"orgs"`
+	// Key derives the pseudonyms. A random key is used when not provided,
+	// so the pseudonyms only match within a single execution.
+	Key string `yaml:"key"`
+}
+
+// Redactor censors the domain names, IP addresses, ASNs and organization names shown in
+// the output. A nil Redactor returns all values unchanged.
+type Redactor struct {
+	sync.Mutex
+	rules RedactionRules
+	key   []byte
+	ips   map[string]string
+}
+
+// NewRedactor returns a Redactor that applies the provided rules. Empty rules default to RedactHash.
+func NewRedactor(rules RedactionRules) (*Redactor, error) {
+	for _, mode := range []*string{&rules.Domains, &rules.IPs, &rules.ASNs, &rules.Orgs} {
+		*mode = strings.ToLower(strings.TrimSpace(*mode))
+
+		switch *mode {
+		case "":
+			*mode = RedactHash
+		case RedactNone, RedactMask, RedactHash:
+		default:
+			return nil, fmt.Errorf("unknown redaction mode: %s", *mode)
+		}
+	}
+
+	key := []byte(rules.Key)
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return nil, fmt.Errorf("failed to generate the redaction key: %v", err)
+		}
+	}
+
+	return &Redactor{
+		rules: rules,
+		key:   key,
+		ips:   make(map[string]string),
+	}, nil
+}
+
+// Domain returns the censored domain name. The hash mode replaces each label, other
+// than the public suffix, so names in the same zone still share a parent domain.
+func (r *Redactor) Domain(name string) string {
+	if r == nil || name == "" {
+		return name
+	}
+
+	name = strings.ToLower(name)
+	suffix, _ := publicsuffix.PublicSuffix(name)
+	if suffix == name {
+		return name
+	}
+
+	switch r.rules.Domains {
+	case RedactMask:
+		// The subdomain labels are kept, since the registered domain identifies the organization
+		if apex, err := publicsuffix.EffectiveTLDPlusOne(name); err == nil {
+			return strings.TrimSuffix(name, apex) + censorString(apex, 0, len(apex))
+		}
+		return censorDomain(name)
+	case RedactHash:
+		labels := strings.Split(strings.TrimSuffix(name, "."+suffix), ".")
+		for i, label := range labels {
+			labels[i] = r.token("domain", label)
+		}
+		return strings.Join(labels, ".") + "." + suffix
+	}
+	return name
+}
+
+// IP returns the censored IP address. The hash mode preserves the prefixes shared
+// by addresses, so the pseudonyms remain within the pseudonyms of their netblocks.
+func (r *Redactor) IP(addr string) string {
+	if r == nil {
+		return addr
+	}
+
+	switch r.rules.IPs {
+	case RedactMask:
+		if strings.Contains(addr, ":") {
+			return censorString(addr, 0, strings.LastIndex(addr, ":"))
+		}
+		return censorIP(addr)
+	case RedactHash:
+		if ip := net.ParseIP(addr); ip != nil {
+			return r.pseudoIP(ip).String()
+		}
+	}
+	return addr
+}
+
+// Netblock returns the censored CIDR notation netblock.
+func (r *Redactor) Netblock(cidr string) string {
+	if r == nil {
+		return cidr
+	}
+
+	switch r.rules.IPs {
+	case RedactMask:
+		return censorNetBlock(cidr)
+	case RedactHash:
+		if _, ipnet, err := net.ParseCIDR(cidr); err == nil {
+			ones, _ := ipnet.Mask.Size()
+			pseudo := &net.IPNet{
+				IP:   r.pseudoIP(ipnet.IP).Mask(ipnet.Mask),
+				Mask: ipnet.Mask,
+			}
+			return fmt.Sprintf("%s/%d", pseudo.IP.String(), ones)
+		}
+	}
+	return cidr
+}
+
+// ASN returns the censored autonomous system number. The reserved ASN zero is not censored.
+func (r *Redactor) ASN(asn int) string {
+	num := strconv.Itoa(asn)
+	if r == nil || asn <= 0 {
+		return num
+	}
+
+	switch r.rules.ASNs {
+	case RedactMask:
+		return censorString(num, 0, len(num))
+	case RedactHash:
+		sum := r.sum("asn", num)
+		return strconv.FormatUint(privateASNStart+uint64(binary.BigEndian.Uint32(sum))%privateASNCount, 10)
+	}
+	return num
+}
+
+// Org returns the censored organization name or description.
+func (r *Redactor) Org(name string) string {
+	if r == nil || name == "" {
+		return name
+	}
+
+	switch r.rules.Orgs {
+	case RedactMask:
+		return censorString(name, 0, len(name))
+	case RedactHash:
+		return "org-" + r.token("org", name)
+	}
+	return name
+}
+
+// Text censors the domain names and IP addresses found within the text, such as a log message.
+func (r *Redactor) Text(text string) string {
+	if r == nil {
+		return text
+	}
+
+	text = ipv6Regex.ReplaceAllStringFunc(text, func(s string) string {
+		if ip := net.ParseIP(s); ip != nil && strings.Contains(s, ":") {
+			return r.IP(s)
+		}
+		return s
+	})
+	text = ipv4Regex.ReplaceAllStringFunc(text, func(s string) string {
+		if ip := net.ParseIP(s); ip != nil {
+			return r.IP(s)
+		}
+		return s
+	})
+	return fqdnRegex.ReplaceAllStringFunc(text, func(s string) string {
+		// Only names ending with a known public suffix are censored, leaving file names alone
+		if _, icann := publicsuffix.PublicSuffix(strings.ToLower(s)); icann {
+			return r.Domain(s)
+		}
+		return s
+	})
+}
+
+func (r *Redactor) sum(kind, value string) []byte {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(kind + ":" + value))
+	return mac.Sum(nil)
+}
+
+func (r *Redactor) token(kind, value string) string {
+	return hex.EncodeToString(r.sum(kind, value)[:4])
+}
+
+// pseudoIP flips each bit of the address based on the bits that precede it, so
+// addresses sharing a prefix have pseudonyms sharing a prefix of the same length.
+func (r *Redactor) pseudoIP(ip net.IP) net.IP {
+	b := ip.To4()
+	if b == nil {
+		b = ip.To16()
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	key := string(b)
+	if p, found := r.ips[key]; found {
+		return net.IP([]byte(p))
+	}
+
+	out := make([]byte, len(b))
+	prefix := make([]byte, len(b))
+	for i := 0; i < len(b)*8; i++ {
+		mac := hmac.New(sha256.New, r.key)
+		mac.Write([]byte{byte(len(b)), byte(i)})
+		mac.Write(prefix)
+		flip := mac.Sum(nil)[0] & 1
+
+		bit := (b[i/8] >> (7 - uint(i%8))) & 1
+		out[i/8] |= (bit ^ flip) << (7 - uint(i%8))
+		prefix[i/8] |= bit << (7 - uint(i%8))
+	}
+
+	r.ips[key] = string(out)
+	return net.IP(out)
+}
@@ -0,0 +1,86 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package format
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactorHash(t *testing.T) {
+	r, err := NewRedactor(RedactionRules{Key: "secret"})
+	require.NoError(t, err)
+
+	www := r.Domain("www.owasp.org")
+	mail := r.Domain("mail.owasp.org")
+	require.NotContains(t, www, "owasp")
+	require.True(t, strings.HasSuffix(www, ".org"))
+	require.Equal(t, www, r.Domain("WWW.owasp.org"))
+	// Names in the same zone still share the parent domain
+	require.Equal(t, strings.SplitN(www, ".", 2)[1], strings.SplitN(mail, ".", 2)[1])
+	require.Equal(t, strings.SplitN(www, ".", 2)[1], r.Domain("owasp.org"))
+	require.Equal(t, "co.uk", r.Domain("co.uk"))
+
+	// The same key produces the same pseudonyms
+	other, err := NewRedactor(RedactionRules{Key: "secret"})
+	require.NoError(t, err)
+	require.Equal(t, www, other.Domain("www.owasp.org"))
+	require.Equal(t, r.IP("104.22.27.77"), other.IP("104.22.27.77"))
+
+	// The address pseudonyms remain within the netblock pseudonyms
+	_, ipnet, err := net.ParseCIDR(r.Netblock("104.16.0.0/12"))
+	require.NoError(t, err)
+	addr := r.IP("104.22.27.77")
+	require.NotEqual(t, "104.22.27.77", addr)
+	require.True(t, ipnet.Contains(net.ParseIP(addr)))
+	require.NotNil(t, net.ParseIP(r.IP("2606:4700::6816:1b4d")).To16())
+
+	asn := r.ASN(13335)
+	require.NotEqual(t, "13335", asn)
+	require.Equal(t, asn, other.ASN(13335))
+	require.Equal(t, "0", r.ASN(0))
+	require.True(t, strings.HasPrefix(r.Org("CLOUDFLARENET"), "org-"))
+}
+
+func TestRedactorModes(t *testing.T) {
+	r, err := NewRedactor(RedactionRules{
+		Domains: "mask",
+		IPs:     "none",
+		ASNs:    "mask",
+		Orgs:    "none",
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "www.xxxxx.xxx", r.Domain("www.owasp.org"))
+	require.Equal(t, "xxxxx.xxx", r.Domain("owasp.org"))
+	require.Equal(t, "104.22.27.77", r.IP("104.22.27.77"))
+	require.Equal(t, "xxxxx", r.ASN(13335))
+	require.Equal(t, "CLOUDFLARENET", r.Org("CLOUDFLARENET"))
+
+	_, err = NewRedactor(RedactionRules{Domains: "blur"})
+	require.Error(t, err)
+
+	var none *Redactor
+	require.Equal(t, "www.owasp.org", none.Domain("www.owasp.org"))
+	require.Equal(t, "13335", none.ASN(13335))
+}
+
+func TestRedactorText(t *testing.T) {
+	r, err := NewRedactor(RedactionRules{Key: "secret"})
+	require.NoError(t, err)
+
+	line := "16:33:48.456831 DNS: www.owasp.org resolved to 104.22.27.77 and 2606:4700::6816:1b4d, see amass.log"
+	got := r.Text(line)
+	require.NotContains(t, got, "owasp")
+	require.NotContains(t, got, "104.22.27.77")
+	require.NotContains(t, got, "2606:4700::6816:1b4d")
+	require.Contains(t, got, r.Domain("www.owasp.org"))
+	require.Contains(t, got, r.IP("104.22.27.77"))
+	require.True(t, strings.HasPrefix(got, "16:33:48.456831 DNS: "))
+	require.True(t, strings.HasSuffix(got, "see amass.log"))
+}
@@ -0,0 +1,115 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/owasp-amass/amass/v4/resolvers"
+)
+
+// The number of the slowest resolvers included in the summary
+const slowResolversShown = 5
+
+// PrintResolverSummary outputs the resolver health information utilized by the command-line tools.
+func PrintResolverSummary(stats []*resolvers.Stats) {
+	FprintResolverSummary(color.Error, stats)
+}
+
+// FprintResolverSummary outputs the resolver health information collected during an enumeration.
+func FprintResolverSummary(out io.Writer, stats []*resolvers.Stats) {
+	var used, removed []*resolvers.Stats
+	var queries, timeouts, responses uint64
+	var qps, effective int
+	var latency time.Duration
+	rcodes := make(map[string]uint64)
+
+	for _, s := range stats {
+		if s.Removed {
+			removed = append(removed, s)
+		} else {
+			qps += s.QPS
+			effective += s.EffectiveQPS
+		}
+		if s.Queries == 0 {
+			continue
+		}
+
+		used = append(used, s)
+		queries += s.Queries
+		timeouts += s.Timeouts
+		responses += s.Responses
+		latency += s.AvgLatency * time.Duration(s.Responses)
+		for rcode, count := range s.Rcodes {
+			rcodes[rcode] += count
+		}
+	}
+	if len(used) == 0 && len(removed) == 0 {
+		return
+	}
+	if responses > 0 {
+		latency /= time.Duration(responses)
+	}
+
+	fmt.Fprintln(out)
+	b.Fprint(out, "DNS Resolvers\n")
+	fmt.Fprint(out, blue(strings.Repeat("-", 80)))
+	fmt.Fprintf(out, "\n%s%s %s%s %s%s %s%s\n",
+		yellow(len(used)), green(" used"), yellow(len(removed)), green(" removed"),
+		yellow(queries), green(" queries"), yellow(timeouts), green(" timeouts"))
+	fmt.Fprintf(out, "%s%s\n", blue("Average Latency: "), yellow(latency.Round(time.Millisecond)))
+	if qps > 0 {
+		// the rates of the resolvers are decreased after timeouts and failures
+		fmt.Fprintf(out, "%s%s%s%s%s\n", blue("Effective Rate: "), yellow(effective), green(" of "), yellow(qps), green(" queries per second"))
+	}
+
+	var codes []string
+	for rcode := range rcodes {
+		codes = append(codes, rcode)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		return rcodes[codes[i]] > rcodes[codes[j]]
+	})
+	for _, rcode := range codes {
+		fmt.Fprintf(out, "\t%-10s %s\n", yellow(rcode), yellow(rcodes[rcode]))
+	}
+
+	if len(removed) > 0 {
+		// The individual removals are found in the log and the resolver reputation file
+		reasons := make(map[string]int)
+		for _, s := range removed {
+			reasons[s.Reason]++
+		}
+
+		var list []string
+		for reason := range reasons {
+			list = append(list, reason)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return reasons[list[i]] > reasons[list[j]]
+		})
+
+		fmt.Fprintf(out, "%s\n", blue("Removed Resolvers:"))
+		for _, reason := range list {
+			fmt.Fprintf(out, "\t%-6s %s\n", yellow(reasons[reason]), green(reason))
+		}
+	}
+
+	sort.Slice(used, func(i, j int) bool {
+		return used[i].AvgLatency > used[j].AvgLatency
+	})
+	if len(used) > slowResolversShown {
+		used = used[:slowResolversShown]
+	}
+	fmt.Fprintf(out, "%s\n", blue("Slowest Resolvers:"))
+	for _, s := range used {
+		fmt.Fprintf(out, "\t%-22s %s %s\n", yellow(s.Address), yellow(s.AvgLatency.Round(time.Millisecond)), green("average latency"))
+	}
+}
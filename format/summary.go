@@ -0,0 +1,287 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package format
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/owasp-amass/amass/v4/provenance"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resolvers"
+)
+
+// SummaryFilename is the name of the file in the output directory that stores the enumeration summary.
+const SummaryFilename = "amass_summary.json"
+
+// Summary is the information presented once an enumeration has finished.
+type Summary struct {
+	Start   time.Time                     `json:"start"`
+	End     time.Time                     `json:"end"`
+	Total   int                           `json:"total_names"`
+	Domains map[string]int                `json:"domains"`
+	ASNs    map[int]*ASNSummaryData       `json:"asns"`
+	Sources map[string]*SourceSummaryData `json:"sources"`
+	DNS     DNSSummaryData                `json:"dns"`
+	Phases  []*PhaseSummaryData           `json:"phases"`
+	names   map[string]struct{}
+	redact  *Redactor
+}
+
+// SourceSummaryData stores the number of names contributed by a data source.
+type SourceSummaryData struct {
+	Tag    string `json:"tag"`
+	Names  int    `json:"names"`
+	First  int    `json:"first"`
+	Unique int    `json:"unique"`
+}
+
+// DNSSummaryData stores the DNS query totals across the resolvers used by an enumeration.
+type DNSSummaryData struct {
+	Queries     uint64            `json:"queries"`
+	Responses   uint64            `json:"responses"`
+	Timeouts    uint64            `json:"timeouts"`
+	Failures    uint64            `json:"failures"`
+	FailureRate float64           `json:"failure_rate"`
+	Rcodes      map[string]uint64 `json:"rcodes"`
+}
+
+// PhaseSummaryData stores the runtime of a single phase of an enumeration.
+type PhaseSummaryData struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+}
+
+// NewSummary returns an empty Summary for the enumeration that began at the provided time.
+func NewSummary(start time.Time) *Summary {
+	return &Summary{
+		Start:   start,
+		Domains: make(map[string]int),
+		ASNs:    make(map[int]*ASNSummaryData),
+		Sources: make(map[string]*SourceSummaryData),
+		DNS:     DNSSummaryData{Rcodes: make(map[string]uint64)},
+		names:   make(map[string]struct{}),
+	}
+}
+
+// AddOutput counts the name, found under the root domain, and the infrastructure it resolved to.
+func (s *Summary) AddOutput(output *requests.Output, domain string) {
+	name := strings.ToLower(output.Name)
+	if _, found := s.names[name]; found {
+		return
+	}
+
+	s.names[name] = struct{}{}
+	s.Total++
+	if domain != "" {
+		s.Domains[domain]++
+	}
+	UpdateSummaryData(output, s.ASNs)
+}
+
+// AddAttributions counts the names contributed by each data source. Only the names
+// previously provided by AddOutput are included.
+func (s *Summary) AddAttributions(attrs []*provenance.Attribution) {
+	byname := make(map[string][]*provenance.Attribution)
+
+	for _, a := range attrs {
+		if _, found := s.names[a.Asset]; found {
+			byname[a.Asset] = append(byname[a.Asset], a)
+		}
+	}
+
+	for _, list := range byname {
+		first := provenance.First(list)
+
+		for _, a := range list {
+			data, found := s.Sources[a.Source]
+			if !found {
+				data = &SourceSummaryData{Tag: a.Tag}
+				s.Sources[a.Source] = data
+			}
+
+			data.Names++
+			if a == first {
+				data.First++
+			}
+			if len(list) == 1 {
+				data.Unique++
+			}
+		}
+	}
+}
+
+// AddResolverStats adds the queries sent to the resolvers to the DNS totals. Queries that
+// timed out or received an error other than NXDOMAIN are counted as failures.
+func (s *Summary) AddResolverStats(stats []*resolvers.Stats) {
+	for _, st := range stats {
+		s.DNS.Queries += st.Queries
+		s.DNS.Responses += st.Responses
+		s.DNS.Timeouts += st.Timeouts
+		s.DNS.Failures += st.Timeouts
+
+		for rcode, count := range st.Rcodes {
+			s.DNS.Rcodes[rcode] += count
+			if rcode != "NOERROR" && rcode != "NXDOMAIN" {
+				s.DNS.Failures += count
+			}
+		}
+	}
+
+	if s.DNS.Queries > 0 {
+		s.DNS.FailureRate = float64(s.DNS.Failures) / float64(s.DNS.Queries)
+	}
+}
+
+// AddPhase records the runtime of an enumeration phase that began at the provided time.
+func (s *Summary) AddPhase(name string, start, end time.Time) {
+	s.Phases = append(s.Phases, &PhaseSummaryData{
+		Name:     name,
+		Start:    start,
+		Duration: end.Sub(start),
+	})
+}
+
+// SetRedactor causes the names and infrastructure to be censored when the summary is printed or saved.
+func (s *Summary) SetRedactor(r *Redactor) {
+	s.redact = r
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (s *Summary) MarshalJSON() ([]byte, error) {
+	type alias Summary
+
+	domains := make(map[string]int, len(s.Domains))
+	for d, count := range s.Domains {
+		domains[s.redact.Domain(d)] += count
+	}
+
+	asns := make(map[string]*ASNSummaryData, len(s.ASNs))
+	for asn, data := range s.ASNs {
+		key := s.redact.ASN(asn)
+
+		entry, found := asns[key]
+		if !found {
+			entry = &ASNSummaryData{Name: data.Name, Netblocks: make(map[string]int)}
+			if asn > 0 {
+				entry.Name = s.redact.Org(data.Name)
+			}
+			asns[key] = entry
+		}
+		for cidr, count := range data.Netblocks {
+			entry.Netblocks[s.redact.Netblock(cidr)] += count
+		}
+	}
+
+	return json.Marshal(&struct {
+		*alias
+		Domains map[string]int             `json:"domains"`
+		ASNs    map[string]*ASNSummaryData `json:"asns"`
+	}{
+		alias:   (*alias)(s),
+		Domains: domains,
+		ASNs:    asns,
+	})
+}
+
+// Save writes the summary as JSON to the file at the provided path.
+func (s *Summary) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the enumeration summary: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create the enumeration summary file: %v", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write the enumeration summary file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write the enumeration summary file: %v", err)
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// PrintSummary outputs the enumeration summary to stderr.
+func PrintSummary(s *Summary) {
+	FprintSummary(color.Error, s)
+}
+
+// FprintSummary outputs the names per root domain, the infrastructure, the data source
+// contributions, the DNS query totals and the runtime of each enumeration phase.
+func FprintSummary(out io.Writer, s *Summary) {
+	FprintEnumerationSummary(out, s.Total, s.ASNs, s.redact)
+
+	if len(s.Domains) > 0 {
+		fprintSectionHeader(out, "Root Domains")
+		domains := make([]string, 0, len(s.Domains))
+		for d := range s.Domains {
+			domains = append(domains, d)
+		}
+		sort.Strings(domains)
+
+		for _, d := range domains {
+			name := s.redact.Domain(d)
+			fmt.Fprintf(out, "%s\t%s %s\n", yellow(fmt.Sprintf("%-30s", name)), yellow(fmt.Sprintf("%-6d", s.Domains[d])), blue("Name(s)"))
+		}
+	}
+
+	if len(s.Sources) > 0 {
+		fprintSectionHeader(out, "Data Sources")
+		srcs := make([]string, 0, len(s.Sources))
+		for src := range s.Sources {
+			srcs = append(srcs, src)
+		}
+		sort.Slice(srcs, func(i, j int) bool {
+			if a, b := s.Sources[srcs[i]], s.Sources[srcs[j]]; a.Names != b.Names {
+				return a.Names > b.Names
+			}
+			return srcs[i] < srcs[j]
+		})
+
+		for _, src := range srcs {
+			data := s.Sources[src]
+			fmt.Fprintf(out, "%s\t%s %s %s %s %s %s\n", green(fmt.Sprintf("%-30s", src)),
+				yellow(fmt.Sprintf("%-6d", data.Names)), blue("Name(s)"), yellow(data.First),
+				blue("First"), yellow(data.Unique), blue("Unique"))
+		}
+	}
+
+	if s.DNS.Queries > 0 {
+		fprintSectionHeader(out, "DNS Queries")
+		fmt.Fprintf(out, "%s%s %s%s %s%s %s%s\n",
+			yellow(s.DNS.Queries), green(" queries"), yellow(s.DNS.Responses), green(" responses"),
+			yellow(s.DNS.Failures), green(" failures"), yellow(fmt.Sprintf("%.2f%%", s.DNS.FailureRate*100)), green(" failure rate"))
+	}
+
+	if len(s.Phases) > 0 {
+		fprintSectionHeader(out, "Runtime")
+		for _, p := range s.Phases {
+			fmt.Fprintf(out, "%s\t%s\n", blue(fmt.Sprintf("%-30s", p.Name)), yellow(p.Duration.Round(time.Millisecond)))
+		}
+		if !s.End.IsZero() {
+			fmt.Fprintf(out, "%s\t%s\n", blue(fmt.Sprintf("%-30s", "Total")), yellow(s.End.Sub(s.Start).Round(time.Millisecond)))
+		}
+	}
+}
+
+func fprintSectionHeader(out io.Writer, title string) {
+	fmt.Fprintln(out)
+	b.Fprintln(out, title)
+	fmt.Fprintln(out, blue(strings.Repeat("-", 80)))
+}
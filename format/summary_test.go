@@ -0,0 +1,106 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/owasp-amass/amass/v4/provenance"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSummary(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	s := NewSummary(start)
+
+	addr := func(ip string) requests.AddressInfo {
+		return requests.AddressInfo{
+			Address:     net.ParseIP(ip),
+			ASN:         13335,
+			CIDRStr:     "104.16.0.0/12",
+			Description: "CLOUDFLARENET",
+		}
+	}
+	s.AddOutput(&requests.Output{Name: "www.owasp.org", Addresses: []requests.AddressInfo{addr("104.22.27.77")}}, "owasp.org")
+	s.AddOutput(&requests.Output{Name: "owasp.org", Addresses: []requests.AddressInfo{addr("104.22.27.77")}}, "owasp.org")
+	s.AddOutput(&requests.Output{Name: "www.owasp.org"}, "owasp.org")
+	s.AddOutput(&requests.Output{Name: "mail.example.com"}, "example.com")
+	require.Equal(t, 3, s.Total)
+	require.Equal(t, map[string]int{"owasp.org": 2, "example.com": 1}, s.Domains)
+	require.Equal(t, 1, s.ASNs[13335].Netblocks["104.16.0.0/12"])
+
+	s.AddAttributions([]*provenance.Attribution{
+		{Asset: "www.owasp.org", Source: "crtsh", Tag: "cert", FirstSeen: start},
+		{Asset: "www.owasp.org", Source: "DNS", Tag: "dns", FirstSeen: start.Add(time.Second)},
+		{Asset: "owasp.org", Source: "User Input", Tag: "user", FirstSeen: start},
+		{Asset: "mail.example.com", Source: "DNS", Tag: "dns", FirstSeen: start},
+		{Asset: "104.22.27.77", Source: "DNS", Tag: "dns", FirstSeen: start},
+	})
+	require.Equal(t, &SourceSummaryData{Tag: "cert", Names: 1, First: 1}, s.Sources["crtsh"])
+	require.Equal(t, &SourceSummaryData{Tag: "dns", Names: 2, First: 1, Unique: 1}, s.Sources["DNS"])
+	require.Equal(t, &SourceSummaryData{Tag: "user", Names: 1, First: 1, Unique: 1}, s.Sources["User Input"])
+
+	s.AddResolverStats([]*resolvers.Stats{
+		{Queries: 80, Responses: 70, Timeouts: 10, Rcodes: map[string]uint64{"NOERROR": 50, "NXDOMAIN": 15, "SERVFAIL": 5}},
+		{Queries: 20, Responses: 20, Rcodes: map[string]uint64{"NOERROR": 20}},
+	})
+	require.Equal(t, uint64(100), s.DNS.Queries)
+	require.Equal(t, uint64(15), s.DNS.Failures)
+	require.InDelta(t, 0.15, s.DNS.FailureRate, 0.0001)
+
+	s.AddPhase("Enumeration", start, start.Add(30*time.Second))
+	s.End = start.Add(time.Minute)
+
+	color.NoColor = true
+	var buf bytes.Buffer
+	FprintSummary(&buf, s)
+	out := buf.String()
+	require.Contains(t, out, "3 names discovered")
+	require.Contains(t, out, "104.16.0.0/12")
+	require.Contains(t, out, "crtsh")
+	require.Contains(t, out, "15.00% failure rate")
+	require.Contains(t, out, "30s")
+
+	redact, err := NewRedactor(RedactionRules{Key: "test"})
+	require.NoError(t, err)
+	s.SetRedactor(redact)
+
+	buf.Reset()
+	FprintSummary(&buf, s)
+	require.NotContains(t, buf.String(), "13335")
+	require.NotContains(t, buf.String(), "owasp.org")
+	require.NotContains(t, buf.String(), "CLOUDFLARENET")
+
+	data, err := json.Marshal(s)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "owasp.org")
+	require.NotContains(t, string(data), "104.16.0.0/12")
+	require.Contains(t, string(data), redact.Domain("owasp.org"))
+}
+
+func TestSummarySave(t *testing.T) {
+	s := NewSummary(time.Now())
+	s.AddOutput(&requests.Output{Name: "www.owasp.org"}, "owasp.org")
+
+	path := filepath.Join(t.TempDir(), SummaryFilename)
+	require.NoError(t, s.Save(path))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	var saved Summary
+	require.NoError(t, json.Unmarshal(data, &saved))
+	require.Equal(t, 1, saved.Total)
+	require.Equal(t, map[string]int{"owasp.org": 1}, saved.Domains)
+}
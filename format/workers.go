@@ -0,0 +1,50 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package format
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/owasp-amass/amass/v4/worker"
+)
+
+// PrintWorkerSummary outputs the worker health information utilized by the command-line tools.
+func PrintWorkerSummary(stats []*worker.WorkerStats) {
+	FprintWorkerSummary(color.Error, stats)
+}
+
+// FprintWorkerSummary outputs the health information collected for the workers of an enumeration.
+func FprintWorkerSummary(out io.Writer, stats []*worker.WorkerStats) {
+	if len(stats) == 0 {
+		return
+	}
+
+	var healthy int
+	var queries, reassigned uint64
+	for _, s := range stats {
+		if s.Healthy {
+			healthy++
+		}
+		queries += s.Queries
+		reassigned += s.Reassigned
+	}
+
+	fmt.Fprintln(out)
+	b.Fprint(out, "Workers\n")
+	fmt.Fprint(out, blue(strings.Repeat("-", 80)))
+	fmt.Fprintf(out, "\n%s%s %s%s %s%s\n",
+		yellow(healthy), green(" healthy"), yellow(queries), green(" queries"), yellow(reassigned), green(" reassigned"))
+	for _, s := range stats {
+		fmt.Fprintf(out, "\t%-22s %s%s %s%s %s%s %s%s\n", yellow(s.Address),
+			yellow(s.Queries), green(" queries"), yellow(s.Responses), green(" responses"),
+			yellow(s.Failures), green(" failures"), yellow(s.Disconnects), green(" disconnects"))
+		if s.LastError != "" {
+			fmt.Fprintf(out, "\t%-22s %s\n", "", green(s.LastError))
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// The maximum length of a line in the imported JSON and text formats.
+const maxLineLength = 4 * 1024 * 1024
+
+// eachLine calls the function for each line that is not empty or a comment.
+func eachLine(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+
+	var num int
+	for scanner.Scan() {
+		num++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if err := fn(line); err != nil {
+			return fmt.Errorf("failed to parse line %d: %v", num, err)
+		}
+	}
+	return scanner.Err()
+}
+
+type massdnsResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Data   struct {
+		Answers []struct {
+			Name string `json:"name"`
+			Type string `json:"type"`
+			Data string `json:"data"`
+		} `json:"answers"`
+	} `json:"data"`
+}
+
+// readMassDNS parses the massdns ndjson (-o J) and simple text (-o S) output.
+func readMassDNS(r io.Reader, b *builder) error {
+	return eachLine(r, func(line string) error {
+		if !strings.HasPrefix(line, "{") {
+			parts := strings.SplitN(line, " ", 3)
+			if len(parts) != 3 {
+				return fmt.Errorf("expected the name, type and data")
+			}
+			b.addRecord(parts[0], parts[1], parts[2])
+			return nil
+		}
+
+		var res massdnsResult
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			return err
+		}
+		if res.Status != "" && res.Status != "NOERROR" {
+			return nil
+		}
+
+		b.addName(res.Name)
+		// The answers are kept with their owner names, so CNAME chains are preserved
+		for _, a := range res.Data.Answers {
+			b.addRecord(a.Name, a.Type, a.Data)
+		}
+		return nil
+	})
+}
+
+type dnsxResult struct {
+	Host       string   `json:"host"`
+	StatusCode string   `json:"status_code"`
+	A          []string `json:"a"`
+	AAAA       []string `json:"aaaa"`
+	CNAME      []string `json:"cname"`
+	NS         []string `json:"ns"`
+	MX         []string `json:"mx"`
+	PTR        []string `json:"ptr"`
+	TXT        []string `json:"txt"`
+}
+
+// readDNSX parses the dnsx JSON (-json) output.
+func readDNSX(r io.Reader, b *builder) error {
+	return eachLine(r, func(line string) error {
+		var res dnsxResult
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			return err
+		}
+		if res.StatusCode != "" && res.StatusCode != "NOERROR" {
+			return nil
+		}
+
+		// The PTR queries are made for addresses
+		if ip := net.ParseIP(res.Host); ip != nil {
+			b.addAddr(ip.String())
+			if rev, err := dns.ReverseAddr(ip.String()); err == nil {
+				for _, ptr := range res.PTR {
+					b.addRecord(rev, "PTR", ptr)
+				}
+			}
+			return nil
+		}
+
+		b.addName(res.Host)
+		// The addresses belong to the last name in the CNAME chain
+		owner := res.Host
+		prev := res.Host
+		for _, cname := range res.CNAME {
+			b.addRecord(prev, "CNAME", cname)
+			prev, owner = cname, cname
+		}
+
+		for _, records := range []struct {
+			owner  string
+			rrtype string
+			list   []string
+		}{
+			{owner, "A", res.A},
+			{owner, "AAAA", res.AAAA},
+			{res.Host, "NS", res.NS},
+			{res.Host, "MX", res.MX},
+			{res.Host, "TXT", res.TXT},
+		} {
+			for _, data := range records.list {
+				b.addRecord(records.owner, records.rrtype, data)
+			}
+		}
+		return nil
+	})
+}
+
+type subfinderResult struct {
+	Host   string `json:"host"`
+	IP     string `json:"ip"`
+	Source string `json:"source"`
+}
+
+// readSubfinder parses the subfinder JSON lines (-oJ) output. The names are attributed to the
+// subfinder source that found them, rather than subfinder itself.
+func readSubfinder(r io.Reader, b *builder) error {
+	return eachLine(r, func(line string) error {
+		var res subfinderResult
+		if err := json.Unmarshal([]byte(line), &res); err != nil {
+			return err
+		}
+
+		if req := b.addName(res.Host); req != nil && req.Source == b.format && res.Source != "" {
+			req.Source = res.Source
+		}
+		if ip := net.ParseIP(res.IP); ip != nil {
+			rrtype := "A"
+			if ip.To4() == nil {
+				rrtype = "AAAA"
+			}
+			b.addRecord(res.Host, rrtype, ip.String())
+		}
+		return nil
+	})
+}
+
+type nmapRun struct {
+	Hosts []struct {
+		Status struct {
+			State string `xml:"state,attr"`
+		} `xml:"status"`
+		Addresses []struct {
+			Addr string `xml:"addr,attr"`
+			Type string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"hostnames>hostname"`
+	} `xml:"host"`
+}
+
+// readNmap parses the nmap XML (-oX) output.
+func readNmap(r io.Reader, b *builder) error {
+	var run nmapRun
+	if err := xml.NewDecoder(r).Decode(&run); err != nil {
+		return fmt.Errorf("failed to parse the nmap XML: %v", err)
+	}
+
+	for _, host := range run.Hosts {
+		if host.Status.State != "" && host.Status.State != "up" {
+			continue
+		}
+
+		for _, addr := range host.Addresses {
+			ip := net.ParseIP(addr.Addr)
+			if ip == nil || (addr.Type != "ipv4" && addr.Type != "ipv6") {
+				continue
+			}
+
+			b.addAddr(ip.String())
+			rrtype := "A"
+			if addr.Type == "ipv6" {
+				rrtype = "AAAA"
+			}
+
+			for _, hn := range host.Hostnames {
+				if strings.EqualFold(hn.Type, "PTR") {
+					if rev, err := dns.ReverseAddr(ip.String()); err == nil {
+						b.addRecord(rev, "PTR", hn.Name)
+					}
+					continue
+				}
+				// The user provided names were resolved to the address
+				b.addRecord(hn.Name, rrtype, ip.String())
+			}
+		}
+	}
+	return nil
+}
+
+// readZone parses the resource records in a DNS zone file.
+func readZone(r io.Reader, b *builder) error {
+	zp := dns.NewZoneParser(r, "", "")
+
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		name := rr.Header().Name
+
+		switch v := rr.(type) {
+		case *dns.A:
+			b.addRecord(name, "A", v.A.String())
+		case *dns.AAAA:
+			b.addRecord(name, "AAAA", v.AAAA.String())
+		case *dns.CNAME:
+			b.addRecord(name, "CNAME", v.Target)
+		case *dns.NS:
+			b.addRecord(name, "NS", v.Ns)
+		case *dns.MX:
+			b.addRecord(name, "MX", v.Mx)
+		case *dns.PTR:
+			b.addRecord(name, "PTR", v.Ptr)
+		case *dns.SRV:
+			b.addRecord(name, "SRV", v.Target)
+		case *dns.TXT:
+			b.addRecord(name, "TXT", strings.Join(v.Txt, " "))
+		default:
+			b.addName(name)
+		}
+	}
+	if err := zp.Err(); err != nil {
+		return fmt.Errorf("failed to parse the zone file: %v", err)
+	}
+	return nil
+}
+
+type fdnsRecord struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// readFDNS parses the forward DNS datasets of Rapid7 Project Sonar, and the compatible datasets.
+func readFDNS(r io.Reader, b *builder) error {
+	return eachLine(r, func(line string) error {
+		var rec fdnsRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			return err
+		}
+
+		b.addRecord(rec.Name, rec.Type, rec.Value)
+		return nil
+	})
+}
@@ -0,0 +1,425 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/caffix/netmap"
+	"github.com/miekg/dns"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/provenance"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/config/config"
+	"github.com/owasp-amass/resolve"
+	"golang.org/x/net/publicsuffix"
+)
+
+// The output formats of other tools that can be imported.
+const (
+	FormatMassDNS   = "massdns"
+	FormatDNSX      = "dnsx"
+	FormatSubfinder = "subfinder"
+	FormatNmap      = "nmap"
+	FormatZone      = "zone"
+	FormatFDNS      = "fdns"
+)
+
+// Formats are all the formats that can be imported.
+var Formats = []string{FormatMassDNS, FormatDNSX, FormatSubfinder, FormatNmap, FormatZone, FormatFDNS}
+
+// Results are the names, with their DNS records, and the addresses found in the imported data.
+type Results struct {
+	Format string
+	Names  []*requests.DNSRequest
+	Addrs  []*requests.AddrRequest
+}
+
+// ReadFile parses the findings in the file using the specified format.
+func ReadFile(path, format string) (*Results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	return Read(f, format)
+}
+
+// Read parses the findings provided by the reader in the specified format. Compressed data
+// is detected and decompressed, and an empty format is detected from the data.
+func Read(r io.Reader, format string) (*Results, error) {
+	reader, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		if format, err = detectFormat(reader); err != nil {
+			return nil, err
+		}
+	}
+
+	format = strings.ToLower(format)
+	b := newBuilder(format)
+	switch format {
+	case FormatMassDNS:
+		err = readMassDNS(reader, b)
+	case FormatDNSX:
+		err = readDNSX(reader, b)
+	case FormatSubfinder:
+		err = readSubfinder(reader, b)
+	case FormatNmap:
+		err = readNmap(reader, b)
+	case FormatZone:
+		err = readZone(reader, b)
+	case FormatFDNS:
+		err = readFDNS(reader, b)
+	default:
+		return nil, fmt.Errorf("unsupported import format: %s", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return b.results(), nil
+}
+
+func decompress(r io.Reader) (*bufio.Reader, error) {
+	reader := bufio.NewReaderSize(r, 1<<20)
+
+	magic, err := reader.Peek(3)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) >= 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress the gzip data: %v", err)
+		}
+		return bufio.NewReaderSize(zr, 1<<20), nil
+	}
+	if bytes.Equal(magic, []byte("BZh")) {
+		return bufio.NewReaderSize(bzip2.NewReader(reader), 1<<20), nil
+	}
+	return reader, nil
+}
+
+func detectFormat(r *bufio.Reader) (string, error) {
+	head, err := r.Peek(64 * 1024)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return "", err
+	}
+
+	var line string
+	for _, l := range strings.Split(string(head), "\n") {
+		if l = strings.TrimSpace(l); l != "" && !strings.HasPrefix(l, ";") && !strings.HasPrefix(l, "#") {
+			line = l
+			break
+		}
+	}
+	if line == "" {
+		return "", fmt.Errorf("failed to detect the format of the imported data")
+	}
+
+	if strings.HasPrefix(line, "<") {
+		if bytes.Contains(head, []byte("<nmaprun")) {
+			return FormatNmap, nil
+		}
+		return "", fmt.Errorf("failed to detect the format of the imported XML data")
+	}
+
+	if strings.HasPrefix(line, "{") {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return "", fmt.Errorf("failed to detect the format of the imported JSON data: %v", err)
+		}
+
+		has := func(keys ...string) bool {
+			for _, k := range keys {
+				if _, found := fields[k]; found {
+					return true
+				}
+			}
+			return false
+		}
+		switch {
+		case has("status") && has("data"):
+			return FormatMassDNS, nil
+		case has("name") && has("type") && has("value"):
+			return FormatFDNS, nil
+		case has("host") && has("a", "aaaa", "cname", "ns", "mx", "ptr", "txt", "resolver", "status_code"):
+			return FormatDNSX, nil
+		case has("host"):
+			return FormatSubfinder, nil
+		}
+		return "", fmt.Errorf("failed to detect the format of the imported JSON data")
+	}
+
+	// The massdns simple output has the name, type and data on each line
+	if parts := strings.Fields(line); len(parts) == 3 && !strings.HasPrefix(line, "$") {
+		if _, found := dns.StringToType[strings.ToUpper(parts[1])]; found {
+			return FormatMassDNS, nil
+		}
+	}
+	return FormatZone, nil
+}
+
+// builder merges the findings for each name, so the names and records are only provided once.
+type builder struct {
+	format string
+	tag    string
+	names  map[string]*requests.DNSRequest
+	seen   map[string]struct{}
+	addrs  map[string]struct{}
+}
+
+func newBuilder(format string) *builder {
+	return &builder{
+		format: format,
+		tag:    importTag(format),
+		names:  make(map[string]*requests.DNSRequest),
+		seen:   make(map[string]struct{}),
+		addrs:  make(map[string]struct{}),
+	}
+}
+
+// importTag returns the tag marking the findings imported from the output of the tool (e.g. import:subfinder).
+func importTag(format string) string {
+	return requests.IMPORT + ":" + format
+}
+
+func cleanName(name string) string {
+	return strings.ToLower(strings.TrimSpace(resolve.RemoveLastDot(strings.TrimSpace(name))))
+}
+
+func (b *builder) addName(name string) *requests.DNSRequest {
+	name = cleanName(name)
+	if _, ok := dns.IsDomainName(name); !ok || name == "" || net.ParseIP(name) != nil {
+		return nil
+	}
+
+	req, found := b.names[name]
+	if !found {
+		req = &requests.DNSRequest{
+			Name:   name,
+			Tag:    b.tag,
+			Source: b.format,
+		}
+		b.names[name] = req
+	}
+	return req
+}
+
+func (b *builder) addRecord(name, rrtype, data string) {
+	qtype, found := dns.StringToType[strings.ToUpper(strings.TrimSpace(rrtype))]
+	if !found {
+		return
+	}
+
+	data = strings.TrimSpace(data)
+	switch qtype {
+	case dns.TypeA, dns.TypeAAAA:
+		ip := net.ParseIP(data)
+		if ip == nil {
+			return
+		}
+		data = ip.String()
+		b.addAddr(data)
+	case dns.TypeCNAME, dns.TypeNS, dns.TypePTR:
+		data = cleanName(data)
+		b.addName(data)
+	case dns.TypeMX, dns.TypeSRV:
+		// Only keep the target of the MX and SRV records
+		parts := strings.Fields(data)
+		if len(parts) == 0 {
+			return
+		}
+		data = cleanName(parts[len(parts)-1])
+		b.addName(data)
+	}
+	if data == "" {
+		return
+	}
+
+	req := b.addName(name)
+	if req == nil {
+		return
+	}
+
+	key := req.Name + "|" + fmt.Sprint(qtype) + "|" + data
+	if _, found := b.seen[key]; found {
+		return
+	}
+	b.seen[key] = struct{}{}
+
+	req.Records = append(req.Records, requests.DNSAnswer{
+		Name: req.Name,
+		Type: int(qtype),
+		Data: data,
+	})
+}
+
+func (b *builder) addAddr(addr string) {
+	if ip := net.ParseIP(strings.TrimSpace(addr)); ip != nil {
+		b.addrs[ip.String()] = struct{}{}
+	}
+}
+
+func (b *builder) results() *Results {
+	res := &Results{Format: b.format}
+
+	for _, req := range b.names {
+		res.Names = append(res.Names, req)
+	}
+	sort.Slice(res.Names, func(i, j int) bool {
+		return res.Names[i].Name < res.Names[j].Name
+	})
+
+	for addr := range b.addrs {
+		res.Addrs = append(res.Addrs, &requests.AddrRequest{
+			Address: addr,
+			Tag:     b.tag,
+			Source:  b.format,
+		})
+	}
+	sort.Slice(res.Addrs, func(i, j int) bool {
+		return res.Addrs[i].Address < res.Addrs[j].Address
+	})
+	return res
+}
+
+// Scope returns the findings within the scope of the configuration. The names are assigned
+// their root domain names, and the addresses are marked in scope when they are within the
+// configured addresses or netblocks, or are the records of names within scope.
+func (res *Results) Scope(cfg *config.Config) *Results {
+	scoped := &Results{Format: res.Format}
+
+	addrDomains := make(map[string]string)
+	for _, req := range res.Names {
+		domain := cfg.WhichDomain(req.Name)
+		if domain == "" {
+			// Reverse DNS names are kept when they point into the scope
+			domain = ptrDomain(cfg, req)
+		}
+		if domain == "" {
+			continue
+		}
+
+		c := req.Clone().(*requests.DNSRequest)
+		c.Domain = domain
+		scoped.Names = append(scoped.Names, c)
+
+		for _, rr := range req.Records {
+			if t := uint16(rr.Type); t == dns.TypeA || t == dns.TypeAAAA {
+				addrDomains[rr.Data] = cfg.WhichDomain(req.Name)
+			}
+		}
+	}
+
+	for _, a := range res.Addrs {
+		domain, found := addrDomains[a.Address]
+		if !found && !cfg.IsAddressInScope(a.Address) {
+			continue
+		}
+		if reserved, _ := amassnet.IsReservedAddress(a.Address); reserved {
+			continue
+		}
+
+		scoped.Addrs = append(scoped.Addrs, &requests.AddrRequest{
+			Address: a.Address,
+			InScope: true,
+			Domain:  domain,
+			Tag:     a.Tag,
+			Source:  a.Source,
+		})
+	}
+	return scoped
+}
+
+func ptrDomain(cfg *config.Config, req *requests.DNSRequest) string {
+	for _, rr := range req.Records {
+		if uint16(rr.Type) == dns.TypePTR && cfg.WhichDomain(rr.Data) != "" {
+			if domain, err := publicsuffix.EffectiveTLDPlusOne(req.Name); err == nil {
+				return domain
+			}
+		}
+	}
+	return ""
+}
+
+// Store enters the findings directly into the graph, without validating them. When the
+// provenance store is provided, the findings are attributed to their sources and tagged with the imported tool.
+func (res *Results) Store(ctx context.Context, g *netmap.Graph, sources *provenance.Store) error {
+	var err error
+	save := func(e error) {
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	now := time.Now()
+	attribute := func(asset, source, tag string) {
+		if sources != nil && source != "" {
+			save(sources.Add(asset, source, tag, now))
+		}
+	}
+
+	for _, req := range res.Names {
+		select {
+		case <-ctx.Done():
+			return err
+		default:
+		}
+
+		attribute(req.Name, req.Source, req.Tag)
+		if len(req.Records) == 0 {
+			_, e := g.UpsertFQDN(ctx, req.Name)
+			save(e)
+			continue
+		}
+
+		for _, rr := range req.Records {
+			switch uint16(rr.Type) {
+			case dns.TypeA:
+				save(g.UpsertA(ctx, req.Name, rr.Data))
+			case dns.TypeAAAA:
+				save(g.UpsertAAAA(ctx, req.Name, rr.Data))
+			case dns.TypeCNAME:
+				save(g.UpsertCNAME(ctx, req.Name, rr.Data))
+			case dns.TypePTR:
+				save(g.UpsertPTR(ctx, req.Name, rr.Data))
+			case dns.TypeSRV:
+				save(g.UpsertSRV(ctx, req.Name, rr.Data))
+			case dns.TypeNS:
+				save(g.UpsertNS(ctx, req.Name, rr.Data))
+			case dns.TypeMX:
+				save(g.UpsertMX(ctx, req.Name, rr.Data))
+			default:
+				_, e := g.UpsertFQDN(ctx, req.Name)
+				save(e)
+			}
+		}
+	}
+
+	for _, a := range res.Addrs {
+		_, e := g.UpsertAddress(ctx, a.Address)
+		save(e)
+		attribute(a.Address, a.Source, a.Tag)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to store the imported findings: %v", err)
+	}
+	return nil
+}
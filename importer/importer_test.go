@@ -0,0 +1,222 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package importer
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caffix/netmap"
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/provenance"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/config/config"
+	oam "github.com/owasp-amass/open-asset-model"
+	"github.com/owasp-amass/open-asset-model/domain"
+	"github.com/stretchr/testify/require"
+)
+
+func readTestFile(t *testing.T, name, format string) *Results {
+	res, err := ReadFile(filepath.Join("testdata", name), format)
+	require.NoError(t, err)
+	return res
+}
+
+// records returns the records of each name as "TYPE data" strings.
+func records(res *Results) map[string][]string {
+	m := make(map[string][]string)
+	for _, req := range res.Names {
+		m[req.Name] = []string{}
+		for _, rr := range req.Records {
+			m[req.Name] = append(m[req.Name], dns.TypeToString[uint16(rr.Type)]+" "+rr.Data)
+		}
+	}
+	return m
+}
+
+func addrs(res *Results) []string {
+	var list []string
+	for _, a := range res.Addrs {
+		list = append(list, a.Address)
+	}
+	return list
+}
+
+func TestDetectFormat(t *testing.T) {
+	for file, format := range map[string]string{
+		"massdns.ndjson": FormatMassDNS,
+		"massdns.txt":    FormatMassDNS,
+		"dnsx.json":      FormatDNSX,
+		"subfinder.json": FormatSubfinder,
+		"nmap.xml":       FormatNmap,
+		"owasp.zone":     FormatZone,
+		"fdns.json":      FormatFDNS,
+		"fdns.json.gz":   FormatFDNS,
+	} {
+		require.Equal(t, format, readTestFile(t, file, "").Format, file)
+	}
+
+	_, err := Read(strings.NewReader(`{"unknown":true}`), "")
+	require.Error(t, err)
+	_, err = Read(strings.NewReader("owasp.org"), "csv")
+	require.Error(t, err)
+}
+
+func TestMassDNS(t *testing.T) {
+	res := readTestFile(t, "massdns.ndjson", FormatMassDNS)
+
+	require.Equal(t, map[string][]string{
+		"www.owasp.org":  {"CNAME owasp.org"},
+		"owasp.org":      {"A 104.22.27.77"},
+		"mail.owasp.org": {"MX mx.example.com"},
+		"mx.example.com": {},
+	}, records(res))
+	require.Equal(t, []string{"104.22.27.77"}, addrs(res))
+
+	text := readTestFile(t, "massdns.txt", "")
+	require.Equal(t, map[string][]string{
+		"www.owasp.org": {"CNAME owasp.org"},
+		"owasp.org":     {"A 104.22.27.77"},
+	}, records(text))
+}
+
+func TestDNSX(t *testing.T) {
+	res := readTestFile(t, "dnsx.json", FormatDNSX)
+
+	require.Equal(t, map[string][]string{
+		"www.owasp.org":             {"CNAME owasp.org"},
+		"owasp.org":                 {"A 104.22.27.77", "A 172.67.10.39", "NS ns1.example.net", "MX mx.example.com", "TXT v=spf1 include:_spf.owasp.org ~all"},
+		"ns1.example.net":           {},
+		"mx.example.com":            {},
+		"edge.owasp.org":            {},
+		"77.27.22.104.in-addr.arpa": {"PTR edge.owasp.org"},
+	}, records(res))
+	require.Equal(t, []string{"104.22.27.77", "172.67.10.39"}, addrs(res))
+}
+
+func TestSubfinder(t *testing.T) {
+	res := readTestFile(t, "subfinder.json", FormatSubfinder)
+
+	require.Equal(t, map[string][]string{
+		"www.owasp.org":   {},
+		"api.owasp.org":   {"A 172.67.10.39"},
+		"www.example.com": {},
+	}, records(res))
+
+	// The names are attributed to the subfinder sources and tagged with the import of subfinder
+	for _, req := range res.Names {
+		require.Equal(t, "import:subfinder", req.Tag)
+	}
+	require.Equal(t, "alienvault", res.Names[0].Source)
+	require.Equal(t, "crtsh", res.Names[1].Source)
+	require.Equal(t, "import:subfinder", res.Addrs[0].Tag)
+	require.Equal(t, FormatSubfinder, res.Addrs[0].Source)
+}
+
+func TestNmap(t *testing.T) {
+	res := readTestFile(t, "nmap.xml", FormatNmap)
+
+	require.Equal(t, map[string][]string{
+		"owasp.org":                 {"A 104.22.27.77"},
+		"edge.owasp.org":            {},
+		"77.27.22.104.in-addr.arpa": {"PTR edge.owasp.org"},
+	}, records(res))
+	// The hosts that are down and the MAC addresses are left out
+	require.Equal(t, []string{"104.22.27.77", "198.51.100.20"}, addrs(res))
+}
+
+func TestZone(t *testing.T) {
+	res := readTestFile(t, "owasp.zone", FormatZone)
+
+	require.Equal(t, map[string][]string{
+		"owasp.org":           {"NS ns1.example.net", "A 104.22.27.77", "MX mx.example.com"},
+		"ns1.example.net":     {},
+		"mx.example.com":      {},
+		"www.owasp.org":       {"CNAME owasp.org"},
+		"_sip._tcp.owasp.org": {"SRV sip.owasp.org"},
+		"sip.owasp.org":       {"AAAA 2606:4700::6816:1b4d"},
+	}, records(res))
+
+	_, err := Read(strings.NewReader("owasp.org. IN A not-an-address\n"), FormatZone)
+	require.Error(t, err)
+}
+
+func TestFDNS(t *testing.T) {
+	res := readTestFile(t, "fdns.json.gz", FormatFDNS)
+
+	require.Equal(t, map[string][]string{
+		"www.owasp.org":   {"CNAME owasp.org"},
+		"owasp.org":       {"A 104.22.27.77"},
+		"www.example.com": {"A 93.184.216.34"},
+	}, records(res))
+}
+
+func TestScope(t *testing.T) {
+	cfg := config.NewConfig()
+	cfg.AddDomain("owasp.org")
+	cfg.Scope.CIDRs = append(cfg.Scope.CIDRs, mustCIDR(t, "198.51.100.0/24"))
+
+	res := readTestFile(t, "nmap.xml", FormatNmap).Scope(cfg)
+
+	var names []string
+	for _, req := range res.Names {
+		names = append(names, req.Name+" "+req.Domain)
+	}
+	require.Equal(t, []string{
+		"77.27.22.104.in-addr.arpa 104.in-addr.arpa",
+		"edge.owasp.org owasp.org",
+		"owasp.org owasp.org",
+	}, names)
+	require.Equal(t, []*requests.AddrRequest{
+		{Address: "104.22.27.77", InScope: true, Domain: "owasp.org", Tag: "import:nmap", Source: FormatNmap},
+		{Address: "198.51.100.20", InScope: true, Tag: "import:nmap", Source: FormatNmap},
+	}, res.Addrs)
+
+	fdns := readTestFile(t, "fdns.json", FormatFDNS).Scope(cfg)
+	require.Len(t, fdns.Names, 2)
+	for _, req := range fdns.Names {
+		require.Equal(t, "owasp.org", req.Domain)
+	}
+}
+
+func TestStore(t *testing.T) {
+	g := netmap.NewGraph("memory", "", "")
+	require.NotNil(t, g)
+
+	sources, err := provenance.NewStore("memory", "")
+	require.NoError(t, err)
+	defer sources.Close()
+
+	ctx := context.Background()
+	require.NoError(t, readTestFile(t, "owasp.zone", FormatZone).Store(ctx, g, sources))
+
+	assets, err := g.DB.FindByScope([]oam.Asset{domain.FQDN{Name: "owasp.org"}}, time.Time{})
+	require.NoError(t, err)
+
+	var names []string
+	for _, a := range assets {
+		if fqdn, ok := a.Asset.(domain.FQDN); ok {
+			names = append(names, fqdn.Name)
+		}
+	}
+	require.Subset(t, names, []string{"owasp.org", "www.owasp.org", "sip.owasp.org"})
+	require.True(t, g.IsCNAMENode(ctx, "www.owasp.org", time.Time{}))
+
+	attrs, err := sources.Sources("sip.owasp.org")
+	require.NoError(t, err)
+	require.Len(t, attrs, 1)
+	require.Equal(t, FormatZone, attrs[0].Source)
+	require.Equal(t, "import:zone", attrs[0].Tag)
+}
+
+func mustCIDR(t *testing.T, s string) *net.IPNet {
+	_, cidr, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return cidr
+}
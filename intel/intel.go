@@ -18,6 +18,7 @@ import (
 	"github.com/owasp-amass/amass/v4/datasrcs"
 	amassnet "github.com/owasp-amass/amass/v4/net"
 	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resolvers"
 	"github.com/owasp-amass/amass/v4/systems"
 	"github.com/owasp-amass/config/config"
 	"github.com/owasp-amass/resolve"
@@ -149,10 +150,11 @@ func (c *Collection) makeDNSTaskFunc() pipeline.TaskFunc {
 			ans := resolve.ExtractAnswers(resp)
 
 			if len(ans) > 0 {
-				d := strings.TrimSpace(resolve.FirstProperSubdomain(c.ctx, c.Sys.TrustedResolvers(), ans[0].Data))
+				d := strings.TrimSpace(resolvers.FirstProperSubdomain(c.ctx, c.Sys.TrustedResolvers(), ans[0].Data))
 
 				if d != "" {
-					go pipeline.SendData(ctx, "filter", &requests.Output{
+					// The output is queued before the task returns, so the filter stage receives it before closing
+					pipeline.SendData(ctx, "filter", &requests.Output{
 						Name:      d,
 						Domain:    d,
 						Addresses: []requests.AddressInfo{addrinfo},
@@ -0,0 +1,48 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package intel
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/owasp-amass/amass/v4/amasstest"
+	"github.com/owasp-amass/amass/v4/systems"
+	"github.com/owasp-amass/config/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostedDomains(t *testing.T) {
+	srv, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	defer srv.Close()
+
+	require.NoError(t, srv.LoadZoneString("0.0.127.in-addr.arpa", "1 IN PTR www.utica.edu."))
+	require.NoError(t, srv.LoadZoneString("utica.edu", `
+@	IN	NS	ns1.utica.edu.
+ns1	IN	A	127.0.0.53
+www	IN	A	127.0.0.1
+`))
+
+	cfg := config.NewConfig()
+	cfg.Scope.Addresses = []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("127.0.0.2")}
+
+	sys := systems.NewSimpleSystem(cfg, srv.Pool(), srv.Pool())
+	defer func() { _ = sys.Shutdown() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	c := NewCollection(cfg, sys)
+	go func() { _ = c.HostedDomains(ctx) }()
+
+	var found []string
+	for out := range c.Output {
+		found = append(found, out.Domain)
+	}
+	require.Equal(t, []string{"utica.edu"}, found)
+}
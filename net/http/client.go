@@ -0,0 +1,182 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"golang.org/x/net/proxy"
+)
+
+// ClientSettings configures the HTTP client built for a single data source.
+type ClientSettings struct {
+	// Proxy is the URL of an HTTP, HTTPS or SOCKS5 proxy, and the environment is used when empty
+	Proxy string `yaml:"proxy,omitempty"`
+	// TLSVerify enables the verification of server certificates
+	TLSVerify bool `yaml:"tls_verify,omitempty"`
+	// CABundle is a PEM file of the authorities trusted in place of the system roots, and enables verification
+	CABundle string `yaml:"ca_bundle,omitempty"`
+	// ClientCert and ClientKey are the PEM files of the certificate presented to the servers
+	ClientCert string `yaml:"client_cert,omitempty"`
+	ClientKey  string `yaml:"client_key,omitempty"`
+	// UserAgent replaces the default user agent sent with the requests
+	UserAgent string `yaml:"user_agent,omitempty"`
+}
+
+// Client sends the HTTP requests and crawls using its own transport, cookie jar and user agent.
+type Client struct {
+	client    *http.Client
+	userAgent string
+}
+
+// NewClient returns a Client built from the settings, which connects to the servers using
+// the provided Dialer. The cookies of the Client are isolated from every other Client.
+func NewClient(settings *ClientSettings, d *amassnet.Dialer) (*Client, error) {
+	if settings == nil {
+		settings = new(ClientSettings)
+	}
+
+	tlsConfig, err := settings.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	tr := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           d.DialContext,
+		MaxIdleConns:          200,
+		MaxConnsPerHost:       50,
+		IdleConnTimeout:       10 * time.Second,
+		TLSHandshakeTimeout:   handshakeTimeout,
+		ExpectContinueTimeout: 5 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+	if err := settings.setProxy(tr, d); err != nil {
+		return nil, err
+	}
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the cookie jar: %v", err)
+	}
+
+	ua := settings.UserAgent
+	if ua == "" {
+		ua = DefaultUserAgent()
+	}
+	return &Client{
+		client: &http.Client{
+			Timeout:   httpTimeout,
+			Transport: tr,
+			Jar:       jar,
+		},
+		userAgent: ua,
+	}, nil
+}
+
+func (s *ClientSettings) tlsConfig() (*tls.Config, error) {
+	c := &tls.Config{InsecureSkipVerify: !s.TLSVerify && s.CABundle == ""}
+
+	if s.CABundle != "" {
+		data, err := os.ReadFile(s.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the CA bundle %s: %v", s.CABundle, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("failed to find certificates in the CA bundle %s", s.CABundle)
+		}
+		c.RootCAs = pool
+	}
+
+	if s.ClientCert != "" || s.ClientKey != "" {
+		if s.ClientCert == "" {
+			return nil, errors.New("failed to provide the client certificate for the client key")
+		}
+		// The key can be provided in the same PEM file as the certificate
+		key := s.ClientKey
+		if key == "" {
+			key = s.ClientCert
+		}
+
+		cert, err := tls.LoadX509KeyPair(s.ClientCert, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the client certificate %s: %v", s.ClientCert, err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
+
+func (s *ClientSettings) setProxy(tr *http.Transport, dialer *amassnet.Dialer) error {
+	if s.Proxy == "" {
+		return nil
+	}
+
+	u, err := url.Parse(s.Proxy)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("failed to parse the proxy URL %s", s.Proxy)
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		tr.Proxy = http.ProxyURL(u)
+	case "socks5", "socks5h":
+		d, err := proxy.FromURL(u, dialerFunc(dialer.DialContext))
+		if err != nil {
+			return fmt.Errorf("failed to create the SOCKS5 dialer for %s: %v", u.Host, err)
+		}
+
+		cd, ok := d.(proxy.ContextDialer)
+		if !ok {
+			return fmt.Errorf("the SOCKS5 dialer for %s does not support contexts", u.Host)
+		}
+		tr.Proxy = nil
+		tr.DialContext = cd.DialContext
+	default:
+		return fmt.Errorf("the proxy scheme %s is not supported", u.Scheme)
+	}
+	return nil
+}
+
+// dialerFunc allows the Amass dialer to carry the connections to a SOCKS5 proxy.
+type dialerFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (f dialerFunc) Dial(network, addr string) (net.Conn, error) {
+	return f(context.Background(), network, addr)
+}
+
+func (f dialerFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return f(ctx, network, addr)
+}
+
+// UserAgent returns the user agent sent with the requests of the Client.
+func (c *Client) UserAgent() string {
+	return c.userAgent
+}
+
+// wrap returns a copy of the Client that sends the requests through the RoundTripper returned by fn.
+func (c *Client) wrap(fn func(http.RoundTripper) http.RoundTripper) *Client {
+	hc := *c.client
+	hc.Transport = fn(hc.Transport)
+
+	return &Client{
+		client:    &hc,
+		userAgent: c.userAgent,
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/owasp-amass/amass/v4/amasstest"
+	"github.com/stretchr/testify/require"
+)
+
+func writeCertificate(t *testing.T, dir, name string, cert tls.Certificate) (string, string) {
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+
+	certPath := filepath.Join(dir, name+".crt")
+	keyPath := filepath.Join(dir, name+".key")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key}), 0600))
+	return certPath, keyPath
+}
+
+func TestClientTLS(t *testing.T) {
+	dir := t.TempDir()
+	srvCert, err := amasstest.NewCertificate("127.0.0.1")
+	require.NoError(t, err)
+	cliCert, err := amasstest.NewCertificate("amass-client")
+	require.NoError(t, err)
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var cn string
+		if len(r.TLS.PeerCertificates) > 0 {
+			cn = r.TLS.PeerCertificates[0].Subject.CommonName
+		}
+		_, _ = w.Write([]byte(cn))
+	}))
+	ts.TLS = &tls.Config{
+		Certificates: []tls.Certificate{srvCert},
+		ClientAuth:   tls.RequestClientCert,
+	}
+	ts.StartTLS()
+	defer ts.Close()
+
+	caPath, _ := writeCertificate(t, dir, "ca", srvCert)
+	certPath, keyPath := writeCertificate(t, dir, "client", cliCert)
+	ctx := context.Background()
+
+	c, err := NewClient(nil, nil)
+	require.NoError(t, err)
+	resp, err := c.RequestWebPage(ctx, &Request{URL: ts.URL})
+	require.NoError(t, err, "certificates are not verified by default")
+	require.Empty(t, resp.Body)
+
+	c, err = NewClient(&ClientSettings{TLSVerify: true}, nil)
+	require.NoError(t, err)
+	_, err = c.RequestWebPage(ctx, &Request{URL: ts.URL})
+	require.Error(t, err, "the self-signed certificate must be rejected")
+
+	c, err = NewClient(&ClientSettings{CABundle: caPath, ClientCert: certPath, ClientKey: keyPath}, nil)
+	require.NoError(t, err)
+	resp, err = c.RequestWebPage(ctx, &Request{URL: ts.URL})
+	require.NoError(t, err)
+	require.Equal(t, "amass-client", resp.Body)
+
+	_, err = NewClient(&ClientSettings{CABundle: filepath.Join(dir, "missing.pem")}, nil)
+	require.Error(t, err)
+	_, err = NewClient(&ClientSettings{ClientKey: keyPath}, nil)
+	require.Error(t, err)
+}
+
+func TestClientIdentity(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := r.Cookie("session"); err != nil {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "amass"})
+			_, _ = w.Write([]byte("new " + r.UserAgent()))
+			return
+		}
+		_, _ = w.Write([]byte("known " + r.UserAgent()))
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	first, err := NewClient(&ClientSettings{UserAgent: "amass-test"}, nil)
+	require.NoError(t, err)
+	second, err := NewClient(nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, DefaultUserAgent(), second.UserAgent())
+
+	resp, err := first.RequestWebPage(ctx, &Request{URL: ts.URL})
+	require.NoError(t, err)
+	require.Equal(t, "new amass-test", resp.Body)
+	resp, err = first.RequestWebPage(ctx, &Request{URL: ts.URL})
+	require.NoError(t, err)
+	require.Equal(t, "known amass-test", resp.Body)
+	// The cookies of one client must not be sent by the others
+	resp, err = second.RequestWebPage(ctx, &Request{URL: ts.URL})
+	require.NoError(t, err)
+	require.Equal(t, "new "+DefaultUserAgent(), resp.Body)
+}
+
+func TestClientProxy(t *testing.T) {
+	var target string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target = r.URL.String()
+		_, _ = w.Write([]byte("proxied"))
+	}))
+	defer proxy.Close()
+
+	c, err := NewClient(&ClientSettings{Proxy: proxy.URL}, nil)
+	require.NoError(t, err)
+	resp, err := c.RequestWebPage(context.Background(), &Request{URL: "http://www.owasp.org/index.html"})
+	require.NoError(t, err)
+	require.Equal(t, "proxied", resp.Body)
+	require.Equal(t, "http://www.owasp.org/index.html", target)
+
+	_, err = NewClient(&ClientSettings{Proxy: "socks5://127.0.0.1:1080"}, nil)
+	require.NoError(t, err)
+	_, err = NewClient(&ClientSettings{Proxy: "ftp://127.0.0.1:21"}, nil)
+	require.Error(t, err)
+	_, err = NewClient(&ClientSettings{Proxy: "not a proxy"}, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,338 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// FixtureMode selects whether HTTP exchanges are written to or served from a fixtures directory.
+type FixtureMode int
+
+// The modes of operation supported by Fixtures.
+const (
+	// FixturesRecord sends the requests and writes the exchanges to the fixtures directory.
+	FixturesRecord FixtureMode = iota + 1
+	// FixturesReplay serves the responses from the fixtures directory without network access.
+	FixturesReplay
+)
+
+// Redacted replaces the credentials removed from recorded HTTP exchanges.
+const Redacted = "REDACTED"
+
+// URL parameters and headers with names matching the expression carry credentials. The whole name
+// must match, so parameters such as author or design are left alone.
+var sensitiveRE = regexp.MustCompile(`(?i)^(x-)?(api[-_]?key|key|((access|api|auth|id|refresh)[-_]?)?token|(client[-_]?)?secret|pass(word|wd)?|(proxy-)?auth(orization)?|sig(nature)?|session([-_]?id)?|(set-)?cookie|credentials?)$`)
+
+// Fixtures records HTTP exchanges to a directory, or replays them from the directory, so data
+// sources can be debugged and tested without access to the services. Credentials are redacted
+// before the exchanges are written, and requests are matched on their redacted form.
+type Fixtures struct {
+	dir     string
+	mode    FixtureMode
+	secrets []string
+	client  *Client
+}
+
+type fixtureFile struct {
+	Recorded time.Time       `json:"recorded"`
+	Request  fixtureRequest  `json:"request"`
+	Response fixtureResponse `json:"response"`
+}
+
+type fixtureRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+type fixtureResponse struct {
+	Status     string      `json:"status"`
+	StatusCode int         `json:"status_code"`
+	Proto      string      `json:"proto"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`
+	BodyBase64 string      `json:"body_base64,omitempty"`
+}
+
+// NewFixtures returns Fixtures operating on the directory in the provided mode. The secrets,
+// such as API keys and passwords, are removed from the exchanges in addition to the URL
+// parameters and headers that carry credentials.
+func NewFixtures(dir string, mode FixtureMode, secrets ...string) (*Fixtures, error) {
+	switch mode {
+	case FixturesRecord:
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create the fixtures directory %s: %v", dir, err)
+		}
+	case FixturesReplay:
+		if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+			return nil, fmt.Errorf("failed to find the fixtures directory %s", dir)
+		}
+	default:
+		return nil, errors.New("failed to provide a valid fixtures mode")
+	}
+
+	f := &Fixtures{
+		dir:  dir,
+		mode: mode,
+	}
+	for _, s := range secrets {
+		if s = strings.TrimSpace(s); s != "" {
+			f.secrets = append(f.secrets, s)
+		}
+	}
+	// Remove the longest secrets first, since they could contain the others
+	sort.Slice(f.secrets, func(i, j int) bool {
+		return len(f.secrets[i]) > len(f.secrets[j])
+	})
+
+	// The requests sent by the Fixtures themselves make direct connections
+	c, err := NewClient(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	f.client = f.Wrap(c)
+	return f, nil
+}
+
+// Wrap returns a copy of the Client that records or replays the exchanges of its requests.
+func (f *Fixtures) Wrap(c *Client) *Client {
+	return c.wrap(f.Transport)
+}
+
+// Dir returns the fixtures directory.
+func (f *Fixtures) Dir() string {
+	return f.dir
+}
+
+// Mode returns the FixtureMode selected for the Fixtures.
+func (f *Fixtures) Mode() FixtureMode {
+	return f.mode
+}
+
+// RequestWebPage behaves as the Client method, while recording or replaying the exchanges.
+func (f *Fixtures) RequestWebPage(ctx context.Context, r *Request) (*Response, error) {
+	return f.client.RequestWebPage(ctx, r)
+}
+
+// Crawl behaves as the Client method, while recording or replaying the exchanges.
+func (f *Fixtures) Crawl(ctx context.Context, u string, scope []string, max int, callback func(*Request, *Response)) error {
+	return f.client.Crawl(ctx, u, scope, max, callback)
+}
+
+// Transport returns a RoundTripper that records the exchanges sent through next, or replays
+// the exchanges without using next at all.
+func (f *Fixtures) Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &fixtureTransport{
+		fixtures: f,
+		next:     next,
+	}
+}
+
+type fixtureTransport struct {
+	fixtures *Fixtures
+	next     http.RoundTripper
+}
+
+// RoundTrip implements the net/http RoundTripper interface.
+func (t *fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		b, err := io.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the request body: %v", err)
+		}
+
+		body = b
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	f := t.fixtures
+	u := f.redactURL(req.URL)
+	b := f.redact(string(body))
+	path := f.path(req.Method, req.URL.Hostname(), u, b)
+	if f.mode == FixturesReplay {
+		return f.replay(req, path, u)
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the response body: %v", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	if err := f.save(path, &fixtureRequest{
+		Method: req.Method,
+		URL:    u,
+		Header: f.redactHeader(req.Header),
+		Body:   b,
+	}, resp, data); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (f *Fixtures) path(method, host, u, body string) string {
+	sum := sha256.Sum256([]byte(method + " " + u + "\n" + body))
+	host = strings.NewReplacer(":", "_", "/", "_").Replace(strings.ToLower(host))
+
+	return filepath.Join(f.dir, fmt.Sprintf("%s_%s_%x.json", host, strings.ToLower(method), sum[:8]))
+}
+
+func (f *Fixtures) save(path string, req *fixtureRequest, resp *http.Response, body []byte) error {
+	ff := &fixtureFile{
+		Recorded: time.Now().UTC(),
+		Request:  *req,
+		Response: fixtureResponse{
+			Status:     resp.Status,
+			StatusCode: resp.StatusCode,
+			Proto:      resp.Proto,
+			Header:     f.redactHeader(resp.Header),
+		},
+	}
+	if utf8.Valid(body) {
+		ff.Response.Body = f.redact(string(body))
+	} else {
+		ff.Response.BodyBase64 = base64.StdEncoding.EncodeToString(f.redactBytes(body))
+	}
+
+	data, err := json.MarshalIndent(ff, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the HTTP fixture: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write the HTTP fixture %s: %v", path, err)
+	}
+	return nil
+}
+
+func (f *Fixtures) replay(req *http.Request, path, u string) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("no HTTP fixture was recorded for %s %s", req.Method, u)
+	}
+
+	var ff fixtureFile
+	if err := json.Unmarshal(data, &ff); err != nil {
+		return nil, fmt.Errorf("failed to decode the HTTP fixture %s: %v", path, err)
+	}
+
+	body := []byte(ff.Response.Body)
+	if ff.Response.BodyBase64 != "" {
+		if body, err = base64.StdEncoding.DecodeString(ff.Response.BodyBase64); err != nil {
+			return nil, fmt.Errorf("failed to decode the HTTP fixture %s body: %v", path, err)
+		}
+	}
+
+	proto := ff.Response.Proto
+	if proto == "" {
+		proto = "HTTP/1.1"
+	}
+	major, minor, _ := http.ParseHTTPVersion(proto)
+
+	hdr := ff.Response.Header
+	if hdr == nil {
+		hdr = make(http.Header)
+	}
+	return &http.Response{
+		Status:        ff.Response.Status,
+		StatusCode:    ff.Response.StatusCode,
+		Proto:         proto,
+		ProtoMajor:    major,
+		ProtoMinor:    minor,
+		Header:        hdr,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func (f *Fixtures) redact(s string) string {
+	for _, secret := range f.secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+	}
+	return s
+}
+
+func (f *Fixtures) redactBytes(b []byte) []byte {
+	for _, secret := range f.secrets {
+		b = bytes.ReplaceAll(b, []byte(secret), []byte(Redacted))
+	}
+	return b
+}
+
+func (f *Fixtures) redactURL(orig *url.URL) string {
+	u := *orig
+
+	if u.User != nil {
+		u.User = url.UserPassword(Redacted, Redacted)
+	}
+	if u.RawQuery != "" {
+		var changed bool
+
+		q := u.Query()
+		for k, vals := range q {
+			if !sensitiveRE.MatchString(k) {
+				continue
+			}
+			for i := range vals {
+				vals[i] = Redacted
+			}
+			changed = true
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+	return f.redact(u.String())
+}
+
+func (f *Fixtures) redactHeader(hdr http.Header) http.Header {
+	if len(hdr) == 0 {
+		return nil
+	}
+
+	h := make(http.Header, len(hdr))
+	for k, vals := range hdr {
+		for _, v := range vals {
+			if sensitiveRE.MatchString(k) {
+				v = Redacted
+			}
+			h[k] = append(h[k], f.redact(v))
+		}
+	}
+	return h
+}
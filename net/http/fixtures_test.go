@@ -0,0 +1,166 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package http
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/caffix/stringset"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixturesRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "caffix" || pass != "hunter2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("apikey") != "s3cr3t" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123"})
+		fmt.Fprintf(w, "www.owasp.org\nkey=%s\n", r.URL.Query().Get("apikey"))
+	}))
+
+	rec, err := NewFixtures(dir, FixturesRecord, "s3cr3t", "hunter2")
+	require.NoError(t, err)
+
+	req := &Request{
+		URL:  ts.URL + "/search?q=owasp.org&apikey=s3cr3t",
+		Auth: &BasicAuth{Username: "caffix", Password: "hunter2"},
+	}
+	resp, err := rec.RequestWebPage(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Equal(t, "www.owasp.org\nkey=s3cr3t\n", resp.Body)
+	ts.Close()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	for _, secret := range []string{"s3cr3t", "hunter2", "abc123"} {
+		require.NotContains(t, string(data), secret)
+	}
+	require.Contains(t, string(data), "apikey="+Redacted)
+
+	play, err := NewFixtures(dir, FixturesReplay)
+	require.NoError(t, err)
+	// A different key still matches the redacted request
+	req.URL = strings.Replace(req.URL, "s3cr3t", "0th3r", 1)
+	resp, err = play.RequestWebPage(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Equal(t, "www.owasp.org\nkey="+Redacted+"\n", resp.Body)
+
+	_, err = play.RequestWebPage(context.Background(), &Request{URL: ts.URL + "/search?q=example.com"})
+	require.Error(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = play.RequestWebPage(ctx, req)
+	require.Error(t, err)
+}
+
+func TestFixturesRedaction(t *testing.T) {
+	dir := t.TempDir()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(append([]byte{0xff, 0xfe, 0x00}, []byte("s3cr3t")...))
+	}))
+	defer ts.Close()
+
+	rec, err := NewFixtures(dir, FixturesRecord, "s3cr3t")
+	require.NoError(t, err)
+
+	req := &Request{
+		URL:    ts.URL + "/search?author=caffix&design=dark&access_token=t0k3n&sig=abc",
+		Header: map[string]string{"X-Api-Key": "h34d3r", "X-Author": "caffix"},
+	}
+	_, err = rec.RequestWebPage(context.Background(), req)
+	require.NoError(t, err)
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	for _, secret := range []string{"t0k3n", "h34d3r", "abc"} {
+		require.NotContains(t, string(data), secret)
+	}
+
+	var ff fixtureFile
+	require.NoError(t, json.Unmarshal(data, &ff))
+	body, err := base64.StdEncoding.DecodeString(ff.Response.BodyBase64)
+	require.NoError(t, err)
+	require.NotContains(t, string(body), "s3cr3t")
+	// Only the names of credentials are redacted
+	require.Contains(t, string(data), "author=caffix")
+	require.Contains(t, string(data), "design=dark")
+	require.Contains(t, string(data), `"caffix"`)
+
+	play, err := NewFixtures(dir, FixturesReplay)
+	require.NoError(t, err)
+	resp, err := play.RequestWebPage(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, "\xff\xfe\x00"+Redacted, resp.Body)
+}
+
+func TestFixturesCrawl(t *testing.T) {
+	dir := t.TempDir()
+	ts := httptest.NewServer(http.FileServer(http.Dir("./static")))
+
+	crawlNames := func(f *Fixtures) []string {
+		names := stringset.New()
+		defer names.Close()
+
+		err := f.Crawl(context.Background(), ts.URL, []string{"127.0.0.1"}, 0, func(req *Request, resp *Response) {
+			if u, err := url.Parse(req.URL); err == nil {
+				names.Insert(u.Path)
+			}
+			names.InsertMany(subRE.FindAllString(resp.Body, -1)...)
+		})
+		require.NoError(t, err)
+		return names.Slice()
+	}
+
+	rec, err := NewFixtures(dir, FixturesRecord)
+	require.NoError(t, err)
+	recorded := crawlNames(rec)
+	require.NotEmpty(t, recorded)
+	ts.Close()
+
+	play, err := NewFixtures(dir, FixturesReplay)
+	require.NoError(t, err)
+	require.ElementsMatch(t, recorded, crawlNames(play))
+}
+
+func TestNewFixtures(t *testing.T) {
+	_, err := NewFixtures(filepath.Join(t.TempDir(), "missing"), FixturesReplay)
+	require.Error(t, err)
+
+	_, err = NewFixtures(t.TempDir(), 0)
+	require.Error(t, err)
+
+	dir := filepath.Join(t.TempDir(), "created")
+	_, err = NewFixtures(dir, FixturesRecord)
+	require.NoError(t, err)
+	require.DirExists(t, dir)
+}
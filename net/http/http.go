@@ -13,7 +13,6 @@ import (
 	"io"
 	"net"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"regexp"
 	"runtime"
@@ -41,18 +40,16 @@ const (
 	darwinUserAgent  = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/110.0.0.0 Safari/537.36"
 	httpTimeout      = 10 * time.Second
 	handshakeTimeout = 5 * time.Second
+	// DefaultMaxBodySize is the largest response body read when the request does not provide a limit.
+	DefaultMaxBodySize int64 = 50 * 1024 * 1024
 )
 
 var (
-	// UserAgent is the default user agent used by Amass during HTTP requests.
-	UserAgent   string
 	subRE       = dns.AnySubdomainRegex()
 	nameStripRE = regexp.MustCompile(`^(u[0-9a-f]{4}|20|22|25|27|2b|2f|3d|3a|40)`)
+	methodRE    = regexp.MustCompile(`^[A-Z]+$`)
 )
 
-// DefaultClient is the same HTTP client used by the package methods.
-var DefaultClient *http.Client
-
 // Header represents the HTTP headers for requests and responses.
 type Header map[string]string
 
@@ -63,6 +60,12 @@ type Request struct {
 	Header Header
 	Body   string
 	Auth   *BasicAuth
+	// NoRedirects causes the redirect response to be returned instead of being followed
+	NoRedirects bool
+	// MaxBodySize limits the bytes of the response body read, and DefaultMaxBodySize is used when zero
+	MaxBodySize int64
+	// Timeout replaces the client timeout for the request when greater than zero
+	Timeout time.Duration
 }
 
 // Response represents the HTTP response in the Amass preferred format.
@@ -73,9 +76,15 @@ type Response struct {
 	ProtoMajor int
 	ProtoMinor int
 	Header     Header
-	Body       string
-	Length     int64
-	TLS        *tls.ConnectionState
+	// RawHeader keeps each of the values provided for the response headers
+	RawHeader map[string][]string
+	Body      string
+	// Truncated is true when the body exceeded the maximum size and was cut short
+	Truncated bool
+	Length    int64
+	// URL is the location of the final response, after any redirects were followed
+	URL string
+	TLS *tls.ConnectionState
 }
 
 // BasicAuth contains the data used for HTTP basic authentication.
@@ -84,31 +93,16 @@ type BasicAuth struct {
 	Password string
 }
 
-func init() {
-	jar, _ := cookiejar.New(nil)
-	DefaultClient = &http.Client{
-		Timeout: httpTimeout,
-		Transport: &http.Transport{
-			Proxy:                 http.ProxyFromEnvironment,
-			DialContext:           amassnet.DialContext,
-			MaxIdleConns:          200,
-			MaxConnsPerHost:       50,
-			IdleConnTimeout:       10 * time.Second,
-			TLSHandshakeTimeout:   handshakeTimeout,
-			ExpectContinueTimeout: 5 * time.Second,
-			TLSClientConfig:       &tls.Config{InsecureSkipVerify: true},
-		},
-		Jar: jar,
-	}
-
+// DefaultUserAgent returns the user agent sent with the requests when none is provided,
+// which matches a popular browser on the operating system.
+func DefaultUserAgent() string {
 	switch runtime.GOOS {
 	case "windows":
-		UserAgent = windowsUserAgent
+		return windowsUserAgent
 	case "darwin":
-		UserAgent = darwinUserAgent
-	default:
-		UserAgent = defaultUserAgent
+		return darwinUserAgent
 	}
+	return defaultUserAgent
 }
 
 // HdrToAmassHeader converts a net/http Header to an Amass Header.
@@ -151,14 +145,34 @@ func ReqToAmassRequest(req *http.Request) *Request {
 
 // RespToAmassResponse converts a net/http Response to an Amass Response.
 func RespToAmassResponse(resp *http.Response) *Response {
+	return respToAmassResponse(resp, -1)
+}
+
+// respToAmassResponse converts the response while reading no more than max bytes of the body.
+// A negative max reads the entire body.
+func respToAmassResponse(resp *http.Response, max int64) *Response {
 	var body string
+	var truncated bool
 	if resp.Body != nil {
-		if b, err := io.ReadAll(resp.Body); err == nil {
+		var r io.Reader = resp.Body
+		if max >= 0 {
+			r = io.LimitReader(resp.Body, max+1)
+		}
+		if b, err := io.ReadAll(r); err == nil {
+			if max >= 0 && int64(len(b)) > max {
+				b = b[:max]
+				truncated = true
+			}
 			body = string(b)
 		}
 		_ = resp.Body.Close()
 	}
 
+	var u string
+	if resp.Request != nil && resp.Request.URL != nil {
+		u = resp.Request.URL.String()
+	}
+
 	return &Response{
 		Status:     resp.Status,
 		StatusCode: resp.StatusCode,
@@ -166,8 +180,11 @@ func RespToAmassResponse(resp *http.Response) *Response {
 		ProtoMajor: resp.ProtoMajor,
 		ProtoMinor: resp.ProtoMinor,
 		Header:     HdrToAmassHeader(resp.Header),
+		RawHeader:  resp.Header.Clone(),
 		Body:       body,
+		Truncated:  truncated,
 		Length:     resp.ContentLength,
+		URL:        u,
 		TLS:        resp.TLS,
 	}
 }
@@ -175,17 +192,17 @@ func RespToAmassResponse(resp *http.Response) *Response {
 // CopyCookies copies cookies from one domain to another. Some of our data
 // sources rely on shared auth tokens and this avoids sending extra requests
 // to have the site reissue cookies for the other domains.
-func CopyCookies(src string, dest string) {
+func (c *Client) CopyCookies(src string, dest string) {
 	srcURL, _ := url.Parse(src)
 	destURL, _ := url.Parse(dest)
-	DefaultClient.Jar.SetCookies(destURL, DefaultClient.Jar.Cookies(srcURL))
+	c.client.Jar.SetCookies(destURL, c.client.Jar.Cookies(srcURL))
 }
 
-// CheckCookie checks if a cookie exists in the cookie jar for a given host
-func CheckCookie(urlString string, cookieName string) bool {
+// CheckCookie checks if a cookie exists in the cookie jar of the Client for a given host.
+func (c *Client) CheckCookie(urlString string, cookieName string) bool {
 	cookieURL, _ := url.Parse(urlString)
 	found := false
-	for _, cookie := range DefaultClient.Jar.Cookies(cookieURL) {
+	for _, cookie := range c.client.Jar.Cookies(cookieURL) {
 		if cookie.Name == cookieName {
 			found = true
 			break
@@ -194,19 +211,50 @@ func CheckCookie(urlString string, cookieName string) bool {
 	return found
 }
 
-// RequestWebPage returns the response headers, body, and status code for the provided URL when successful.
+// RequestWebPage sends the request using a new Client with the default settings, which makes
+// direct connections and does not keep the cookies between the requests.
 func RequestWebPage(ctx context.Context, r *Request) (*Response, error) {
+	c, err := NewClient(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.RequestWebPage(ctx, r)
+}
+
+// RequestWebPage returns the response headers, body, and status code for the provided URL when successful.
+func (c *Client) RequestWebPage(ctx context.Context, r *Request) (*Response, error) {
 	if r == nil {
 		return nil, errors.New("failed to provide a valid Amass HTTP request")
 	}
 
 	if r.Method == "" {
 		r.Method = "GET"
-	} else if r.Method != "GET" && r.Method != "POST" {
+	}
+	r.Method = strings.ToUpper(r.Method)
+	if !methodRE.MatchString(r.Method) {
 		return nil, errors.New("failed to provide a valid HTTP method")
 	}
 
-	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, strings.NewReader(r.Body))
+	hc := c.client
+	if r.NoRedirects || r.Timeout > 0 {
+		cc := *hc
+		if r.NoRedirects {
+			cc.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			}
+		}
+		if r.Timeout > 0 {
+			cc.Timeout = r.Timeout
+		}
+		hc = &cc
+	}
+
+	var body io.Reader
+	if r.Body != "" {
+		body = strings.NewReader(r.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, r.Method, r.URL, body)
 	if err != nil {
 		return nil, err
 	}
@@ -216,22 +264,27 @@ func RequestWebPage(ctx context.Context, r *Request) (*Response, error) {
 		req.SetBasicAuth(r.Auth.Username, r.Auth.Password)
 	}
 
-	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("User-Agent", c.userAgent)
 	req.Header.Set("Accept", Accept)
 	req.Header.Set("Accept-Language", AcceptLang)
 	for k, v := range r.Header {
 		req.Header.Set(k, v)
 	}
 
-	resp, err := DefaultClient.Do(req)
+	resp, err := hc.Do(req)
 	if err != nil {
 		return nil, err
 	}
-	return RespToAmassResponse(resp), nil
+
+	max := r.MaxBodySize
+	if max <= 0 {
+		max = DefaultMaxBodySize
+	}
+	return respToAmassResponse(resp, max), nil
 }
 
 // Crawl will spider the web page at the URL argument looking while staying within the scope provided.
-func Crawl(ctx context.Context, u string, scope []string, max int, callback func(*Request, *Response)) error {
+func (c *Client) Crawl(ctx context.Context, u string, scope []string, max int, callback func(*Request, *Response)) error {
 	select {
 	case <-ctx.Done():
 		return fmt.Errorf("the context expired")
@@ -251,7 +304,7 @@ func Crawl(ctx context.Context, u string, scope []string, max int, callback func
 	g := geziyor.NewGeziyor(&geziyor.Options{
 		StartURLs:             []string{u},
 		RobotsTxtDisabled:     true,
-		UserAgent:             UserAgent,
+		UserAgent:             c.userAgent,
 		LogDisabled:           true,
 		ConcurrentRequests:    5,
 		RequestDelay:          50 * time.Millisecond,
@@ -301,8 +354,10 @@ func Crawl(ctx context.Context, u string, scope []string, max int, callback func
 				ProtoMajor: r.ProtoMajor,
 				ProtoMinor: r.ProtoMinor,
 				Header:     HdrToAmassHeader(r.Header),
+				RawHeader:  r.Header.Clone(),
 				Body:       string(r.Body),
 				Length:     r.ContentLength,
+				URL:        r.Request.URL.String(),
 				TLS:        r.TLS,
 			})
 		},
@@ -312,7 +367,7 @@ func Crawl(ctx context.Context, u string, scope []string, max int, callback func
 		RetryTimes:     2,
 		RetryHTTPCodes: []int{408, 500, 502, 503, 504, 522, 524},
 	})
-	g.Client.Client = DefaultClient
+	g.Client.Client = c.client
 
 	g.Start()
 	return nil
@@ -335,11 +390,12 @@ func whichDomain(name string, scope []string) string {
 }
 
 // PullCertificateNames attempts to pull a cert from one or more ports on an IP.
-func PullCertificateNames(ctx context.Context, addr string, ports []int) []string {
+// The connections are made by the provided Dialer.
+func PullCertificateNames(ctx context.Context, d *amassnet.Dialer, addr string, ports []int) []string {
 	var names []string
 	// check hosts for certificates that contain subdomain names
 	for _, port := range ports {
-		if c, err := TLSConn(ctx, addr, port); err == nil {
+		if c, err := TLSConn(ctx, d, addr, port); err == nil {
 			// get the correct certificate in the chain
 			certChain := c.ConnectionState().PeerCertificates
 			// create the new requests from names found within the cert
@@ -356,13 +412,13 @@ func PullCertificateNames(ctx context.Context, addr string, ports []int) []strin
 	return names
 }
 
-// TLSConn attempts to make a TLS connection with the host on the given port.
-func TLSConn(ctx context.Context, host string, port int) (*tls.Conn, error) {
+// TLSConn attempts to make a TLS connection with the host on the given port using the Dialer.
+func TLSConn(ctx context.Context, d *amassnet.Dialer, host string, port int) (*tls.Conn, error) {
 	// set the maximum time allowed for making the connection
 	tCtx, cancel := context.WithTimeout(ctx, handshakeTimeout)
 	defer cancel()
 	// obtain the connection
-	conn, err := amassnet.DialContext(tCtx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	conn, err := d.DialContext(tCtx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
 	if err != nil {
 		return nil, err
 	}
@@ -19,25 +19,35 @@ import (
 
 	"github.com/caffix/stringset"
 	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/amasstest"
 	amassdns "github.com/owasp-amass/amass/v4/net/dns"
 	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
 )
 
+func newTestClient(t *testing.T) *Client {
+	c, err := NewClient(nil, nil)
+	require.NoError(t, err)
+	return c
+}
+
 func TestCopyCookies(t *testing.T) {
+	c := newTestClient(t)
 	u, _ := url.Parse("http://owasp.org")
-	DefaultClient.Jar.SetCookies(u, []*http.Cookie{{
+	c.client.Jar.SetCookies(u, []*http.Cookie{{
 		Name:  "Test",
 		Value: "Cookie",
 	}})
-	CopyCookies("http://owasp.org", "http://example.com")
+	c.CopyCookies("http://owasp.org", "http://example.com")
 
 	u2, _ := url.Parse("http://example.com")
-	if c := DefaultClient.Jar.Cookies(u2); len(c) == 0 || c[0].Value != "Cookie" {
+	if cookies := c.client.Jar.Cookies(u2); len(cookies) == 0 || cookies[0].Value != "Cookie" {
 		t.Errorf("Failed to copy the cookie")
 	}
 }
 
 func TestCheckCookie(t *testing.T) {
+	c := newTestClient(t)
 	type args struct {
 		urlString  string
 		cookieName string
@@ -57,7 +67,7 @@ func TestCheckCookie(t *testing.T) {
 				}
 
 				cookies := []*http.Cookie{{Name: "cookie1", Value: "sample cookie value"}}
-				DefaultClient.Jar.SetCookies(sampleURL, cookies)
+				c.client.Jar.SetCookies(sampleURL, cookies)
 
 			},
 			args: args{
@@ -80,7 +90,7 @@ func TestCheckCookie(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.init()
-			if got := CheckCookie(tt.args.urlString, tt.args.cookieName); got != tt.want {
+			if got := c.CheckCookie(tt.args.urlString, tt.args.cookieName); got != tt.want {
 				t.Errorf("CheckCookie() = %v, want %v", got, tt.want)
 			}
 		})
@@ -88,6 +98,7 @@ func TestCheckCookie(t *testing.T) {
 }
 
 func TestRequestWebPage(t *testing.T) {
+	c := newTestClient(t)
 	name := "caffix"
 	pass := "OWASP"
 	hkey := "OWASP-Leader"
@@ -118,7 +129,7 @@ func TestRequestWebPage(t *testing.T) {
 	}))
 	defer ts.Close()
 
-	resp, err := RequestWebPage(context.TODO(), &Request{
+	resp, err := c.RequestWebPage(context.TODO(), &Request{
 		URL:  ts.URL,
 		Auth: &BasicAuth{name, pass},
 	})
@@ -127,7 +138,7 @@ func TestRequestWebPage(t *testing.T) {
 	}
 
 	var headers = map[string]string{hkey: name}
-	resp, err = RequestWebPage(context.TODO(), &Request{
+	resp, err = c.RequestWebPage(context.TODO(), &Request{
 		URL:    ts.URL,
 		Method: "POST",
 		Header: headers,
@@ -141,13 +152,75 @@ func TestRequestWebPage(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	resp, err = RequestWebPage(ctx, &Request{URL: ts.URL})
+	resp, err = c.RequestWebPage(ctx, &Request{URL: ts.URL})
 	if err == nil || resp != nil {
 		t.Errorf("Failed to detect the expired context")
 	}
 }
 
+func TestRequestWebPageOptions(t *testing.T) {
+	c := newTestClient(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, "/final", http.StatusMovedPermanently)
+		case "/slow":
+			time.Sleep(500 * time.Millisecond)
+		case "/links":
+			w.Header().Add("Link", `<https://api.owasp.org/?page=2>; rel="next"`)
+			w.Header().Add("Link", `<https://api.owasp.org/?page=9>; rel="last"`)
+		default:
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "%s %s %s", r.Method, r.URL.Path, body)
+		}
+	}))
+	defer ts.Close()
+
+	for _, method := range []string{"put", "PATCH", "DELETE", "GET"} {
+		resp, err := c.RequestWebPage(context.Background(), &Request{
+			URL:    ts.URL + "/final",
+			Method: method,
+			Body:   `{"page":1}`,
+		})
+		require.NoError(t, err)
+		require.Equal(t, strings.ToUpper(method)+` /final {"page":1}`, resp.Body)
+	}
+
+	resp, err := c.RequestWebPage(context.Background(), &Request{URL: ts.URL + "/final", Method: "HEAD"})
+	require.NoError(t, err)
+	require.Equal(t, 200, resp.StatusCode)
+	require.Empty(t, resp.Body)
+
+	_, err = c.RequestWebPage(context.Background(), &Request{URL: ts.URL, Method: "GET /"})
+	require.Error(t, err)
+
+	resp, err = c.RequestWebPage(context.Background(), &Request{URL: ts.URL + "/redirect"})
+	require.NoError(t, err)
+	require.Equal(t, ts.URL+"/final", resp.URL)
+	require.Equal(t, "GET /final ", resp.Body)
+
+	resp, err = c.RequestWebPage(context.Background(), &Request{URL: ts.URL + "/redirect", NoRedirects: true})
+	require.NoError(t, err)
+	require.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	require.Equal(t, ts.URL+"/redirect", resp.URL)
+	require.Equal(t, "/final", resp.Header["Location"])
+
+	resp, err = c.RequestWebPage(context.Background(), &Request{URL: ts.URL + "/final", MaxBodySize: 5})
+	require.NoError(t, err)
+	require.True(t, resp.Truncated)
+	require.Equal(t, "GET /", resp.Body)
+
+	resp, err = c.RequestWebPage(context.Background(), &Request{URL: ts.URL + "/links"})
+	require.NoError(t, err)
+	require.False(t, resp.Truncated)
+	require.Len(t, resp.RawHeader["Link"], 2)
+
+	_, err = c.RequestWebPage(context.Background(), &Request{URL: ts.URL + "/slow", Timeout: 100 * time.Millisecond})
+	require.Error(t, err)
+}
+
 func TestCrawl(t *testing.T) {
+	c := newTestClient(t)
 	re, err := regexp.Compile(amassdns.AnySubdomainRegexString())
 	if err != nil {
 		return
@@ -188,7 +261,7 @@ func TestCrawl(t *testing.T) {
 		set := stringset.New(test.want...)
 		defer set.Close()
 
-		err := Crawl(context.Background(), ts.URL, []string{"127.0.0.1"}, test.depth, func(req *Request, resp *Response) {
+		err := c.Crawl(context.Background(), ts.URL, []string{"127.0.0.1"}, test.depth, func(req *Request, resp *Response) {
 			if u, err := url.Parse(req.URL); err == nil {
 				got.Insert(CleanName(u.Hostname()))
 			}
@@ -208,29 +281,43 @@ func TestCrawl(t *testing.T) {
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
 
-	err = Crawl(ctx, ts.URL, []string{"127.0.0.1"}, 0, func(req *Request, resp *Response) {})
+	err = c.Crawl(ctx, ts.URL, []string{"127.0.0.1"}, 0, func(req *Request, resp *Response) {})
 	if err != nil && err.Error() != "the context expired during the crawl of "+ts.URL {
 		t.Errorf("Failed to catch the expired context during the crawl")
 	}
 
-	err = Crawl(ctx, ts.URL, []string{"127.0.0.1"}, 0, func(req *Request, resp *Response) {})
+	err = c.Crawl(ctx, ts.URL, []string{"127.0.0.1"}, 0, func(req *Request, resp *Response) {})
 	if err != nil && err.Error() != "the context expired" {
 		t.Errorf("Failed to catch the expired context before the crawl")
 	}
 }
 
 func TestPullCertificateNames(t *testing.T) {
-	r := resolve.NewResolvers()
-	if r == nil {
-		t.Errorf("Failed to setup the DNS resolver")
+	srv, err := amasstest.NewDNSServer()
+	if err != nil {
+		t.Fatalf("Failed to start the DNS server: %v", err)
+	}
+	defer srv.Close()
+
+	if err := srv.LoadZoneString("utica.edu", "www IN A 127.0.0.1"); err != nil {
+		t.Fatalf("Failed to load the zone: %v", err)
+	}
+
+	cert, err := amasstest.NewCertificate("www.utica.edu", "utica.edu", "127.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to create the certificate: %v", err)
 	}
-	_ = r.AddResolvers(20, "8.8.8.8")
+
+	ts := amasstest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), cert)
+	defer ts.Close()
+
+	r := srv.Pool()
 	defer r.Stop()
 
 	msg := resolve.QueryMsg("www.utica.edu", dns.TypeA)
 	resp, err := r.QueryBlocking(context.Background(), msg)
-	if err != nil && resp == nil && len(resp.Answer) > 0 {
-		t.Errorf("Failed to obtain the IP address")
+	if err != nil || resp == nil || len(resp.Answer) == 0 {
+		t.Fatalf("Failed to obtain the IP address")
 	}
 
 	ans := resolve.ExtractAnswers(resp)
@@ -240,22 +327,24 @@ func TestPullCertificateNames(t *testing.T) {
 
 	rr := resolve.AnswersByType(ans, dns.TypeA)
 	if len(rr) == 0 {
-		t.Errorf("Failed to obtain the answers of the correct type")
+		t.Fatalf("Failed to obtain the answers of the correct type")
 	}
 
 	ip := net.ParseIP(strings.TrimSpace(rr[0].Data))
 	if ip == nil {
-		t.Errorf("Failed to extract a valid IP address from the DNS response")
+		t.Fatalf("Failed to extract a valid IP address from the DNS response")
 	}
 
-	if names := PullCertificateNames(context.Background(), ip.String(), []int{443}); len(names) == 0 {
-		t.Errorf("Failed to obtain names from a certificate from address %s", ip.String())
+	port := amasstest.ServerPort(ts)
+	names := PullCertificateNames(context.Background(), nil, ip.String(), []int{port})
+	if len(names) != 2 || !stringset.New(names...).Has("www.utica.edu") {
+		t.Errorf("Failed to obtain names from a certificate from address %s: %v", ip.String(), names)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	cancel()
 
-	if names := PullCertificateNames(ctx, ip.String(), []int{443}); len(names) != 0 {
+	if names := PullCertificateNames(ctx, nil, ip.String(), []int{port}); len(names) != 0 {
 		t.Errorf("Failed to detect the expired context")
 	}
 }
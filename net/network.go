@@ -7,10 +7,16 @@ package net
 import (
 	"bytes"
 	"context"
+	"errors"
+	"fmt"
 	"math/big"
 	"net"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
+
+	"golang.org/x/net/proxy"
 )
 
 // IPv4RE is a regular expression that will match an IPv4 address.
@@ -19,8 +25,9 @@ const IPv4RE = "((25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)[.]){3}(25[0-5]|2[0-4][0-
 // ReservedCIDRDescription is the description used for reserved address ranges.
 const ReservedCIDRDescription = "Reserved Network Address Blocks"
 
-// LocalAddr is the global option for specifying the network interface.
-var LocalAddr net.Addr
+// ErrProxyUDP is returned when UDP is dialed while the connections are routed through a proxy,
+// since SOCKS5 proxies such as Tor only carry TCP streams.
+var ErrProxyUDP = errors.New("UDP cannot be routed through the SOCKS5 proxy")
 
 // ReservedCIDRs includes all the networks that are reserved for special use.
 var ReservedCIDRs = []string{
@@ -55,37 +62,140 @@ func init() {
 	}
 }
 
-// DialContext performs the dial using global variables (e.g. LocalAddr).
-func DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
-	d := &net.Dialer{DualStack: true}
+// Dialer makes the connections for a System, binding them to the selected network interface
+// address and routing them through the SOCKS5 proxy when one is provided. A nil Dialer makes
+// direct connections.
+type Dialer struct {
+	sync.Mutex
+	localAddr net.Addr
+	proxyURL  string
+	proxy     proxy.ContextDialer
+}
 
-	_, p, err := net.SplitHostPort(addr)
-	if err != nil {
-		return nil, err
+// NewDialer returns a Dialer making direct connections from any local address.
+func NewDialer() *Dialer {
+	return new(Dialer)
+}
+
+// SetLocalAddr binds the connections made by the Dialer to the address, which can be provided
+// with the network mask of the interface. The binding is removed when the address is nil.
+func (d *Dialer) SetLocalAddr(addr net.Addr) {
+	d.Lock()
+	defer d.Unlock()
+
+	d.localAddr = addr
+}
+
+// LocalAddr returns the address the connections are bound to, or nil.
+func (d *Dialer) LocalAddr() net.Addr {
+	if d == nil {
+		return nil
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	return d.localAddr
+}
+
+// SetProxy routes the connections made by the Dialer through the SOCKS5 proxy at the URL
+// (e.g. socks5://127.0.0.1:9050). The proxy is removed when the URL is empty.
+func (d *Dialer) SetProxy(u string) error {
+	if u == "" {
+		d.Lock()
+		d.proxyURL, d.proxy = "", nil
+		d.Unlock()
+		return nil
 	}
 
-	port, err := strconv.Atoi(p)
+	pu, err := url.Parse(u)
+	if err != nil || pu.Host == "" {
+		return fmt.Errorf("failed to parse the proxy URL %s", u)
+	}
+	if s := strings.ToLower(pu.Scheme); s != "socks5" && s != "socks5h" {
+		return fmt.Errorf("the proxy scheme %s is not supported, since only SOCKS5 proxies can carry all the traffic", pu.Scheme)
+	}
+
+	pd, err := proxy.FromURL(pu, directDialer{d: d})
 	if err != nil {
-		return nil, err
+		return fmt.Errorf("failed to create the SOCKS5 dialer for %s: %v", pu.Host, err)
+	}
+	cd, ok := pd.(proxy.ContextDialer)
+	if !ok {
+		return fmt.Errorf("the SOCKS5 dialer for %s does not support contexts", pu.Host)
 	}
 
-	if LocalAddr != nil {
-		addr, _, err := net.ParseCIDR(LocalAddr.String())
+	d.Lock()
+	d.proxyURL, d.proxy = u, cd
+	d.Unlock()
+	return nil
+}
 
-		if err == nil && strings.HasPrefix(network, "tcp") {
-			d.LocalAddr = &net.TCPAddr{
-				IP:   addr,
-				Port: port,
-			}
-		} else if err == nil && strings.HasPrefix(network, "udp") {
-			d.LocalAddr = &net.UDPAddr{
-				IP:   addr,
-				Port: port,
-			}
+// Proxy returns the URL of the proxy the connections are routed through, or an empty string.
+func (d *Dialer) Proxy() string {
+	if d == nil {
+		return ""
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	return d.proxyURL
+}
+
+// Proxied returns true when the connections are routed through a proxy.
+func (d *Dialer) Proxied() bool {
+	return d.Proxy() != ""
+}
+
+// DialContext connects to the address on the named network using the settings of the Dialer.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	var pd proxy.ContextDialer
+	if d != nil {
+		d.Lock()
+		pd = d.proxy
+		d.Unlock()
+	}
+
+	if pd == nil {
+		return d.dialDirect(ctx, network, addr)
+	}
+	if strings.HasPrefix(network, "udp") {
+		return nil, fmt.Errorf("failed to dial %s: %w", addr, ErrProxyUDP)
+	}
+	return pd.DialContext(ctx, network, addr)
+}
+
+// directDialer connects to the proxy without routing the connection through itself.
+type directDialer struct {
+	d *Dialer
+}
+
+func (dd directDialer) Dial(network, addr string) (net.Conn, error) {
+	return dd.d.dialDirect(context.Background(), network, addr)
+}
+
+func (dd directDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return dd.d.dialDirect(ctx, network, addr)
+}
+
+func (d *Dialer) dialDirect(ctx context.Context, network, addr string) (net.Conn, error) {
+	nd := &net.Dialer{DualStack: true}
+
+	if la := d.LocalAddr(); la != nil {
+		ip := net.ParseIP(la.String())
+		if i, _, err := net.ParseCIDR(la.String()); err == nil {
+			ip = i
+		}
+		// The local port is selected by the operating system
+		if ip != nil && strings.HasPrefix(network, "tcp") {
+			nd.LocalAddr = &net.TCPAddr{IP: ip}
+		} else if ip != nil && strings.HasPrefix(network, "udp") {
+			nd.LocalAddr = &net.UDPAddr{IP: ip}
 		}
 	}
 
-	return d.DialContext(ctx, network, addr)
+	return nd.DialContext(ctx, network, addr)
 }
 
 // IsIPv4 returns true when the provided net.IP address is an IPv4 address.
@@ -179,6 +289,49 @@ func Range2CIDR(first, last net.IP) *net.IPNet {
 	return ipnet
 }
 
+// Range2CIDRs returns the smallest set of CIDRs that exactly covers the IP range.
+func Range2CIDRs(first, last net.IP) []*net.IPNet {
+	if ip := first.To4(); ip != nil {
+		first = ip
+	}
+	if ip := last.To4(); ip != nil {
+		last = ip
+	}
+
+	start, bits := ipToInt(first)
+	end, lbits := ipToInt(last)
+	if bits == 0 || bits != lbits || start.Cmp(end) == 1 {
+		return nil
+	}
+
+	var cidrs []*net.IPNet
+	one := big.NewInt(1)
+	size := new(big.Int)
+	blockEnd := new(big.Int)
+	for cur := new(big.Int).Set(start); cur.Cmp(end) <= 0; cur.Add(cur, size) {
+		// Find the largest block aligned at the current address that fits within the range
+		host := int(cur.TrailingZeroBits())
+		if cur.Sign() == 0 || host > bits {
+			host = bits
+		}
+		for ; host > 0; host-- {
+			size.Lsh(one, uint(host))
+			blockEnd.Add(cur, size)
+			blockEnd.Sub(blockEnd, one)
+			if blockEnd.Cmp(end) <= 0 {
+				break
+			}
+		}
+		size.Lsh(one, uint(host))
+
+		cidrs = append(cidrs, &net.IPNet{
+			IP:   intToIP(cur, bits),
+			Mask: net.CIDRMask(bits-host, bits),
+		})
+	}
+	return cidrs
+}
+
 // AllHosts returns a slice containing all the IP addresses within
 // the CIDR provided by the parameter. This implementation was
 // obtained/modified from the following:
@@ -5,8 +5,11 @@
 package net
 
 import (
+	"context"
+	"errors"
 	"net"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -74,6 +77,37 @@ func TestFirstLast(t *testing.T) {
 	}
 }
 
+func TestRange2CIDRs(t *testing.T) {
+	tests := []struct {
+		First    string
+		Last     string
+		Expected []string
+	}{
+		{"72.237.4.0", "72.237.4.255", []string{"72.237.4.0/24"}},
+		{"1.0.4.0", "1.0.7.255", []string{"1.0.4.0/22"}},
+		{"1.0.0.0", "1.0.2.255", []string{"1.0.0.0/23", "1.0.2.0/24"}},
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"192.168.1.0", "192.168.1.0", []string{"192.168.1.0/32"}},
+		{"2620:0:860:2::", "2620:0:860:3:ffff:ffff:ffff:ffff", []string{"2620:0:860:2::/63"}},
+	}
+
+	if cidrs := Range2CIDRs(net.ParseIP("192.168.1.255"), net.ParseIP("192.168.1.1")); cidrs != nil {
+		t.Errorf("Failed to return nil when %s was greater than %s", "192.168.1.255", "192.168.1.1")
+	}
+
+	for _, test := range tests {
+		var got []string
+		for _, cidr := range Range2CIDRs(net.ParseIP(test.First), net.ParseIP(test.Last)) {
+			got = append(got, cidr.String())
+		}
+
+		if strings.Join(got, ",") != strings.Join(test.Expected, ",") {
+			t.Errorf("First IP %s and last IP %s returned %v instead of %v", test.First, test.Last, got, test.Expected)
+		}
+	}
+}
+
 func TestRange2CIDR(t *testing.T) {
 	tests := []struct {
 		First    string
@@ -253,3 +287,59 @@ func TestIPDec(t *testing.T) {
 		}
 	}
 }
+
+func TestDialer(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			c.Close()
+		}
+	}()
+
+	// A nil Dialer makes direct connections
+	var nd *Dialer
+	if nd.Proxied() || nd.LocalAddr() != nil {
+		t.Errorf("The nil Dialer reported settings")
+	}
+	if c, err := nd.DialContext(context.Background(), "tcp", ln.Addr().String()); err != nil {
+		t.Errorf("The nil Dialer failed to connect: %v", err)
+	} else {
+		c.Close()
+	}
+
+	d := NewDialer()
+	_, ipnet, _ := net.ParseCIDR("127.0.0.1/8")
+	ipnet.IP = net.ParseIP("127.0.0.1")
+	d.SetLocalAddr(ipnet)
+	c, err := d.DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("The Dialer failed to connect from the local address: %v", err)
+	}
+	if host, _, _ := net.SplitHostPort(c.LocalAddr().String()); host != "127.0.0.1" {
+		t.Errorf("The connection was made from %s", c.LocalAddr().String())
+	}
+	c.Close()
+
+	if err := d.SetProxy("http://127.0.0.1:8080"); err == nil {
+		t.Errorf("Failed to reject the HTTP proxy")
+	}
+	if err := d.SetProxy("socks5://127.0.0.1:1080"); err != nil || !d.Proxied() {
+		t.Errorf("Failed to set the SOCKS5 proxy: %v", err)
+	}
+	// The proxy of a Dialer does not affect the other Dialers
+	if NewDialer().Proxied() {
+		t.Errorf("The proxy was shared between the Dialers")
+	}
+	if _, err := d.DialContext(context.Background(), "udp", ln.Addr().String()); !errors.Is(err, ErrProxyUDP) {
+		t.Errorf("Failed to reject the UDP dial through the proxy: %v", err)
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package rdap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/owasp-amass/amass/v4/resources"
+)
+
+// Bootstrap finds the RDAP servers responsible for domain names, IP addresses and
+// autonomous system numbers, using the IANA bootstrap registries (RFC 9224).
+type Bootstrap struct {
+	domains map[string][]string
+	nets    []*netService
+	asns    []*asnService
+}
+
+type netService struct {
+	ipnet *net.IPNet
+	urls  []string
+}
+
+type asnService struct {
+	first int
+	last  int
+	urls  []string
+}
+
+// The format shared by the IANA bootstrap files.
+type bootstrapFile struct {
+	Description string       `json:"description"`
+	Publication string       `json:"publication"`
+	Services    [][][]string `json:"services"`
+	Version     string       `json:"version"`
+}
+
+var (
+	defaultOnce      sync.Once
+	defaultBootstrap *Bootstrap
+	defaultErr       error
+)
+
+// DefaultBootstrap returns the Bootstrap built from the IANA files shipped with Amass.
+func DefaultBootstrap() (*Bootstrap, error) {
+	defaultOnce.Do(func() {
+		var readers []io.Reader
+
+		for _, name := range []string{"dns.json", "ipv4.json", "ipv6.json", "asn.json"} {
+			r, err := resources.GetResourceFile("rdap/" + name)
+			if err != nil {
+				defaultErr = err
+				return
+			}
+			readers = append(readers, r)
+		}
+		defaultBootstrap, defaultErr = LoadBootstrap(readers[0], readers[1], readers[2], readers[3])
+	})
+	return defaultBootstrap, defaultErr
+}
+
+// LoadBootstrap returns a Bootstrap built from the IANA files for domain names, IPv4 addresses,
+// IPv6 addresses and autonomous system numbers. Any of the readers can be nil.
+func LoadBootstrap(dns, ipv4, ipv6, asn io.Reader) (*Bootstrap, error) {
+	b := &Bootstrap{domains: make(map[string][]string)}
+
+	for _, f := range []struct {
+		r    io.Reader
+		name string
+		add  func([]string, []string) error
+	}{
+		{dns, "DNS", b.addDomains},
+		{ipv4, "IPv4", b.addNets},
+		{ipv6, "IPv6", b.addNets},
+		{asn, "ASN", b.addASNs},
+	} {
+		if f.r == nil {
+			continue
+		}
+
+		var file bootstrapFile
+		if err := json.NewDecoder(f.r).Decode(&file); err != nil {
+			return nil, fmt.Errorf("failed to decode the %s bootstrap file: %v", f.name, err)
+		}
+		for _, svc := range file.Services {
+			if len(svc) != 2 {
+				continue
+			}
+			if err := f.add(svc[0], svc[1]); err != nil {
+				return nil, fmt.Errorf("failed to load the %s bootstrap file: %v", f.name, err)
+			}
+		}
+	}
+	return b, nil
+}
+
+func (b *Bootstrap) addDomains(tlds, urls []string) error {
+	for _, tld := range tlds {
+		b.domains[strings.ToLower(strings.Trim(tld, "."))] = urls
+	}
+	return nil
+}
+
+func (b *Bootstrap) addNets(cidrs, urls []string) error {
+	for _, cidr := range cidrs {
+		_, ipnet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return err
+		}
+		b.nets = append(b.nets, &netService{ipnet: ipnet, urls: urls})
+	}
+	return nil
+}
+
+func (b *Bootstrap) addASNs(ranges, urls []string) error {
+	for _, r := range ranges {
+		first, last, found := strings.Cut(r, "-")
+		if !found {
+			last = first
+		}
+
+		f, err := strconv.Atoi(first)
+		if err != nil {
+			return err
+		}
+		l, err := strconv.Atoi(last)
+		if err != nil {
+			return err
+		}
+		b.asns = append(b.asns, &asnService{first: f, last: l, urls: urls})
+	}
+	return nil
+}
+
+// DomainURL returns the base URL of the RDAP server for the domain name.
+func (b *Bootstrap) DomainURL(name string) (string, bool) {
+	labels := strings.Split(strings.ToLower(strings.Trim(name, ".")), ".")
+	// The longest matching label sequence is selected
+	for i := range labels {
+		if urls, found := b.domains[strings.Join(labels[i:], ".")]; found {
+			return preferHTTPS(urls)
+		}
+	}
+	return "", false
+}
+
+// IPURL returns the base URL of the RDAP server for the IP address.
+func (b *Bootstrap) IPURL(ip net.IP) (string, bool) {
+	var best *netService
+
+	for _, svc := range b.nets {
+		if !svc.ipnet.Contains(ip) {
+			continue
+		}
+		if best == nil {
+			best = svc
+			continue
+		}
+		// The longest matching prefix is selected
+		if ones, _ := svc.ipnet.Mask.Size(); ones > maskSize(best.ipnet) {
+			best = svc
+		}
+	}
+	if best == nil {
+		return "", false
+	}
+	return preferHTTPS(best.urls)
+}
+
+// ASNURL returns the base URL of the RDAP server for the autonomous system number.
+func (b *Bootstrap) ASNURL(asn int) (string, bool) {
+	for _, svc := range b.asns {
+		if asn >= svc.first && asn <= svc.last {
+			return preferHTTPS(svc.urls)
+		}
+	}
+	return "", false
+}
+
+func maskSize(ipnet *net.IPNet) int {
+	ones, _ := ipnet.Mask.Size()
+	return ones
+}
+
+func preferHTTPS(urls []string) (string, bool) {
+	if len(urls) == 0 {
+		return "", false
+	}
+
+	for _, u := range urls {
+		if strings.HasPrefix(strings.ToLower(u), "https://") {
+			return u, true
+		}
+	}
+	return urls[0], true
+}
@@ -0,0 +1,354 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package rdap
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owasp-amass/amass/v4/net/http"
+)
+
+// ErrNotFound is returned when the RDAP server has no object for the query.
+var ErrNotFound = errors.New("the object was not found")
+
+// Requester sends the HTTP requests of the Client.
+type Requester interface {
+	RequestWebPage(ctx context.Context, r *http.Request) (*http.Response, error)
+}
+
+// Client queries the RDAP servers selected by the bootstrap registries.
+type Client struct {
+	web  Requester
+	boot *Bootstrap
+}
+
+// NewClient returns a Client that sends the requests using web and selects the servers using boot.
+// A net/http Client with the default settings and the DefaultBootstrap are used when the arguments are nil.
+func NewClient(web Requester, boot *Bootstrap) (*Client, error) {
+	if web == nil {
+		c, err := http.NewClient(nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		web = c
+	}
+	if boot == nil {
+		b, err := DefaultBootstrap()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the RDAP bootstrap files: %v", err)
+		}
+		boot = b
+	}
+	return &Client{web: web, boot: boot}, nil
+}
+
+// Query selects the type of lookup from the query, which can be an IP address, a CIDR,
+// an autonomous system number with or without the AS prefix, or a domain name.
+func (c *Client) Query(ctx context.Context, query string) (*Response, error) {
+	query = strings.TrimSpace(query)
+
+	if ip := net.ParseIP(query); ip != nil {
+		return c.IP(ctx, ip)
+	}
+	if ip, _, err := net.ParseCIDR(query); err == nil {
+		return c.lookup(ctx, "ip/"+query, func() (string, bool) { return c.boot.IPURL(ip) })
+	}
+	if asn, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(query), "AS")); err == nil {
+		return c.Autnum(ctx, asn)
+	}
+	if query == "" {
+		return nil, errors.New("failed to provide the RDAP query")
+	}
+	return c.Domain(ctx, query)
+}
+
+// Domain returns the registration data for the domain name.
+func (c *Client) Domain(ctx context.Context, name string) (*Response, error) {
+	name = strings.ToLower(strings.Trim(name, "."))
+
+	return c.lookup(ctx, "domain/"+name, func() (string, bool) { return c.boot.DomainURL(name) })
+}
+
+// IP returns the registration data for the network containing the IP address.
+func (c *Client) IP(ctx context.Context, ip net.IP) (*Response, error) {
+	return c.lookup(ctx, "ip/"+ip.String(), func() (string, bool) { return c.boot.IPURL(ip) })
+}
+
+// Autnum returns the registration data for the autonomous system number.
+func (c *Client) Autnum(ctx context.Context, asn int) (*Response, error) {
+	return c.lookup(ctx, "autnum/"+strconv.Itoa(asn), func() (string, bool) { return c.boot.ASNURL(asn) })
+}
+
+func (c *Client) lookup(ctx context.Context, path string, server func() (string, bool)) (*Response, error) {
+	base, found := server()
+	if !found {
+		return nil, fmt.Errorf("failed to find the RDAP server for %s", path)
+	}
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+
+	u := base + path
+	resp, err := c.web.RequestWebPage(ctx, &http.Request{
+		URL:    u,
+		Header: http.Header{"Accept": "application/rdap+json"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to request %s: %v", u, err)
+	}
+	if resp.StatusCode == 404 {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to request %s: %s", u, resp.Status)
+	}
+
+	final := resp.URL
+	if final == "" {
+		final = u
+	}
+	return Parse(final, []byte(resp.Body))
+}
+
+// Response is the registration data returned by an RDAP server, with the most
+// useful fields of the domain, network and autnum object classes extracted.
+type Response struct {
+	// URL is the location the response was received from
+	URL         string
+	ObjectClass string
+	Handle      string
+	Name        string
+	Type        string
+	Country     string
+	Status      []string
+	Port43      string
+	// Registry is the regional Internet registry operating the server, when known
+	Registry     string
+	StartAddress string
+	EndAddress   string
+	CIDRs        []string
+	StartAutnum  int
+	EndAutnum    int
+	Nameservers  []string
+	// Events maps the event actions, such as registration, to their dates
+	Events   map[string]time.Time
+	Entities []*Entity
+	Remarks  []string
+	// Raw is the JSON document returned by the server
+	Raw string
+}
+
+// Entity is a contact or organization associated with an RDAP object.
+type Entity struct {
+	Handle string
+	Roles  []string
+	Name   string
+	Org    string
+	Email  string
+}
+
+// The subset of the RDAP JSON responses (RFC 9083) that is extracted.
+type jsonObject struct {
+	ObjectClassName string       `json:"objectClassName"`
+	Handle          string       `json:"handle"`
+	Name            string       `json:"name"`
+	LDHName         string       `json:"ldhName"`
+	Type            string       `json:"type"`
+	Country         string       `json:"country"`
+	Status          []string     `json:"status"`
+	Port43          string       `json:"port43"`
+	StartAddress    string       `json:"startAddress"`
+	EndAddress      string       `json:"endAddress"`
+	StartAutnum     int          `json:"startAutnum"`
+	EndAutnum       int          `json:"endAutnum"`
+	CIDRs           []jsonCIDR   `json:"cidr0_cidrs"`
+	Nameservers     []jsonObject `json:"nameservers"`
+	Events          []jsonEvent  `json:"events"`
+	Entities        []jsonEntity `json:"entities"`
+	Remarks         []jsonRemark `json:"remarks"`
+	ErrorCode       int          `json:"errorCode"`
+	Title           string       `json:"title"`
+}
+
+type jsonCIDR struct {
+	V4Prefix string `json:"v4prefix"`
+	V6Prefix string `json:"v6prefix"`
+	Length   int    `json:"length"`
+}
+
+type jsonEvent struct {
+	Action string `json:"eventAction"`
+	Date   string `json:"eventDate"`
+}
+
+type jsonEntity struct {
+	Handle     string            `json:"handle"`
+	Roles      []string          `json:"roles"`
+	VCardArray []json.RawMessage `json:"vcardArray"`
+	Entities   []jsonEntity      `json:"entities"`
+}
+
+type jsonRemark struct {
+	Title       string   `json:"title"`
+	Description []string `json:"description"`
+}
+
+// Parse extracts the registration data from the RDAP JSON document received from the URL.
+func Parse(u string, data []byte) (*Response, error) {
+	var obj jsonObject
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("failed to decode the RDAP response: %v", err)
+	}
+	if obj.ErrorCode != 0 {
+		if obj.ErrorCode == 404 {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("the RDAP server returned error %d: %s", obj.ErrorCode, obj.Title)
+	}
+
+	r := &Response{
+		URL:          u,
+		ObjectClass:  obj.ObjectClassName,
+		Handle:       obj.Handle,
+		Name:         obj.Name,
+		Type:         obj.Type,
+		Country:      obj.Country,
+		Status:       obj.Status,
+		Port43:       obj.Port43,
+		Registry:     registryFromURL(u),
+		StartAddress: obj.StartAddress,
+		EndAddress:   obj.EndAddress,
+		StartAutnum:  obj.StartAutnum,
+		EndAutnum:    obj.EndAutnum,
+		Events:       make(map[string]time.Time),
+		Raw:          string(data),
+	}
+	// Domain objects are identified by the LDH name
+	if r.Name == "" {
+		r.Name = strings.ToLower(obj.LDHName)
+	}
+
+	for _, c := range obj.CIDRs {
+		prefix := c.V4Prefix
+		if prefix == "" {
+			prefix = c.V6Prefix
+		}
+		if _, ipnet, err := net.ParseCIDR(prefix + "/" + strconv.Itoa(c.Length)); err == nil {
+			r.CIDRs = append(r.CIDRs, ipnet.String())
+		}
+	}
+	for _, ns := range obj.Nameservers {
+		if ns.LDHName != "" {
+			r.Nameservers = append(r.Nameservers, strings.ToLower(strings.Trim(ns.LDHName, ".")))
+		}
+	}
+	for _, e := range obj.Events {
+		if t, err := time.Parse(time.RFC3339, e.Date); err == nil && e.Action != "" {
+			r.Events[e.Action] = t
+		}
+	}
+	for _, rem := range obj.Remarks {
+		if text := strings.TrimSpace(strings.Join(rem.Description, " ")); text != "" {
+			r.Remarks = append(r.Remarks, text)
+		}
+	}
+	r.Entities = flattenEntities(obj.Entities)
+	return r, nil
+}
+
+// Registered returns the registration date of the object, or the zero time when not provided.
+func (r *Response) Registered() time.Time {
+	return r.Events["registration"]
+}
+
+func flattenEntities(entities []jsonEntity) []*Entity {
+	var results []*Entity
+
+	for _, e := range entities {
+		ent := &Entity{
+			Handle: e.Handle,
+			Roles:  e.Roles,
+		}
+		parseVCard(ent, e.VCardArray)
+
+		results = append(results, ent)
+		results = append(results, flattenEntities(e.Entities)...)
+	}
+	return results
+}
+
+// parseVCard extracts the fields of a jCard (RFC 7095), which is the array ["vcard", [properties]].
+func parseVCard(ent *Entity, card []json.RawMessage) {
+	if len(card) != 2 {
+		return
+	}
+
+	var props [][]json.RawMessage
+	if err := json.Unmarshal(card[1], &props); err != nil {
+		return
+	}
+
+	for _, p := range props {
+		if len(p) < 4 {
+			continue
+		}
+
+		var name, value string
+		if err := json.Unmarshal(p[0], &name); err != nil {
+			continue
+		}
+		if err := json.Unmarshal(p[3], &value); err != nil {
+			// Structured values, such as the org property, are arrays
+			var values []string
+			if err := json.Unmarshal(p[3], &values); err != nil || len(values) == 0 {
+				continue
+			}
+			value = values[0]
+		}
+
+		switch strings.ToLower(name) {
+		case "fn":
+			ent.Name = value
+		case "org":
+			ent.Org = value
+		case "email":
+			ent.Email = value
+		}
+	}
+}
+
+func registryFromURL(u string) string {
+	p, err := url.Parse(u)
+	if err != nil {
+		return ""
+	}
+
+	host := strings.ToLower(p.Hostname())
+	for _, rir := range []struct {
+		label string
+		name  string
+	}{
+		{"afrinic", "AFRINIC"},
+		{"apnic", "APNIC"},
+		{"arin", "ARIN"},
+		{"lacnic", "LACNIC"},
+		{"ripe", "RIPENCC"},
+	} {
+		for _, label := range strings.Split(host, ".") {
+			if label == rir.label {
+				return rir.name
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,170 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package rdap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultBootstrap(t *testing.T) {
+	b, err := DefaultBootstrap()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		lookup func() (string, bool)
+		want   string
+	}{
+		{"IPv4", func() (string, bool) { return b.IPURL(net.ParseIP("8.8.8.8")) }, "https://rdap.arin.net/registry/"},
+		{"IPv4 RIPE", func() (string, bool) { return b.IPURL(net.ParseIP("193.0.6.139")) }, "https://rdap.db.ripe.net/"},
+		{"IPv6", func() (string, bool) { return b.IPURL(net.ParseIP("2001:4860:4860::8888")) }, "https://rdap.arin.net/registry/"},
+		{"ASN", func() (string, bool) { return b.ASNURL(15169) }, "https://rdap.arin.net/registry/"},
+		{"domain", func() (string, bool) { return b.DomainURL("www.owasp.org") }, "https://rdap.publicinterestregistry.org/rdap/"},
+		{"FQDN", func() (string, bool) { return b.DomainURL("www.example.com.") }, "https://rdap.verisign.com/com/v1/"},
+	}
+
+	for _, test := range tests {
+		u, found := test.lookup()
+		require.True(t, found, test.name)
+		require.Equal(t, test.want, u, test.name)
+	}
+
+	_, found := b.DomainURL("example.invalid")
+	require.False(t, found)
+	_, found = b.IPURL(net.ParseIP("10.0.0.1"))
+	require.False(t, found)
+}
+
+func TestBootstrapMostSpecific(t *testing.T) {
+	ipv4 := `{"services": [
+		[["10.0.0.0/8"], ["http://wide.example/"]],
+		[["10.1.0.0/16"], ["http://narrow.example/", "https://narrow.example/"]]
+	]}`
+	dns := `{"services": [[["uk"], ["https://uk.example/"]], [["co.uk"], ["https://co.uk.example/"]]]}`
+	asn := `{"services": [[["100-200", "300"], ["https://asn.example/"]]]}`
+
+	b, err := LoadBootstrap(strings.NewReader(dns), strings.NewReader(ipv4), nil, strings.NewReader(asn))
+	require.NoError(t, err)
+
+	u, _ := b.IPURL(net.ParseIP("10.1.2.3"))
+	require.Equal(t, "https://narrow.example/", u)
+	u, _ = b.IPURL(net.ParseIP("10.2.2.3"))
+	require.Equal(t, "http://wide.example/", u)
+	u, _ = b.DomainURL("www.owasp.co.uk")
+	require.Equal(t, "https://co.uk.example/", u)
+	u, _ = b.DomainURL("www.owasp.uk")
+	require.Equal(t, "https://uk.example/", u)
+	_, found := b.ASNURL(300)
+	require.True(t, found)
+	_, found = b.ASNURL(250)
+	require.False(t, found)
+
+	_, err = LoadBootstrap(nil, strings.NewReader(`{"services": [[["not a CIDR"], ["https://a/"]]]}`), nil, nil)
+	require.Error(t, err)
+}
+
+const networkResponse = `{
+  "objectClassName": "ip network",
+  "handle": "NET-8-8-8-0-2",
+  "startAddress": "8.8.8.0",
+  "endAddress": "8.8.8.255",
+  "name": "GOGL",
+  "type": "DIRECT ALLOCATION",
+  "status": ["active"],
+  "port43": "whois.arin.net",
+  "cidr0_cidrs": [{"v4prefix": "8.8.8.0", "length": 24}],
+  "events": [
+    {"eventAction": "registration", "eventDate": "2014-03-14T16:52:05-04:00"},
+    {"eventAction": "last changed", "eventDate": "2014-03-14T16:52:05-04:00"}
+  ],
+  "remarks": [{"title": "Registration Comments", "description": ["Google LLC", "network"]}],
+  "entities": [{
+    "handle": "GOGL",
+    "roles": ["registrant"],
+    "vcardArray": ["vcard", [
+      ["version", {}, "text", "4.0"],
+      ["fn", {}, "text", "Google LLC"],
+      ["org", {}, "text", ["Google", "Networks"]]
+    ]],
+    "entities": [{
+      "handle": "ABUSE5250-ARIN",
+      "roles": ["abuse"],
+      "vcardArray": ["vcard", [["fn", {}, "text", "Abuse"], ["email", {}, "text", "network-abuse@google.com"]]]
+    }]
+  }]
+}`
+
+func TestClientQuery(t *testing.T) {
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+
+		switch r.URL.Path {
+		case "/arin/registry/ip/8.8.8.8":
+			_, _ = w.Write([]byte(networkResponse))
+		case "/registry/domain/owasp.org":
+			_, _ = w.Write([]byte(`{"objectClassName": "domain", "ldhName": "OWASP.ORG",
+				"nameservers": [{"objectClassName": "nameserver", "ldhName": "NS1.OWASP.ORG."}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	ipv4 := fmt.Sprintf(`{"services": [[["8.0.0.0/8"], ["%s/arin/registry/"]]]}`, ts.URL)
+	dns := fmt.Sprintf(`{"services": [[["org"], ["%s/registry"]]]}`, ts.URL)
+	asn := fmt.Sprintf(`{"services": [[["1-100"], ["%s/registry/"]]]}`, ts.URL)
+	b, err := LoadBootstrap(strings.NewReader(dns), strings.NewReader(ipv4), nil, strings.NewReader(asn))
+	require.NoError(t, err)
+
+	c, err := NewClient(nil, b)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	resp, err := c.Query(ctx, "8.8.8.8")
+	require.NoError(t, err)
+	require.Equal(t, "application/rdap+json", accept)
+	require.Equal(t, "ip network", resp.ObjectClass)
+	require.Equal(t, "GOGL", resp.Name)
+	require.Equal(t, []string{"8.8.8.0/24"}, resp.CIDRs)
+	require.Equal(t, "whois.arin.net", resp.Port43)
+	require.Equal(t, time.Date(2014, 3, 14, 20, 52, 5, 0, time.UTC), resp.Registered().UTC())
+	require.Equal(t, []string{"Google LLC network"}, resp.Remarks)
+	require.Len(t, resp.Entities, 2)
+	require.Equal(t, "Google", resp.Entities[0].Org)
+	require.Equal(t, "Google LLC", resp.Entities[0].Name)
+	require.Equal(t, "network-abuse@google.com", resp.Entities[1].Email)
+
+	_, err = c.Query(ctx, "www.owasp.org")
+	require.True(t, errors.Is(err, ErrNotFound), "the query is sent for the name provided")
+	resp, err = c.Query(ctx, "owasp.org.")
+	require.NoError(t, err)
+	require.Equal(t, "owasp.org", resp.Name)
+	require.Equal(t, []string{"ns1.owasp.org"}, resp.Nameservers)
+
+	_, err = c.Query(ctx, "AS64")
+	require.True(t, errors.Is(err, ErrNotFound))
+	_, err = c.Query(ctx, "AS64512")
+	require.Error(t, err, "no server is responsible for the ASN")
+	_, err = c.Query(ctx, "")
+	require.Error(t, err)
+}
+
+func TestRegistryFromURL(t *testing.T) {
+	require.Equal(t, "ARIN", registryFromURL("https://rdap.arin.net/registry/ip/8.8.8.8"))
+	require.Equal(t, "RIPENCC", registryFromURL("https://rdap.db.ripe.net/ip/193.0.6.139"))
+	require.Equal(t, "LACNIC", registryFromURL("https://rdap.lacnic.net/rdap/autnum/28000"))
+	require.Empty(t, registryFromURL("https://rdap.verisign.com/com/v1/domain/example.com"))
+}
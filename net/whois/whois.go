@@ -0,0 +1,286 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package whois
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	amassnet "github.com/owasp-amass/amass/v4/net"
+)
+
+const (
+	// IANAServer is the WHOIS server that refers the queries to the responsible registries.
+	IANAServer = "whois.iana.org"
+	// MaxReferrals limits the number of servers followed after the first.
+	MaxReferrals = 3
+
+	defaultTimeout = 30 * time.Second
+	maxResponse    = 1 << 20
+)
+
+// Response is the text returned by the last WHOIS server queried, and the fields parsed from it.
+type Response struct {
+	Server string
+	// Fields maps the lowercase keys to each of the values provided for them
+	Fields map[string][]string
+	Raw    string
+}
+
+// Client sends the WHOIS queries over TCP port 43 and follows the referrals between servers.
+type Client struct {
+	// Server is the first server queried, and IANAServer is used when empty
+	Server string
+	// Port is used for the servers provided without one, and 43 is used when zero
+	Port int
+	// Timeout limits each of the queries, and 30 seconds is used when zero
+	Timeout time.Duration
+	// Dialer makes the connections to the servers, and direct connections are made when nil
+	Dialer *amassnet.Dialer
+}
+
+// Query sends the query to the WHOIS servers and returns the response of the last server in the referral chain.
+func (c *Client) Query(ctx context.Context, query string) (*Response, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, errors.New("failed to provide the WHOIS query")
+	}
+
+	server := c.Server
+	if server == "" {
+		server = IANAServer
+	}
+
+	var last *Response
+	visited := make(map[string]struct{})
+	for i := 0; i <= MaxReferrals && server != ""; i++ {
+		if _, found := visited[server]; found {
+			break
+		}
+		visited[server] = struct{}{}
+
+		raw, err := c.send(ctx, server, query)
+		if err != nil {
+			if last != nil {
+				// The response already received is better than nothing
+				return last, nil
+			}
+			return nil, err
+		}
+
+		last = &Response{
+			Server: server,
+			Fields: Parse(raw),
+			Raw:    raw,
+		}
+		server = referral(last.Fields)
+	}
+	return last, nil
+}
+
+func (c *Client) send(ctx context.Context, server, query string) (string, error) {
+	timeout := c.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	port := c.Port
+	if port <= 0 {
+		port = 43
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr := server
+	// The servers can be provided with the port
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		addr = net.JoinHostPort(server, fmt.Sprint(port))
+	}
+	conn, err := c.Dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to the WHOIS server %s: %v", addr, err)
+	}
+	defer conn.Close()
+
+	if d, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(d)
+	}
+	if _, err := io.WriteString(conn, query+"\r\n"); err != nil {
+		return "", fmt.Errorf("failed to send the query to the WHOIS server %s: %v", addr, err)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(conn, maxResponse))
+	if err != nil && len(data) == 0 {
+		return "", fmt.Errorf("failed to read the response from the WHOIS server %s: %v", addr, err)
+	}
+	return string(data), nil
+}
+
+// Parse extracts the "key: value" fields from the WHOIS text, ignoring the comments.
+func Parse(raw string) map[string][]string {
+	fields := make(map[string][]string)
+
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "%") || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ">>>") {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+		if key == "" || value == "" || strings.Contains(key, "  ") {
+			continue
+		}
+		fields[key] = append(fields[key], value)
+	}
+	return fields
+}
+
+// referral returns the server that the fields refer the query to, or an empty string.
+func referral(fields map[string][]string) string {
+	for _, key := range []string{"refer", "whois", "registrar whois server", "referralserver"} {
+		for _, v := range fields[key] {
+			if server := serverName(v); server != "" {
+				return server
+			}
+		}
+	}
+	return ""
+}
+
+// serverName accepts the host name, optionally with the port, or the whois:// URLs used by ARIN.
+func serverName(v string) string {
+	v = strings.TrimSpace(v)
+
+	if strings.Contains(v, "://") {
+		u, err := url.Parse(v)
+		// RWHOIS servers do not speak the WHOIS protocol
+		if err != nil || !strings.EqualFold(u.Scheme, "whois") {
+			return ""
+		}
+		v = u.Host
+	}
+	if strings.ContainsAny(v, " /") {
+		return ""
+	}
+	return strings.ToLower(v)
+}
+
+// Registry returns the regional Internet registry operating the server, when known.
+func (r *Response) Registry() string {
+	host := r.Server
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	for _, label := range strings.Split(strings.ToLower(host), ".") {
+		switch label {
+		case "afrinic":
+			return "AFRINIC"
+		case "apnic":
+			return "APNIC"
+		case "arin":
+			return "ARIN"
+		case "lacnic":
+			return "LACNIC"
+		case "ripe":
+			return "RIPENCC"
+		}
+	}
+	return ""
+}
+
+// Created returns the registration date provided by the server, or the zero time when not found.
+func (r *Response) Created() time.Time {
+	for _, key := range []string{"regdate", "created", "creation date"} {
+		for _, v := range r.Fields[key] {
+			for _, layout := range []string{time.RFC3339, "2006-01-02", "20060102"} {
+				if t, err := time.Parse(layout, v); err == nil {
+					return t
+				}
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// Netblocks returns the CIDRs of the network described by the response, converting the address ranges.
+func (r *Response) Netblocks() []string {
+	var cidrs []string
+
+	for _, key := range []string{"cidr", "inetnum", "inet6num", "netrange"} {
+		for _, v := range r.Fields[key] {
+			for _, item := range strings.Split(v, ",") {
+				item = strings.TrimSpace(item)
+
+				if p, err := netip.ParsePrefix(item); err == nil {
+					cidrs = append(cidrs, p.Masked().String())
+					continue
+				}
+				if first, last, found := strings.Cut(item, "-"); found {
+					start, err1 := netip.ParseAddr(strings.TrimSpace(first))
+					end, err2 := netip.ParseAddr(strings.TrimSpace(last))
+					if err1 == nil && err2 == nil && start.BitLen() == end.BitLen() {
+						cidrs = append(cidrs, rangeToCIDRs(start, end)...)
+					}
+				}
+			}
+		}
+		// The first key found describes the network
+		if len(cidrs) > 0 {
+			break
+		}
+	}
+	return cidrs
+}
+
+// maxRangeCIDRs limits the prefixes returned for an address range that is not aligned.
+const maxRangeCIDRs = 32
+
+func rangeToCIDRs(start, end netip.Addr) []string {
+	var cidrs []string
+
+	for start.Compare(end) <= 0 && len(cidrs) < maxRangeCIDRs {
+		// Find the largest prefix that begins at start and does not go past end
+		best := netip.PrefixFrom(start, start.BitLen())
+		for bits := start.BitLen() - 1; bits >= 0; bits-- {
+			p, err := start.Prefix(bits)
+			if err != nil || p.Addr() != start || lastAddr(p).Compare(end) > 0 {
+				break
+			}
+			best = p
+		}
+		cidrs = append(cidrs, best.String())
+
+		next := lastAddr(best).Next()
+		if !next.IsValid() {
+			break
+		}
+		start = next
+	}
+	return cidrs
+}
+
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().AsSlice()
+
+	for i := p.Bits(); i < len(b)*8; i++ {
+		b[i/8] |= 1 << (7 - uint(i%8))
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
@@ -0,0 +1,139 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package whois
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startServer returns the address of a WHOIS server that answers each query using fn.
+func startServer(t *testing.T, fn func(query string) string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(c net.Conn) {
+				defer c.Close()
+
+				line, err := bufio.NewReader(c).ReadString('\n')
+				if err != nil {
+					return
+				}
+				_, _ = io.WriteString(c, fn(strings.TrimSpace(line)))
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestQueryReferrals(t *testing.T) {
+	registry := startServer(t, func(query string) string {
+		return "% RIPE database\n\ninetnum:        193.0.0.0 - 193.0.7.255\n" +
+			"netname:        RIPE-NCC\ndescr:          RIPE Network Coordination Centre\n" +
+			"descr:          Amsterdam, Netherlands\ncreated:        2003-03-17T12:15:57Z\nsource:         RIPE\n"
+	})
+
+	var queries []string
+	iana := startServer(t, func(query string) string {
+		queries = append(queries, query)
+		return "% IANA WHOIS server\n\nrefer:        " + registry + "\n\ninetnum:      193.0.0.0 - 193.255.255.255\n"
+	})
+
+	c := &Client{Server: iana}
+	resp, err := c.Query(context.Background(), " 193.0.6.139 ")
+	require.NoError(t, err)
+	require.Equal(t, []string{"193.0.6.139"}, queries)
+	require.Equal(t, registry, resp.Server)
+	require.Equal(t, []string{"193.0.0.0 - 193.0.7.255"}, resp.Fields["inetnum"])
+	require.Equal(t, []string{"RIPE Network Coordination Centre", "Amsterdam, Netherlands"}, resp.Fields["descr"])
+	require.Equal(t, []string{"2003-03-17T12:15:57Z"}, resp.Fields["created"])
+	require.Contains(t, resp.Raw, "% RIPE database")
+
+	_, err = c.Query(context.Background(), "")
+	require.Error(t, err)
+}
+
+func TestQueryFailedReferral(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	unreachable := ln.Addr().String()
+	_ = ln.Close()
+
+	first := startServer(t, func(query string) string {
+		return "refer: " + unreachable + "\ndomain: OWASP.ORG\n"
+	})
+
+	c := &Client{Server: first}
+	resp, err := c.Query(context.Background(), "owasp.org")
+	require.NoError(t, err, "the last response received must be returned")
+	require.Equal(t, first, resp.Server)
+	require.Equal(t, []string{"OWASP.ORG"}, resp.Fields["domain"])
+
+	_, err = (&Client{Server: unreachable}).Query(context.Background(), "owasp.org")
+	require.Error(t, err)
+}
+
+func TestReferral(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"refer: whois.ripe.net", "whois.ripe.net"},
+		{"whois: WHOIS.PIR.ORG", "whois.pir.org"},
+		{"Registrar WHOIS Server: whois.markmonitor.com", "whois.markmonitor.com"},
+		{"ReferralServer:  whois://whois.ripe.net", "whois.ripe.net"},
+		{"ReferralServer:  rwhois://rwhois.example.net:4321", ""},
+		{"Comment: no referral here", ""},
+	}
+
+	for _, test := range tests {
+		require.Equal(t, test.want, referral(Parse(test.text)), test.text)
+	}
+}
+
+func TestResponseNetwork(t *testing.T) {
+	arin := &Response{
+		Server: "whois.arin.net",
+		Fields: Parse("NetRange:       8.8.8.0 - 8.8.8.255\nCIDR:           8.8.8.0/24\nRegDate:        2014-03-14\n"),
+	}
+	require.Equal(t, "ARIN", arin.Registry())
+	require.Equal(t, []string{"8.8.8.0/24"}, arin.Netblocks())
+	require.Equal(t, time.Date(2014, 3, 14, 0, 0, 0, 0, time.UTC), arin.Created())
+
+	ripe := &Response{
+		Server: "whois.ripe.net:43",
+		Fields: Parse("inetnum:        193.0.0.0 - 193.0.10.255\ncreated:        2003-03-17T12:15:57Z\n"),
+	}
+	require.Equal(t, "RIPENCC", ripe.Registry())
+	require.Equal(t, []string{"193.0.0.0/21", "193.0.8.0/23", "193.0.10.0/24"}, ripe.Netblocks())
+	require.Equal(t, time.Date(2003, 3, 17, 12, 15, 57, 0, time.UTC), ripe.Created())
+
+	lacnic := &Response{
+		Server: "whois.lacnic.net",
+		Fields: Parse("inetnum:     200.160.0.0/20\ninet6num:    2001:12ff::/32\ncreated:     19980101\n"),
+	}
+	require.Equal(t, []string{"200.160.0.0/20"}, lacnic.Netblocks())
+	require.Equal(t, time.Date(1998, 1, 1, 0, 0, 0, 0, time.UTC), lacnic.Created())
+
+	unknown := &Response{Server: "whois.example.com", Fields: Parse("domain: EXAMPLE.COM\n")}
+	require.Empty(t, unknown.Registry())
+	require.Empty(t, unknown.Netblocks())
+	require.True(t, unknown.Created().IsZero())
+}
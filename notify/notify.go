@@ -0,0 +1,406 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/net/http"
+)
+
+// The message formats accepted by the webhooks.
+const (
+	FormatGeneric = "generic"
+	FormatSlack   = "slack"
+	FormatTeams   = "teams"
+)
+
+// The default settings for the webhooks.
+const (
+	DefaultBatchSize = 50
+	DefaultInterval  = 30
+	DefaultRetries   = 3
+	DefaultTemplate  = `{{.Name}}{{range .Records}} {{.Type}}:{{.Value}}{{end}}`
+)
+
+var (
+	// the delay before the first retry, which doubles after each attempt
+	retryDelay    = time.Second
+	maxRetryDelay = time.Minute
+	sendTimeout   = 30 * time.Second
+	// how long Close waits for the queued findings before the requests are abandoned
+	closeTimeout = 15 * time.Second
+)
+
+// Settings describe a webhook receiving the findings of the enumeration.
+type Settings struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Format is generic, slack or teams, and generic is used when empty
+	Format string `yaml:"format,omitempty"`
+	// Template is the text/template rendering each finding within the chat messages
+	Template string            `yaml:"template,omitempty"`
+	Headers  map[string]string `yaml:"headers,omitempty"`
+	// NewOnly limits the findings to the names never seen before the current enumeration
+	NewOnly bool `yaml:"new_only,omitempty"`
+	// Domains limits the findings to the names within these domains
+	Domains []string `yaml:"domains,omitempty"`
+	// RecordTypes limits the findings to the names with these DNS records, such as A or CNAME
+	RecordTypes []string `yaml:"record_types,omitempty"`
+	// BatchSize is the largest number of findings sent in a single request
+	BatchSize int `yaml:"batch_size,omitempty"`
+	// Interval is the number of seconds the findings are collected before being sent
+	Interval int `yaml:"interval,omitempty"`
+	// Retries is the number of times a failed request is repeated. A negative value disables the retries.
+	Retries int                  `yaml:"retries,omitempty"`
+	HTTP    *http.ClientSettings `yaml:"http,omitempty"`
+}
+
+// Validate checks that the settings describe a webhook that can receive the findings.
+func (s *Settings) Validate() error {
+	if !strings.HasPrefix(s.URL, "http://") && !strings.HasPrefix(s.URL, "https://") {
+		return fmt.Errorf("the webhook URL %q is not an HTTP or HTTPS URL", s.URL)
+	}
+
+	switch strings.ToLower(s.Format) {
+	case "", FormatGeneric, FormatSlack, FormatTeams:
+	default:
+		return fmt.Errorf("the %s webhook format is not known", s.Format)
+	}
+
+	if s.BatchSize < 0 || s.Interval < 0 {
+		return errors.New("the webhook batch size and interval cannot be negative")
+	}
+	if s.Template != "" {
+		if _, err := template.New("finding").Parse(s.Template); err != nil {
+			return fmt.Errorf("failed to parse the webhook template: %v", err)
+		}
+	}
+	return nil
+}
+
+// Record is a DNS record of the name identified by a Finding.
+type Record struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Finding is a name discovered by the enumeration and the records associated with it.
+type Finding struct {
+	Name       string    `json:"name"`
+	Domain     string    `json:"domain"`
+	New        bool      `json:"new"`
+	Records    []Record  `json:"records,omitempty"`
+	Sources    []string  `json:"sources,omitempty"`
+	Discovered time.Time `json:"discovered"`
+}
+
+// Notifier sends the findings of the enumeration to the webhooks in batches.
+type Notifier struct {
+	hooks []*webhook
+}
+
+// NewNotifier returns a Notifier sending the findings to the webhooks described by the settings.
+// The connections to the webhooks are made by the Dialer, and delivery failures are written to the logger.
+func NewNotifier(settings []*Settings, d *amassnet.Dialer, logger *log.Logger) (*Notifier, error) {
+	n := new(Notifier)
+
+	for i, s := range settings {
+		if err := s.Validate(); err != nil {
+			return nil, err
+		}
+
+		w, err := newWebhook(s, d, logger)
+		if err != nil {
+			return nil, err
+		}
+		if w.settings.Name == "" {
+			w.settings.Name = "webhook" + strconv.Itoa(i+1)
+		}
+		n.hooks = append(n.hooks, w)
+	}
+
+	for _, w := range n.hooks {
+		go w.run()
+	}
+	return n, nil
+}
+
+// Notify queues the findings for the webhooks that accept them.
+func (n *Notifier) Notify(findings ...*Finding) {
+	if n == nil {
+		return
+	}
+
+	for _, w := range n.hooks {
+		for _, f := range findings {
+			if f = w.accept(f); f != nil {
+				w.queue(f)
+			}
+		}
+	}
+}
+
+// Close sends the queued findings and stops the webhooks. The requests still failing after
+// a short time are abandoned, so the retries cannot hold up the shutdown.
+func (n *Notifier) Close() {
+	if n == nil {
+		return
+	}
+
+	for _, w := range n.hooks {
+		close(w.done)
+	}
+
+	t := time.NewTimer(closeTimeout)
+	defer t.Stop()
+	for _, w := range n.hooks {
+		select {
+		case <-w.finished:
+		case <-t.C:
+			for _, w := range n.hooks {
+				w.cancel()
+			}
+			<-w.finished
+		}
+	}
+	for _, w := range n.hooks {
+		w.cancel()
+	}
+}
+
+type webhook struct {
+	sync.Mutex
+	settings    Settings
+	tmpl        *template.Template
+	client      *http.Client
+	log         *log.Logger
+	domains     []string
+	recordTypes map[string]struct{}
+	pending     []*Finding
+	full        chan struct{}
+	done        chan struct{}
+	finished    chan struct{}
+	ctx         context.Context
+	cancel      context.CancelFunc
+}
+
+func newWebhook(s *Settings, d *amassnet.Dialer, logger *log.Logger) (*webhook, error) {
+	settings := *s
+	settings.Format = strings.ToLower(settings.Format)
+	if settings.Format == "" {
+		settings.Format = FormatGeneric
+	}
+	if settings.Template == "" {
+		settings.Template = DefaultTemplate
+	}
+	if settings.BatchSize == 0 {
+		settings.BatchSize = DefaultBatchSize
+	}
+	if settings.Interval == 0 {
+		settings.Interval = DefaultInterval
+	}
+	if settings.Retries == 0 {
+		settings.Retries = DefaultRetries
+	}
+
+	tmpl, err := template.New("finding").Parse(settings.Template)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the webhook template: %v", err)
+	}
+
+	// The webhook URLs often carry secrets, so the server certificates are verified by default
+	hs := settings.HTTP
+	if hs == nil {
+		hs = &http.ClientSettings{TLSVerify: true}
+	}
+	client, err := http.NewClient(hs, d)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the HTTP client for the webhook: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &webhook{
+		ctx:         ctx,
+		cancel:      cancel,
+		settings:    settings,
+		tmpl:        tmpl,
+		client:      client,
+		log:         logger,
+		recordTypes: make(map[string]struct{}),
+		full:        make(chan struct{}, 1),
+		done:        make(chan struct{}),
+		finished:    make(chan struct{}),
+	}
+	for _, d := range settings.Domains {
+		w.domains = append(w.domains, strings.ToLower(strings.Trim(d, ".")))
+	}
+	for _, t := range settings.RecordTypes {
+		w.recordTypes[RecordType(t)] = struct{}{}
+	}
+	return w, nil
+}
+
+// RecordType returns the DNS record type, such as CNAME, for a record type or graph relation name.
+func RecordType(t string) string {
+	return strings.ToUpper(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(t)), "_record"))
+}
+
+// accept returns the finding as it should be sent to the webhook, or nil when filtered out.
+func (w *webhook) accept(f *Finding) *Finding {
+	if w.settings.NewOnly && !f.New {
+		return nil
+	}
+
+	if len(w.domains) > 0 {
+		name := strings.ToLower(f.Name)
+
+		var found bool
+		for _, d := range w.domains {
+			if name == d || strings.HasSuffix(name, "."+d) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+
+	if len(w.recordTypes) == 0 {
+		return f
+	}
+
+	var records []Record
+	for _, rec := range f.Records {
+		if _, found := w.recordTypes[RecordType(rec.Type)]; found {
+			records = append(records, rec)
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	c := *f
+	c.Records = records
+	return &c
+}
+
+func (w *webhook) queue(f *Finding) {
+	w.Lock()
+	w.pending = append(w.pending, f)
+	full := len(w.pending) >= w.settings.BatchSize
+	w.Unlock()
+
+	if full {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (w *webhook) run() {
+	defer close(w.finished)
+
+	t := time.NewTicker(time.Duration(w.settings.Interval) * time.Second)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-w.done:
+			w.flush()
+			return
+		case <-w.full:
+			w.flush()
+		case <-t.C:
+			w.flush()
+		}
+	}
+}
+
+func (w *webhook) flush() {
+	for {
+		w.Lock()
+		num := len(w.pending)
+		if num > w.settings.BatchSize {
+			num = w.settings.BatchSize
+		}
+		batch := w.pending[:num]
+		w.pending = w.pending[num:]
+		w.Unlock()
+
+		if len(batch) == 0 {
+			return
+		}
+		if err := w.send(batch); err != nil && w.log != nil {
+			w.log.Printf("Notifications: failed to send %d findings to the %s webhook: %v", len(batch), w.settings.Name, err)
+		}
+	}
+}
+
+func (w *webhook) send(batch []*Finding) error {
+	body, err := w.payload(batch)
+	if err != nil {
+		return err
+	}
+
+	hdrs := http.Header{"Content-Type": "application/json"}
+	for k, v := range w.settings.Headers {
+		hdrs[k] = v
+	}
+
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(w.ctx, sendTimeout)
+		resp, err := w.client.RequestWebPage(ctx, &http.Request{
+			URL:    w.settings.URL,
+			Method: "POST",
+			Header: hdrs,
+			Body:   body,
+		})
+		cancel()
+
+		if err == nil {
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return nil
+			}
+			err = fmt.Errorf("the webhook returned %s", resp.Status)
+			// The client errors will not be fixed by sending the request again
+			if resp.StatusCode >= 400 && resp.StatusCode < 500 &&
+				resp.StatusCode != 408 && resp.StatusCode != 429 {
+				return err
+			}
+			if after, e := strconv.Atoi(resp.Header["Retry-After"]); e == nil && after > 0 {
+				delay = time.Duration(after) * time.Second
+			}
+		}
+		if attempt >= w.settings.Retries {
+			return err
+		}
+
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+		// The retries stop when the requests are abandoned by Close
+		t := time.NewTimer(delay)
+		select {
+		case <-w.ctx.Done():
+			t.Stop()
+			return err
+		case <-t.C:
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package notify
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type receiver struct {
+	sync.Mutex
+	server   *httptest.Server
+	bodies   []string
+	headers  []http.Header
+	failures int
+}
+
+func newReceiver(failures int) *receiver {
+	r := &receiver{failures: failures}
+
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.Lock()
+		defer r.Unlock()
+
+		if r.failures > 0 {
+			r.failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		body, _ := io.ReadAll(req.Body)
+		r.bodies = append(r.bodies, string(body))
+		r.headers = append(r.headers, req.Header.Clone())
+	}))
+	return r
+}
+
+func (r *receiver) received() []string {
+	r.Lock()
+	defer r.Unlock()
+
+	return append([]string(nil), r.bodies...)
+}
+
+func testFindings() []*Finding {
+	now := time.Now()
+
+	return []*Finding{
+		{
+			Name:       "www.owasp.org",
+			Domain:     "owasp.org",
+			New:        true,
+			Records:    []Record{{Type: "CNAME", Value: "owasp.org"}},
+			Sources:    []string{"crtsh"},
+			Discovered: now,
+		},
+		{
+			Name:       "owasp.org",
+			Domain:     "owasp.org",
+			Records:    []Record{{Type: "A", Value: "104.22.27.77"}, {Type: "NS", Value: "ns1.owasp.org"}},
+			Discovered: now,
+		},
+		{
+			Name:       "mail.example.com",
+			Domain:     "example.com",
+			New:        true,
+			Records:    []Record{{Type: "A", Value: "192.0.2.25"}},
+			Discovered: now,
+		},
+	}
+}
+
+func TestNotifierGeneric(t *testing.T) {
+	r := newReceiver(0)
+	defer r.server.Close()
+
+	n, err := NewNotifier([]*Settings{{
+		Name:      "monitor",
+		URL:       r.server.URL,
+		Headers:   map[string]string{"Authorization": "Bearer token"},
+		BatchSize: 2,
+	}}, nil, nil)
+	require.NoError(t, err)
+	n.Notify(testFindings()...)
+	n.Close()
+
+	bodies := r.received()
+	require.Len(t, bodies, 2)
+	require.Equal(t, "Bearer token", r.headers[0].Get("Authorization"))
+	require.Equal(t, "application/json", r.headers[0].Get("Content-Type"))
+
+	var names []string
+	for _, body := range bodies {
+		var msg genericMessage
+		require.NoError(t, json.Unmarshal([]byte(body), &msg))
+		require.Equal(t, "monitor", msg.Webhook)
+		require.Equal(t, len(msg.Findings), msg.Count)
+		for _, f := range msg.Findings {
+			names = append(names, f.Name)
+		}
+	}
+	require.ElementsMatch(t, []string{"www.owasp.org", "owasp.org", "mail.example.com"}, names)
+}
+
+func TestNotifierFilters(t *testing.T) {
+	r := newReceiver(0)
+	defer r.server.Close()
+
+	n, err := NewNotifier([]*Settings{{
+		URL:         r.server.URL,
+		Format:      "slack",
+		Template:    "{{.Name}} ({{range .Records}}{{.Type}} {{.Value}}{{end}})",
+		NewOnly:     true,
+		Domains:     []string{"owasp.org"},
+		RecordTypes: []string{"cname_record"},
+	}}, nil, nil)
+	require.NoError(t, err)
+	n.Notify(testFindings()...)
+	n.Close()
+
+	bodies := r.received()
+	require.Len(t, bodies, 1)
+
+	var msg slackMessage
+	require.NoError(t, json.Unmarshal([]byte(bodies[0]), &msg))
+	require.Equal(t, "*OWASP Amass discovered 1 name*\nwww.owasp.org (CNAME owasp.org)", msg.Text)
+}
+
+func TestNotifierTeams(t *testing.T) {
+	r := newReceiver(0)
+	defer r.server.Close()
+
+	n, err := NewNotifier([]*Settings{{
+		URL:         r.server.URL,
+		Format:      "teams",
+		RecordTypes: []string{"A"},
+	}}, nil, nil)
+	require.NoError(t, err)
+	n.Notify(testFindings()...)
+	n.Close()
+
+	bodies := r.received()
+	require.Len(t, bodies, 1)
+
+	var msg teamsMessage
+	require.NoError(t, json.Unmarshal([]byte(bodies[0]), &msg))
+	require.Equal(t, "MessageCard", msg.Type)
+	require.Equal(t, "OWASP Amass discovered 2 names", msg.Title)
+	require.Equal(t, "owasp.org A:104.22.27.77\n\nmail.example.com A:192.0.2.25", msg.Text)
+}
+
+func TestNotifierRetries(t *testing.T) {
+	delay := retryDelay
+	retryDelay = 10 * time.Millisecond
+	defer func() { retryDelay = delay }()
+
+	r := newReceiver(2)
+	defer r.server.Close()
+
+	n, err := NewNotifier([]*Settings{{URL: r.server.URL}}, nil, nil)
+	require.NoError(t, err)
+	n.Notify(testFindings()[0])
+	n.Close()
+	require.Len(t, r.received(), 1)
+
+	// The findings are dropped once the retries are exhausted
+	r = newReceiver(2)
+	defer r.server.Close()
+
+	n, err = NewNotifier([]*Settings{{URL: r.server.URL, Retries: 1}}, nil, nil)
+	require.NoError(t, err)
+	n.Notify(testFindings()[0])
+	n.Close()
+	require.Empty(t, r.received())
+}
+
+func TestNotifierCloseAbandonsRetries(t *testing.T) {
+	timeout := closeTimeout
+	closeTimeout = 100 * time.Millisecond
+	defer func() { closeTimeout = timeout }()
+
+	r := newReceiver(1000)
+	defer r.server.Close()
+
+	n, err := NewNotifier([]*Settings{{URL: r.server.URL, Retries: 10}}, nil, nil)
+	require.NoError(t, err)
+	n.Notify(testFindings()...)
+
+	start := time.Now()
+	n.Close()
+	require.Less(t, time.Since(start), 5*time.Second)
+	require.Empty(t, r.received())
+}
+
+func TestSettingsValidate(t *testing.T) {
+	require.NoError(t, (&Settings{URL: "https://hooks.slack.com/services/T/B/X", Format: "Slack"}).Validate())
+	require.Error(t, (&Settings{URL: "hooks.slack.com"}).Validate())
+	require.Error(t, (&Settings{URL: "https://example.com", Format: "irc"}).Validate())
+	require.Error(t, (&Settings{URL: "https://example.com", Template: "{{.Name"}).Validate())
+	require.Error(t, (&Settings{URL: "https://example.com", BatchSize: -1}).Validate())
+
+	_, err := NewNotifier([]*Settings{{URL: "ftp://example.com"}}, nil, nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,94 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// genericMessage is the body sent to the generic webhooks.
+type genericMessage struct {
+	Webhook  string     `json:"webhook"`
+	Time     time.Time  `json:"time"`
+	Count    int        `json:"count"`
+	Text     string     `json:"text"`
+	Findings []*Finding `json:"findings"`
+}
+
+// slackMessage is the body accepted by the Slack-compatible incoming webhooks.
+type slackMessage struct {
+	Text string `json:"text"`
+}
+
+// teamsMessage is the MessageCard accepted by the Microsoft Teams incoming webhooks.
+type teamsMessage struct {
+	Type    string `json:"@type"`
+	Context string `json:"@context"`
+	Summary string `json:"summary"`
+	Title   string `json:"title"`
+	Text    string `json:"text"`
+}
+
+func (w *webhook) payload(batch []*Finding) (string, error) {
+	lines, err := w.render(batch)
+	if err != nil {
+		return "", err
+	}
+
+	title := fmt.Sprintf("OWASP Amass discovered %d names", len(batch))
+	if len(batch) == 1 {
+		title = "OWASP Amass discovered 1 name"
+	}
+
+	var msg interface{}
+	switch w.settings.Format {
+	case FormatSlack:
+		msg = &slackMessage{Text: "*" + title + "*\n" + strings.Join(lines, "\n")}
+	case FormatTeams:
+		msg = &teamsMessage{
+			Type:    "MessageCard",
+			Context: "https://schema.org/extensions",
+			Summary: title,
+			Title:   title,
+			// Teams requires blank lines between the paragraphs of the card
+			Text: strings.Join(lines, "\n\n"),
+		}
+	default:
+		msg = &genericMessage{
+			Webhook:  w.settings.Name,
+			Time:     time.Now().UTC(),
+			Count:    len(batch),
+			Text:     strings.Join(lines, "\n"),
+			Findings: batch,
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// The templates commonly produce characters, such as '>', that should not be escaped in chat messages
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(msg); err != nil {
+		return "", fmt.Errorf("failed to encode the webhook message: %v", err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func (w *webhook) render(batch []*Finding) ([]string, error) {
+	var lines []string
+
+	for _, f := range batch {
+		var buf bytes.Buffer
+
+		if err := w.tmpl.Execute(&buf, f); err != nil {
+			return nil, fmt.Errorf("failed to render the webhook template: %v", err)
+		}
+		lines = append(lines, buf.String())
+	}
+	return lines, nil
+}
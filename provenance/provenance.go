@@ -0,0 +1,189 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package provenance
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// Attribution records that a data source discovered an asset, such as a FQDN or IP address.
+type Attribution struct {
+	Asset     string    `json:"asset"`
+	Source    string    `json:"source"`
+	Tag       string    `json:"tag"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// attribution is the table, kept in the graph database, that links assets to their data sources.
+type attribution struct {
+	ID        uint64    `gorm:"primaryKey;autoIncrement:true"`
+	Asset     string    `gorm:"uniqueIndex:idx_asset_source;not null"`
+	Source    string    `gorm:"uniqueIndex:idx_asset_source;not null"`
+	Tag       string    `gorm:"not null"`
+	FirstSeen time.Time `gorm:"index;not null"`
+	LastSeen  time.Time `gorm:"index;not null"`
+}
+
+// TableName implements the gorm Tabler interface.
+func (attribution) TableName() string {
+	return "asset_sources"
+}
+
+// Store keeps the data sources that discovered each asset, and when they first and last found it.
+type Store struct {
+	sync.Mutex
+	db *gorm.DB
+}
+
+// NewStore returns a Store for the graph database identified by the system ("memory", "local"
+// or "postgres") and the data source name, which is the path of the local SQLite database.
+func NewStore(system, dsn string) (*Store, error) {
+	var dialector gorm.Dialector
+
+	switch system {
+	case "memory":
+		dialector = sqlite.Open(fmt.Sprintf("file:provenance%d?mode=memory&cache=shared", rand.Int31()))
+	case "local":
+		// The graph shares the database file, so wait for its writes to complete
+		dialector = sqlite.Open(dsn + "?_pragma=busy_timeout(10000)")
+	case "postgres":
+		dialector = postgres.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database system: %s", system)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the provenance database: %v", err)
+	}
+	if err := db.AutoMigrate(&attribution{}); err != nil {
+		return nil, fmt.Errorf("failed to create the provenance table: %v", err)
+	}
+	return &Store{db: db}, nil
+}
+
+// Close releases the database connections held by the Store.
+func (s *Store) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Add records that the source discovered the asset at the provided time. The first time
+// is kept when the asset was previously attributed to the source.
+func (s *Store) Add(asset, source, tag string, seen time.Time) error {
+	asset = strings.ToLower(strings.TrimSpace(asset))
+	if asset == "" || source == "" {
+		return errors.New("the asset and source must be provided")
+	}
+	if seen.IsZero() {
+		seen = time.Now()
+	}
+	seen = seen.UTC()
+
+	s.Lock()
+	defer s.Unlock()
+
+	// A single statement avoids holding the database lock, which is shared with the graph
+	err := s.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "asset"}, {Name: "source"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"tag": gorm.Expr("CASE WHEN excluded.tag <> '' THEN excluded.tag ELSE asset_sources.tag END"),
+			"first_seen": gorm.Expr("CASE WHEN excluded.first_seen < asset_sources.first_seen " +
+				"THEN excluded.first_seen ELSE asset_sources.first_seen END"),
+			"last_seen": gorm.Expr("CASE WHEN excluded.last_seen > asset_sources.last_seen " +
+				"THEN excluded.last_seen ELSE asset_sources.last_seen END"),
+		}),
+	}).Create(&attribution{
+		Asset:     asset,
+		Source:    source,
+		Tag:       tag,
+		FirstSeen: seen,
+		LastSeen:  seen,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to store the %s attribution for %s: %v", source, asset, err)
+	}
+	return nil
+}
+
+// Sources returns the attributions of the asset, ordered by the time each source first
+// discovered it, so the first element identifies the source that found the asset first.
+func (s *Store) Sources(asset string) ([]*Attribution, error) {
+	var recs []attribution
+
+	asset = strings.ToLower(strings.TrimSpace(asset))
+	if err := s.db.Where("asset = ?", asset).Find(&recs).Error; err != nil {
+		return nil, fmt.Errorf("failed to obtain the sources of %s: %v", asset, err)
+	}
+	return convert(recs), nil
+}
+
+// Attributions returns all the attributions last seen at or after the provided time.
+// If since.IsZero(), the parameter is ignored.
+func (s *Store) Attributions(since time.Time) ([]*Attribution, error) {
+	var recs []attribution
+
+	tx := s.db
+	if !since.IsZero() {
+		tx = tx.Where("last_seen >= ?", since.UTC())
+	}
+	if err := tx.Find(&recs).Error; err != nil {
+		return nil, fmt.Errorf("failed to obtain the attributions: %v", err)
+	}
+	return convert(recs), nil
+}
+
+func convert(recs []attribution) []*Attribution {
+	results := make([]*Attribution, 0, len(recs))
+
+	for _, rec := range recs {
+		results = append(results, &Attribution{
+			Asset:     rec.Asset,
+			Source:    rec.Source,
+			Tag:       rec.Tag,
+			FirstSeen: rec.FirstSeen,
+			LastSeen:  rec.LastSeen,
+		})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Asset != results[j].Asset {
+			return results[i].Asset < results[j].Asset
+		}
+		if !results[i].FirstSeen.Equal(results[j].FirstSeen) {
+			return results[i].FirstSeen.Before(results[j].FirstSeen)
+		}
+		return results[i].Source < results[j].Source
+	})
+	return results
+}
+
+// First returns the attribution of the source that discovered the asset first, or nil
+// when the asset has not been attributed to any source.
+func First(attrs []*Attribution) *Attribution {
+	var first *Attribution
+
+	for _, a := range attrs {
+		if first == nil || a.FirstSeen.Before(first.FirstSeen) {
+			first = a
+		}
+	}
+	return first
+}
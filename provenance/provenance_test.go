@@ -0,0 +1,65 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package provenance
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	s, err := NewStore("memory", "")
+	require.NoError(t, err)
+	defer s.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, s.Add("www.owasp.org", "HackerTarget", "api", now.Add(time.Minute)))
+	require.NoError(t, s.Add("WWW.owasp.org", "crtsh", "cert", now))
+	require.NoError(t, s.Add("www.owasp.org", "HackerTarget", "api", now.Add(2*time.Minute)))
+	require.NoError(t, s.Add("104.22.27.77", "DNS", "dns", now))
+	require.Error(t, s.Add("", "DNS", "dns", now))
+
+	attrs, err := s.Sources("www.owasp.org")
+	require.NoError(t, err)
+	require.Len(t, attrs, 2)
+	require.Equal(t, "crtsh", attrs[0].Source)
+	require.Equal(t, "HackerTarget", attrs[1].Source)
+	require.True(t, attrs[1].FirstSeen.Equal(now.Add(time.Minute)))
+	require.True(t, attrs[1].LastSeen.Equal(now.Add(2*time.Minute)))
+	require.Equal(t, "crtsh", First(attrs).Source)
+
+	all, err := s.Attributions(now.Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	require.Equal(t, "HackerTarget", all[0].Source)
+
+	all, err = s.Attributions(time.Time{})
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+}
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "amass.sqlite")
+	first := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+
+	s, err := NewStore("local", path)
+	require.NoError(t, err)
+	require.NoError(t, s.Add("www.owasp.org", "crtsh", "cert", first))
+	require.NoError(t, s.Close())
+
+	s, err = NewStore("local", path)
+	require.NoError(t, err)
+	defer s.Close()
+	require.NoError(t, s.Add("www.owasp.org", "crtsh", "cert", time.Now()))
+
+	attrs, err := s.Sources("www.owasp.org")
+	require.NoError(t, err)
+	require.Len(t, attrs, 1)
+	require.True(t, attrs[0].FirstSeen.Equal(first))
+	require.True(t, attrs[0].LastSeen.After(first))
+}
@@ -6,6 +6,7 @@ package requests
 
 import (
 	"net"
+	"sort"
 	"strings"
 	"sync"
 
@@ -34,16 +35,28 @@ var reservedCIDRs = []string{
 	"192.0.0.0/29",
 }
 
+// ASNStore is a persistent source of ASN and netblock information consulted by the ASNCache.
+type ASNStore interface {
+	AddrSearch(addr string) *ASNRequest
+	ASNSearch(asn int) *ASNRequest
+	DescriptionSearch(s string) []*ASNRequest
+}
+
 // ASNCache builds a cache of ASN and netblock information.
 type ASNCache struct {
 	sync.RWMutex
-	cache  map[int]*ASNRequest
-	ranger cidranger.Ranger
+	cache    map[int]*ASNRequest
+	prefixes map[string]*cacheRangerEntry
+	ranger   cidranger.Ranger
+	store    ASNStore
+	loaded   map[int]struct{}
 }
 
+// cacheRangerEntry holds every origin AS announcing the prefix.
 type cacheRangerEntry struct {
-	IPNet net.IPNet
-	Data  *ASNRequest
+	IPNet   net.IPNet
+	Origins []*ASNRequest
+	stored  bool
 }
 
 // The reserved network address ranges
@@ -64,23 +77,37 @@ func (e *cacheRangerEntry) Network() net.IPNet {
 // NewASNCache returns an empty ASNCache for saving and searching ASN and netblock information.
 func NewASNCache() *ASNCache {
 	return &ASNCache{
-		cache:  make(map[int]*ASNRequest),
-		ranger: cidranger.NewPCTrieRanger(),
+		cache:    make(map[int]*ASNRequest),
+		prefixes: make(map[string]*cacheRangerEntry),
+		ranger:   cidranger.NewPCTrieRanger(),
+		loaded:   make(map[int]struct{}),
 	}
 }
 
+// SetStore assigns the ASNStore searched by the ASNCache for information not already cached.
+func (c *ASNCache) SetStore(store ASNStore) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.store = store
+}
+
 // Update saves the information in ASNRequest into the ASNCache.
 func (c *ASNCache) Update(req *ASNRequest) {
 	c.Lock()
 	defer c.Unlock()
 
+	c.update(req)
+}
+
+func (c *ASNCache) update(req *ASNRequest) []*cacheRangerEntry {
 	as, found := c.cache[req.ASN]
 	if !found {
-		c.cache[req.ASN] = req
+		as = req
+		c.cache[req.ASN] = as
 		if len(req.Netblocks) == 0 {
 			req.Netblocks = []string{req.Prefix}
 		}
-		return
 	}
 
 	// This is additional information for an ASN entry
@@ -97,7 +124,8 @@ func (c *ASNCache) Update(req *ASNRequest) {
 		as.Description = req.Description
 	}
 
-	// Add new CIDR ranges to cached netblocks
+	var entries []*cacheRangerEntry
+	// Add new CIDR ranges to cached netblocks and the prefixes announced by the ASN
 	for _, cidr := range append([]string{req.Prefix}, req.Netblocks...) {
 		var known bool
 
@@ -111,7 +139,39 @@ func (c *ASNCache) Update(req *ASNRequest) {
 		if !known {
 			as.Netblocks = append(as.Netblocks, cidr)
 		}
+		if entry := c.insertPrefix(cidr, as); entry != nil {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+func (c *ASNCache) insertPrefix(cidr string, as *ASNRequest) *cacheRangerEntry {
+	_, ipnet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil
+	}
+	if ones, _ := ipnet.Mask.Size(); ones == 0 {
+		return nil
+	}
+
+	key := ipnet.String()
+	entry, found := c.prefixes[key]
+	if !found {
+		entry = &cacheRangerEntry{IPNet: *ipnet}
+		if err := c.ranger.Insert(entry); err != nil {
+			return nil
+		}
+		c.prefixes[key] = entry
+	}
+
+	for _, origin := range entry.Origins {
+		if origin.ASN == as.ASN {
+			return entry
+		}
 	}
+	entry.Origins = append(entry.Origins, as)
+	return entry
 }
 
 // DescriptionSearch matches the provided string against description fields in the cache and
@@ -120,6 +180,12 @@ func (c *ASNCache) DescriptionSearch(s string) []*ASNRequest {
 	c.Lock()
 	defer c.Unlock()
 
+	if c.store != nil {
+		for _, req := range c.store.DescriptionSearch(s) {
+			c.loadASN(req)
+		}
+	}
+
 	var matches []*ASNRequest
 	for _, entry := range c.cache {
 		if strings.Contains(entry.Description, s) {
@@ -135,11 +201,26 @@ func (c *ASNCache) ASNSearch(asn int) *ASNRequest {
 	c.Lock()
 	defer c.Unlock()
 
+	if _, found := c.loaded[asn]; !found && c.store != nil {
+		c.loaded[asn] = struct{}{}
+
+		if req := c.store.ASNSearch(asn); req != nil {
+			c.update(req)
+		}
+	}
 	return c.cache[asn]
 }
 
-// AddrSearch returns the cached ASN / netblock info that the addr parameter belongs in,
-// or nil when not found in the cache.
+func (c *ASNCache) loadASN(req *ASNRequest) {
+	if _, found := c.loaded[req.ASN]; !found {
+		c.loaded[req.ASN] = struct{}{}
+		c.update(req)
+	}
+}
+
+// AddrSearch returns the cached ASN / netblock info for the most specific prefix that the addr
+// parameter belongs in, or nil when not found in the cache. Other origins of the prefix and the
+// origins of less specific prefixes containing the address are provided as alternates.
 func (c *ASNCache) AddrSearch(addr string) *ASNRequest {
 	c.Lock()
 	defer c.Unlock()
@@ -159,73 +240,92 @@ func (c *ASNCache) AddrSearch(addr string) *ASNRequest {
 		}
 	}
 
-	entry := c.searchRangerData(ip)
-	if entry == nil {
-		c.rawData2Ranger(ip)
-
-		entry = c.searchRangerData(ip)
-		if entry == nil {
-			return nil
-		}
+	entries := c.searchRangerData(ip)
+	// The store has already flattened its ranges to the most specific
+	if c.store != nil && (len(entries) == 0 || !entries[0].stored) && c.storeData2Ranger(addr) {
+		entries = c.searchRangerData(ip)
+	}
+	if len(entries) == 0 {
+		return nil
 	}
 
+	entry := entries[0]
+	as := entry.Origins[0]
 	prefix := entry.IPNet.String()
 	netblocks := stringset.New(prefix)
 	defer netblocks.Close()
 
-	netblocks.InsertMany(entry.Data.Netblocks...)
+	netblocks.InsertMany(as.Netblocks...)
 	return &ASNRequest{
-		Address:     addr,
-		ASN:         entry.Data.ASN,
-		CC:          entry.Data.CC,
-		Prefix:      prefix,
-		Netblocks:   netblocks.Slice(),
-		Description: entry.Data.Description,
+		Address:        addr,
+		ASN:            as.ASN,
+		CC:             as.CC,
+		Prefix:         prefix,
+		Registry:       as.Registry,
+		AllocationDate: as.AllocationDate,
+		Netblocks:      netblocks.Slice(),
+		Description:    as.Description,
+		Alternates:     alternateOrigins(addr, entries),
 	}
 }
 
-func (c *ASNCache) searchRangerData(ip net.IP) *cacheRangerEntry {
-	if entries, err := c.ranger.ContainingNetworks(ip); err == nil {
-		for _, e := range entries {
-			if entry, ok := e.(*cacheRangerEntry); ok {
-				return entry
-			}
+func alternateOrigins(addr string, entries []*cacheRangerEntry) []*ASNRequest {
+	var alts []*ASNRequest
+
+	for i, entry := range entries {
+		origins := entry.Origins
+		if i == 0 {
+			origins = origins[1:]
+		}
+
+		for _, as := range origins {
+			alts = append(alts, &ASNRequest{
+				Address:     addr,
+				ASN:         as.ASN,
+				CC:          as.CC,
+				Prefix:      entry.IPNet.String(),
+				Registry:    as.Registry,
+				Description: as.Description,
+			})
 		}
 	}
-	return nil
+	return alts
 }
 
-func (c *ASNCache) rawData2Ranger(ip net.IP) {
-	var cidr *net.IPNet
-	var data *ASNRequest
-
-	for _, record := range c.cache {
-		for _, netblock := range record.Netblocks {
-			_, ipnet, err := net.ParseCIDR(netblock)
-			if err != nil {
-				continue
-			}
-			if ones, _ := ipnet.Mask.Size(); ones == 0 {
-				continue
-			}
+// searchRangerData returns the entries containing the IP address, ordered from the most specific.
+func (c *ASNCache) searchRangerData(ip net.IP) []*cacheRangerEntry {
+	var entries []*cacheRangerEntry
 
-			if ipnet.Contains(ip) {
-				// Select the smallest CIDR
-				if cidr != nil && compareCIDRSizes(cidr, ipnet) == 1 {
-					continue
-				}
-				data = record
-				cidr = ipnet
+	if found, err := c.ranger.ContainingNetworks(ip); err == nil {
+		for _, e := range found {
+			if entry, ok := e.(*cacheRangerEntry); ok && len(entry.Origins) > 0 {
+				entries = append(entries, entry)
 			}
 		}
 	}
 
-	if cidr != nil {
-		_ = c.ranger.Insert(&cacheRangerEntry{
-			IPNet: *cidr,
-			Data:  data,
-		})
+	sort.SliceStable(entries, func(i, j int) bool {
+		return compareCIDRSizes(&entries[i].IPNet, &entries[j].IPNet) == 1
+	})
+	return entries
+}
+
+func (c *ASNCache) storeData2Ranger(addr string) bool {
+	req := c.store.AddrSearch(addr)
+	if req == nil {
+		return false
+	}
+
+	alts := req.Alternates
+	req.Alternates = nil
+	entries := c.update(req)
+	for _, alt := range alts {
+		entries = append(entries, c.update(alt)...)
+	}
+	for _, entry := range entries {
+		entry.stored = true
 	}
+	return len(entries) > 0
 }
 
 func compareCIDRSizes(first, second *net.IPNet) int {
@@ -6,6 +6,7 @@ package requests
 
 import (
 	"net"
+	"strings"
 	"testing"
 	"time"
 
@@ -113,6 +114,41 @@ func TestAddrSearch(t *testing.T) {
 	}
 }
 
+func TestLongestPrefixMatch(t *testing.T) {
+	cache := NewASNCache()
+
+	cache.Update(&ASNRequest{
+		ASN:         3356,
+		Prefix:      "4.0.0.0/9",
+		Description: "LEVEL3",
+	})
+	cache.Update(&ASNRequest{
+		ASN:         6167,
+		Prefix:      "4.4.0.0/16",
+		Description: "CELLCO-PART",
+	})
+	cache.Update(&ASNRequest{
+		ASN:         22394,
+		Prefix:      "4.4.0.0/16",
+		Description: "CELLCO",
+	})
+
+	entry := cache.AddrSearch("4.4.4.4")
+	require.NotNil(t, entry)
+	require.Equal(t, 6167, entry.ASN)
+	require.Equal(t, "4.4.0.0/16", entry.Prefix)
+	require.Len(t, entry.Alternates, 2)
+	require.Equal(t, 22394, entry.Alternates[0].ASN)
+	require.Equal(t, "4.4.0.0/16", entry.Alternates[0].Prefix)
+	require.Equal(t, 3356, entry.Alternates[1].ASN)
+	require.Equal(t, "4.0.0.0/9", entry.Alternates[1].Prefix)
+
+	entry = cache.AddrSearch("4.5.0.1")
+	require.NotNil(t, entry)
+	require.Equal(t, 3356, entry.ASN)
+	require.Empty(t, entry.Alternates)
+}
+
 func TestIsReservedAddress(t *testing.T) {
 	t.Parallel()
 	tests := []struct {
@@ -144,3 +180,71 @@ func TestIsReservedAddress(t *testing.T) {
 	}
 
 }
+
+type testStore struct {
+	addrs    int
+	asns     int
+	requests []*ASNRequest
+}
+
+func (s *testStore) AddrSearch(addr string) *ASNRequest {
+	s.addrs++
+	ip := net.ParseIP(addr)
+
+	for _, req := range s.requests {
+		if _, ipnet, err := net.ParseCIDR(req.Prefix); err == nil && ipnet.Contains(ip) {
+			return &ASNRequest{Address: addr, ASN: req.ASN, Prefix: req.Prefix, Description: req.Description}
+		}
+	}
+	return nil
+}
+
+func (s *testStore) ASNSearch(asn int) *ASNRequest {
+	s.asns++
+
+	for _, req := range s.requests {
+		if req.ASN == asn {
+			return &ASNRequest{ASN: req.ASN, Prefix: req.Prefix, Description: req.Description}
+		}
+	}
+	return nil
+}
+
+func (s *testStore) DescriptionSearch(str string) []*ASNRequest {
+	var results []*ASNRequest
+
+	for _, req := range s.requests {
+		if strings.Contains(req.Description, str) {
+			results = append(results, &ASNRequest{ASN: req.ASN, Prefix: req.Prefix, Description: req.Description})
+		}
+	}
+	return results
+}
+
+func TestStoreFallback(t *testing.T) {
+	store := &testStore{requests: []*ASNRequest{
+		{ASN: 26808, Prefix: "72.237.4.0/24", Description: "UTICA-COLLEGE"},
+		{ASN: 13335, Prefix: "104.16.0.0/13", Description: "CLOUDFLARENET"},
+	}}
+	cache := NewASNCache()
+	cache.SetStore(store)
+
+	entry := cache.AddrSearch("72.237.4.113")
+	require.NotNil(t, entry)
+	require.Equal(t, 26808, entry.ASN)
+	require.Equal(t, "72.237.4.0/24", entry.Prefix)
+	// The second search must be served by the cache
+	require.NotNil(t, cache.AddrSearch("72.237.4.10"))
+	require.Equal(t, 1, store.addrs)
+	require.Nil(t, cache.AddrSearch("8.8.8.8"))
+
+	entry = cache.ASNSearch(13335)
+	require.NotNil(t, entry)
+	require.Equal(t, "CLOUDFLARENET", entry.Description)
+	require.NotNil(t, cache.ASNSearch(13335))
+	require.Equal(t, 1, store.asns)
+
+	matches := cache.DescriptionSearch("UTICA")
+	require.Len(t, matches, 1)
+	require.Equal(t, []string{"72.237.4.0/24"}, matches[0].Netblocks)
+}
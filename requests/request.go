@@ -27,6 +27,24 @@ const (
 	OutputTopic        = "amass:output"
 )
 
+// The tags identifying the types of the sources that discover names and addresses.
+const (
+	NONE     = "none"
+	ALT      = "alt"
+	API      = "api"
+	ARCHIVE  = "archive"
+	AXFR     = "axfr"
+	BRUTE    = "brute"
+	CERT     = "cert"
+	CRAWL    = "crawl"
+	DNS      = "dns"
+	EXTERNAL = "ext"
+	IMPORT   = "import"
+	RIR      = "rir"
+	SCRAPE   = "scrape"
+	USER     = "user"
+)
+
 // DNSAnswer is the type used by Amass to represent a DNS record.
 type DNSAnswer struct {
 	Name string `json:"name"`
@@ -37,17 +55,23 @@ type DNSAnswer struct {
 
 // DNSRequest handles data needed throughout Service processing of a DNS name.
 type DNSRequest struct {
-	Name    string
-	Domain  string
-	Records []DNSAnswer
+	Name       string
+	Domain     string
+	Records    []DNSAnswer
+	Tag        string
+	Source     string
+	Discovered time.Time
 }
 
 // Clone implements pipeline Data.
 func (d *DNSRequest) Clone() pipeline.Data {
 	return &DNSRequest{
-		Name:    d.Name,
-		Domain:  d.Domain,
-		Records: append([]DNSAnswer(nil), d.Records...),
+		Name:       d.Name,
+		Domain:     d.Domain,
+		Records:    append([]DNSAnswer(nil), d.Records...),
+		Tag:        d.Tag,
+		Source:     d.Source,
+		Discovered: d.Discovered,
 	}
 }
 
@@ -160,17 +184,23 @@ func (z *ZoneXFRRequest) MarkAsProcessed() {}
 
 // AddrRequest handles data needed throughout Service processing of a network address.
 type AddrRequest struct {
-	Address string
-	InScope bool
-	Domain  string
+	Address    string
+	InScope    bool
+	Domain     string
+	Tag        string
+	Source     string
+	Discovered time.Time
 }
 
 // Clone implements pipeline Data.
 func (a *AddrRequest) Clone() pipeline.Data {
 	return &AddrRequest{
-		Address: a.Address,
-		InScope: a.InScope,
-		Domain:  a.Domain,
+		Address:    a.Address,
+		InScope:    a.InScope,
+		Domain:     a.Domain,
+		Tag:        a.Tag,
+		Source:     a.Source,
+		Discovered: a.Discovered,
 	}
 }
 
@@ -200,6 +230,7 @@ type ASNRequest struct {
 	AllocationDate time.Time
 	Description    string
 	Netblocks      []string
+	Alternates     []*ASNRequest
 }
 
 // Clone implements pipeline Data.
@@ -213,6 +244,7 @@ func (a *ASNRequest) Clone() pipeline.Data {
 		AllocationDate: a.AllocationDate,
 		Description:    a.Description,
 		Netblocks:      a.Netblocks,
+		Alternates:     a.Alternates,
 	}
 }
 
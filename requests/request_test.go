@@ -25,6 +25,16 @@ func TestDNSRequestClone(t *testing.T) {
 				Records: append([]DNSAnswer(nil), []DNSAnswer{}...),
 			},
 		},
+		{
+			name: "Attributed test",
+			req: DNSRequest{
+				Name:       "www.example.com",
+				Domain:     "example.com",
+				Tag:        CERT,
+				Source:     "crtsh",
+				Discovered: time.Now(),
+			},
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
@@ -32,6 +42,9 @@ func TestDNSRequestClone(t *testing.T) {
 			require.Equal(t, clone.Name, test.req.Name)
 			require.Equal(t, clone.Domain, test.req.Domain)
 			require.Equal(t, clone.Records, test.req.Records)
+			require.Equal(t, clone.Tag, test.req.Tag)
+			require.Equal(t, clone.Source, test.req.Source)
+			require.Equal(t, clone.Discovered, test.req.Discovered)
 		})
 	}
 
@@ -240,9 +253,12 @@ func TestAddrRequestClone(t *testing.T) {
 		{
 			name: "Simple test",
 			req: AddrRequest{
-				Address: "8.8.8.8",
-				Domain:  "www.example.com",
-				InScope: true,
+				Address:    "8.8.8.8",
+				Domain:     "www.example.com",
+				InScope:    true,
+				Tag:        DNS,
+				Source:     "DNS",
+				Discovered: time.Now(),
 			},
 		},
 	}
@@ -252,6 +268,9 @@ func TestAddrRequestClone(t *testing.T) {
 			require.Equal(t, clone.Address, test.req.Address)
 			require.Equal(t, clone.Domain, test.req.Domain)
 			require.Equal(t, clone.InScope, test.req.InScope)
+			require.Equal(t, clone.Tag, test.req.Tag)
+			require.Equal(t, clone.Source, test.req.Source)
+			require.Equal(t, clone.Discovered, test.req.Discovered)
 		})
 	}
 }
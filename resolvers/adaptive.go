@@ -0,0 +1,144 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+const (
+	// AdaptInterval is how often the adaptive limits are adjusted to the outcomes of the queries.
+	AdaptInterval = time.Second
+	// the ratio of failed queries that causes the limit to be decreased
+	aimdFailureRatio = 0.1
+	// the factor applied to the limit when too many queries fail
+	aimdDecreaseFactor = 0.5
+	// the number of queries measured before the limit is adjusted
+	aimdMinSamples = 10
+	// the number of intervals without failures needed to return to the maximum from zero
+	aimdIncreaseSteps = 20
+)
+
+// AIMD is an additive increase, multiplicative decrease controller for a rate or in-flight limit.
+// The outcomes of the queries are counted during each interval. The limit is cut by half when more
+// than a tenth of the queries timed out, or returned SERVFAIL or REFUSED, and increases by a step
+// otherwise, so the limit backs off quickly from overloaded resolvers and recovers gradually.
+type AIMD struct {
+	sync.Mutex
+	min       int
+	max       int
+	limit     float64
+	successes int
+	failures  int
+}
+
+// NewAIMD returns an AIMD controller starting from the maximum limit.
+func NewAIMD(min, max int) *AIMD {
+	if max < 1 {
+		max = 1
+	}
+	if min < 1 {
+		min = 1
+	}
+	if min > max {
+		min = max
+	}
+
+	return &AIMD{
+		min:   min,
+		max:   max,
+		limit: float64(max),
+	}
+}
+
+// Record counts the outcome of a query using the response code. The resolve.RcodeNoResponse code
+// counts the query as timed out.
+func (a *AIMD) Record(rcode int) {
+	a.Lock()
+	defer a.Unlock()
+
+	switch rcode {
+	case resolve.RcodeNoResponse, dns.RcodeServerFailure, dns.RcodeRefused:
+		a.failures++
+	default:
+		a.successes++
+	}
+}
+
+// Adjust ends the measurement interval and returns the updated limit. The limit is not changed
+// until enough queries have been measured.
+func (a *AIMD) Adjust() int {
+	a.Lock()
+	defer a.Unlock()
+
+	total := a.successes + a.failures
+	if total < aimdMinSamples {
+		return int(a.limit)
+	}
+
+	if float64(a.failures)/float64(total) > aimdFailureRatio {
+		a.limit *= aimdDecreaseFactor
+	} else {
+		step := float64(a.max) / aimdIncreaseSteps
+		if step < 1 {
+			step = 1
+		}
+		a.limit += step
+	}
+	a.clamp()
+
+	a.successes = 0
+	a.failures = 0
+	return int(a.limit)
+}
+
+// Limit returns the current limit.
+func (a *AIMD) Limit() int {
+	a.Lock()
+	defer a.Unlock()
+
+	return int(a.limit)
+}
+
+// Max returns the limit the controller cannot exceed.
+func (a *AIMD) Max() int {
+	a.Lock()
+	defer a.Unlock()
+
+	return a.max
+}
+
+// SetMax changes the limit the controller cannot exceed, such as when resolvers join or leave a
+// pool. A limit that was not decreased follows the new maximum.
+func (a *AIMD) SetMax(max int) {
+	a.Lock()
+	defer a.Unlock()
+
+	if max < 1 {
+		max = 1
+	}
+	if a.limit >= float64(a.max) {
+		a.limit = float64(max)
+	}
+	a.max = max
+	if a.min > max {
+		a.min = max
+	}
+	a.clamp()
+}
+
+// clamp must be called while holding the lock.
+func (a *AIMD) clamp() {
+	if a.limit > float64(a.max) {
+		a.limit = float64(a.max)
+	}
+	if a.limit < float64(a.min) {
+		a.limit = float64(a.min)
+	}
+}
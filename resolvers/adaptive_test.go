@@ -0,0 +1,108 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+func recordN(a *AIMD, rcode, n int) {
+	for i := 0; i < n; i++ {
+		a.Record(rcode)
+	}
+}
+
+func TestAIMD(t *testing.T) {
+	a := NewAIMD(2, 100)
+	require.Equal(t, 100, a.Limit())
+
+	// the limit does not change before enough queries were measured
+	recordN(a, resolve.RcodeNoResponse, aimdMinSamples-1)
+	require.Equal(t, 100, a.Adjust())
+
+	// timeouts, SERVFAIL and REFUSED cut the limit by half
+	recordN(a, dns.RcodeServerFailure, 1)
+	require.Equal(t, 50, a.Adjust())
+	recordN(a, dns.RcodeRefused, 5)
+	recordN(a, dns.RcodeSuccess, 5)
+	require.Equal(t, 25, a.Adjust())
+
+	// a few failures are tolerated and the limit increases by a step
+	recordN(a, dns.RcodeNameError, 19)
+	recordN(a, resolve.RcodeNoResponse, 1)
+	require.Equal(t, 30, a.Adjust())
+
+	// the limit stays within the minimum and maximum
+	for i := 0; i < 10; i++ {
+		recordN(a, resolve.RcodeNoResponse, aimdMinSamples)
+		a.Adjust()
+	}
+	require.Equal(t, 2, a.Limit())
+	for i := 0; i < 2*aimdIncreaseSteps; i++ {
+		recordN(a, dns.RcodeSuccess, aimdMinSamples)
+		a.Adjust()
+	}
+	require.Equal(t, 100, a.Limit())
+
+	// a limit that was not decreased follows the maximum
+	a.SetMax(200)
+	require.Equal(t, 200, a.Limit())
+	a.SetMax(10)
+	require.Equal(t, 10, a.Limit())
+	recordN(a, dns.RcodeServerFailure, aimdMinSamples)
+	require.Equal(t, 5, a.Adjust())
+	a.SetMax(50)
+	require.Equal(t, 5, a.Limit())
+	require.Equal(t, 50, a.Max())
+}
+
+func TestAdaptiveRates(t *testing.T) {
+	good := testServer(t, honestHandler)
+	bad := testServer(t, refusedHandler)
+
+	p := NewPool()
+	defer p.Stop()
+	require.NoError(t, p.AddResolvers(100, good, bad))
+	require.Equal(t, 200, p.QPS())
+	require.Equal(t, 200, p.EffectiveQPS())
+
+	ctx := context.Background()
+	for _, res := range p.activeResolvers() {
+		for i := 0; i < aimdMinSamples; i++ {
+			_, _ = p.exchangeWith(ctx, res, resolve.QueryMsg("www.example.com", dns.TypeA))
+		}
+	}
+	p.adapt()
+
+	for _, s := range p.Stats() {
+		require.Equal(t, 100, s.QPS)
+		if s.Address == bad {
+			require.Equal(t, 50, s.EffectiveQPS)
+		} else {
+			require.Equal(t, 100, s.EffectiveQPS)
+		}
+	}
+	// half of the queries sent by the pool failed
+	require.Equal(t, 100, p.EffectiveQPS())
+	require.Equal(t, 200, p.QPS())
+
+	// the resolvers are selected in proportion to their rates
+	var picks int
+	for i := 0; i < 3000; i++ {
+		if p.randResolver().address == bad {
+			picks++
+		}
+	}
+	require.InDelta(t, 1000, picks, 150)
+
+	// the rate is limited by the maximum set for the pool
+	p.SetMaxQPS(40)
+	require.Equal(t, 40, p.EffectiveQPS())
+}
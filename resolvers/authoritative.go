@@ -0,0 +1,531 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/resolve"
+)
+
+// DefaultAuthQPS is the default number of queries per second sent to each authoritative name server.
+const DefaultAuthQPS = 20
+
+const (
+	// the EDNS0 UDP payload size recommended by the DNS flag day 2020
+	authUDPSize = 1232
+	// the number of referrals followed for a single query
+	maxReferrals = 5
+)
+
+// AuthServers sends the queries for names within known zones directly to the authoritative
+// name servers of the zone. Delegations to sub-zones are followed as they are discovered, and
+// queries that cannot be answered by the authoritative servers are sent to the fallback pool.
+type AuthServers struct {
+	sync.Mutex
+	done     chan struct{}
+	log      *log.Logger
+	qps      int
+	port     string
+	timeout  time.Duration
+	zones    map[string]*zone
+	lame     map[string]string
+	servers  map[string]*resolver
+	fallback *Pool
+}
+
+type zone struct {
+	name    string
+	servers []*resolver
+	lame    map[*resolver]struct{}
+}
+
+// NewAuthServers initializes an AuthServers that limits the queries sent to each name server
+// to the provided number per second. The fallback pool resolves the name server addresses and
+// handles the queries that cannot be sent to the authoritative servers.
+func NewAuthServers(qps int, fallback *Pool) *AuthServers {
+	return &AuthServers{
+		done:     make(chan struct{}),
+		log:      log.New(io.Discard, "", 0),
+		qps:      qps,
+		port:     "53",
+		timeout:  DefaultTimeout,
+		zones:    make(map[string]*zone),
+		lame:     make(map[string]string),
+		servers:  make(map[string]*resolver),
+		fallback: fallback,
+	}
+}
+
+// SetLogger assigns a new logger to the authoritative servers.
+func (a *AuthServers) SetLogger(l *log.Logger) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.log = l
+}
+
+// SetTimeout updates the amount of time to wait for response messages.
+func (a *AuthServers) SetTimeout(d time.Duration) {
+	a.Lock()
+	defer a.Unlock()
+
+	a.timeout = d
+}
+
+// Stop releases the resources held by the authoritative servers.
+func (a *AuthServers) Stop() {
+	a.Lock()
+	defer a.Unlock()
+
+	select {
+	case <-a.done:
+		return
+	default:
+	}
+	close(a.done)
+
+	for _, res := range a.servers {
+		res.close()
+	}
+}
+
+// Stats returns the statistics collected for each authoritative name server.
+func (a *AuthServers) Stats() []*Stats {
+	a.Lock()
+	defer a.Unlock()
+
+	var stats []*Stats
+	for _, res := range a.servers {
+		stats = append(stats, res.snapshot())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Address < stats[j].Address
+	})
+	return stats
+}
+
+// AddZone registers the name servers for the zone. Name server host names are resolved using the
+// fallback pool. When the zone is already known with different servers, the delegation is replaced.
+func (a *AuthServers) AddZone(ctx context.Context, name string, nameservers ...string) error {
+	name = strings.ToLower(resolve.RemoveLastDot(name))
+
+	var addrs []string
+	for _, ns := range nameservers {
+		ns = strings.ToLower(resolve.RemoveLastDot(ns))
+
+		if ip := net.ParseIP(ns); ip != nil {
+			addrs = append(addrs, ip.String())
+			continue
+		}
+		addrs = append(addrs, a.resolveNameServer(ctx, ns)...)
+	}
+	if len(addrs) == 0 {
+		return fmt.Errorf("failed to obtain the addresses of the %s name servers", name)
+	}
+
+	return a.setZone(name, addrs)
+}
+
+func (a *AuthServers) setZone(name string, addrs []string) error {
+	a.Lock()
+	defer a.Unlock()
+
+	z := &zone{
+		name: name,
+		lame: make(map[*resolver]struct{}),
+	}
+	for _, addr := range addrs {
+		addr = net.JoinHostPort(addr, a.port)
+
+		res, found := a.servers[addr]
+		if !found {
+			res = newAuthServer(addr, a.qps, a.dialer())
+			a.servers[addr] = res
+		}
+		z.servers = append(z.servers, res)
+	}
+
+	list := serverList(z.servers)
+	// do not use the name servers again after they stopped serving the zone
+	if a.lame[name] == list {
+		return fmt.Errorf("the delegation for %s is lame", name)
+	}
+	if cur, found := a.zones[name]; found {
+		if sameServers(cur.servers, z.servers) {
+			return nil
+		}
+		a.log.Printf("The delegation for %s has changed: %s", name, list)
+	} else {
+		a.log.Printf("Sending queries for %s to the authoritative name servers: %s", name, list)
+	}
+	a.zones[name] = z
+	return nil
+}
+
+// dialer returns the Dialer of the fallback pool, so the queries sent to the authoritative
+// servers are routed the same way as the other queries.
+func (a *AuthServers) dialer() *amassnet.Dialer {
+	if a.fallback == nil {
+		return nil
+	}
+	return a.fallback.Dialer()
+}
+
+func sameServers(a, b []*resolver) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	set := make(map[*resolver]struct{}, len(a))
+	for _, res := range a {
+		set[res] = struct{}{}
+	}
+	for _, res := range b {
+		if _, found := set[res]; !found {
+			return false
+		}
+	}
+	return true
+}
+
+func serverList(servers []*resolver) string {
+	var addrs []string
+
+	for _, res := range servers {
+		addrs = append(addrs, res.address)
+	}
+	sort.Strings(addrs)
+	return strings.Join(addrs, ", ")
+}
+
+func (a *AuthServers) resolveNameServer(ctx context.Context, ns string) []string {
+	if a.fallback == nil {
+		return nil
+	}
+
+	var addrs []string
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		resp, err := a.fallback.QueryBlocking(ctx, resolve.QueryMsg(ns, qtype))
+		if err != nil || resp.Rcode != dns.RcodeSuccess {
+			continue
+		}
+
+		for _, ans := range resolve.AnswersByType(resolve.ExtractAnswers(resp), qtype) {
+			if ip := net.ParseIP(ans.Data); ip != nil {
+				addrs = append(addrs, ip.String())
+			}
+		}
+	}
+	return addrs
+}
+
+// Zone returns the most specific known zone containing the name, or an empty string.
+func (a *AuthServers) Zone(name string) string {
+	if z := a.findZone(name); z != nil {
+		return z.name
+	}
+	return ""
+}
+
+func (a *AuthServers) findZone(name string) *zone {
+	a.Lock()
+	defer a.Unlock()
+
+	labels := strings.Split(strings.ToLower(resolve.RemoveLastDot(name)), ".")
+	for i := 0; i < len(labels); i++ {
+		if z, found := a.zones[strings.Join(labels[i:], ".")]; found {
+			return z
+		}
+	}
+	return nil
+}
+
+func (a *AuthServers) removeZone(z *zone, reason string) {
+	a.Lock()
+	defer a.Unlock()
+
+	if cur, found := a.zones[z.name]; found && cur == z {
+		delete(a.zones, z.name)
+		a.lame[z.name] = serverList(z.servers)
+		a.log.Printf("The delegation for %s has changed: %s", z.name, reason)
+	}
+}
+
+// Query sends the provided DNS message to the authoritative servers and returns the response on the channel.
+func (a *AuthServers) Query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
+	select {
+	case <-ctx.Done():
+	case <-a.done:
+	default:
+		go a.query(ctx, msg, ch)
+		return
+	}
+
+	errNoResponse(msg, ch)
+}
+
+// QueryBlocking sends the provided DNS message to the authoritative servers and returns the response.
+func (a *AuthServers) QueryBlocking(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	ch := make(chan *dns.Msg, 1)
+	a.Query(ctx, msg, ch)
+
+	select {
+	case <-ctx.Done():
+		return msg, errors.New("the context expired")
+	case resp := <-ch:
+		if resp == nil || resp.Rcode == resolve.RcodeNoResponse {
+			return resp, errors.New("query failed")
+		}
+		return resp, nil
+	}
+}
+
+func (a *AuthServers) query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
+	name := msg.Question[0].Name
+
+	for i := 0; i < maxReferrals; i++ {
+		z := a.findZone(name)
+		if z == nil {
+			break
+		}
+
+		resp, err := a.zoneExchange(ctx, z, msg)
+		if err != nil {
+			break
+		}
+		// follow the delegation to the sub-zone
+		if child, ns := referral(z.name, name, resp); child != "" {
+			if err := a.addDelegation(ctx, child, ns, resp.Extra); err != nil {
+				break
+			}
+			continue
+		}
+
+		ch <- resp
+		return
+	}
+
+	if a.fallback != nil {
+		a.fallback.Query(ctx, msg, ch)
+		return
+	}
+	errNoResponse(msg, ch)
+}
+
+// zoneExchange sends the message to the name servers of the zone until one provides a usable response.
+func (a *AuthServers) zoneExchange(ctx context.Context, z *zone, msg *dns.Msg) (*dns.Msg, error) {
+	a.Lock()
+	timeout := a.timeout
+	var servers []*resolver
+	for _, res := range z.servers {
+		if _, lame := z.lame[res]; !lame {
+			servers = append(servers, res)
+		}
+	}
+	a.Unlock()
+
+	var last *dns.Msg
+	for _, idx := range rand.Perm(len(servers)) {
+		res := servers[idx]
+
+		resp, err := exchange(ctx, res, msg, timeout, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			continue
+		}
+		if a.lameResponse(z, msg, resp) {
+			a.markLame(z, res)
+			continue
+		}
+		if resp.Rcode == dns.RcodeServerFailure {
+			last = resp
+			continue
+		}
+		return resp, nil
+	}
+
+	if last != nil {
+		return last, nil
+	}
+	return nil, fmt.Errorf("the name servers for %s did not respond", z.name)
+}
+
+// lameResponse checks if the name server is no longer authoritative for the zone.
+func (a *AuthServers) lameResponse(z *zone, msg, resp *dns.Msg) bool {
+	if resp.Rcode == dns.RcodeRefused {
+		return true
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return false
+	}
+	if resp.Authoritative {
+		return false
+	}
+	child, _ := referral(z.name, msg.Question[0].Name, resp)
+	return child == ""
+}
+
+func (a *AuthServers) markLame(z *zone, res *resolver) {
+	a.Lock()
+	z.lame[res] = struct{}{}
+	remaining := len(z.servers) - len(z.lame)
+	a.Unlock()
+
+	if remaining <= 0 {
+		a.removeZone(z, "the name servers are no longer authoritative")
+	}
+}
+
+// referral returns the delegated sub-zone and the name servers when the response is a referral.
+func referral(zone, qname string, resp *dns.Msg) (string, []string) {
+	if resp.Rcode != dns.RcodeSuccess || resp.Authoritative || len(resp.Answer) > 0 {
+		return "", nil
+	}
+
+	qname = strings.ToLower(resolve.RemoveLastDot(qname))
+	var child string
+	var nameservers []string
+	for _, rr := range resp.Ns {
+		ns, ok := rr.(*dns.NS)
+		if !ok {
+			continue
+		}
+
+		owner := strings.ToLower(resolve.RemoveLastDot(ns.Hdr.Name))
+		if owner == zone || !dns.IsSubDomain(zone, owner) || !dns.IsSubDomain(owner, qname) {
+			continue
+		}
+		if child != "" && owner != child {
+			continue
+		}
+
+		child = owner
+		nameservers = append(nameservers, strings.ToLower(resolve.RemoveLastDot(ns.Ns)))
+	}
+	return child, nameservers
+}
+
+// addDelegation registers the sub-zone using the glue records provided with the referral.
+func (a *AuthServers) addDelegation(ctx context.Context, child string, nameservers []string, extra []dns.RR) error {
+	glue := make(map[string][]string)
+	for _, rr := range extra {
+		owner := strings.ToLower(resolve.RemoveLastDot(rr.Header().Name))
+
+		switch v := rr.(type) {
+		case *dns.A:
+			glue[owner] = append(glue[owner], v.A.String())
+		case *dns.AAAA:
+			glue[owner] = append(glue[owner], v.AAAA.String())
+		}
+	}
+
+	var servers []string
+	for _, ns := range nameservers {
+		if addrs, found := glue[ns]; found {
+			servers = append(servers, addrs...)
+		} else {
+			servers = append(servers, ns)
+		}
+	}
+	return a.AddZone(ctx, child, servers...)
+}
+
+func newAuthServer(addr string, qps int, d *amassnet.Dialer) *resolver {
+	return newResolverWith(addr, qps, newAuthExchanger(addr, d))
+}
+
+// authExchanger sends non-recursive queries and falls back when EDNS or UDP cannot be used.
+type authExchanger struct {
+	sync.Mutex
+	addr   string
+	dialer *amassnet.Dialer
+	noEDNS bool
+	udp    *dns.Client
+	tcp    *dns.Client
+}
+
+func newAuthExchanger(addr string, d *amassnet.Dialer) *authExchanger {
+	return &authExchanger{
+		addr:   addr,
+		dialer: d,
+		udp:    &dns.Client{Net: "udp", UDPSize: authUDPSize},
+		tcp:    &dns.Client{Net: "tcp"},
+	}
+}
+
+// Exchange implements the Exchanger interface.
+func (e *authExchanger) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, time.Duration, error) {
+	e.Lock()
+	edns := !e.noEDNS
+	e.Unlock()
+	// the queries are sent over TCP when a proxy is in use, since it cannot carry UDP
+	if e.dialer.Proxied() {
+		return exchangeConn(ctx, e.dialer, e.tcp, authQueryMsg(msg, edns), e.addr)
+	}
+
+	resp, rtt, err := exchangeConn(ctx, e.dialer, e.udp, authQueryMsg(msg, edns), e.addr)
+	// servers that do not implement EDNS respond with errors (RFC 6891, section 7)
+	if err == nil && edns && ednsRejected(resp) {
+		var rtt2 time.Duration
+
+		resp, rtt2, err = exchangeConn(ctx, e.dialer, e.udp, authQueryMsg(msg, false), e.addr)
+		rtt += rtt2
+		if err == nil && !ednsRejected(resp) {
+			edns = false
+			e.Lock()
+			e.noEDNS = true
+			e.Unlock()
+		}
+	}
+	if err != nil {
+		return nil, rtt, err
+	}
+	// try again over TCP when the response did not fit in the datagram
+	if resp.Truncated {
+		var trtt time.Duration
+
+		resp, trtt, err = exchangeConn(ctx, e.dialer, e.tcp, authQueryMsg(msg, edns), e.addr)
+		rtt += trtt
+	}
+	return resp, rtt, err
+}
+
+func ednsRejected(resp *dns.Msg) bool {
+	return resp.Rcode == dns.RcodeFormatError || resp.Rcode == dns.RcodeNotImplemented || resp.Rcode == dns.RcodeBadVers
+}
+
+// authQueryMsg returns a copy of the message without recursion desired and with the selected EDNS settings.
+func authQueryMsg(msg *dns.Msg, edns bool) *dns.Msg {
+	q := msg.Copy()
+	q.RecursionDesired = false
+
+	var extra []dns.RR
+	for _, rr := range q.Extra {
+		if rr.Header().Rrtype != dns.TypeOPT {
+			extra = append(extra, rr)
+		}
+	}
+	q.Extra = extra
+
+	if edns {
+		q.SetEdns0(authUDPSize, false)
+	}
+	return q
+}
@@ -0,0 +1,192 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+// testAuthServer starts UDP and TCP DNS servers on the address and returns the port number.
+func testAuthServer(t *testing.T, ip, port string, handler dns.HandlerFunc) string {
+	pc, err := net.ListenPacket("udp", net.JoinHostPort(ip, port))
+	require.NoError(t, err)
+	_, port, _ = net.SplitHostPort(pc.LocalAddr().String())
+
+	l, err := net.Listen("tcp", net.JoinHostPort(ip, port))
+	require.NoError(t, err)
+
+	for _, srv := range []*dns.Server{
+		{PacketConn: pc, Handler: handler},
+		{Listener: l, Handler: handler},
+	} {
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+
+		go func(s *dns.Server) { _ = s.ActivateAndServe() }(srv)
+		<-started
+		t.Cleanup(func() { _ = srv.Shutdown() })
+	}
+	return port
+}
+
+func authReply(req *dns.Msg, rcode int, answers ...dns.RR) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetRcode(req, rcode)
+	m.Authoritative = true
+	m.Answer = append(m.Answer, answers...)
+	return m
+}
+
+func aRecord(name, addr string) dns.RR {
+	return &dns.A{
+		Hdr: dns.RR_Header{Name: dns.Fqdn(name), Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP(addr),
+	}
+}
+
+func TestAuthServersDelegation(t *testing.T) {
+	var refuse atomic.Bool
+
+	parent := func(w dns.ResponseWriter, req *dns.Msg) {
+		name := req.Question[0].Name
+
+		switch {
+		case req.RecursionDesired:
+			_ = w.WriteMsg(authReply(req, dns.RcodeRefused))
+		case name == "www.example.com.":
+			_ = w.WriteMsg(authReply(req, dns.RcodeSuccess, aRecord(name, "192.0.2.1")))
+		case dns.IsSubDomain("sub.example.com.", name):
+			m := new(dns.Msg)
+			m.SetReply(req)
+			m.Ns = append(m.Ns, &dns.NS{
+				Hdr: dns.RR_Header{Name: "sub.example.com.", Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 60},
+				Ns:  "ns1.sub.example.com.",
+			})
+			m.Extra = append(m.Extra, aRecord("ns1.sub.example.com", "127.0.0.2"))
+			_ = w.WriteMsg(m)
+		default:
+			_ = w.WriteMsg(authReply(req, dns.RcodeNameError))
+		}
+	}
+	child := func(w dns.ResponseWriter, req *dns.Msg) {
+		name := req.Question[0].Name
+
+		switch {
+		case refuse.Load():
+			_ = w.WriteMsg(authReply(req, dns.RcodeRefused))
+		case name == "host.sub.example.com.":
+			_ = w.WriteMsg(authReply(req, dns.RcodeSuccess, aRecord(name, "192.0.2.10")))
+		default:
+			_ = w.WriteMsg(authReply(req, dns.RcodeNameError))
+		}
+	}
+	recursive := func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.RecursionAvailable = true
+		m.Answer = append(m.Answer, aRecord(req.Question[0].Name, "198.51.100.7"))
+		_ = w.WriteMsg(m)
+	}
+
+	port := testAuthServer(t, "127.0.0.1", "0", parent)
+	_ = testAuthServer(t, "127.0.0.2", port, child)
+
+	fallback := NewPool()
+	defer fallback.Stop()
+	require.NoError(t, fallback.AddResolvers(100, testServer(t, recursive)))
+
+	auth := NewAuthServers(100, fallback)
+	defer auth.Stop()
+	auth.port = port
+
+	ctx := context.Background()
+	require.NoError(t, auth.AddZone(ctx, "example.com", "127.0.0.1"))
+	require.Equal(t, "example.com", auth.Zone("foo.example.com"))
+	require.Equal(t, "", auth.Zone("example.org"))
+
+	resp, err := auth.QueryBlocking(ctx, resolve.QueryMsg("www.example.com", dns.TypeA))
+	require.NoError(t, err)
+	require.True(t, resp.Authoritative)
+	require.Equal(t, "192.0.2.1", resp.Answer[0].(*dns.A).A.String())
+
+	resp, err = auth.QueryBlocking(ctx, resolve.QueryMsg("missing.example.com", dns.TypeA))
+	require.NoError(t, err)
+	require.Equal(t, dns.RcodeNameError, resp.Rcode)
+
+	// The referral to the sub-zone is followed using the glue records
+	resp, err = auth.QueryBlocking(ctx, resolve.QueryMsg("host.sub.example.com", dns.TypeA))
+	require.NoError(t, err)
+	require.True(t, resp.Authoritative)
+	require.Equal(t, "192.0.2.10", resp.Answer[0].(*dns.A).A.String())
+	require.Equal(t, "sub.example.com", auth.Zone("host.sub.example.com"))
+
+	// Names outside the known zones are sent to the fallback pool
+	resp, err = auth.QueryBlocking(ctx, resolve.QueryMsg("www.example.org", dns.TypeA))
+	require.NoError(t, err)
+	require.Equal(t, "198.51.100.7", resp.Answer[0].(*dns.A).A.String())
+
+	// The sub-zone is removed once the name server stops serving it
+	refuse.Store(true)
+	resp, err = auth.QueryBlocking(ctx, resolve.QueryMsg("host.sub.example.com", dns.TypeA))
+	require.NoError(t, err)
+	require.Equal(t, "198.51.100.7", resp.Answer[0].(*dns.A).A.String())
+	require.Equal(t, "example.com", auth.Zone("host.sub.example.com"))
+
+	stats := auth.Stats()
+	require.Len(t, stats, 2)
+	require.Equal(t, "127.0.0.1:"+port, stats[0].Address)
+	require.Equal(t, "127.0.0.2:"+port, stats[1].Address)
+	require.Equal(t, uint64(1), stats[1].Rcodes["REFUSED"])
+}
+
+func TestAuthServersTransportFallbacks(t *testing.T) {
+	handler := func(w dns.ResponseWriter, req *dns.Msg) {
+		// the server does not implement EDNS and sends large responses
+		if req.IsEdns0() != nil {
+			_ = w.WriteMsg(authReply(req, dns.RcodeFormatError))
+			return
+		}
+		if w.LocalAddr().Network() == "udp" {
+			m := authReply(req, dns.RcodeSuccess)
+			m.Truncated = true
+			_ = w.WriteMsg(m)
+			return
+		}
+
+		var answers []dns.RR
+		for i := 1; i <= 50; i++ {
+			answers = append(answers, aRecord(req.Question[0].Name, net.IPv4(192, 0, 2, byte(i)).String()))
+		}
+		_ = w.WriteMsg(authReply(req, dns.RcodeSuccess, answers...))
+	}
+	port := testAuthServer(t, "127.0.0.1", "0", handler)
+
+	auth := NewAuthServers(100, nil)
+	defer auth.Stop()
+	auth.port = port
+
+	ctx := context.Background()
+	require.NoError(t, auth.AddZone(ctx, "example.com", "127.0.0.1"))
+
+	for i := 0; i < 2; i++ {
+		resp, err := auth.QueryBlocking(ctx, resolve.QueryMsg("big.example.com", dns.TypeA))
+		require.NoError(t, err)
+		require.Equal(t, dns.RcodeSuccess, resp.Rcode)
+		require.Len(t, resp.Answer, 50)
+	}
+
+	xchg := auth.servers["127.0.0.1:"+port].xchg.(*authExchanger)
+	require.True(t, xchg.noEDNS)
+	// Without a fallback pool, names outside the zones cannot be resolved
+	_, err := auth.QueryBlocking(ctx, resolve.QueryMsg("www.example.org", dns.TypeA))
+	require.Error(t, err)
+}
@@ -0,0 +1,107 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"context"
+	"sync"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+// The domains used to build names that should not exist when checking for NXDOMAIN hijacking.
+var hijackCheckDomains = []string{"owasp.org", "google.com", "example.com"}
+
+// ClientSubnetCheck ensures that all the resolvers in the pool respond to the query
+// and do not send the EDNS client subnet information.
+func (p *Pool) ClientSubnetCheck() {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for _, res := range p.activeResolvers() {
+		wg.Add(1)
+
+		go func(res *resolver) {
+			defer wg.Done()
+
+			if reason := p.clientSubnetCheck(ctx, res); reason != "" {
+				p.remove(res, reason)
+			}
+		}(res)
+	}
+	wg.Wait()
+}
+
+func (p *Pool) clientSubnetCheck(ctx context.Context, res *resolver) string {
+	var resp *dns.Msg
+	// give resolvers one additional chance to respond
+	for i := 0; i < 2 && resp == nil; i++ {
+		resp, _ = p.exchangeWith(ctx, res, resolve.QueryMsg("o-o.myaddr.l.google.com", dns.TypeTXT))
+	}
+	if resp == nil {
+		return ReasonNoResponse
+	}
+	// check if the resolver responded, but did not return a successful response
+	if resp.Rcode != dns.RcodeSuccess || (!resp.Authoritative && !resp.RecursionAvailable) {
+		return ReasonClientSubnet
+	}
+	// check if the response included the expected record
+	if len(resolve.AnswersByType(resolve.ExtractAnswers(resp), dns.TypeTXT)) == 0 {
+		return ReasonClientSubnet
+	}
+	return ""
+}
+
+// HijackCheck removes the resolvers that return answers for names that do not exist. When the verify
+// pool is provided, only names that it confirms to not exist are used for the check.
+func (p *Pool) HijackCheck(ctx context.Context, verify *Pool) {
+	p.hijackCheck(ctx, verify, hijackCheckDomains)
+}
+
+func (p *Pool) hijackCheck(ctx context.Context, verify *Pool, domains []string) {
+	var names []string
+
+	for _, d := range domains {
+		name := resolve.UnlikelyName(d)
+		if name == "" {
+			continue
+		}
+		if verify != nil {
+			if resp, err := verify.QueryBlocking(ctx, resolve.QueryMsg(name, dns.TypeA)); err != nil || resp.Rcode != dns.RcodeNameError {
+				continue
+			}
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, res := range p.activeResolvers() {
+		wg.Add(1)
+
+		go func(res *resolver) {
+			defer wg.Done()
+
+			var hijacked bool
+			for _, name := range names {
+				resp, err := p.exchangeWith(ctx, res, resolve.QueryMsg(name, dns.TypeA))
+				if err != nil || resp.Rcode != dns.RcodeSuccess {
+					continue
+				}
+				if len(resolve.AnswersByType(resolve.ExtractAnswers(resp), dns.TypeA)) > 0 {
+					res.stats.hijack()
+					hijacked = true
+				}
+			}
+			if hijacked {
+				p.remove(res, ReasonHijack)
+			}
+		}(res)
+	}
+	wg.Wait()
+}
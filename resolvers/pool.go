@@ -0,0 +1,578 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/caffix/queue"
+	"github.com/miekg/dns"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/resolve"
+	"go.uber.org/ratelimit"
+)
+
+// DefaultTimeout is the amount of time the pool waits for a response message by default.
+const DefaultTimeout = 2 * time.Second
+
+const thresholdCheckInterval = 3 * time.Second
+
+// Pool is a pool of DNS resolvers managed for brute forcing using random selection.
+// The pool tracks the health of each resolver and records why resolvers are removed.
+type Pool struct {
+	sync.Mutex
+	done      chan struct{}
+	log       *log.Logger
+	all       []*resolver
+	active    []*resolver
+	rmap      map[string]*resolver
+	detector  *resolver
+	wildcards map[string]*wildcard
+	queue     queue.Queue
+	qps       int
+	maxSet    bool
+	rate      ratelimit.Limiter
+	ctrl      *AIMD
+	effective int
+	weights   []int
+	servRates *resolve.RateTracker
+	timeout   time.Duration
+	options   *resolve.ThresholdOptions
+	tlsConfig *tls.Config
+	dialer    *amassnet.Dialer
+}
+
+type request struct {
+	Ctx    context.Context
+	Msg    *dns.Msg
+	Result chan *dns.Msg
+}
+
+// NewPool initializes a Pool.
+func NewPool() *Pool {
+	p := &Pool{
+		done:      make(chan struct{}),
+		log:       log.New(io.Discard, "", 0),
+		rmap:      make(map[string]*resolver),
+		wildcards: make(map[string]*wildcard),
+		queue:     queue.NewQueue(),
+		timeout:   DefaultTimeout,
+		options:   new(resolve.ThresholdOptions),
+		ctrl:      NewAIMD(1, 1),
+	}
+
+	go p.enforceMaxQPS()
+	go p.thresholdChecks()
+	go p.adaptRates()
+	return p
+}
+
+// Len returns the number of resolvers in the pool that have not been removed.
+func (p *Pool) Len() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return len(p.active)
+}
+
+// SetLogger assigns a new logger to the resolver pool.
+func (p *Pool) SetLogger(l *log.Logger) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.log = l
+}
+
+// SetRateTracker assigns the tracker that rate limits the queries sent for each name server.
+func (p *Pool) SetRateTracker(rt *resolve.RateTracker) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.servRates = rt
+}
+
+// SetTimeout updates the amount of time this pool will wait for response messages.
+func (p *Pool) SetTimeout(d time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.timeout = d
+}
+
+// QPS returns the maximum queries per second provided by the resolver pool.
+func (p *Pool) QPS() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.qps
+}
+
+// EffectiveQPS returns the queries per second currently sent by the pool, after the rates were
+// adapted to the timeouts and failures of the resolvers.
+func (p *Pool) EffectiveQPS() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.effective
+}
+
+// SetMaxQPS allows a preferred maximum number of queries per second to be specified for the pool.
+func (p *Pool) SetMaxQPS(qps int) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.qps = qps
+	p.maxSet = qps > 0
+	p.updateRate()
+}
+
+// SetThresholdOptions updates the settings used for discontinuing use of a resolver due to poor performance.
+func (p *Pool) SetThresholdOptions(opt *resolve.ThresholdOptions) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.options = opt
+}
+
+// SetTLSConfig assigns the TLS configuration used by DNS-over-HTTPS and DNS-over-TLS resolvers
+// added to the pool after the call.
+func (p *Pool) SetTLSConfig(c *tls.Config) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.tlsConfig = c
+}
+
+// SetDialer assigns the Dialer that makes the connections of the resolvers added to the pool
+// after the call. The resolvers make direct connections when no Dialer is assigned.
+func (p *Pool) SetDialer(d *amassnet.Dialer) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.dialer = d
+}
+
+// Dialer returns the Dialer that makes the connections of the resolvers in the pool.
+func (p *Pool) Dialer() *amassnet.Dialer {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.dialer
+}
+
+// AddResolvers initializes and adds new resolvers to the pool of resolvers. The addresses can be IP
+// addresses, DNS-over-TLS addresses (tls://ip:853) or DNS-over-HTTPS URLs (https://host/dns-query),
+// and each resolver is limited to the provided number of queries per second, unless the entry
+// selects its own QPS (see ParseResolver).
+func (p *Pool) AddResolvers(qps int, addrs ...string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if qps == 0 {
+		return errors.New("failed to provide a maximum number of queries per second greater than zero")
+	}
+
+	for _, addr := range addrs {
+		if res := p.newResolver(qps, addr); res != nil {
+			p.active = append(p.active, res)
+			if !p.maxSet {
+				p.qps += res.qps
+			}
+		}
+	}
+	// create the new rate limiter for the updated QPS
+	p.updateRate()
+	return nil
+}
+
+// AddExchanger adds a resolver to the pool that sends the queries using the provided Exchanger,
+// such as the remote workers resolving names on behalf of the pool. The name identifies the
+// resolver in the statistics of the pool, and the resolver is limited to qps queries per second.
+func (p *Pool) AddExchanger(name string, qps int, x Exchanger) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if qps == 0 {
+		return errors.New("failed to provide a maximum number of queries per second greater than zero")
+	}
+	if x == nil {
+		return errors.New("failed to provide an exchanger")
+	}
+	if _, found := p.rmap[name]; found {
+		return fmt.Errorf("failed to add the exchanger: %s is already in the pool", name)
+	}
+
+	res := newResolverWith(name, qps, x)
+	p.rmap[name] = res
+	p.all = append(p.all, res)
+	p.active = append(p.active, res)
+	if !p.maxSet {
+		p.qps += qps
+	}
+	p.updateRate()
+	return nil
+}
+
+// newResolver must be called while holding the pool lock.
+func (p *Pool) newResolver(qps int, entry string) *resolver {
+	addr, n, err := ParseResolver(entry)
+	if err != nil {
+		p.log.Printf("%v", err)
+		return nil
+	}
+	if n > 0 {
+		qps = n
+	}
+	// check that this address will not create a duplicate resolver
+	if _, found := p.rmap[addr]; found {
+		return nil
+	}
+
+	res := newResolver(addr, qps, p.tlsConfig, p.dialer)
+	if res == nil {
+		return nil
+	}
+
+	p.rmap[addr] = res
+	p.all = append(p.all, res)
+	return res
+}
+
+// Stop will release resources for the resolver pool and all add resolvers.
+func (p *Pool) Stop() {
+	p.Lock()
+	select {
+	case <-p.done:
+		p.Unlock()
+		return
+	default:
+	}
+	close(p.done)
+	rt := p.servRates
+	all := append([]*resolver(nil), p.all...)
+	p.Unlock()
+
+	if rt != nil {
+		rt.Stop()
+	}
+	for _, res := range all {
+		res.close()
+	}
+	// release the requests remaining on the queue
+	p.queue.Process(func(element interface{}) {
+		if req, ok := element.(*request); ok {
+			errNoResponse(req.Msg, req.Result)
+		}
+	})
+}
+
+// Query queues the provided DNS message and returns the response on the provided channel.
+func (p *Pool) Query(ctx context.Context, msg *dns.Msg, ch chan *dns.Msg) {
+	if msg == nil {
+		ch <- msg
+		return
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-p.done:
+	default:
+		p.Lock()
+		rt := p.servRates
+		p.Unlock()
+
+		if rt != nil {
+			rt.Take(msg.Question[0].Name)
+		}
+		p.queue.Append(&request{
+			Ctx:    ctx,
+			Msg:    msg,
+			Result: ch,
+		})
+		return
+	}
+
+	errNoResponse(msg, ch)
+}
+
+// QueryChan queues the provided DNS message and sends the response on the returned channel.
+func (p *Pool) QueryChan(ctx context.Context, msg *dns.Msg) chan *dns.Msg {
+	ch := make(chan *dns.Msg, 1)
+	p.Query(ctx, msg, ch)
+	return ch
+}
+
+// QueryBlocking queues the provided DNS message and returns the associated response message.
+func (p *Pool) QueryBlocking(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	select {
+	case <-ctx.Done():
+		return msg, errors.New("the context expired")
+	default:
+	}
+
+	ch := p.QueryChan(ctx, msg)
+
+	select {
+	case <-ctx.Done():
+		return msg, errors.New("the context expired")
+	case resp := <-ch:
+		var err error
+		if resp == nil {
+			err = errors.New("query failed")
+		}
+		return resp, err
+	}
+}
+
+func (p *Pool) enforceMaxQPS() {
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-p.queue.Signal():
+		}
+
+		p.Lock()
+		rate := p.rate
+		p.Unlock()
+		if rate != nil {
+			rate.Take()
+		}
+
+		e, ok := p.queue.Next()
+		if !ok {
+			continue
+		}
+		if req, ok := e.(*request); ok {
+			if res := p.randResolver(); res != nil {
+				go p.exchange(res, req)
+				continue
+			}
+			errNoResponse(req.Msg, req.Result)
+		}
+	}
+}
+
+// randResolver selects the resolvers in proportion to their adapted rates.
+func (p *Pool) randResolver() *resolver {
+	p.Lock()
+	defer p.Unlock()
+
+	l := len(p.active)
+	if l == 0 {
+		return nil
+	}
+	if len(p.weights) == l && p.weights[l-1] > 0 {
+		return p.active[sort.SearchInts(p.weights, rand.Intn(p.weights[l-1])+1)]
+	}
+	return p.active[rand.Intn(l)]
+}
+
+func (p *Pool) exchange(res *resolver, req *request) {
+	resp, err := p.exchangeWith(req.Ctx, res, req.Msg)
+	if err != nil {
+		errNoResponse(req.Msg, req.Result)
+		return
+	}
+	req.Result <- resp
+}
+
+// exchangeWith sends the message to the resolver and collects the statistics for the exchange.
+func (p *Pool) exchangeWith(ctx context.Context, res *resolver, msg *dns.Msg) (*dns.Msg, error) {
+	p.Lock()
+	timeout := p.timeout
+	rt := p.servRates
+	p.Unlock()
+
+	resp, err := exchange(ctx, res, msg, timeout, rt)
+	// a cancelled query does not reflect on the pool
+	if ctx.Err() == nil {
+		rcode := resolve.RcodeNoResponse
+		if err == nil {
+			rcode = resp.Rcode
+		}
+		p.ctrl.Record(rcode)
+	}
+	return resp, err
+}
+
+// exchange waits for the resolver rate limit, sends the message and records the outcome.
+func exchange(ctx context.Context, res *resolver, msg *dns.Msg, timeout time.Duration, rt *resolve.RateTracker) (*dns.Msg, error) {
+	// wait for the resolver rate limit before starting the timer
+	res.take()
+	name := msg.Question[0].Name
+	tctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	resp, rtt, err := res.exchange(tctx, msg)
+	// a cancelled query does not reflect on the resolver
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil || resp == nil {
+		res.stats.timeout()
+		res.ctrl.Record(resolve.RcodeNoResponse)
+		if rt != nil {
+			rt.Timeout(name)
+		}
+		if err == nil {
+			err = errors.New("no response")
+		}
+		return nil, err
+	}
+
+	res.stats.response(resp.Rcode, rtt)
+	res.ctrl.Record(resp.Rcode)
+	if rt != nil {
+		rt.Success(name)
+	}
+	return resp, nil
+}
+
+// remove discontinues use of the resolver and records the reason.
+func (p *Pool) remove(res *resolver, reason string) {
+	p.Lock()
+	defer p.Unlock()
+
+	if !res.stop(reason) {
+		return
+	}
+
+	for i, r := range p.active {
+		if r == res {
+			p.active = append(p.active[:i], p.active[i+1:]...)
+			break
+		}
+	}
+	if !p.maxSet {
+		p.qps -= res.qps
+	}
+	p.updateRate()
+	p.log.Printf("Resolver %s was removed from the pool: %s", res.address, reason)
+}
+
+func (p *Pool) activeResolvers() []*resolver {
+	p.Lock()
+	defer p.Unlock()
+
+	return append([]*resolver(nil), p.active...)
+}
+
+// updateRate must be called while holding the pool lock. The rate of the pool is the sum of the
+// adapted rates of the resolvers, without exceeding the limit of the pool controller.
+func (p *Pool) updateRate() {
+	var total int
+
+	p.weights = p.weights[:0]
+	for _, res := range p.active {
+		total += res.effectiveQPS()
+		p.weights = append(p.weights, total)
+	}
+	if p.qps <= 0 {
+		p.effective = total
+		p.rate = nil
+		return
+	}
+
+	p.ctrl.SetMax(p.qps)
+	rate := p.ctrl.Limit()
+	if total > 0 && total < rate {
+		rate = total
+	}
+	if rate != p.effective || p.rate == nil {
+		p.effective = rate
+		p.rate = ratelimit.New(rate)
+	}
+}
+
+func (p *Pool) adaptRates() {
+	t := time.NewTicker(AdaptInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+			p.adapt()
+		}
+	}
+}
+
+// adapt adjusts the rates of the resolvers and the pool to the outcomes of the recent queries.
+func (p *Pool) adapt() {
+	for _, res := range p.activeResolvers() {
+		res.adapt()
+	}
+	p.ctrl.Adjust()
+
+	p.Lock()
+	defer p.Unlock()
+
+	prev := p.effective
+	p.updateRate()
+	if p.effective < prev {
+		p.log.Printf("The rate of the resolver pool was decreased to %d queries per second after failures", p.effective)
+	}
+}
+
+func (p *Pool) thresholdChecks() {
+	t := time.NewTicker(thresholdCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+			p.removeIfThresholdViolated()
+		}
+	}
+}
+
+func (p *Pool) removeIfThresholdViolated() {
+	p.Lock()
+	opts := *p.options
+	p.Unlock()
+
+	if opts.ThresholdValue == 0 {
+		return
+	}
+
+	for _, res := range p.activeResolvers() {
+		if reason := res.stats.thresholdViolation(&opts); reason != "" {
+			p.remove(res, reason)
+		}
+	}
+}
+
+// Stats returns the statistics collected for each resolver added to the pool.
+func (p *Pool) Stats() []*Stats {
+	p.Lock()
+	all := append([]*resolver(nil), p.all...)
+	p.Unlock()
+
+	var stats []*Stats
+	for _, res := range all {
+		stats = append(stats, res.snapshot())
+	}
+	return stats
+}
+
+func errNoResponse(msg *dns.Msg, ch chan *dns.Msg) {
+	if msg != nil {
+		msg.Rcode = resolve.RcodeNoResponse
+	}
+	ch <- msg
+}
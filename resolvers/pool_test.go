@@ -0,0 +1,160 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer starts a DNS server on the loopback interface using the provided handler.
+func testServer(t *testing.T, handler dns.HandlerFunc) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	srv := &dns.Server{
+		PacketConn:        pc,
+		Handler:           handler,
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+// honestHandler answers for names under example.com and returns NXDOMAIN for all other names.
+func honestHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.RecursionAvailable = true
+
+	name := req.Question[0].Name
+	if name == "www.example.com." {
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("192.0.2.1"),
+		})
+	} else {
+		m.Rcode = dns.RcodeNameError
+	}
+	_ = w.WriteMsg(m)
+}
+
+// hijackHandler answers every query with an address.
+func hijackHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	m.RecursionAvailable = true
+	m.Answer = append(m.Answer, &dns.A{
+		Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+		A:   net.ParseIP("198.51.100.1"),
+	})
+	_ = w.WriteMsg(m)
+}
+
+func refusedHandler(w dns.ResponseWriter, req *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetRcode(req, dns.RcodeRefused)
+	_ = w.WriteMsg(m)
+}
+
+func TestQueryStats(t *testing.T) {
+	addr := testServer(t, honestHandler)
+
+	p := NewPool()
+	defer p.Stop()
+	require.NoError(t, p.AddResolvers(100, addr))
+	require.Equal(t, 1, p.Len())
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		resp, err := p.QueryBlocking(ctx, resolve.QueryMsg("www.example.com", dns.TypeA))
+		require.NoError(t, err)
+		require.Equal(t, dns.RcodeSuccess, resp.Rcode)
+		require.Len(t, resp.Answer, 1)
+	}
+	resp, err := p.QueryBlocking(ctx, resolve.QueryMsg("missing.example.com", dns.TypeA))
+	require.NoError(t, err)
+	require.Equal(t, dns.RcodeNameError, resp.Rcode)
+
+	stats := p.Stats()
+	require.Len(t, stats, 1)
+	require.Equal(t, addr, stats[0].Address)
+	require.Equal(t, uint64(6), stats[0].Queries)
+	require.Equal(t, uint64(6), stats[0].Responses)
+	require.Equal(t, uint64(5), stats[0].Rcodes["NOERROR"])
+	require.Equal(t, uint64(1), stats[0].Rcodes["NXDOMAIN"])
+	require.Greater(t, stats[0].AvgLatency, time.Duration(0))
+	require.False(t, stats[0].Removed)
+}
+
+func TestThresholdRemoval(t *testing.T) {
+	good := testServer(t, honestHandler)
+	bad := testServer(t, refusedHandler)
+
+	p := NewPool()
+	defer p.Stop()
+	require.NoError(t, p.AddResolvers(100, good, bad))
+	p.SetThresholdOptions(&resolve.ThresholdOptions{
+		ThresholdValue:     3,
+		CountQueryRefusals: true,
+	})
+
+	ctx := context.Background()
+	for _, res := range p.activeResolvers() {
+		for i := 0; i < 3; i++ {
+			_, _ = p.exchangeWith(ctx, res, resolve.QueryMsg("www.example.com", dns.TypeA))
+		}
+	}
+	p.removeIfThresholdViolated()
+	require.Equal(t, 1, p.Len())
+
+	for _, s := range p.Stats() {
+		if s.Address == bad {
+			require.True(t, s.Removed)
+			require.True(t, strings.HasPrefix(s.Reason, ReasonThreshold))
+			require.Equal(t, uint64(3), s.Rcodes["REFUSED"])
+		} else {
+			require.False(t, s.Removed)
+		}
+	}
+}
+
+func TestHijackCheck(t *testing.T) {
+	good := testServer(t, honestHandler)
+	liar := testServer(t, hijackHandler)
+
+	verify := NewPool()
+	defer verify.Stop()
+	require.NoError(t, verify.AddResolvers(100, good))
+
+	p := NewPool()
+	defer p.Stop()
+	require.NoError(t, p.AddResolvers(100, good, liar))
+
+	p.hijackCheck(context.Background(), verify, []string{"example.com"})
+	require.Equal(t, 1, p.Len())
+
+	for _, s := range p.Stats() {
+		if s.Address == liar {
+			require.True(t, s.Removed)
+			require.Equal(t, ReasonHijack, s.Reason)
+			require.Equal(t, uint64(1), s.Hijacks)
+		} else {
+			require.False(t, s.Removed)
+			require.Zero(t, s.Hijacks)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReputationFilename is the name of the file in the output directory that stores resolver reputation.
+const ReputationFilename = "resolvers.json"
+
+const (
+	// resolvers that lied are avoided for this amount of time
+	dishonestPenalty = 30 * 24 * time.Hour
+	// resolvers removed in this many consecutive runs are avoided
+	maxRemovalStreak = 3
+	// resolvers with a removal streak are retried after this amount of time
+	unreliablePenalty = 7 * 24 * time.Hour
+)
+
+// Reputation tracks the health of resolvers across enumerations.
+type Reputation struct {
+	sync.Mutex
+	path    string
+	records map[string]*ReputationRecord
+}
+
+// ReputationRecord is the history of a single resolver across enumerations.
+type ReputationRecord struct {
+	Address    string        `json:"address"`
+	Runs       int           `json:"runs"`
+	Removals   int           `json:"removals"`
+	Streak     int           `json:"removal_streak"`
+	Queries    uint64        `json:"queries"`
+	Responses  uint64        `json:"responses"`
+	Timeouts   uint64        `json:"timeouts"`
+	Hijacks    uint64        `json:"hijacks"`
+	AvgLatency time.Duration `json:"avg_latency"`
+	LastReason string        `json:"last_reason,omitempty"`
+	LastRemove time.Time     `json:"last_removed,omitempty"`
+	LastSeen   time.Time     `json:"last_seen"`
+}
+
+// LoadReputation reads the resolver reputation file at the provided path.
+// A missing file results in an empty Reputation that will be created when saved.
+func LoadReputation(path string) (*Reputation, error) {
+	rep := &Reputation{
+		path:    path,
+		records: make(map[string]*ReputationRecord),
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return rep, nil
+		}
+		return rep, fmt.Errorf("failed to read the resolver reputation file: %v", err)
+	}
+
+	var records []*ReputationRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return rep, fmt.Errorf("failed to parse the resolver reputation file: %v", err)
+	}
+	for _, rec := range records {
+		if rec.Address != "" {
+			rep.records[rec.Address] = rec
+		}
+	}
+	return rep, nil
+}
+
+// Record returns the history for the resolver address, or nil when the resolver is unknown.
+func (r *Reputation) Record(addr string) *ReputationRecord {
+	r.Lock()
+	defer r.Unlock()
+
+	if rec, found := r.records[addr]; found {
+		c := *rec
+		return &c
+	}
+	return nil
+}
+
+// Bad returns true when previous enumerations showed the resolver should not be used.
+func (r *Reputation) Bad(addr string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	rec, found := r.records[addr]
+	if !found {
+		return false
+	}
+	// A resolver that is avoided cannot reset its streak, so it gets retried eventually
+	if rec.Streak >= maxRemovalStreak && time.Since(rec.LastRemove) < unreliablePenalty {
+		return true
+	}
+	switch rec.LastReason {
+	case ReasonHijack, ReasonClientSubnet:
+		return time.Since(rec.LastRemove) < dishonestPenalty
+	}
+	return false
+}
+
+// Known returns true when the resolver has previously been used without being removed.
+func (r *Reputation) Known(addr string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	rec, found := r.records[addr]
+	return found && rec.Streak == 0 && rec.Runs > rec.Removals
+}
+
+// Update adds the statistics collected during an enumeration to the resolver histories.
+func (r *Reputation) Update(stats []*Stats) {
+	r.Lock()
+	defer r.Unlock()
+
+	now := time.Now()
+	for _, s := range stats {
+		// resolvers that were never queried provide no new information
+		if s.Queries == 0 && !s.Removed {
+			continue
+		}
+
+		rec, found := r.records[s.Address]
+		if !found {
+			rec = &ReputationRecord{Address: s.Address}
+			r.records[s.Address] = rec
+		}
+
+		rec.Runs++
+		rec.LastSeen = now
+		if total := rec.Responses + s.Responses; total > 0 {
+			rec.AvgLatency = time.Duration((uint64(rec.AvgLatency)*rec.Responses + uint64(s.AvgLatency)*s.Responses) / total)
+		}
+		rec.Queries += s.Queries
+		rec.Responses += s.Responses
+		rec.Timeouts += s.Timeouts
+		rec.Hijacks += s.Hijacks
+		if s.Removed {
+			rec.Removals++
+			rec.Streak++
+			rec.LastReason = s.Reason
+			rec.LastRemove = now
+		} else {
+			rec.Streak = 0
+		}
+	}
+}
+
+// Save writes the resolver histories to the reputation file.
+func (r *Reputation) Save() error {
+	r.Lock()
+	defer r.Unlock()
+
+	records := make([]*ReputationRecord, 0, len(r.records))
+	for _, rec := range r.records {
+		records = append(records, rec)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].Address < records[j].Address
+	})
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode the resolver reputation data: %v", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ReputationFilename+".*")
+	if err != nil {
+		return fmt.Errorf("failed to create the resolver reputation file: %v", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write the resolver reputation file: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("failed to write the resolver reputation file: %v", err)
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// Sort orders the addresses so that resolvers known to be healthy and fast come first,
+// and removes the addresses of resolvers that should not be used. The addresses can be
+// resolver entries selecting their own QPS, which are kept in the returned addresses.
+func (r *Reputation) Sort(addrs []string) []string {
+	var known, unknown []string
+	keys := make(map[string]string, len(addrs))
+
+	for _, addr := range addrs {
+		key := addr
+		if a, _, err := ParseResolver(addr); err == nil {
+			key = a
+		}
+		if r.Bad(key) {
+			continue
+		}
+
+		keys[addr] = key
+		if r.Known(key) {
+			known = append(known, addr)
+		} else {
+			unknown = append(unknown, addr)
+		}
+	}
+
+	r.Lock()
+	sort.SliceStable(known, func(i, j int) bool {
+		return r.records[keys[known[i]]].AvgLatency < r.records[keys[known[j]]].AvgLatency
+	})
+	r.Unlock()
+	return append(known, unknown...)
+}
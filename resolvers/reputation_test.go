@@ -0,0 +1,82 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReputation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ReputationFilename)
+
+	rep, err := LoadReputation(path)
+	require.NoError(t, err)
+	require.False(t, rep.Bad("192.0.2.1:53"))
+
+	run := []*Stats{
+		{Address: "192.0.2.1:53", Queries: 100, Responses: 100, AvgLatency: 20 * time.Millisecond},
+		{Address: "192.0.2.2:53", Queries: 100, Responses: 90, AvgLatency: 10 * time.Millisecond},
+		{Address: "192.0.2.3:53", Queries: 5, Responses: 5, Hijacks: 2, Removed: true, Reason: ReasonHijack},
+		{Address: "192.0.2.4:53", Queries: 50, Timeouts: 50, Removed: true, Reason: ReasonThreshold},
+		{Address: "192.0.2.5:53"},
+	}
+	rep.Update(run)
+	require.NoError(t, rep.Save())
+
+	rep, err = LoadReputation(path)
+	require.NoError(t, err)
+	require.Nil(t, rep.Record("192.0.2.5:53"))
+	require.True(t, rep.Bad("192.0.2.3:53"))
+	require.False(t, rep.Bad("192.0.2.4:53"))
+	require.Equal(t, uint64(2), rep.Record("192.0.2.3:53").Hijacks)
+
+	addrs := []string{"192.0.2.5:53", "192.0.2.4:53", "192.0.2.3:53", "192.0.2.1:53", "192.0.2.2:53"}
+	require.Equal(t, []string{"192.0.2.2:53", "192.0.2.1:53", "192.0.2.5:53", "192.0.2.4:53"}, rep.Sort(addrs))
+
+	// Resolvers removed in consecutive runs are avoided
+	for i := 1; i < maxRemovalStreak; i++ {
+		rep.Update(run[3:4])
+	}
+	require.True(t, rep.Bad("192.0.2.4:53"))
+	require.Equal(t, maxRemovalStreak, rep.Record("192.0.2.4:53").Streak)
+
+	// A successful run resets the streak
+	rep.Update([]*Stats{{Address: "192.0.2.4:53", Queries: 10, Responses: 10}})
+	require.False(t, rep.Bad("192.0.2.4:53"))
+}
+
+func TestReputationRecovery(t *testing.T) {
+	rep, err := LoadReputation(filepath.Join(t.TempDir(), ReputationFilename))
+	require.NoError(t, err)
+
+	addr := "192.0.2.4:53"
+	removed := []*Stats{{Address: addr, Queries: 50, Timeouts: 50, Removed: true, Reason: ReasonThreshold}}
+	for i := 0; i < maxRemovalStreak; i++ {
+		rep.Update(removed)
+	}
+	require.True(t, rep.Bad(addr))
+	require.Empty(t, rep.Sort([]string{addr}))
+
+	// The resolver is retried once the penalty has passed
+	rep.records[addr].LastRemove = time.Now().Add(-unreliablePenalty - time.Hour)
+	require.False(t, rep.Bad(addr))
+	require.Equal(t, []string{addr}, rep.Sort([]string{addr}))
+
+	// Failing again avoids the resolver for another penalty
+	rep.Update(removed)
+	require.True(t, rep.Bad(addr))
+	require.Equal(t, maxRemovalStreak+1, rep.Record(addr).Streak)
+
+	// A successful retry restores the resolver
+	rep.records[addr].LastRemove = time.Now().Add(-unreliablePenalty - time.Hour)
+	rep.Update([]*Stats{{Address: addr, Queries: 10, Responses: 10}})
+	require.False(t, rep.Bad(addr))
+	require.Zero(t, rep.Record(addr).Streak)
+	require.True(t, rep.Known(addr))
+}
@@ -0,0 +1,283 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/resolve"
+	"go.uber.org/ratelimit"
+)
+
+// Reasons recorded when resolvers are removed from a pool.
+const (
+	ReasonThreshold    = "exceeded the failure threshold"
+	ReasonNoResponse   = "did not respond to the client subnet check"
+	ReasonClientSubnet = "failed the client subnet check"
+	ReasonHijack       = "returned answers for names that do not exist"
+)
+
+type resolver struct {
+	sync.Mutex
+	address   string
+	qps       int
+	effective int
+	rate      ratelimit.Limiter
+	ctrl      *AIMD
+	xchg      Exchanger
+	stats     *stats
+	done      chan struct{}
+	reason    string
+}
+
+// Exchanger sends a DNS message to a resolver using a specific transport, or to the remote
+// processes resolving names on behalf of the pool.
+type Exchanger interface {
+	Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, time.Duration, error)
+}
+
+// newResolver expects an address that has already been normalized.
+func newResolver(addr string, qps int, tlsConfig *tls.Config, d *amassnet.Dialer) *resolver {
+	xchg, err := newExchanger(addr, tlsConfig, d)
+	if err != nil {
+		return nil
+	}
+	return newResolverWith(addr, qps, xchg)
+}
+
+// newResolverWith returns a resolver sending the queries using the Exchanger. The rate starts at
+// qps queries per second and adapts to the failures of the resolver without exceeding qps.
+func newResolverWith(addr string, qps int, xchg Exchanger) *resolver {
+	return &resolver{
+		address:   addr,
+		qps:       qps,
+		effective: qps,
+		rate:      ratelimit.New(qps),
+		ctrl:      NewAIMD(1, qps),
+		xchg:      xchg,
+		stats:     newStats(),
+		done:      make(chan struct{}),
+	}
+}
+
+func (r *resolver) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, time.Duration, error) {
+	r.stats.query()
+	return r.xchg.Exchange(ctx, msg)
+}
+
+// take waits for the current rate limit of the resolver.
+func (r *resolver) take() {
+	r.Lock()
+	rate := r.rate
+	r.Unlock()
+
+	rate.Take()
+}
+
+// adapt adjusts the rate of the resolver to the outcomes of the queries and returns the rate.
+func (r *resolver) adapt() int {
+	qps := r.ctrl.Adjust()
+
+	r.Lock()
+	defer r.Unlock()
+
+	if qps != r.effective {
+		r.rate = ratelimit.New(qps)
+		r.effective = qps
+	}
+	return qps
+}
+
+func (r *resolver) effectiveQPS() int {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.effective
+}
+
+// close releases the connections held by the transport.
+func (r *resolver) close() {
+	if c, ok := r.xchg.(io.Closer); ok {
+		_ = c.Close()
+	}
+}
+
+// stop returns false when the resolver was already stopped.
+func (r *resolver) stop(reason string) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	select {
+	case <-r.done:
+		return false
+	default:
+	}
+
+	close(r.done)
+	r.reason = reason
+	return true
+}
+
+func (r *resolver) stopped() bool {
+	select {
+	case <-r.done:
+		return true
+	default:
+	}
+	return false
+}
+
+func (r *resolver) snapshot() *Stats {
+	s := r.stats.snapshot()
+
+	s.Address = r.address
+	s.QPS = r.qps
+	r.Lock()
+	s.EffectiveQPS = r.effective
+	s.Reason = r.reason
+	r.Unlock()
+	s.Removed = r.stopped()
+	return s
+}
+
+// Stats is a snapshot of the statistics collected for a resolver. QPS is the maximum rate of the
+// resolver, and EffectiveQPS is the rate the resolver was adapted to after its recent failures.
+type Stats struct {
+	Address      string            `json:"address"`
+	Queries      uint64            `json:"queries"`
+	Responses    uint64            `json:"responses"`
+	Timeouts     uint64            `json:"timeouts"`
+	Rcodes       map[string]uint64 `json:"rcodes"`
+	Hijacks      uint64            `json:"hijacks"`
+	QPS          int               `json:"qps"`
+	EffectiveQPS int               `json:"effective_qps"`
+	AvgLatency   time.Duration     `json:"avg_latency"`
+	MaxLatency   time.Duration     `json:"max_latency"`
+	Removed      bool              `json:"removed"`
+	Reason       string            `json:"reason,omitempty"`
+}
+
+type stats struct {
+	sync.Mutex
+	queries      uint64
+	responses    uint64
+	timeouts     uint64
+	rcodes       map[int]uint64
+	hijacks      uint64
+	failures     uint64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+}
+
+func newStats() *stats {
+	return &stats{rcodes: make(map[int]uint64)}
+}
+
+func (s *stats) query() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.queries++
+}
+
+func (s *stats) timeout() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.timeouts++
+	s.failures++
+}
+
+func (s *stats) hijack() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.hijacks++
+}
+
+func (s *stats) response(rcode int, rtt time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.responses++
+	s.rcodes[rcode]++
+	s.totalLatency += rtt
+	if rtt > s.maxLatency {
+		s.maxLatency = rtt
+	}
+
+	switch rcode {
+	case dns.RcodeFormatError, dns.RcodeServerFailure, dns.RcodeNotImplemented, dns.RcodeRefused:
+		s.failures++
+	default:
+		s.failures = 0
+	}
+}
+
+// thresholdViolation returns the removal reason when the options have been violated.
+func (s *stats) thresholdViolation(opts *resolve.ThresholdOptions) string {
+	s.Lock()
+	defer s.Unlock()
+
+	if !opts.CumulativeAccumulation {
+		if s.failures >= opts.ThresholdValue {
+			return fmt.Sprintf("%s of %d consecutive failures", ReasonThreshold, opts.ThresholdValue)
+		}
+		return ""
+	}
+
+	var total uint64
+	if opts.CountTimeouts {
+		total += s.timeouts
+	}
+	if opts.CountFormatErrors {
+		total += s.rcodes[dns.RcodeFormatError]
+	}
+	if opts.CountServerFailures {
+		total += s.rcodes[dns.RcodeServerFailure]
+	}
+	if opts.CountNotImplemented {
+		total += s.rcodes[dns.RcodeNotImplemented]
+	}
+	if opts.CountQueryRefusals {
+		total += s.rcodes[dns.RcodeRefused]
+	}
+	if total >= opts.ThresholdValue {
+		return fmt.Sprintf("%s of %d failures", ReasonThreshold, opts.ThresholdValue)
+	}
+	return ""
+}
+
+func (s *stats) snapshot() *Stats {
+	s.Lock()
+	defer s.Unlock()
+
+	ss := &Stats{
+		Queries:    s.queries,
+		Responses:  s.responses,
+		Timeouts:   s.timeouts,
+		Rcodes:     make(map[string]uint64, len(s.rcodes)),
+		Hijacks:    s.hijacks,
+		MaxLatency: s.maxLatency,
+	}
+	if s.responses > 0 {
+		ss.AvgLatency = s.totalLatency / time.Duration(s.responses)
+	}
+	for rcode, count := range s.rcodes {
+		name, found := dns.RcodeToString[rcode]
+		if !found {
+			name = fmt.Sprintf("RCODE%d", rcode)
+		}
+		ss.Rcodes[name] = count
+	}
+	return ss
+}
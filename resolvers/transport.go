@@ -0,0 +1,369 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+)
+
+const (
+	dohScheme = "https://"
+	dotScheme = "tls://"
+	// the default path for DNS-over-HTTPS queries (RFC 8484)
+	dohDefaultPath = "/dns-query"
+	dohContentType = "application/dns-message"
+	dotDefaultPort = "853"
+	// the number of idle DNS-over-TLS connections kept for each resolver
+	maxIdleConns = 8
+	// the setting following a resolver address that selects the queries per second of the resolver
+	qpsSetting = "qps="
+)
+
+// ParseResolver returns the normalized address of a resolver entry and the maximum queries per
+// second selected for the resolver with the qps setting (e.g. tls://1.1.1.1:853 qps=20). The QPS
+// is zero when the entry does not select one.
+func ParseResolver(entry string) (string, int, error) {
+	fields := strings.Fields(entry)
+	if len(fields) == 0 {
+		return "", 0, fmt.Errorf("invalid DNS resolver entry: %q", entry)
+	}
+
+	addr, err := NormalizeAddress(fields[0])
+	if err != nil {
+		return "", 0, err
+	}
+
+	var qps int
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(strings.ToLower(field), qpsSetting) {
+			return "", 0, fmt.Errorf("invalid DNS resolver setting %s: %s", field, entry)
+		}
+
+		qps, err = strconv.Atoi(field[len(qpsSetting):])
+		if err != nil || qps <= 0 {
+			return "", 0, fmt.Errorf("invalid DNS resolver QPS %s: %s", field, entry)
+		}
+	}
+	return addr, qps, nil
+}
+
+// FormatResolver returns the resolver entry for the address and the queries per second selected
+// for the resolver, or the address alone when the QPS is zero.
+func FormatResolver(addr string, qps int) string {
+	if qps <= 0 {
+		return addr
+	}
+	return addr + " " + qpsSetting + strconv.Itoa(qps)
+}
+
+// NormalizeAddress checks the resolver address and returns it in the form used by the pool.
+// Plain IP addresses receive the default port 53, DNS-over-TLS addresses (tls://host[:port])
+// receive port 853, and DNS-over-HTTPS URLs (https://host/dns-query) receive the default path.
+func NormalizeAddress(addr string) (string, error) {
+	addr = strings.TrimSpace(addr)
+
+	switch lower := strings.ToLower(addr); {
+	case strings.HasPrefix(lower, dohScheme):
+		u, err := url.Parse(addr)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("invalid DNS-over-HTTPS resolver URL: %s", addr)
+		}
+		u.Scheme = "https"
+		if u.Path == "" || u.Path == "/" {
+			u.Path = dohDefaultPath
+		}
+		return u.String(), nil
+	case strings.HasPrefix(lower, dotScheme):
+		hostport := addr[len(dotScheme):]
+		host, port, err := net.SplitHostPort(hostport)
+		if err != nil {
+			host = strings.Trim(hostport, "[]")
+			port = dotDefaultPort
+		}
+		if host == "" || strings.ContainsAny(host, "/?#") {
+			return "", fmt.Errorf("invalid DNS-over-TLS resolver address: %s", addr)
+		}
+		return dotScheme + net.JoinHostPort(host, port), nil
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = strings.Trim(addr, "[]")
+		port = "53"
+	}
+	if net.ParseIP(host) == nil {
+		return "", fmt.Errorf("invalid DNS resolver IP address: %s", addr)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// newExchanger returns the transport selected by the normalized resolver address.
+func newExchanger(addr string, tlsConfig *tls.Config, d *amassnet.Dialer) (Exchanger, error) {
+	switch {
+	case strings.HasPrefix(addr, dohScheme):
+		return newDoHExchanger(addr, tlsConfig, d), nil
+	case strings.HasPrefix(addr, dotScheme):
+		return newDoTExchanger(strings.TrimPrefix(addr, dotScheme), tlsConfig, d), nil
+	}
+
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil || net.ParseIP(host) == nil {
+		return nil, fmt.Errorf("invalid DNS resolver address: %s", addr)
+	}
+	return newUDPExchanger(addr, d), nil
+}
+
+type udpExchanger struct {
+	addr   string
+	dialer *amassnet.Dialer
+	udp    *dns.Client
+	tcp    *dns.Client
+}
+
+func newUDPExchanger(addr string, d *amassnet.Dialer) *udpExchanger {
+	return &udpExchanger{
+		addr:   addr,
+		dialer: d,
+		udp:    &dns.Client{Net: "udp", UDPSize: dns.DefaultMsgSize},
+		tcp:    &dns.Client{Net: "tcp"},
+	}
+}
+
+// Exchange implements the Exchanger interface.
+func (u *udpExchanger) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, time.Duration, error) {
+	// the queries are sent over TCP when a proxy is in use, since it cannot carry UDP
+	if u.dialer.Proxied() {
+		return exchangeConn(ctx, u.dialer, u.tcp, msg, u.addr)
+	}
+
+	resp, rtt, err := exchangeConn(ctx, u.dialer, u.udp, msg, u.addr)
+	if err != nil {
+		return nil, rtt, err
+	}
+	// try again over TCP when the response did not fit in the datagram
+	if resp.Truncated {
+		var trtt time.Duration
+
+		resp, trtt, err = exchangeConn(ctx, u.dialer, u.tcp, msg, u.addr)
+		rtt += trtt
+	}
+	return resp, rtt, err
+}
+
+// exchangeConn sends the message over a connection made by the Dialer on the network of the
+// client, so it is bound to the selected local address and routed through the proxy when one is in use.
+func exchangeConn(ctx context.Context, d *amassnet.Dialer, c *dns.Client, msg *dns.Msg, addr string) (*dns.Msg, time.Duration, error) {
+	conn, err := d.DialContext(ctx, c.Net, addr)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+
+	return c.ExchangeWithConnContext(ctx, msg, &dns.Conn{Conn: conn})
+}
+
+// dohExchanger sends DNS messages using DNS-over-HTTPS POST requests (RFC 8484).
+type dohExchanger struct {
+	url    string
+	client *http.Client
+}
+
+func newDoHExchanger(u string, tlsConfig *tls.Config, d *amassnet.Dialer) *dohExchanger {
+	var tc *tls.Config
+	if tlsConfig != nil {
+		tc = tlsConfig.Clone()
+	}
+
+	return &dohExchanger{
+		url: u,
+		client: &http.Client{
+			Transport: &http.Transport{
+				Proxy:               http.ProxyFromEnvironment,
+				DialContext:         d.DialContext,
+				TLSClientConfig:     tc,
+				ForceAttemptHTTP2:   true,
+				MaxIdleConnsPerHost: maxIdleConns,
+				IdleConnTimeout:     90 * time.Second,
+				TLSHandshakeTimeout: 10 * time.Second,
+			},
+			// redirects are not expected from DNS-over-HTTPS servers
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Exchange implements the Exchanger interface.
+func (d *dohExchanger) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, time.Duration, error) {
+	q := msg.Copy()
+	// the message ID should be zero to allow caching of the responses
+	q.Id = 0
+	data, err := q.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	start := time.Now()
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, time.Since(start), err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, dns.MaxMsgSize))
+	rtt := time.Since(start)
+	if err != nil {
+		return nil, rtt, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, rtt, fmt.Errorf("the DNS-over-HTTPS server returned status code %d", resp.StatusCode)
+	}
+
+	m := new(dns.Msg)
+	if err := m.Unpack(body); err != nil {
+		return nil, rtt, fmt.Errorf("failed to unpack the DNS-over-HTTPS response: %v", err)
+	}
+	m.Id = msg.Id
+	return m, rtt, nil
+}
+
+// Close implements the io.Closer interface.
+func (d *dohExchanger) Close() error {
+	d.client.CloseIdleConnections()
+	return nil
+}
+
+// dotExchanger sends DNS messages using DNS-over-TLS (RFC 7858) and reuses the connections.
+type dotExchanger struct {
+	sync.Mutex
+	addr   string
+	dialer *amassnet.Dialer
+	client *dns.Client
+	idle   []*dns.Conn
+	closed bool
+}
+
+func newDoTExchanger(addr string, tlsConfig *tls.Config, d *amassnet.Dialer) *dotExchanger {
+	var tc *tls.Config
+	if tlsConfig != nil {
+		tc = tlsConfig.Clone()
+	}
+
+	return &dotExchanger{
+		addr:   addr,
+		dialer: d,
+		client: &dns.Client{Net: "tcp-tls", TLSConfig: tc},
+	}
+}
+
+// Exchange implements the Exchanger interface.
+func (d *dotExchanger) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, time.Duration, error) {
+	conn, reused := d.getConn()
+	for {
+		var err error
+
+		if conn == nil {
+			conn, err = d.dial(ctx)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+
+		resp, rtt, err := d.client.ExchangeWithConnContext(ctx, msg, conn)
+		if err == nil {
+			d.putConn(conn)
+			return resp, rtt, nil
+		}
+
+		_ = conn.Close()
+		// the server may have closed the idle connection
+		if !reused || ctx.Err() != nil {
+			return nil, rtt, err
+		}
+		conn, reused = nil, false
+	}
+}
+
+// dial makes the TLS connection over a connection made by the Dialer, so it is bound to the
+// selected local address and routed through the proxy when one is in use.
+func (d *dotExchanger) dial(ctx context.Context) (*dns.Conn, error) {
+	conn, err := d.dialer.DialContext(ctx, "tcp", d.addr)
+	if err != nil {
+		return nil, err
+	}
+
+	tc := new(tls.Config)
+	if d.client.TLSConfig != nil {
+		tc = d.client.TLSConfig.Clone()
+	}
+	if tc.ServerName == "" {
+		tc.ServerName, _, _ = net.SplitHostPort(d.addr)
+	}
+
+	c := tls.Client(conn, tc)
+	if err := c.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &dns.Conn{Conn: c}, nil
+}
+
+func (d *dotExchanger) getConn() (*dns.Conn, bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	if l := len(d.idle); l > 0 {
+		conn := d.idle[l-1]
+		d.idle = d.idle[:l-1]
+		return conn, true
+	}
+	return nil, false
+}
+
+func (d *dotExchanger) putConn(conn *dns.Conn) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.closed || len(d.idle) >= maxIdleConns {
+		_ = conn.Close()
+		return
+	}
+	d.idle = append(d.idle, conn)
+}
+
+// Close implements the io.Closer interface.
+func (d *dotExchanger) Close() error {
+	d.Lock()
+	defer d.Unlock()
+
+	for _, conn := range d.idle {
+		_ = conn.Close()
+	}
+	d.idle = nil
+	d.closed = true
+	return nil
+}
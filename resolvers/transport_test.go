@@ -0,0 +1,288 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+// dohWriter collects the response message written by a DNS handler.
+type dohWriter struct {
+	dns.ResponseWriter
+	msg *dns.Msg
+}
+
+func (w *dohWriter) WriteMsg(m *dns.Msg) error {
+	w.msg = m
+	return nil
+}
+
+// testDoHServer starts a DNS-over-HTTPS server that answers using the provided handler.
+func testDoHServer(t *testing.T, handler dns.HandlerFunc) (*httptest.Server, string) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != dohDefaultPath || r.Header.Get("Content-Type") != dohContentType {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		req := new(dns.Msg)
+		if err := req.Unpack(body); err != nil || req.Id != 0 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		dw := &dohWriter{}
+		handler(dw, req)
+		data, err := dw.msg.Pack()
+		if err != nil {
+			http.Error(w, "server failure", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", dohContentType)
+		_, _ = w.Write(data)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, ts.URL + dohDefaultPath
+}
+
+// testDoTServer starts a DNS-over-TLS server using the certificate of the provided server.
+func testDoTServer(t *testing.T, ts *httptest.Server, handler dns.HandlerFunc) string {
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: ts.TLS.Certificates})
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	srv := &dns.Server{
+		Listener:          l,
+		Net:               "tcp-tls",
+		Handler:           handler,
+		NotifyStartedFunc: func() { close(started) },
+	}
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return dotScheme + l.Addr().String()
+}
+
+func testTLSConfig(ts *httptest.Server) *tls.Config {
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	return &tls.Config{RootCAs: roots}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		addr     string
+		expected string
+		err      bool
+	}{
+		{"8.8.8.8", "8.8.8.8:53", false},
+		{" 8.8.8.8:5353 ", "8.8.8.8:5353", false},
+		{"[2001:4860:4860::8888]", "[2001:4860:4860::8888]:53", false},
+		{"dns.google", "", true},
+		{"tls://8.8.8.8", "tls://8.8.8.8:853", false},
+		{"TLS://8.8.8.8:8853", "tls://8.8.8.8:8853", false},
+		{"tls://[2001:4860:4860::8888]", "tls://[2001:4860:4860::8888]:853", false},
+		{"tls://", "", true},
+		{"https://dns.google/dns-query", "https://dns.google/dns-query", false},
+		{"https://1.1.1.1", "https://1.1.1.1/dns-query", false},
+		{"https://doh.example.com:8443/resolve", "https://doh.example.com:8443/resolve", false},
+		{"https:///dns-query", "", true},
+	}
+
+	for _, test := range tests {
+		addr, err := NormalizeAddress(test.addr)
+		if test.err {
+			require.Error(t, err, test.addr)
+			continue
+		}
+		require.NoError(t, err, test.addr)
+		require.Equal(t, test.expected, addr)
+	}
+}
+
+func TestParseResolver(t *testing.T) {
+	tests := []struct {
+		entry string
+		addr  string
+		qps   int
+		err   bool
+	}{
+		{"8.8.8.8", "8.8.8.8:53", 0, false},
+		{"tls://1.1.1.1:853 qps=20", "tls://1.1.1.1:853", 20, false},
+		{" https://dns.google  QPS=5 ", "https://dns.google/dns-query", 5, false},
+		{"8.8.8.8 qps=0", "", 0, true},
+		{"8.8.8.8 qps=fast", "", 0, true},
+		{"8.8.8.8 rate=5", "", 0, true},
+		{"dns.google qps=5", "", 0, true},
+		{"", "", 0, true},
+	}
+
+	for _, test := range tests {
+		addr, qps, err := ParseResolver(test.entry)
+		if test.err {
+			require.Error(t, err, test.entry)
+			continue
+		}
+		require.NoError(t, err, test.entry)
+		require.Equal(t, test.addr, addr)
+		require.Equal(t, test.qps, qps)
+		// The formatted entry is parsed back to the same resolver
+		a, q, err := ParseResolver(FormatResolver(addr, qps))
+		require.NoError(t, err)
+		require.Equal(t, addr, a)
+		require.Equal(t, qps, q)
+	}
+}
+
+func TestEncryptedTransports(t *testing.T) {
+	ts, doh := testDoHServer(t, honestHandler)
+	dot := testDoTServer(t, ts, honestHandler)
+
+	p := NewPool()
+	defer p.Stop()
+	p.SetTLSConfig(testTLSConfig(ts))
+	require.NoError(t, p.AddResolvers(50, doh))
+	// The entry selects the QPS of the resolver instead of the QPS provided to the pool
+	require.NoError(t, p.AddResolvers(50, FormatResolver(dot, 20)))
+	require.Equal(t, 2, p.Len())
+	require.Equal(t, 70, p.QPS())
+
+	ctx := context.Background()
+	for _, res := range p.activeResolvers() {
+		for i := 0; i < 3; i++ {
+			msg := resolve.QueryMsg("www.example.com", dns.TypeA)
+
+			resp, err := p.exchangeWith(ctx, res, msg)
+			require.NoError(t, err, res.address)
+			require.Equal(t, msg.Id, resp.Id)
+			require.Equal(t, dns.RcodeSuccess, resp.Rcode)
+			require.Len(t, resp.Answer, 1)
+			require.Equal(t, "192.0.2.1", resp.Answer[0].(*dns.A).A.String())
+		}
+
+		resp, err := p.exchangeWith(ctx, res, resolve.QueryMsg("missing.example.com", dns.TypeA))
+		require.NoError(t, err)
+		require.Equal(t, dns.RcodeNameError, resp.Rcode)
+	}
+
+	for _, s := range p.Stats() {
+		require.Equal(t, uint64(4), s.Queries, s.Address)
+		require.Equal(t, uint64(4), s.Responses, s.Address)
+		require.Zero(t, s.Timeouts, s.Address)
+	}
+
+	// The queries sent through the pool also use the encrypted transports
+	resp, err := p.QueryBlocking(ctx, resolve.QueryMsg("www.example.com", dns.TypeA))
+	require.NoError(t, err)
+	require.Len(t, resp.Answer, 1)
+}
+
+func TestEncryptedTransportFailures(t *testing.T) {
+	ts, doh := testDoHServer(t, honestHandler)
+	dot := testDoTServer(t, ts, honestHandler)
+
+	// The certificates cannot be verified without the test root
+	p := NewPool()
+	defer p.Stop()
+	require.NoError(t, p.AddResolvers(50, doh, dot))
+
+	ctx := context.Background()
+	for _, res := range p.activeResolvers() {
+		_, err := p.exchangeWith(ctx, res, resolve.QueryMsg("www.example.com", dns.TypeA))
+		require.Error(t, err, res.address)
+		require.Equal(t, uint64(1), res.snapshot().Timeouts)
+	}
+
+	p2 := NewPool()
+	defer p2.Stop()
+	p2.SetTLSConfig(testTLSConfig(ts))
+	require.NoError(t, p2.AddResolvers(50, strings.TrimSuffix(doh, dohDefaultPath)+"/wrong-path"))
+
+	res := p2.activeResolvers()[0]
+	_, err := p2.exchangeWith(ctx, res, resolve.QueryMsg("www.example.com", dns.TypeA))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "status code 400")
+}
+
+func TestTransportsLocalAddr(t *testing.T) {
+	var lock sync.Mutex
+	sources := make(map[string][]string)
+	handler := func(w dns.ResponseWriter, req *dns.Msg) {
+		network := "tcp"
+		if _, udp := w.RemoteAddr().(*net.UDPAddr); udp {
+			network = "udp"
+		}
+		host, _, _ := net.SplitHostPort(w.RemoteAddr().String())
+
+		lock.Lock()
+		sources[network] = append(sources[network], host)
+		lock.Unlock()
+		// The response is truncated over UDP, so the query is sent again over TCP
+		if network == "udp" && req.Question[0].Name == "tc.example.com." {
+			m := new(dns.Msg)
+			m.SetReply(req)
+			m.Truncated = true
+			_ = w.WriteMsg(m)
+			return
+		}
+		honestHandler(w, req)
+	}
+
+	port := testAuthServer(t, "127.0.0.1", "0", handler)
+	addr := net.JoinHostPort("127.0.0.1", port)
+	ts, _ := testDoHServer(t, handler)
+	dot := testDoTServer(t, ts, handler)
+
+	d := amassnet.NewDialer()
+	// The connections are bound to the address selected for the network interface
+	d.SetLocalAddr(&net.IPAddr{IP: net.ParseIP("127.0.0.2")})
+
+	p := NewPool()
+	defer p.Stop()
+	p.SetDialer(d)
+	p.SetTLSConfig(testTLSConfig(ts))
+	require.NoError(t, p.AddResolvers(50, addr, dot))
+	require.Equal(t, 2, p.Len())
+
+	ctx := context.Background()
+	for _, res := range p.activeResolvers() {
+		_, err := p.exchangeWith(ctx, res, resolve.QueryMsg("tc.example.com", dns.TypeA))
+		require.NoError(t, err, res.address)
+	}
+	_, _, err := newAuthExchanger(addr, d).Exchange(ctx, resolve.QueryMsg("tc.example.com", dns.TypeA))
+	require.NoError(t, err)
+
+	lock.Lock()
+	defer lock.Unlock()
+	// two UDP queries, and the TCP queries following the truncated responses and over TLS
+	require.Len(t, sources["udp"], 2)
+	require.Len(t, sources["tcp"], 3)
+	for _, hosts := range sources {
+		for _, host := range hosts {
+			require.Equal(t, "127.0.0.2", host)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package resolvers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/caffix/stringset"
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+)
+
+const (
+	numOfWildcardTests int = 3
+	maxQueryAttempts   int = 5
+)
+
+var wildcardQueryTypes = []uint16{
+	dns.TypeCNAME,
+	dns.TypeA,
+	dns.TypeAAAA,
+}
+
+type wildcard struct {
+	sync.Mutex
+	Detected bool
+	Answers  []*resolve.ExtractedAnswer
+}
+
+// SetDetectionResolver sets the provided DNS resolver as responsible for wildcard detection.
+func (p *Pool) SetDetectionResolver(qps int, addr string) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := addr
+	if a, _, err := ParseResolver(addr); err == nil {
+		key = a
+	}
+	if res, found := p.rmap[key]; found {
+		p.detector = res
+		return
+	}
+	if res := p.newResolver(qps, addr); res != nil {
+		p.active = append(p.active, res)
+		p.detector = res
+		p.updateRate()
+	}
+}
+
+func (p *Pool) getDetectionResolver() *resolver {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.detector == nil || p.detector.stopped() {
+		if l := len(p.active); l > 0 {
+			p.detector = p.active[0]
+		}
+	}
+	return p.detector
+}
+
+// WildcardDetected returns true when the provided DNS response could be a wildcard match.
+func (p *Pool) WildcardDetected(ctx context.Context, resp *dns.Msg, domain string) bool {
+	if p.getDetectionResolver() == nil {
+		return false
+	}
+
+	name := strings.ToLower(resolve.RemoveLastDot(resp.Question[0].Name))
+	domain = strings.ToLower(resolve.RemoveLastDot(domain))
+	if labels := strings.Split(name, "."); len(labels) > len(strings.Split(domain, ".")) {
+		name = strings.Join(labels[1:], ".")
+	}
+
+	var found bool
+	// Check for a DNS wildcard at each label starting with the registered domain
+	resolve.RegisteredToFQDN(domain, name, func(sub string) bool {
+		if w := p.getWildcard(ctx, sub); w.respMatchesWildcard(resp) {
+			found = true
+			return true
+		}
+		return false
+	})
+	return found
+}
+
+func (p *Pool) getWildcard(ctx context.Context, sub string) *wildcard {
+	p.Lock()
+	w, found := p.wildcards[sub]
+	if !found {
+		w = &wildcard{}
+		p.wildcards[sub] = w
+		// hold the wildcard lock until the test has completed
+		w.Lock()
+	}
+	p.Unlock()
+
+	if !found {
+		w.Detected, w.Answers = p.wildcardTest(ctx, sub)
+		w.Unlock()
+	}
+	return w
+}
+
+func (w *wildcard) respMatchesWildcard(resp *dns.Msg) bool {
+	w.Lock()
+	defer w.Unlock()
+
+	if w.Detected {
+		if len(w.Answers) == 0 || len(resp.Answer) == 0 {
+			return w.Detected
+		}
+
+		set := stringset.New()
+		defer set.Close()
+
+		insertRecordData(set, resolve.ExtractAnswers(resp))
+		intersectRecordData(set, w.Answers)
+		if set.Len() > 0 {
+			return w.Detected
+		}
+	}
+	return false
+}
+
+// Determines if the provided subdomain has a DNS wildcard.
+func (p *Pool) wildcardTest(ctx context.Context, sub string) (bool, []*resolve.ExtractedAnswer) {
+	var detected bool
+	var answers []*resolve.ExtractedAnswer
+
+	set := stringset.New()
+	defer set.Close()
+	// Query multiple times with unlikely names against this subdomain
+	for i := 0; i < numOfWildcardTests; i++ {
+		var name string
+		for name == "" {
+			name = resolve.UnlikelyName(sub)
+		}
+
+		var ans []*resolve.ExtractedAnswer
+		for _, t := range wildcardQueryTypes {
+			if a := p.makeQueryAttempts(ctx, name, t); len(a) > 0 {
+				detected = true
+				ans = append(ans, a...)
+			}
+		}
+
+		if i == 0 {
+			insertRecordData(set, ans)
+		} else {
+			intersectRecordData(set, ans)
+		}
+		answers = append(answers, ans...)
+	}
+
+	already := stringset.New()
+	defer already.Close()
+
+	var final []*resolve.ExtractedAnswer
+	// Create the slice of answers common across all the responses from unlikely name queries
+	for _, a := range answers {
+		a.Data = strings.Trim(a.Data, ".")
+
+		if set.Has(a.Data) && !already.Has(a.Data) {
+			final = append(final, a)
+			already.Insert(a.Data)
+		}
+	}
+	if detected {
+		if d := p.getDetectionResolver(); d != nil {
+			p.Lock()
+			p.log.Printf("DNS wildcard detected: Resolver %s: %s", d.address, "*."+sub)
+			p.Unlock()
+		}
+	}
+	return detected, final
+}
+
+func (p *Pool) makeQueryAttempts(ctx context.Context, name string, qtype uint16) []*resolve.ExtractedAnswer {
+	for i := 0; i < maxQueryAttempts; i++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		detector := p.getDetectionResolver()
+		if detector == nil {
+			break
+		}
+
+		resp, err := p.exchangeWith(ctx, detector, resolve.QueryMsg(name, qtype))
+		if err != nil {
+			continue
+		}
+		// Check if the response indicates that the name does not exist
+		if resp.Rcode == dns.RcodeNameError {
+			break
+		}
+		if resp.Rcode == dns.RcodeSuccess {
+			if len(resp.Answer) == 0 {
+				break
+			}
+			return resolve.ExtractAnswers(resp)
+		}
+	}
+	return nil
+}
+
+func intersectRecordData(set *stringset.Set, ans []*resolve.ExtractedAnswer) {
+	records := stringset.New()
+	defer records.Close()
+
+	for _, a := range ans {
+		records.Insert(strings.Trim(a.Data, "."))
+	}
+	set.Intersect(records)
+}
+
+func insertRecordData(set *stringset.Set, ans []*resolve.ExtractedAnswer) {
+	records := stringset.New()
+	defer records.Close()
+
+	for _, a := range ans {
+		records.Insert(strings.Trim(a.Data, "."))
+	}
+	set.Union(records)
+}
+
+// NsecTraversal attempts to retrieve a DNS zone using NSEC-walking.
+func (p *Pool) NsecTraversal(ctx context.Context, domain string) ([]*dns.NSEC, error) {
+	select {
+	case <-ctx.Done():
+		return nil, errors.New("the context has expired")
+	case <-p.done:
+		return nil, errors.New("the resolver pool has been stopped")
+	default:
+	}
+
+	var err error
+	domain = domain + "."
+	var results []*dns.NSEC
+	names := make(map[string]struct{})
+	for next := domain; ; {
+		var nsec *dns.NSEC
+
+		nsec, err = p.searchGap(ctx, next)
+		if err != nil {
+			break
+		}
+		if _, yes := names[nsec.NextDomain]; yes {
+			break
+		}
+		names[nsec.NextDomain] = struct{}{}
+
+		next = nsec.NextDomain
+		results = append(results, nsec)
+		if next == domain {
+			break
+		}
+	}
+	return results, err
+}
+
+func (p *Pool) searchGap(ctx context.Context, name string) (*dns.NSEC, error) {
+	for i := 0; i < maxQueryAttempts; i++ {
+		resp, err := p.QueryBlocking(ctx, resolve.WalkMsg(name, dns.TypeNSEC))
+		if err != nil || resp.Rcode == dns.RcodeNameError {
+			break
+		}
+		if resp.Rcode == dns.RcodeSuccess {
+			if len(resp.Answer) == 0 {
+				break
+			}
+			for _, rr := range append(resp.Answer, resp.Ns...) {
+				if nsec, ok := rr.(*dns.NSEC); ok {
+					return nsec, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("NsecTraversal: %s NSEC record not found", name)
+}
+
+// FirstProperSubdomain returns the first subdomain name using the provided name and
+// Pool that responds successfully to a DNS query for the NS record type.
+func FirstProperSubdomain(ctx context.Context, p *Pool, name string) string {
+	var domain string
+	// Obtain all parts of the subdomain name
+	labels := strings.Split(strings.TrimSpace(name), ".")
+loop:
+	for i := 0; i < len(labels)-1; i++ {
+		sub := strings.Join(labels[i:], ".")
+
+		for j := 0; j < maxQueryAttempts; j++ {
+			resp, err := p.QueryBlocking(ctx, resolve.QueryMsg(sub, dns.TypeNS))
+			if err != nil || resp.Rcode == dns.RcodeNameError {
+				continue loop
+			}
+			if resp.Rcode == dns.RcodeSuccess {
+				if len(resp.Answer) == 0 {
+					continue loop
+				}
+				if d := resolve.AnswersByType(resolve.ExtractAnswers(resp), dns.TypeNS); len(d) > 0 {
+					domain = sub
+					break loop
+				}
+			}
+		}
+	}
+	return domain
+}
@@ -16,7 +16,7 @@ import (
 	"strconv"
 )
 
-//go:embed scripts ip2asn-combined.tsv.gz alterations.txt namelist.txt user_agents.txt
+//go:embed rdap scripts ip2asn-combined.tsv.gz alterations.txt namelist.txt user_agents.txt
 var resourceFS embed.FS
 
 // IP2ASN is a range record provided by the iptoasn.com service.
@@ -42,10 +42,16 @@ func GetIP2ASNData() ([]*IP2ASN, error) {
 	}
 	defer zr.Close()
 
+	return ReadIP2ASNData(zr)
+}
+
+// ReadIP2ASNData returns all the range records read from the tab-separated iptoasn.com formatted data.
+func ReadIP2ASNData(reader io.Reader) ([]*IP2ASN, error) {
 	var ranges []*IP2ASN
-	r := csv.NewReader(zr)
+	r := csv.NewReader(reader)
 	r.Comma = '\t'
 	r.FieldsPerRecord = 5
+	r.LazyQuotes = true
 	for {
 		record, err := r.Read()
 		if err == io.EOF {
@@ -0,0 +1,110 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package systems
+
+import (
+	"errors"
+	"time"
+
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/owasp-amass/amass/v4/worker"
+	"github.com/owasp-amass/config/config"
+)
+
+// WorkersResolver is the name of the resolver sending the queries to the workers in the resolver
+// pool statistics.
+const WorkersResolver = "workers"
+
+// DistributedSystem is a LocalSystem sending the queries of the untrusted resolvers to a cluster
+// of remote workers. The brute forcing and other forward queries are sharded across the workers,
+// while the trusted resolvers, data sources and graph databases remain in this process.
+type DistributedSystem struct {
+	*LocalSystem
+	cluster *worker.Cluster
+}
+
+// NewDistributedSystem returns a DistributedSystem sending the untrusted queries to the cluster.
+// The cluster is closed when the System is shut down.
+func NewDistributedSystem(cfg *config.Config, dialer *amassnet.Dialer, cluster *worker.Cluster) (*DistributedSystem, error) {
+	if cluster == nil {
+		return nil, errors.New("the system was not provided a cluster of workers")
+	}
+
+	l, err := newLocalSystem(cfg, dialer, func(web *http.Client, trusted *resolvers.Pool, rep *resolvers.Reputation) (*resolvers.Pool, error) {
+		qps := cluster.Capacity()
+		if qps == 0 {
+			return nil, worker.ErrNoWorkers
+		}
+
+		pool := resolvers.NewPool()
+		pool.SetLogger(cfg.Log)
+		if cfg.MaxDNSQueries > 0 {
+			pool.SetMaxQPS(cfg.MaxDNSQueries)
+		} else {
+			cfg.MaxDNSQueries = qps
+		}
+		if err := pool.AddExchanger(WorkersResolver, qps, cluster); err != nil {
+			pool.Stop()
+			return nil, err
+		}
+		// the workers need time to retry with their own resolvers before the query fails
+		pool.SetTimeout(worker.QueryTimeout + time.Second)
+		return pool, nil
+	})
+	if err != nil {
+		_ = cluster.Close()
+		return nil, err
+	}
+	// the reputation tracks the local resolvers, and the workers keep their own
+	l.reputation = nil
+
+	return &DistributedSystem{
+		LocalSystem: l,
+		cluster:     cluster,
+	}, nil
+}
+
+// Cluster returns the workers resolving the untrusted queries of the System.
+func (d *DistributedSystem) Cluster() *worker.Cluster {
+	return d.cluster
+}
+
+// Shutdown implements the System interface.
+func (d *DistributedSystem) Shutdown() error {
+	err := d.LocalSystem.Shutdown()
+
+	_ = d.cluster.Close()
+	return err
+}
+
+// NewWorkerPool returns the pool of resolvers a worker uses for the queries of the coordinators.
+// The pool is built from the configuration like the untrusted resolvers of a LocalSystem, and
+// the trusted resolvers are only used to check the untrusted resolvers for hijacking.
+func NewWorkerPool(cfg *config.Config, dialer *amassnet.Dialer) (*resolvers.Pool, error) {
+	if dialer == nil {
+		dialer = amassnet.NewDialer()
+	}
+	web, err := http.NewClient(nil, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	trusted, num := trustedResolvers(cfg, dialer)
+	if trusted == nil || num == 0 {
+		return nil, errors.New("the worker was unable to build the pool of trusted resolvers")
+	}
+	defer trusted.Stop()
+
+	pool, num := untrustedResolvers(cfg, dialer, web, trusted, loadReputation(cfg))
+	if pool == nil || num == 0 {
+		if pool != nil {
+			pool.Stop()
+		}
+		return nil, errors.New("the worker was unable to build the pool of resolvers")
+	}
+	return pool, nil
+}
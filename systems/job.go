@@ -0,0 +1,152 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package systems
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/caffix/netmap"
+	"github.com/caffix/service"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/provenance"
+	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/owasp-amass/config/config"
+)
+
+// JobSystem implements a System for one of the enumerations sharing a long-running parent System.
+// The resolvers, dialer, ASN cache, graph databases and provenance of the parent are reused, while
+// the configuration and the data sources belong to the job, since the data sources report their
+// findings to a single enumeration and use the configuration to determine the scope. The cookies
+// of the web client and the addresses swept also belong to the job, so the concurrent jobs do
+// not affect each other.
+type JobSystem struct {
+	sync.Mutex
+	Cfg     *config.Config
+	parent  System
+	web     *http.Client
+	sweeps  *Sweeps
+	sources []service.Service
+	done    bool
+}
+
+// NewJobSystem returns a JobSystem using the provided configuration and the architecture of the parent System.
+func NewJobSystem(cfg *config.Config, parent System) *JobSystem {
+	web, err := http.NewClient(nil, parent.Dialer())
+	if err != nil {
+		web = parent.WebClient()
+	}
+
+	return &JobSystem{
+		Cfg:    cfg,
+		parent: parent,
+		web:    web,
+		sweeps: NewSweeps(MaxSweepQueries),
+	}
+}
+
+// Config implements the System interface.
+func (j *JobSystem) Config() *config.Config {
+	return j.Cfg
+}
+
+// Resolvers implements the System interface.
+func (j *JobSystem) Resolvers() *resolvers.Pool {
+	return j.parent.Resolvers()
+}
+
+// TrustedResolvers implements the System interface.
+func (j *JobSystem) TrustedResolvers() *resolvers.Pool {
+	return j.parent.TrustedResolvers()
+}
+
+// Cache implements the System interface.
+func (j *JobSystem) Cache() *requests.ASNCache {
+	return j.parent.Cache()
+}
+
+// Dialer implements the System interface.
+func (j *JobSystem) Dialer() *amassnet.Dialer {
+	return j.parent.Dialer()
+}
+
+// WebClient implements the System interface.
+func (j *JobSystem) WebClient() *http.Client {
+	return j.web
+}
+
+// Sweeps implements the System interface.
+func (j *JobSystem) Sweeps() *Sweeps {
+	return j.sweeps
+}
+
+// AddSource implements the System interface.
+func (j *JobSystem) AddSource(src service.Service) error {
+	j.Lock()
+	defer j.Unlock()
+
+	j.sources = append(j.sources, src)
+	sort.Slice(j.sources, func(a, b int) bool {
+		return j.sources[a].String() < j.sources[b].String()
+	})
+	return nil
+}
+
+// AddAndStart implements the System interface.
+func (j *JobSystem) AddAndStart(srv service.Service) error {
+	err := srv.Start()
+
+	if err == nil {
+		return j.AddSource(srv)
+	}
+	return err
+}
+
+// DataSources implements the System interface.
+func (j *JobSystem) DataSources() []service.Service {
+	j.Lock()
+	defer j.Unlock()
+
+	return append([]service.Service(nil), j.sources...)
+}
+
+// SetDataSources implements the System interface.
+func (j *JobSystem) SetDataSources(sources []service.Service) error {
+	return startDataSources(j, sources)
+}
+
+// GraphDatabases implements the System interface.
+func (j *JobSystem) GraphDatabases() []*netmap.Graph {
+	return j.parent.GraphDatabases()
+}
+
+// Provenance implements the System interface.
+func (j *JobSystem) Provenance() *provenance.Store {
+	return j.parent.Provenance()
+}
+
+// GetMemoryUsage implements the System interface.
+func (j *JobSystem) GetMemoryUsage() uint64 {
+	return j.parent.GetMemoryUsage()
+}
+
+// Shutdown implements the System interface. Only the data sources of the job are
+// stopped, and the parent System remains available to the other jobs.
+func (j *JobSystem) Shutdown() error {
+	j.Lock()
+	if j.done {
+		j.Unlock()
+		return nil
+	}
+	j.done = true
+	sources := j.sources
+	j.sources = nil
+	j.Unlock()
+
+	stopDataSources(sources)
+	return nil
+}
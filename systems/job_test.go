@@ -0,0 +1,63 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package systems
+
+import (
+	"testing"
+
+	"github.com/caffix/service"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/config/config"
+	"github.com/stretchr/testify/require"
+)
+
+type testSource struct {
+	*service.BaseService
+}
+
+func newTestSource(name string) *testSource {
+	s := new(testSource)
+
+	s.BaseService = service.NewBaseService(s, name)
+	return s
+}
+
+func TestJobSystem(t *testing.T) {
+	parent := NewSimpleSystem(config.NewConfig(), nil, nil)
+	defer func() { _ = parent.Shutdown() }()
+	parent.Dial = amassnet.NewDialer()
+
+	cfg := config.NewConfig()
+	cfg.AddDomain("owasp.org")
+	job := NewJobSystem(cfg, parent)
+	require.Equal(t, cfg, job.Config())
+	require.Equal(t, parent.GraphDatabases(), job.GraphDatabases())
+	require.Equal(t, parent.Provenance(), job.Provenance())
+	require.Equal(t, parent.Cache(), job.Cache())
+	require.Same(t, parent.Dialer(), job.Dialer())
+	// The cookies and swept addresses of the concurrent jobs are kept apart
+	other := NewJobSystem(config.NewConfig(), parent)
+	require.NotSame(t, job.WebClient(), other.WebClient())
+	require.NotSame(t, job.Sweeps(), other.Sweeps())
+	require.True(t, job.Sweeps().Add("192.0.2.1"))
+	require.True(t, other.Sweeps().Add("192.0.2.1"))
+
+	b, a := newTestSource("b"), newTestSource("a")
+	require.NoError(t, job.SetDataSources([]service.Service{b, a}))
+	require.Equal(t, []service.Service{a, b}, job.DataSources())
+	require.Empty(t, parent.DataSources())
+
+	// Only the data sources of the job are stopped
+	require.NoError(t, job.Shutdown())
+	require.Empty(t, job.DataSources())
+	for _, src := range []*testSource{a, b} {
+		select {
+		case <-src.Done():
+		default:
+			t.Errorf("the %s data source was not stopped", src)
+		}
+	}
+	require.NoError(t, job.Shutdown())
+}
@@ -5,57 +5,107 @@
 package systems
 
 import (
+	"context"
+	"encoding/csv"
 	"errors"
 	"fmt"
-	"net"
+	"io"
 	"os"
 	"path/filepath"
 	"runtime"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/caffix/netmap"
 	"github.com/caffix/service"
+	"github.com/owasp-amass/amass/v4/asndb"
 	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/provenance"
 	"github.com/owasp-amass/amass/v4/requests"
-	"github.com/owasp-amass/amass/v4/resources"
+	"github.com/owasp-amass/amass/v4/resolvers"
 	"github.com/owasp-amass/config/config"
 	"github.com/owasp-amass/resolve"
 )
 
+const (
+	// publicResolversURL provides the public DNS resolvers and their reliability
+	publicResolversURL = "https://public-dns.info/nameservers-all.csv"
+	// the reliability required of the public DNS resolvers used by the System
+	minResolverReliability = 0.85
+)
+
 // LocalSystem implements a System to be executed within a single process.
 type LocalSystem struct {
 	Cfg               *config.Config
-	pool              *resolve.Resolvers
-	trusted           *resolve.Resolvers
+	pool              *resolvers.Pool
+	trusted           *resolvers.Pool
+	reputation        *resolvers.Reputation
 	graphs            []*netmap.Graph
+	sources           *provenance.Store
 	cache             *requests.ASNCache
+	store             *asndb.Store
+	dialer            *amassnet.Dialer
+	web               *http.Client
+	sweeps            *Sweeps
 	done              chan struct{}
 	doneAlreadyClosed bool
 	addSource         chan service.Service
 	allSources        chan chan []service.Service
 }
 
-// NewLocalSystem returns an initialized LocalSystem object.
-func NewLocalSystem(cfg *config.Config) (*LocalSystem, error) {
+// NewLocalSystem returns an initialized LocalSystem object. The System makes its connections
+// using the provided Dialer, or a Dialer making direct connections when it is nil.
+func NewLocalSystem(cfg *config.Config, dialer *amassnet.Dialer) (*LocalSystem, error) {
+	return newLocalSystem(cfg, dialer, func(web *http.Client, trusted *resolvers.Pool, rep *resolvers.Reputation) (*resolvers.Pool, error) {
+		pool, num := untrustedResolvers(cfg, dialer, web, trusted, rep)
+		if pool == nil || num == 0 {
+			return nil, errors.New("the system was unable to build the pool of untrusted resolvers")
+		}
+		if cfg.MaxDNSQueries == 0 {
+			cfg.MaxDNSQueries = pool.QPS()
+		} else {
+			pool.SetMaxQPS(cfg.MaxDNSQueries)
+		}
+		return pool, nil
+	})
+}
+
+// untrustedBuilder returns the pool of untrusted resolvers used by a LocalSystem.
+type untrustedBuilder func(web *http.Client, trusted *resolvers.Pool, rep *resolvers.Reputation) (*resolvers.Pool, error)
+
+func newLocalSystem(cfg *config.Config, dialer *amassnet.Dialer, untrusted untrustedBuilder) (*LocalSystem, error) {
 	if err := cfg.CheckSettings(); err != nil {
 		return nil, err
 	}
 
-	trusted, num := trustedResolvers(cfg)
+	// Make sure that the output directory is setup for this local system
+	if err := setupOutputDirectory(cfg); err != nil {
+		return nil, err
+	}
+
+	// The network interface and proxy selected for this System do not affect the other Systems
+	if dialer == nil {
+		dialer = amassnet.NewDialer()
+	}
+	web, err := http.NewClient(nil, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := loadReputation(cfg)
+	trusted, num := trustedResolvers(cfg, dialer)
 	if trusted == nil || num == 0 {
 		return nil, errors.New("the system was unable to build the pool of trusted resolvers")
 	}
 
-	pool, num := untrustedResolvers(cfg)
-	if pool == nil || num == 0 {
-		return nil, errors.New("the system was unable to build the pool of untrusted resolvers")
-	}
-	if cfg.MaxDNSQueries == 0 {
-		cfg.MaxDNSQueries += num * cfg.ResolversQPS
-	} else {
-		pool.SetMaxQPS(cfg.MaxDNSQueries)
+	pool, err := untrusted(web, trusted, rep)
+	if err != nil {
+		trusted.Stop()
+		return nil, err
 	}
 	// set a single name server rate limiter for both resolver pools
 	rate := resolve.NewRateTracker()
@@ -66,7 +116,11 @@ func NewLocalSystem(cfg *config.Config) (*LocalSystem, error) {
 		Cfg:        cfg,
 		pool:       pool,
 		trusted:    trusted,
+		reputation: rep,
 		cache:      requests.NewASNCache(),
+		dialer:     dialer,
+		web:        web,
+		sweeps:     NewSweeps(MaxSweepQueries),
 		done:       make(chan struct{}, 2),
 		addSource:  make(chan service.Service),
 		allSources: make(chan chan []service.Service, 10),
@@ -77,11 +131,6 @@ func NewLocalSystem(cfg *config.Config) (*LocalSystem, error) {
 		_ = sys.Shutdown()
 		return nil, err
 	}
-	// Make sure that the output directory is setup for this local system
-	if err := sys.setupOutputDirectory(); err != nil {
-		_ = sys.Shutdown()
-		return nil, err
-	}
 	// Setup the correct graph database handler
 	if err := sys.setupGraphDBs(cfg); err != nil {
 		_ = sys.Shutdown()
@@ -92,18 +141,68 @@ func NewLocalSystem(cfg *config.Config) (*LocalSystem, error) {
 	return sys, nil
 }
 
+// NewIsolatedSystem returns a LocalSystem that sends all DNS queries to the provided resolver pools
+// and stores the findings in an in-memory graph. The results of enumerations performed with this
+// System do not depend on the network or the findings of previous enumerations.
+func NewIsolatedSystem(cfg *config.Config, pool, trusted *resolvers.Pool) (*LocalSystem, error) {
+	if err := cfg.CheckSettings(); err != nil {
+		return nil, err
+	}
+	if err := setupOutputDirectory(cfg); err != nil {
+		return nil, err
+	}
+
+	sources, err := provenance.NewStore("memory", "")
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := pool.Dialer()
+	if dialer == nil {
+		dialer = amassnet.NewDialer()
+	}
+	web, err := http.NewClient(nil, dialer)
+	if err != nil {
+		return nil, err
+	}
+
+	pool.SetLogger(cfg.Log)
+	trusted.SetLogger(cfg.Log)
+	sys := &LocalSystem{
+		Cfg:        cfg,
+		pool:       pool,
+		trusted:    trusted,
+		graphs:     []*netmap.Graph{netmap.NewGraph("memory", "", "")},
+		sources:    sources,
+		cache:      requests.NewASNCache(),
+		dialer:     dialer,
+		web:        web,
+		sweeps:     NewSweeps(MaxSweepQueries),
+		done:       make(chan struct{}, 2),
+		addSource:  make(chan service.Service),
+		allSources: make(chan chan []service.Service, 10),
+	}
+
+	go sys.manageDataSources()
+	if err := sys.loadCacheData(); err != nil {
+		_ = sys.Shutdown()
+		return nil, err
+	}
+	return sys, nil
+}
+
 // Config implements the System interface.
 func (l *LocalSystem) Config() *config.Config {
 	return l.Cfg
 }
 
 // Resolvers implements the System interface.
-func (l *LocalSystem) Resolvers() *resolve.Resolvers {
+func (l *LocalSystem) Resolvers() *resolvers.Pool {
 	return l.pool
 }
 
 // TrustedResolvers implements the System interface.
-func (l *LocalSystem) TrustedResolvers() *resolve.Resolvers {
+func (l *LocalSystem) TrustedResolvers() *resolvers.Pool {
 	return l.trusted
 }
 
@@ -112,6 +211,21 @@ func (l *LocalSystem) Cache() *requests.ASNCache {
 	return l.cache
 }
 
+// Dialer implements the System interface.
+func (l *LocalSystem) Dialer() *amassnet.Dialer {
+	return l.dialer
+}
+
+// WebClient implements the System interface.
+func (l *LocalSystem) WebClient() *http.Client {
+	return l.web
+}
+
+// Sweeps implements the System interface.
+func (l *LocalSystem) Sweeps() *Sweeps {
+	return l.sweeps
+}
+
 // AddSource implements the System interface.
 func (l *LocalSystem) AddSource(src service.Service) error {
 	l.addSource <- src
@@ -138,11 +252,16 @@ func (l *LocalSystem) DataSources() []service.Service {
 
 // SetDataSources assigns the data sources that will be used by the system.
 func (l *LocalSystem) SetDataSources(sources []service.Service) error {
+	return startDataSources(l, sources)
+}
+
+// startDataSources adds the data sources that successfully start to the System.
+func startDataSources(sys System, sources []service.Service) error {
 	ch := make(chan error, len(sources))
 	// Add all the data sources that successfully start to the list
 	for _, src := range sources {
 		go func(src service.Service, ch chan error) {
-			ch <- l.AddAndStart(src)
+			ch <- sys.AddAndStart(src)
 		}(src, ch)
 	}
 
@@ -162,11 +281,31 @@ loop:
 	return err
 }
 
+// stopDataSources stops the data sources concurrently and waits for them to finish.
+func stopDataSources(sources []service.Service) {
+	var wg sync.WaitGroup
+
+	for _, src := range sources {
+		wg.Add(1)
+
+		go func(s service.Service, w *sync.WaitGroup) {
+			defer w.Done()
+			_ = s.Stop()
+		}(src, &wg)
+	}
+	wg.Wait()
+}
+
 // GraphDatabases implements the System interface.
 func (l *LocalSystem) GraphDatabases() []*netmap.Graph {
 	return l.graphs
 }
 
+// Provenance implements the System interface.
+func (l *LocalSystem) Provenance() *provenance.Store {
+	return l.sources
+}
+
 // Shutdown implements the System interface.
 func (l *LocalSystem) Shutdown() error {
 	if l.doneAlreadyClosed {
@@ -174,30 +313,32 @@ func (l *LocalSystem) Shutdown() error {
 	}
 	l.doneAlreadyClosed = true
 
-	var wg sync.WaitGroup
-	for _, src := range l.DataSources() {
-		wg.Add(1)
-
-		go func(s service.Service, w *sync.WaitGroup) {
-			defer w.Done()
-			_ = s.Stop()
-		}(src, &wg)
-	}
-
-	wg.Wait()
+	stopDataSources(l.DataSources())
 	close(l.done)
 	for range l.GraphDatabases() {
 		//g.Close()
 	}
 
+	if l.reputation != nil {
+		l.reputation.Update(l.pool.Stats())
+		if err := l.reputation.Save(); err != nil {
+			l.Cfg.Log.Printf("%v", err)
+		}
+	}
 	l.pool.Stop()
 	l.trusted.Stop()
+	if l.store != nil {
+		_ = l.store.Close()
+	}
+	if l.sources != nil {
+		_ = l.sources.Close()
+	}
 	l.cache = nil
 	return nil
 }
 
-func (l *LocalSystem) setupOutputDirectory() error {
-	path := config.OutputDirectory(l.Cfg.Dir)
+func setupOutputDirectory(cfg *config.Config) error {
+	path := config.OutputDirectory(cfg.Dir)
 	if path == "" {
 		return nil
 	}
@@ -213,33 +354,60 @@ func (l *LocalSystem) setupOutputDirectory() error {
 
 // Select the graph that will store the System findings.
 func (l *LocalSystem) setupGraphDBs(cfg *config.Config) error {
-	// Add the local database settings to the configuration
-	cfg.GraphDBs = append(cfg.GraphDBs, cfg.LocalDatabaseSettings(cfg.GraphDBs))
-
-	for _, db := range cfg.GraphDBs {
-		if db.Primary {
-			var g *netmap.Graph
-
-			if db.System == "local" {
-				g = netmap.NewGraph(db.System, filepath.Join(config.OutputDirectory(cfg.Dir), "amass.sqlite"), db.Options)
-			} else {
-				connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s", db.Host, db.Port, db.Username, db.Password, db.DBName)
-				g = netmap.NewGraph(db.System, connStr, db.Options)
-			}
+	g, err := PrimaryGraph(cfg)
+	if err != nil {
+		return err
+	}
+	l.graphs = append(l.graphs, g)
 
-			if g == nil {
-				return fmt.Errorf("System: failed to create the graph for database: %s", db.System)
-			}
+	sources, err := PrimaryProvenance(cfg)
+	if err != nil {
+		return err
+	}
+	l.sources = sources
+	return nil
+}
 
-			l.graphs = append(l.graphs, g)
-			break
-		}
+// PrimaryGraph returns the graph for the primary database in the configuration,
+// which is the local database when no other database is the primary.
+func PrimaryGraph(cfg *config.Config) (*netmap.Graph, error) {
+	db, dsn, err := primaryDatabase(cfg)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(l.graphs) == 0 {
-		return errors.New("System: no primary databases found to create the graph")
+	g := netmap.NewGraph(db.System, dsn, db.Options)
+	if g == nil {
+		return nil, fmt.Errorf("System: failed to create the graph for database: %s", db.System)
 	}
-	return nil
+	return g, nil
+}
+
+// PrimaryProvenance returns the store keeping the data sources that discovered each asset
+// in the primary database of the configuration.
+func PrimaryProvenance(cfg *config.Config) (*provenance.Store, error) {
+	db, dsn, err := primaryDatabase(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return provenance.NewStore(db.System, dsn)
+}
+
+// primaryDatabase returns the settings and data source name of the primary database.
+func primaryDatabase(cfg *config.Config) (*config.Database, string, error) {
+	dbs := append([]*config.Database{}, cfg.GraphDBs...)
+	dbs = append(dbs, cfg.LocalDatabaseSettings(cfg.GraphDBs))
+
+	for _, db := range dbs {
+		if !db.Primary {
+			continue
+		}
+		if db.System == "local" {
+			return db, filepath.Join(config.OutputDirectory(cfg.Dir), "amass.sqlite"), nil
+		}
+		return db, fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s", db.Host, db.Port, db.Username, db.Password, db.DBName), nil
+	}
+	return nil, "", errors.New("System: no primary databases found to create the graph")
 }
 
 // GetMemoryUsage returns the number bytes allocated to heap objects on this system.
@@ -269,58 +437,61 @@ func (l *LocalSystem) manageDataSources() {
 }
 
 func (l *LocalSystem) loadCacheData() error {
-	ranges, err := resources.GetIP2ASNData()
-	if err != nil {
-		return err
+	dir := config.OutputDirectory(l.Cfg.Dir)
+	if dir == "" {
+		return errors.New("failed to obtain the output directory for the ASN database")
 	}
 
-	for _, r := range ranges {
-		cidr := amassnet.Range2CIDR(r.FirstIP, r.LastIP)
-		if cidr == nil {
-			continue
-		}
-		if ones, _ := cidr.Mask.Size(); ones == 0 {
-			continue
-		}
-
-		l.cache.Update(&requests.ASNRequest{
-			Address:     r.FirstIP.String(),
-			ASN:         r.ASN,
-			CC:          r.CC,
-			Prefix:      cidr.String(),
-			Description: r.Description,
-		})
+	store, err := asndb.OpenOrBuild(filepath.Join(dir, asndb.Filename))
+	if err != nil {
+		return fmt.Errorf("failed to load the ASN database: %v", err)
 	}
+
+	l.store = store
+	l.cache.SetStore(store)
 	return nil
 }
 
-func trustedResolvers(cfg *config.Config) (*resolve.Resolvers, int) {
-	pool := resolve.NewResolvers()
+func trustedResolvers(cfg *config.Config, d *amassnet.Dialer) (*resolvers.Pool, int) {
+	pool := resolvers.NewPool()
+	pool.SetLogger(cfg.Log)
+	pool.SetDialer(d)
 	trusted := config.DefaultBaselineResolvers
+	detector := "8.8.8.8"
 	if len(cfg.TrustedResolvers) > 0 {
-		trusted = cfg.TrustedResolvers
+		trusted = checkAddresses(cfg, cfg.TrustedResolvers)
+		// The provided resolvers may be the only ones reachable on the network
+		if len(trusted) > 0 {
+			detector = trusted[0]
+		}
 	}
 
 	_ = pool.AddResolvers(cfg.TrustedQPS, trusted...)
-	pool.SetDetectionResolver(cfg.TrustedQPS, "8.8.8.8")
+	pool.SetDetectionResolver(cfg.TrustedQPS, detector)
 
-	pool.SetLogger(cfg.Log)
 	pool.SetTimeout(2 * time.Second)
 	return pool, pool.Len()
 }
 
-func untrustedResolvers(cfg *config.Config) (*resolve.Resolvers, int) {
+func untrustedResolvers(cfg *config.Config, d *amassnet.Dialer, web *http.Client, trusted *resolvers.Pool, rep *resolvers.Reputation) (*resolvers.Pool, int) {
 	if len(cfg.Resolvers) == 0 {
-		cfg.Resolvers = publicResolverAddrs(cfg)
+		cfg.Resolvers = publicResolverAddrs(cfg, web)
 		if len(cfg.Resolvers) == 0 {
 			// Failed to use the public DNS resolvers database
 			cfg.Resolvers = config.DefaultBaselineResolvers
 		}
 	}
-	cfg.Resolvers = checkAddresses(cfg.Resolvers)
+	cfg.Resolvers = checkAddresses(cfg, cfg.Resolvers)
+	// Skip the resolvers that misbehaved during previous enumerations
+	if rep != nil {
+		if addrs := rep.Sort(cfg.Resolvers); len(addrs) > 0 {
+			cfg.Resolvers = addrs
+		}
+	}
 
-	pool := resolve.NewResolvers()
+	pool := resolvers.NewPool()
 	pool.SetLogger(cfg.Log)
+	pool.SetDialer(d)
 	if cfg.MaxDNSQueries > 0 {
 		pool.SetMaxQPS(cfg.MaxDNSQueries)
 	}
@@ -335,34 +506,92 @@ func untrustedResolvers(cfg *config.Config) (*resolve.Resolvers, int) {
 		CountQueryRefusals:  true,
 	})
 	pool.ClientSubnetCheck()
+	pool.HijackCheck(context.Background(), trusted)
 	return pool, pool.Len()
 }
 
-func publicResolverAddrs(cfg *config.Config) []string {
-	addrs := config.PublicResolvers
+func loadReputation(cfg *config.Config) *resolvers.Reputation {
+	dir := config.OutputDirectory(cfg.Dir)
+	if dir == "" {
+		return nil
+	}
 
-	if len(config.PublicResolvers) == 0 {
-		if err := config.GetPublicDNSResolvers(); err != nil {
-			cfg.Log.Printf("%v", err)
+	rep, err := resolvers.LoadReputation(filepath.Join(dir, resolvers.ReputationFilename))
+	if err != nil {
+		cfg.Log.Printf("%v", err)
+	}
+	return rep
+}
+
+// publicResolverAddrs obtains the reliable public DNS resolvers using the web client of the System,
+// so the request is made through the proxy selected for the System.
+func publicResolverAddrs(cfg *config.Config, web *http.Client) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	resp, err := web.RequestWebPage(ctx, &http.Request{URL: publicResolversURL})
+	if err != nil || resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		cfg.Log.Printf("failed to obtain the Public DNS csv file at %s: %v", publicResolversURL, err)
+		return nil
+	}
+	return parsePublicResolvers(resp.Body)
+}
+
+// parsePublicResolvers returns the addresses of the reliable resolvers in the public-dns.info
+// CSV file, excluding the baseline resolvers.
+func parsePublicResolvers(data string) []string {
+	baseline := make(map[string]struct{}, len(config.DefaultBaselineResolvers))
+	for _, addr := range config.DefaultBaselineResolvers {
+		baseline[addr] = struct{}{}
+	}
+
+	var addrs []string
+	ipIdx, relIdx := -1, -1
+	r := csv.NewReader(strings.NewReader(data))
+	r.FieldsPerRecord = -1
+	for i := 0; ; i++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			continue
+		}
+		if i == 0 {
+			for idx, val := range record {
+				switch val {
+				case "ip_address":
+					ipIdx = idx
+				case "reliability":
+					relIdx = idx
+				}
+			}
+			continue
+		}
+		if ipIdx < 0 || relIdx < 0 || ipIdx >= len(record) || relIdx >= len(record) {
+			continue
+		}
+		if _, found := baseline[record[ipIdx]]; found {
+			continue
+		}
+		if rel, err := strconv.ParseFloat(record[relIdx], 64); err == nil && rel >= minResolverReliability {
+			addrs = append(addrs, record[ipIdx])
 		}
-		addrs = config.PublicResolvers
 	}
 	return addrs
 }
 
-func checkAddresses(addrs []string) []string {
-	ips := []string{}
+// checkAddresses returns the valid resolver entries in the form used by the resolver pools.
+func checkAddresses(cfg *config.Config, addrs []string) []string {
+	valid := []string{}
 
 	for _, addr := range addrs {
-		ip, port, err := net.SplitHostPort(addr)
+		a, qps, err := resolvers.ParseResolver(addr)
 		if err != nil {
-			ip = addr
-			port = "53"
-		}
-		if net.ParseIP(ip) == nil {
+			cfg.Log.Printf("%v", err)
 			continue
 		}
-		ips = append(ips, net.JoinHostPort(ip, port))
+		valid = append(valid, resolvers.FormatResolver(a, qps))
 	}
-	return ips
+	return valid
 }
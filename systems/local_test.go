@@ -5,8 +5,16 @@
 package systems
 
 import (
+	"context"
 	"reflect"
 	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/amasstest"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/owasp-amass/config/config"
+	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
 )
 
 func TestCheckAddresses(t *testing.T) {
@@ -45,14 +53,73 @@ func TestCheckAddresses(t *testing.T) {
 			addr:     []string{"192.168.61.221", "NotAnIP:80", "111.111.111.111:111"},
 			expected: []string{"192.168.61.221:53", "111.111.111.111:111"},
 		},
+		{
+			name:     "IPv6 without port",
+			addr:     []string{"2001:4860:4860::8888"},
+			expected: []string{"[2001:4860:4860::8888]:53"},
+		},
+		{
+			name:     "DNS-over-TLS",
+			addr:     []string{"tls://1.1.1.1", "tls://9.9.9.9:8853", "tls://dns.example.com"},
+			expected: []string{"tls://1.1.1.1:853", "tls://9.9.9.9:8853", "tls://dns.example.com:853"},
+		},
+		{
+			name:     "DNS-over-HTTPS",
+			addr:     []string{"https://dns.google/dns-query", "https://1.1.1.1", "https://"},
+			expected: []string{"https://dns.google/dns-query", "https://1.1.1.1/dns-query"},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			ips := checkAddresses(tt.addr)
+			ips := checkAddresses(config.NewConfig(), tt.addr)
 			if !reflect.DeepEqual(ips, tt.expected) {
 				t.Errorf("Unexpected Result, expected %v, got %v", tt.expected, ips)
 			}
 		})
 	}
 }
+
+func TestParsePublicResolvers(t *testing.T) {
+	data := "ip_address,name,reliability\n" +
+		"192.0.2.1,reliable,1.00\n" +
+		"192.0.2.2,unreliable,0.50\n" +
+		config.DefaultBaselineResolvers[0] + ",baseline,1.00\n" +
+		"short\n" +
+		"192.0.2.3,,0.90\n"
+
+	expected := []string{"192.0.2.1", "192.0.2.3"}
+	if addrs := parsePublicResolvers(data); !reflect.DeepEqual(addrs, expected) {
+		t.Errorf("Unexpected Result, expected %v, got %v", expected, addrs)
+	}
+}
+
+func TestLocalSystemOffline(t *testing.T) {
+	srv, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	defer srv.Close()
+	require.NoError(t, srv.LoadZoneString("example.com", "www IN A 127.0.0.80"))
+	// The resolvers are expected to answer the client subnet check
+	require.NoError(t, srv.LoadZoneString("l.google.com", `o-o.myaddr IN TXT "edns0-client-subnet 127.0.0.1/32"`))
+
+	cfg := config.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.Resolvers = []string{srv.Addr()}
+	cfg.TrustedResolvers = []string{srv.Addr()}
+	sys, err := NewLocalSystem(cfg, nil)
+	require.NoError(t, err)
+	defer func() { _ = sys.Shutdown() }()
+
+	require.Equal(t, 1, sys.Resolvers().Len())
+	require.Equal(t, 1, sys.TrustedResolvers().Len())
+	require.Equal(t, cfg.ResolversQPS, cfg.MaxDNSQueries)
+
+	for _, pool := range []*resolvers.Pool{sys.Resolvers(), sys.TrustedResolvers()} {
+		resp, err := pool.QueryBlocking(context.Background(), resolve.QueryMsg("www.example.com", dns.TypeA))
+		require.NoError(t, err)
+		ans := resolve.ExtractAnswers(resp)
+		require.Len(t, ans, 1)
+		require.Equal(t, "127.0.0.80", ans[0].Data)
+	}
+	require.NotZero(t, srv.Queries("udp"))
+}
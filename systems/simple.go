@@ -9,32 +9,78 @@ import (
 
 	"github.com/caffix/netmap"
 	"github.com/caffix/service"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/provenance"
 	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resolvers"
 	"github.com/owasp-amass/config/config"
-	"github.com/owasp-amass/resolve"
 )
 
+// SimpleSystem implements a System with a single data source, intended for testing components in isolation.
 type SimpleSystem struct {
 	Cfg      *config.Config
-	Pool     *resolve.Resolvers
-	Trusted  *resolve.Resolvers
+	Pool     *resolvers.Pool
+	Trusted  *resolvers.Pool
 	Graph    *netmap.Graph
+	Sources  *provenance.Store
 	ASNCache *requests.ASNCache
+	Dial     *amassnet.Dialer
+	Web      *http.Client
+	Sweep    *Sweeps
 	Service  service.Service
 }
 
+// NewSimpleSystem returns a SimpleSystem using the provided resolver pools, an in-memory graph and an empty ASN cache.
+func NewSimpleSystem(cfg *config.Config, pool, trusted *resolvers.Pool) *SimpleSystem {
+	if pool != nil {
+		pool.SetLogger(cfg.Log)
+	}
+	if trusted != nil {
+		trusted.SetLogger(cfg.Log)
+	}
+
+	var dialer *amassnet.Dialer
+	if pool != nil {
+		dialer = pool.Dialer()
+	}
+	web, _ := http.NewClient(nil, dialer)
+
+	sources, _ := provenance.NewStore("memory", "")
+	return &SimpleSystem{
+		Cfg:      cfg,
+		Pool:     pool,
+		Trusted:  trusted,
+		Graph:    netmap.NewGraph("memory", "", ""),
+		Sources:  sources,
+		ASNCache: requests.NewASNCache(),
+		Dial:     dialer,
+		Web:      web,
+		Sweep:    NewSweeps(MaxSweepQueries),
+	}
+}
+
 // Config implements the System interface.
 func (ss *SimpleSystem) Config() *config.Config { return ss.Cfg }
 
 // Resolvers implements the System interface.
-func (ss *SimpleSystem) Resolvers() *resolve.Resolvers { return ss.Pool }
+func (ss *SimpleSystem) Resolvers() *resolvers.Pool { return ss.Pool }
 
 // TrustedResolvers implements the System interface.
-func (ss *SimpleSystem) TrustedResolvers() *resolve.Resolvers { return ss.Trusted }
+func (ss *SimpleSystem) TrustedResolvers() *resolvers.Pool { return ss.Trusted }
 
 // Cache implements the System interface.
 func (ss *SimpleSystem) Cache() *requests.ASNCache { return ss.ASNCache }
 
+// Dialer implements the System interface.
+func (ss *SimpleSystem) Dialer() *amassnet.Dialer { return ss.Dial }
+
+// WebClient implements the System interface.
+func (ss *SimpleSystem) WebClient() *http.Client { return ss.Web }
+
+// Sweeps implements the System interface.
+func (ss *SimpleSystem) Sweeps() *Sweeps { return ss.Sweep }
+
 // AddSource implements the System interface.
 func (ss *SimpleSystem) AddSource(src service.Service) error { ss.Service = src; return nil }
 
@@ -50,28 +96,44 @@ func (ss *SimpleSystem) AddAndStart(srv service.Service) error {
 }
 
 // DataSources implements the System interface.
-func (ss *SimpleSystem) DataSources() []service.Service { return []service.Service{ss.Service} }
+func (ss *SimpleSystem) DataSources() []service.Service {
+	if ss.Service == nil {
+		return nil
+	}
+	return []service.Service{ss.Service}
+}
 
 // SetDataSources assigns the data sources that will be used by the system.
 func (ss *SimpleSystem) SetDataSources(sources []service.Service) error {
-	ss.Service = sources[0]
+	if len(sources) > 0 {
+		ss.Service = sources[0]
+	}
 	return nil
 }
 
 // GraphDatabases implements the System interface.
 func (ss *SimpleSystem) GraphDatabases() []*netmap.Graph { return []*netmap.Graph{ss.Graph} }
 
+// Provenance implements the System interface.
+func (ss *SimpleSystem) Provenance() *provenance.Store { return ss.Sources }
+
 // Shutdown implements the System interface.
 func (ss *SimpleSystem) Shutdown() error {
 	if ss.Service != nil {
 		_ = ss.Service.Stop()
 	}
+	if ss.Sources != nil {
+		_ = ss.Sources.Close()
+	}
 	/*if ss.Graph != nil {
 		ss.Graph.Close()
 	}*/
 	if ss.Pool != nil {
 		ss.Pool.Stop()
 	}
+	if ss.Trusted != nil {
+		ss.Trusted.Stop()
+	}
 	if ss.ASNCache != nil {
 		ss.ASNCache = nil
 	}
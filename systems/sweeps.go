@@ -0,0 +1,57 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package systems
+
+import (
+	"sync"
+
+	bf "github.com/tylertreat/BoomFilters"
+)
+
+// MaxSweepQueries is the number of reverse DNS queries the sweeps of a System have in flight.
+const MaxSweepQueries = 1000
+
+// Sweeps keeps the addresses already swept by the reverse DNS sweeps of the data sources using a
+// System, and limits the queries the sweeps send at the same time. Each System has its own Sweeps,
+// so the enumerations using different Systems do not skip the addresses swept by each other.
+type Sweeps struct {
+	sync.Mutex
+	filter *bf.StableBloomFilter
+	slots  chan struct{}
+}
+
+// NewSweeps returns Sweeps allowing max reverse DNS queries in flight.
+func NewSweeps(max int) *Sweeps {
+	if max <= 0 {
+		max = MaxSweepQueries
+	}
+
+	s := &Sweeps{
+		filter: bf.NewDefaultStableBloomFilter(1000000, 0.01),
+		slots:  make(chan struct{}, max),
+	}
+	for i := 0; i < max; i++ {
+		s.slots <- struct{}{}
+	}
+	return s
+}
+
+// Add returns true when the address has not been swept before, and marks it as swept.
+func (s *Sweeps) Add(addr string) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return !s.filter.TestAndAdd([]byte(addr))
+}
+
+// Acquire blocks until another reverse DNS query can be sent.
+func (s *Sweeps) Acquire() {
+	<-s.slots
+}
+
+// Release allows another reverse DNS query to be sent, after one acquired has completed.
+func (s *Sweeps) Release() {
+	s.slots <- struct{}{}
+}
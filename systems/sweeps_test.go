@@ -0,0 +1,46 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package systems
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSweeps(t *testing.T) {
+	first := NewSweeps(1)
+	second := NewSweeps(1)
+
+	require.True(t, first.Add("192.0.2.1"))
+	require.False(t, first.Add("192.0.2.1"))
+	// The addresses swept by another System are not skipped
+	require.True(t, second.Add("192.0.2.1"))
+
+	first.Acquire()
+	acquired := make(chan struct{})
+	go func() {
+		first.Acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("More queries were acquired than allowed")
+	case <-time.After(50 * time.Millisecond):
+	}
+	// The limit of one System does not block the other
+	second.Acquire()
+	second.Release()
+
+	first.Release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("The released query was not acquired")
+	}
+	first.Release()
+}
@@ -10,9 +10,12 @@ import (
 
 	"github.com/caffix/netmap"
 	"github.com/caffix/service"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/amass/v4/net/http"
+	"github.com/owasp-amass/amass/v4/provenance"
 	"github.com/owasp-amass/amass/v4/requests"
+	"github.com/owasp-amass/amass/v4/resolvers"
 	"github.com/owasp-amass/config/config"
-	"github.com/owasp-amass/resolve"
 )
 
 // System is the object type for managing services that perform various reconnaissance activities.
@@ -21,14 +24,23 @@ type System interface {
 	Config() *config.Config
 
 	// Returns the pool that handles queries using untrusted DNS resolvers
-	Resolvers() *resolve.Resolvers
+	Resolvers() *resolvers.Pool
 
 	// Returns the pool that handles queries using trusted DNS resolvers
-	TrustedResolvers() *resolve.Resolvers
+	TrustedResolvers() *resolvers.Pool
 
 	// Returns the cache populated by the system
 	Cache() *requests.ASNCache
 
+	// Dialer returns the Dialer making the network connections of the System
+	Dialer() *amassnet.Dialer
+
+	// WebClient returns the HTTP client used when a data source does not have its own
+	WebClient() *http.Client
+
+	// Sweeps returns the state shared by the reverse DNS sweeps of the data sources
+	Sweeps() *Sweeps
+
 	// AddSource appends the provided data source to the slice of sources managed by the System
 	AddSource(srv service.Service) error
 
@@ -44,6 +56,9 @@ type System interface {
 	// GraphDatabases return the Graphs used by the System
 	GraphDatabases() []*netmap.Graph
 
+	// Provenance returns the store keeping the data sources that discovered each asset
+	Provenance() *provenance.Store
+
 	// GetMemoryUsage() returns the number bytes allocated to heap objects on this system
 	GetMemoryUsage() uint64
 
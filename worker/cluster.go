@@ -0,0 +1,362 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+	"github.com/owasp-amass/resolve"
+)
+
+const (
+	// HealthInterval is how often the coordinator pings each worker
+	HealthInterval = 2 * time.Second
+	// a worker that has not sent a frame for this long is considered dead
+	deadAfter         = 3 * HealthInterval
+	dialTimeout       = 10 * time.Second
+	maxReconnectDelay = time.Minute
+)
+
+// ErrNoWorkers is returned when none of the workers in the cluster are reachable.
+var ErrNoWorkers = errors.New("no workers are available")
+
+// Cluster shards the queries of the coordinator across the workers. It implements the Exchanger
+// interface of the resolver pools, tracks the health of each worker, and sends the queries in
+// flight on a worker that was lost to the remaining workers.
+type Cluster struct {
+	sync.Mutex
+	token   string
+	dialer  *amassnet.Dialer
+	log     *log.Logger
+	members []*member
+	done    chan struct{}
+	closed  bool
+}
+
+type member struct {
+	addr       string
+	remote     *remote
+	capacity   int
+	inflight   int
+	queries    uint64
+	responses  uint64
+	failures   uint64
+	reassigned uint64
+	downs      int
+	dials      int
+	retry      time.Time
+	connecting bool
+	lastErr    string
+}
+
+// WorkerStats is a snapshot of the statistics the coordinator collected for a worker.
+type WorkerStats struct {
+	Address     string `json:"address"`
+	Healthy     bool   `json:"healthy"`
+	Capacity    int    `json:"capacity"`
+	InFlight    int    `json:"in_flight"`
+	Queries     uint64 `json:"queries"`
+	Responses   uint64 `json:"responses"`
+	Failures    uint64 `json:"failures"`
+	Reassigned  uint64 `json:"reassigned"`
+	Disconnects int    `json:"disconnects"`
+	LastError   string `json:"last_error,omitempty"`
+}
+
+// NewCluster connects to the workers at the provided addresses using the token. The workers that
+// cannot be reached are retried in the background, but at least one worker must be reachable.
+func NewCluster(addrs []string, token string, d *amassnet.Dialer, l *log.Logger) (*Cluster, error) {
+	if d == nil {
+		d = amassnet.NewDialer()
+	}
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	c := &Cluster{
+		token:  token,
+		dialer: d,
+		log:    l,
+		done:   make(chan struct{}),
+	}
+	for _, addr := range addrs {
+		addr, err := NormalizeAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		c.members = append(c.members, &member{addr: addr})
+	}
+	if len(c.members) == 0 {
+		return nil, errors.New("failed to provide the addresses of the workers")
+	}
+
+	var wg sync.WaitGroup
+	for _, m := range c.members {
+		wg.Add(1)
+		go func(m *member) {
+			defer wg.Done()
+			c.connect(m)
+		}(m)
+	}
+	wg.Wait()
+
+	if c.Capacity() == 0 {
+		var errs []string
+		for _, s := range c.Stats() {
+			errs = append(errs, s.LastError)
+		}
+		_ = c.Close()
+		return nil, fmt.Errorf("failed to connect to the workers: %v", errs)
+	}
+
+	go c.monitor()
+	return c, nil
+}
+
+// NormalizeAddress adds the default port to a worker address that does not provide one.
+func NormalizeAddress(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, strconv.Itoa(DefaultPort)
+	}
+	if host == "" {
+		return "", fmt.Errorf("invalid worker address: %s", addr)
+	}
+	return net.JoinHostPort(host, port), nil
+}
+
+// Capacity returns the queries per second the healthy workers can resolve.
+func (c *Cluster) Capacity() int {
+	c.Lock()
+	defer c.Unlock()
+
+	var total int
+	for _, m := range c.members {
+		if m.remote != nil {
+			total += m.capacity
+		}
+	}
+	return total
+}
+
+// Stats returns the statistics collected for each worker in the cluster.
+func (c *Cluster) Stats() []*WorkerStats {
+	c.Lock()
+	defer c.Unlock()
+
+	var stats []*WorkerStats
+	for _, m := range c.members {
+		stats = append(stats, &WorkerStats{
+			Address:     m.addr,
+			Healthy:     m.remote != nil,
+			Capacity:    m.capacity,
+			InFlight:    m.inflight,
+			Queries:     m.queries,
+			Responses:   m.responses,
+			Failures:    m.failures,
+			Reassigned:  m.reassigned,
+			Disconnects: m.downs,
+			LastError:   m.lastErr,
+		})
+	}
+	return stats
+}
+
+// Exchange implements the Exchanger interface. The query is sent to the healthy worker with the
+// fewest queries in flight relative to its capacity, and is sent to another worker when the
+// connection is lost before the response arrives.
+func (c *Cluster) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, time.Duration, error) {
+	start := time.Now()
+
+	var reassigned bool
+	for {
+		m, r := c.pick(reassigned)
+		if r == nil {
+			return nil, time.Since(start), ErrNoWorkers
+		}
+
+		resp, err := r.exchange(ctx, msg)
+		c.finish(m, err)
+		if err == nil {
+			return resp, time.Since(start), nil
+		}
+		if !errors.Is(err, ErrWorkerDown) || ctx.Err() != nil {
+			return nil, time.Since(start), err
+		}
+
+		c.markDown(m, r, r.closed())
+		reassigned = true
+	}
+}
+
+// pick selects the worker for the next query and counts the query as in flight.
+func (c *Cluster) pick(reassigned bool) (*member, *remote) {
+	c.Lock()
+	defer c.Unlock()
+
+	var best *member
+	for _, m := range c.members {
+		if m.remote == nil || m.capacity <= 0 {
+			continue
+		}
+		// compare the load of the workers as in flight queries per unit of capacity
+		if best == nil || m.inflight*best.capacity < best.inflight*m.capacity {
+			best = m
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	best.inflight++
+	best.queries++
+	if reassigned {
+		best.reassigned++
+	}
+	return best, best.remote
+}
+
+func (c *Cluster) finish(m *member, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	m.inflight--
+	if err == nil {
+		m.responses++
+	} else if !errors.Is(err, ErrWorkerDown) && !errors.Is(err, context.Canceled) {
+		m.failures++
+	}
+}
+
+// markDown removes the worker from the selection and schedules the reconnection.
+func (c *Cluster) markDown(m *member, r *remote, err error) {
+	r.close(err)
+
+	c.Lock()
+	defer c.Unlock()
+
+	if m.remote != r {
+		return
+	}
+	if err == nil {
+		err = ErrWorkerDown
+	}
+
+	m.remote = nil
+	m.downs++
+	m.lastErr = err.Error()
+	m.retry = time.Now().Add(reconnectDelay(1))
+	c.log.Printf("Worker %s was removed from the cluster: %v", m.addr, err)
+}
+
+func (c *Cluster) connect(m *member) {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	r, err := dialWorker(ctx, c.dialer, m.addr, c.token)
+
+	c.Lock()
+	defer c.Unlock()
+
+	m.connecting = false
+	if err != nil {
+		m.dials++
+		m.lastErr = err.Error()
+		m.retry = time.Now().Add(reconnectDelay(m.dials))
+		return
+	}
+	if c.closed {
+		r.close(nil)
+		return
+	}
+
+	m.remote = r
+	m.capacity = r.capacity
+	m.dials = 0
+	m.lastErr = ""
+	c.log.Printf("Worker %s joined the cluster with a capacity of %d queries per second", m.addr, r.capacity)
+}
+
+// monitor pings the healthy workers, removes the workers that stop responding and reconnects to
+// the workers that were lost.
+func (c *Cluster) monitor() {
+	t := time.NewTicker(HealthInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-t.C:
+		}
+
+		c.checkHealth()
+	}
+}
+
+func (c *Cluster) checkHealth() {
+	c.Lock()
+	members := append([]*member(nil), c.members...)
+	c.Unlock()
+
+	now := time.Now()
+	for _, m := range members {
+		c.Lock()
+		r := m.remote
+		reconnect := r == nil && !m.connecting && now.After(m.retry)
+		if reconnect {
+			m.connecting = true
+		}
+		c.Unlock()
+
+		switch {
+		case reconnect:
+			go c.connect(m)
+		case r == nil:
+		case r.closed() != nil:
+			c.markDown(m, r, r.closed())
+		case r.idle() > deadAfter:
+			c.markDown(m, r, errors.New("the worker stopped responding to the health checks"))
+		default:
+			if err := r.ping(); err != nil {
+				c.markDown(m, r, err)
+			}
+		}
+	}
+}
+
+// Close implements the io.Closer interface and disconnects from the workers.
+func (c *Cluster) Close() error {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.done)
+
+	for _, m := range c.members {
+		if m.remote != nil {
+			m.remote.close(nil)
+			m.remote = nil
+		}
+	}
+	return nil
+}
+
+func reconnectDelay(downs int) time.Duration {
+	return resolve.TruncatedExponentialBackoff(downs, time.Second, maxReconnectDelay)
+}
@@ -0,0 +1,121 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package worker distributes the DNS queries of an enumeration across remote worker processes.
+//
+// The coordinator and the workers exchange frames over TCP. Each frame starts with a one byte
+// type and the big-endian uint32 length of the payload. A connection begins with a handshake
+// proving that both ends hold the same token without sending it: the worker sends a random
+// challenge, the coordinator answers with the HMAC-SHA256 of the challenge and its own challenge,
+// and the worker answers with the HMAC of the coordinator challenge and the number of queries per
+// second it can resolve. Afterwards, the coordinator sends queries and pings, and the worker sends
+// responses and pongs. The frames are not encrypted, so the workers should be reached over
+// trusted networks or tunnels.
+package worker
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// DefaultPort is the TCP port the workers listen on by default.
+const DefaultPort = 4100
+
+const (
+	frameChallenge byte = iota + 1
+	frameAuth
+	frameWelcome
+	frameDenied
+	frameQuery
+	frameResponse
+	framePing
+	framePong
+)
+
+const (
+	frameHeaderSize = 5
+	// a packed DNS message cannot exceed 64KiB, plus the query identifier
+	maxFrameSize = 65535 + 4
+	nonceSize    = 32
+	macSize      = sha256.Size
+	// the HMAC of each side is computed with a different label, so a MAC cannot be replayed
+	coordinatorLabel = "amass-coordinator"
+	workerLabel      = "amass-worker"
+)
+
+// ErrDenied is returned when the worker or the coordinator does not hold the same token.
+var ErrDenied = errors.New("the worker authentication failed")
+
+func writeFrame(w io.Writer, t byte, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return fmt.Errorf("failed to write the frame: %d bytes exceeds the maximum size", len(payload))
+	}
+
+	buf := make([]byte, frameHeaderSize+len(payload))
+	buf[0] = t
+	binary.BigEndian.PutUint32(buf[1:frameHeaderSize], uint32(len(payload)))
+	copy(buf[frameHeaderSize:], payload)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var hdr [frameHeaderSize]byte
+
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > maxFrameSize {
+		return 0, nil, fmt.Errorf("failed to read the frame: %d bytes exceeds the maximum size", size)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], payload, nil
+}
+
+// packID prefixes the payload with the identifier matching a query to its response.
+func packID(id uint32, data []byte) []byte {
+	buf := make([]byte, 4+len(data))
+	binary.BigEndian.PutUint32(buf, id)
+	copy(buf[4:], data)
+	return buf
+}
+
+func unpackID(payload []byte) (uint32, []byte, error) {
+	if len(payload) < 4 {
+		return 0, nil, errors.New("failed to read the identifier of the frame")
+	}
+	return binary.BigEndian.Uint32(payload), payload[4:], nil
+}
+
+func newNonce() ([]byte, error) {
+	nonce := make([]byte, nonceSize)
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate the challenge: %v", err)
+	}
+	return nonce, nil
+}
+
+func sign(token, label string, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(token))
+	mac.Write([]byte(label))
+	mac.Write(nonce)
+	return mac.Sum(nil)
+}
+
+func verify(token, label string, nonce, sum []byte) bool {
+	return hmac.Equal(sign(token, label, nonce), sum)
+}
@@ -0,0 +1,228 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package worker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	amassnet "github.com/owasp-amass/amass/v4/net"
+)
+
+var (
+	// ErrWorkerDown is returned for the queries in flight when the connection to a worker is lost.
+	ErrWorkerDown = errors.New("the connection to the worker was lost")
+	// ErrQueryFailed is returned when the worker did not obtain a response for the query.
+	ErrQueryFailed = errors.New("the worker failed to resolve the query")
+)
+
+// remote is the connection of the coordinator to a single worker.
+type remote struct {
+	sync.Mutex
+	addr     string
+	conn     net.Conn
+	capacity int
+	nextID   uint32
+	pending  map[uint32]chan []byte
+	lastSeen time.Time
+	wmu      sync.Mutex
+	done     chan struct{}
+	err      error
+}
+
+// dialWorker connects to the worker at addr and performs the handshake.
+func dialWorker(ctx context.Context, d *amassnet.Dialer, addr, token string) (*remote, error) {
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the worker at %s: %v", addr, err)
+	}
+
+	r := bufio.NewReader(conn)
+	capacity, err := coordinatorHandshake(conn, r, token)
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("failed the handshake with the worker at %s: %v", addr, err)
+	}
+
+	rem := &remote{
+		addr:     addr,
+		conn:     conn,
+		capacity: capacity,
+		pending:  make(map[uint32]chan []byte),
+		lastSeen: time.Now(),
+		done:     make(chan struct{}),
+	}
+	go rem.readResponses(r)
+	return rem, nil
+}
+
+func coordinatorHandshake(conn net.Conn, r *bufio.Reader, token string) (int, error) {
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	t, challenge, err := readFrame(r)
+	if err != nil {
+		return 0, err
+	}
+	if t != frameChallenge || len(challenge) != nonceSize {
+		return 0, errors.New("the worker did not send a challenge")
+	}
+
+	nonce, err := newNonce()
+	if err != nil {
+		return 0, err
+	}
+	if err := writeFrame(conn, frameAuth, append(sign(token, coordinatorLabel, challenge), nonce...)); err != nil {
+		return 0, err
+	}
+
+	t, welcome, err := readFrame(r)
+	if err != nil {
+		return 0, err
+	}
+	if t == frameDenied {
+		return 0, ErrDenied
+	}
+	// the worker must also prove that it holds the token
+	if t != frameWelcome || len(welcome) != macSize+4 || !verify(token, workerLabel, nonce, welcome[:macSize]) {
+		return 0, ErrDenied
+	}
+	return int(binary.BigEndian.Uint32(welcome[macSize:])), nil
+}
+
+// exchange sends the query to the worker and waits for the response message.
+func (r *remote) exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, error) {
+	data, err := msg.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack the query: %v", err)
+	}
+
+	ch := make(chan []byte, 1)
+	r.Lock()
+	if r.err != nil {
+		r.Unlock()
+		return nil, ErrWorkerDown
+	}
+	r.nextID++
+	id := r.nextID
+	r.pending[id] = ch
+	r.Unlock()
+
+	if err := r.write(frameQuery, packID(id, data)); err != nil {
+		r.close(err)
+		return nil, ErrWorkerDown
+	}
+
+	select {
+	case <-ctx.Done():
+		r.Lock()
+		delete(r.pending, id)
+		r.Unlock()
+		return nil, ctx.Err()
+	case <-r.done:
+		return nil, ErrWorkerDown
+	case data := <-ch:
+		if len(data) == 0 {
+			return nil, ErrQueryFailed
+		}
+
+		resp := new(dns.Msg)
+		if err := resp.Unpack(data); err != nil {
+			return nil, fmt.Errorf("failed to unpack the response from the worker at %s: %v", r.addr, err)
+		}
+		return resp, nil
+	}
+}
+
+// ping checks that the worker is still reading from the connection.
+func (r *remote) ping() error {
+	var seq [8]byte
+
+	binary.BigEndian.PutUint64(seq[:], uint64(time.Now().UnixNano()))
+	if err := r.write(framePing, seq[:]); err != nil {
+		r.close(err)
+		return err
+	}
+	return nil
+}
+
+// idle returns the amount of time since the worker last sent a frame.
+func (r *remote) idle() time.Duration {
+	r.Lock()
+	defer r.Unlock()
+
+	return time.Since(r.lastSeen)
+}
+
+func (r *remote) write(t byte, payload []byte) error {
+	r.wmu.Lock()
+	defer r.wmu.Unlock()
+
+	_ = r.conn.SetWriteDeadline(time.Now().Add(handshakeTimeout))
+	return writeFrame(r.conn, t, payload)
+}
+
+func (r *remote) readResponses(rd *bufio.Reader) {
+	for {
+		t, payload, err := readFrame(rd)
+		if err != nil {
+			r.close(err)
+			return
+		}
+
+		r.Lock()
+		r.lastSeen = time.Now()
+		r.Unlock()
+		if t != frameResponse {
+			continue
+		}
+
+		id, data, err := unpackID(payload)
+		if err != nil {
+			r.close(err)
+			return
+		}
+
+		r.Lock()
+		ch, found := r.pending[id]
+		delete(r.pending, id)
+		r.Unlock()
+		if found {
+			ch <- data
+		}
+	}
+}
+
+// close releases the connection, and the queries in flight return ErrWorkerDown.
+func (r *remote) close(err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.err != nil {
+		return
+	}
+	if err == nil {
+		err = net.ErrClosed
+	}
+	r.err = err
+	r.pending = make(map[uint32]chan []byte)
+	_ = r.conn.Close()
+	close(r.done)
+}
+
+// closed returns the reason the connection was closed, or nil when it is still open.
+func (r *remote) closed() error {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.err
+}
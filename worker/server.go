@@ -0,0 +1,246 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package worker
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/owasp-amass/resolve"
+)
+
+const (
+	handshakeTimeout = 10 * time.Second
+	// QueryTimeout is the amount of time a worker spends resolving a query before it reports
+	// the query as failed
+	QueryTimeout = 4 * time.Second
+	// the queries each coordinator connection can have in flight before the worker stops reading
+	maxConnQueries = 10000
+)
+
+// Server is the worker process resolving the queries sent by the coordinators with its own pool of resolvers.
+type Server struct {
+	sync.Mutex
+	pool   *resolvers.Pool
+	token  string
+	log    *log.Logger
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// NewServer returns a Server resolving the queries with the provided pool. The coordinators must
+// hold the same token to send queries to the Server.
+func NewServer(pool *resolvers.Pool, token string, l *log.Logger) *Server {
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	return &Server{
+		pool:  pool,
+		token: token,
+		log:   l,
+		conns: make(map[net.Conn]struct{}),
+	}
+}
+
+// Serve accepts the coordinator connections on the listener until the Server is closed.
+func (s *Server) Serve(ln net.Listener) error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return net.ErrClosed
+	}
+	s.ln = ln
+	s.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.Lock()
+			closed := s.closed
+			s.Unlock()
+
+			if closed {
+				return nil
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+
+		if !s.track(conn) {
+			_ = conn.Close()
+			return nil
+		}
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+// Close stops accepting connections and closes the connections of the coordinators.
+func (s *Server) Close() error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
+	s.closed = true
+	ln := s.ln
+	for conn := range s.conns {
+		_ = conn.Close()
+	}
+	s.Unlock()
+
+	var err error
+	if ln != nil {
+		err = ln.Close()
+	}
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) track(conn net.Conn) bool {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.closed {
+		return false
+	}
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.conns, conn)
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer s.untrack(conn)
+	defer func() { _ = conn.Close() }()
+
+	r := bufio.NewReader(conn)
+	if err := s.handshake(conn, r); err != nil {
+		s.log.Printf("The connection from %s was refused: %v", conn.RemoteAddr(), err)
+		return
+	}
+	s.log.Printf("The coordinator at %s connected", conn.RemoteAddr())
+
+	// the queries in flight are cancelled before waiting for them to return
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wmu sync.Mutex
+	write := func(t byte, payload []byte) {
+		wmu.Lock()
+		defer wmu.Unlock()
+
+		if err := writeFrame(conn, t, payload); err != nil {
+			_ = conn.Close()
+		}
+	}
+
+	slots := make(chan struct{}, maxConnQueries)
+	for {
+		t, payload, err := readFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				s.log.Printf("The connection from %s failed: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		switch t {
+		case framePing:
+			write(framePong, payload)
+		case frameQuery:
+			id, data, err := unpackID(payload)
+			if err != nil {
+				return
+			}
+
+			slots <- struct{}{}
+			wg.Add(1)
+			go func(id uint32, data []byte) {
+				defer wg.Done()
+				defer func() { <-slots }()
+
+				write(frameResponse, packID(id, s.resolve(ctx, data)))
+			}(id, data)
+		default:
+			s.log.Printf("The coordinator at %s sent an unexpected frame", conn.RemoteAddr())
+			return
+		}
+	}
+}
+
+func (s *Server) handshake(conn net.Conn, r io.Reader) error {
+	_ = conn.SetDeadline(time.Now().Add(handshakeTimeout))
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	challenge, err := newNonce()
+	if err != nil {
+		return err
+	}
+	if err := writeFrame(conn, frameChallenge, challenge); err != nil {
+		return err
+	}
+
+	t, payload, err := readFrame(r)
+	if err != nil {
+		return err
+	}
+	if t != frameAuth || len(payload) != macSize+nonceSize || !verify(s.token, coordinatorLabel, challenge, payload[:macSize]) {
+		_ = writeFrame(conn, frameDenied, nil)
+		return ErrDenied
+	}
+
+	welcome := make([]byte, macSize+4)
+	copy(welcome, sign(s.token, workerLabel, payload[macSize:]))
+	binary.BigEndian.PutUint32(welcome[macSize:], uint32(s.pool.QPS()))
+	return writeFrame(conn, frameWelcome, welcome)
+}
+
+// resolve returns the packed response message, or nothing when the query failed.
+func (s *Server) resolve(ctx context.Context, data []byte) []byte {
+	msg := new(dns.Msg)
+	if err := msg.Unpack(data); err != nil || len(msg.Question) == 0 {
+		return nil
+	}
+
+	qctx, cancel := context.WithTimeout(ctx, QueryTimeout)
+	defer cancel()
+
+	resp, err := s.pool.QueryBlocking(qctx, msg)
+	if err != nil || resp == nil || resp.Rcode == resolve.RcodeNoResponse {
+		return nil
+	}
+
+	packed, err := resp.Pack()
+	if err != nil {
+		return nil
+	}
+	return packed
+}
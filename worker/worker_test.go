@@ -0,0 +1,231 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/amasstest"
+	"github.com/owasp-amass/amass/v4/resolvers"
+	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret"
+
+const testZone = `$ORIGIN example.com.
+@	3600	IN	SOA	ns1.example.com. admin.example.com. 1 7200 3600 1209600 3600
+www	IN	A	192.0.2.1
+`
+
+// startWorker serves the queries with the pool on a loopback port and returns the worker address.
+func startWorker(t *testing.T, pool *resolvers.Pool, token string) (string, *Server) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	srv := NewServer(pool, token, nil)
+	go func() { _ = srv.Serve(ln) }()
+
+	t.Cleanup(func() {
+		_ = srv.Close()
+		pool.Stop()
+	})
+	return ln.Addr().String(), srv
+}
+
+func zonePool(t *testing.T) *resolvers.Pool {
+	srv, err := amasstest.NewDNSServer()
+	require.NoError(t, err)
+	t.Cleanup(srv.Close)
+
+	require.NoError(t, srv.LoadZoneString("example.com", testZone))
+	return srv.Pool()
+}
+
+// slowPool answers each query after the delay.
+func slowPool(t *testing.T, delay time.Duration) *resolvers.Pool {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	started := make(chan struct{})
+	srv := &dns.Server{
+		PacketConn:        pc,
+		NotifyStartedFunc: func() { close(started) },
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			time.Sleep(delay)
+
+			m := new(dns.Msg)
+			m.SetReply(req)
+			_ = w.WriteMsg(m)
+		}),
+	}
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+
+	pool := resolvers.NewPool()
+	require.NoError(t, pool.AddResolvers(1000, pc.LocalAddr().String()))
+	pool.SetTimeout(10 * time.Second)
+	return pool
+}
+
+func TestHandshake(t *testing.T) {
+	addr, _ := startWorker(t, zonePool(t), testToken)
+	ctx := context.Background()
+
+	r, err := dialWorker(ctx, nil, addr, testToken)
+	require.NoError(t, err)
+	require.Equal(t, amasstest.DefaultQPS, r.capacity)
+	r.close(nil)
+
+	_, err = dialWorker(ctx, nil, addr, "wrong")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), ErrDenied.Error())
+
+	// the worker must also prove that it holds the token
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	rogue := NewServer(zonePool(t), "other", nil)
+	go func() { _ = rogue.Serve(ln) }()
+	defer rogue.Close()
+
+	_, err = NewCluster([]string{ln.Addr().String()}, testToken, nil, nil)
+	require.Error(t, err)
+}
+
+func TestClusterShardsQueries(t *testing.T) {
+	var addrs []string
+	for i := 0; i < 3; i++ {
+		addr, _ := startWorker(t, zonePool(t), testToken)
+		addrs = append(addrs, addr)
+	}
+
+	c, err := NewCluster(addrs, testToken, nil, nil)
+	require.NoError(t, err)
+	defer c.Close()
+	require.Equal(t, 3*amasstest.DefaultQPS, c.Capacity())
+
+	pool := resolvers.NewPool()
+	defer pool.Stop()
+	require.NoError(t, pool.AddExchanger("workers", c.Capacity(), c))
+	require.Equal(t, 1, pool.Len())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 60; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			name := "www.example.com"
+			if i%2 == 1 {
+				name = fmt.Sprintf("missing%d.example.com", i)
+			}
+			resp, err := pool.QueryBlocking(context.Background(), resolve.QueryMsg(name, dns.TypeA))
+			require.NoError(t, err)
+			if i%2 == 1 {
+				require.Equal(t, dns.RcodeNameError, resp.Rcode)
+				return
+			}
+			require.Equal(t, dns.RcodeSuccess, resp.Rcode)
+			require.Len(t, resolve.ExtractAnswers(resp), 1)
+		}(i)
+	}
+	wg.Wait()
+
+	var total uint64
+	for _, s := range c.Stats() {
+		require.True(t, s.Healthy)
+		require.Zero(t, s.InFlight)
+		require.Equal(t, s.Queries, s.Responses)
+		total += s.Queries
+	}
+	require.Equal(t, uint64(60), total)
+}
+
+func TestReassignWhenWorkerDies(t *testing.T) {
+	slowAddr, slow := startWorker(t, slowPool(t, time.Second), testToken)
+	fastAddr, _ := startWorker(t, zonePool(t), testToken)
+
+	c, err := NewCluster([]string{slowAddr, fastAddr}, testToken, nil, nil)
+	require.NoError(t, err)
+	defer c.Close()
+
+	num := 20
+	errs := make(chan error, num)
+	for i := 0; i < num; i++ {
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			resp, _, err := c.Exchange(ctx, resolve.QueryMsg("www.example.com", dns.TypeA))
+			if err == nil && len(resolve.ExtractAnswers(resp)) == 0 {
+				err = errors.New("the response was not provided by the healthy worker")
+			}
+			errs <- err
+		}()
+	}
+
+	// the slow worker is lost while holding queries in flight
+	require.Eventually(t, func() bool {
+		for _, s := range c.Stats() {
+			if s.Address == slowAddr && s.InFlight > 0 {
+				return true
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+	_ = slow.Close()
+
+	for i := 0; i < num; i++ {
+		require.NoError(t, <-errs)
+	}
+
+	for _, s := range c.Stats() {
+		switch s.Address {
+		case slowAddr:
+			require.False(t, s.Healthy)
+			require.Equal(t, 1, s.Disconnects)
+			require.Zero(t, s.Responses)
+		case fastAddr:
+			require.True(t, s.Healthy)
+			require.NotZero(t, s.Reassigned)
+			require.Equal(t, uint64(num), s.Responses)
+		}
+	}
+	require.Equal(t, amasstest.DefaultQPS, c.Capacity())
+}
+
+func TestNoWorkers(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	_, err = NewCluster([]string{addr}, testToken, nil, nil)
+	require.Error(t, err)
+
+	_, err = NewCluster(nil, testToken, nil, nil)
+	require.Error(t, err)
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	addr, err := NormalizeAddress("192.0.2.1")
+	require.NoError(t, err)
+	require.Equal(t, "192.0.2.1:4100", addr)
+
+	addr, err = NormalizeAddress("worker.example.com:5000")
+	require.NoError(t, err)
+	require.Equal(t, "worker.example.com:5000", addr)
+
+	_, err = NormalizeAddress(":5000")
+	require.Error(t, err)
+}
@@ -0,0 +1,326 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package zonefile answers DNS queries from RFC 1035 zone files held in memory, so enumerations,
+// workers and script tests can run against the zones without sending queries to the network.
+package zonefile
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/amass/v4/resolvers"
+)
+
+const (
+	// DefaultQPS is the rate limit applied to the resolver pools built for the Zones.
+	DefaultQPS      int = 1000
+	maxZoneCNAMEs   int = 8
+	defaultZoneSize int = 512
+	// the name of the Zones in the statistics of the resolver pools
+	poolName = "zonefile"
+)
+
+// Zones answers the DNS queries from the zones loaded into it. Queries with the recursion desired
+// bit set are answered as a recursive resolver would, so the same Zones can stand in for both the
+// resolvers and the authoritative name servers of the loaded zones.
+type Zones struct {
+	sync.Mutex
+	zones map[string]*zoneData
+}
+
+type zoneData struct {
+	origin  string
+	soa     dns.RR
+	records map[string][]dns.RR
+}
+
+// NewZones returns Zones without any zone loaded.
+func NewZones() *Zones {
+	return &Zones{zones: make(map[string]*zoneData)}
+}
+
+// Pool returns a resolver pool that answers all queries from the Zones and uses them for wildcard detection.
+func (zs *Zones) Pool() *resolvers.Pool {
+	p := resolvers.NewPool()
+
+	_ = p.AddExchanger(poolName, DefaultQPS, zs)
+	p.SetDetectionResolver(DefaultQPS, poolName)
+	p.SetTimeout(time.Second)
+	return p
+}
+
+// Exchange implements the resolvers.Exchanger interface.
+func (zs *Zones) Exchange(ctx context.Context, msg *dns.Msg) (*dns.Msg, time.Duration, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	start := time.Now()
+	resp := zs.Answer(msg)
+	return resp, time.Since(start), nil
+}
+
+// LoadZoneFile adds the zone in the master file at the provided path to the Zones.
+// When the origin is empty, the owner of the SOA record in the file is used as the origin.
+func (zs *Zones) LoadZoneFile(origin, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open the zone file %s: %v", path, err)
+	}
+	defer f.Close()
+
+	return zs.LoadZone(origin, f, path)
+}
+
+// LoadZoneString adds the zone provided in the master file format to the Zones.
+func (zs *Zones) LoadZoneString(origin, data string) error {
+	return zs.LoadZone(origin, strings.NewReader(data), "")
+}
+
+// LoadZone reads a zone in the master file format and adds it to the Zones.
+// Names relative to the origin and a default TTL are permitted within the zone data.
+func (zs *Zones) LoadZone(origin string, r io.Reader, filename string) error {
+	var rrs []dns.RR
+	var soa dns.RR
+
+	if origin != "" {
+		origin = dns.CanonicalName(origin)
+	}
+	zp := dns.NewZoneParser(r, origin, filename)
+	zp.SetDefaultTTL(300)
+	for rr, ok := zp.Next(); ok; rr, ok = zp.Next() {
+		rr.Header().Name = dns.CanonicalName(rr.Header().Name)
+		if rr.Header().Rrtype == dns.TypeSOA && soa == nil {
+			soa = rr
+		}
+		rrs = append(rrs, rr)
+	}
+	if err := zp.Err(); err != nil {
+		return fmt.Errorf("failed to parse the zone %s: %v", filename, err)
+	}
+
+	if origin == "" {
+		if soa == nil {
+			return fmt.Errorf("the zone %s has no SOA record and no origin was provided", filename)
+		}
+		origin = soa.Header().Name
+	}
+	if soa == nil || soa.Header().Name != origin {
+		soa = defaultSOA(origin)
+		rrs = append(rrs, soa)
+	}
+
+	z := &zoneData{
+		origin:  origin,
+		soa:     soa,
+		records: make(map[string][]dns.RR),
+	}
+	for _, rr := range rrs {
+		name := rr.Header().Name
+
+		if !dns.IsSubDomain(origin, name) {
+			return fmt.Errorf("the record %s is outside of the zone %s", name, origin)
+		}
+		z.records[name] = append(z.records[name], rr)
+	}
+
+	zs.Lock()
+	defer zs.Unlock()
+
+	zs.zones[origin] = z
+	return nil
+}
+
+func defaultSOA(origin string) dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: origin, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 300},
+		Ns:      "ns." + origin,
+		Mbox:    "hostmaster." + origin,
+		Serial:  1,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  300,
+	}
+}
+
+// Answer returns the response to the query from the loaded zones. The response is not truncated,
+// so the servers sending it over UDP must truncate it to the size accepted by the client.
+func (zs *Zones) Answer(req *dns.Msg) *dns.Msg {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.RecursionAvailable = req.RecursionDesired
+
+	if len(req.Question) != 1 {
+		resp.Rcode = dns.RcodeFormatError
+		return resp
+	}
+
+	q := req.Question[0]
+	if z := zs.findZone(q.Name); z != nil {
+		zs.answer(resp, z, dns.CanonicalName(q.Name), q.Qtype, req.RecursionDesired, 0)
+	} else if req.RecursionDesired {
+		resp.Rcode = dns.RcodeNameError
+	} else {
+		resp.Rcode = dns.RcodeRefused
+	}
+
+	if opt := req.IsEdns0(); opt != nil {
+		resp.SetEdns0(opt.UDPSize(), false)
+	}
+	return resp
+}
+
+// MaxUDPSize returns the size of the UDP responses accepted by the client sending the query.
+func MaxUDPSize(req *dns.Msg) int {
+	if opt := req.IsEdns0(); opt != nil {
+		return int(opt.UDPSize())
+	}
+	return defaultZoneSize
+}
+
+func (zs *Zones) findZone(name string) *zoneData {
+	zs.Lock()
+	defer zs.Unlock()
+
+	name = dns.CanonicalName(name)
+	for {
+		if z, found := zs.zones[name]; found {
+			return z
+		}
+
+		off, end := dns.NextLabel(name, 0)
+		if end {
+			return nil
+		}
+		name = name[off:]
+	}
+}
+
+func (zs *Zones) answer(resp *dns.Msg, z *zoneData, name string, qtype uint16, recurse bool, depth int) {
+	if ns, glue := z.delegation(name); len(ns) > 0 {
+		if sub := zs.findZone(ns[0].Header().Name); recurse && sub != nil && sub != z {
+			zs.answer(resp, sub, name, qtype, recurse, depth)
+			return
+		}
+		if qtype != dns.TypeNS || ns[0].Header().Name != name || !recurse {
+			resp.Authoritative = false
+			resp.Ns = append(resp.Ns, ns...)
+			resp.Extra = append(resp.Extra, glue...)
+			return
+		}
+		resp.Answer = append(resp.Answer, ns...)
+		return
+	}
+
+	if depth == 0 {
+		resp.Authoritative = true
+	}
+	rrs, exists := z.lookup(name)
+	if !exists {
+		resp.Rcode = dns.RcodeNameError
+		resp.Ns = append(resp.Ns, z.soa)
+		return
+	}
+
+	var found bool
+	for _, rr := range rrs {
+		if t := rr.Header().Rrtype; t == qtype || qtype == dns.TypeANY {
+			resp.Answer = append(resp.Answer, rr)
+			found = true
+		}
+	}
+	if found {
+		return
+	}
+
+	for _, rr := range rrs {
+		if cname, ok := rr.(*dns.CNAME); ok {
+			resp.Answer = append(resp.Answer, rr)
+			// Follow the alias when the target is within the loaded zones
+			if target := dns.CanonicalName(cname.Target); depth < maxZoneCNAMEs {
+				if tz := zs.findZone(target); tz != nil && (recurse || tz == z) {
+					zs.answer(resp, tz, target, qtype, recurse, depth+1)
+				}
+			}
+			return
+		}
+	}
+	resp.Ns = append(resp.Ns, z.soa)
+}
+
+// delegation returns the NS records and glue when the name falls within a zone cut below the origin.
+func (z *zoneData) delegation(name string) ([]dns.RR, []dns.RR) {
+	labels := dns.SplitDomainName(name)
+
+	for i := len(labels) - len(dns.SplitDomainName(z.origin)) - 1; i >= 0; i-- {
+		cut := dns.Fqdn(strings.Join(labels[i:], "."))
+
+		var ns []dns.RR
+		for _, rr := range z.records[cut] {
+			if rr.Header().Rrtype == dns.TypeNS {
+				ns = append(ns, rr)
+			}
+		}
+		if len(ns) == 0 {
+			continue
+		}
+
+		var glue []dns.RR
+		for _, rr := range ns {
+			host := dns.CanonicalName(rr.(*dns.NS).Ns)
+
+			for _, a := range z.records[host] {
+				if t := a.Header().Rrtype; t == dns.TypeA || t == dns.TypeAAAA {
+					glue = append(glue, a)
+				}
+			}
+		}
+		return ns, glue
+	}
+	return nil, nil
+}
+
+// lookup returns the records for the name, synthesizing them from a wildcard when necessary,
+// and whether the name exists within the zone.
+func (z *zoneData) lookup(name string) ([]dns.RR, bool) {
+	if rrs, found := z.records[name]; found {
+		return rrs, true
+	}
+	// Check for an empty non-terminal
+	for owner := range z.records {
+		if strings.HasSuffix(owner, "."+name) {
+			return nil, true
+		}
+	}
+
+	for off, end := dns.NextLabel(name, 0); !end; off, end = dns.NextLabel(name, off) {
+		parent := name[off:]
+		if !dns.IsSubDomain(z.origin, parent) {
+			break
+		}
+
+		if rrs, found := z.records["*."+parent]; found {
+			var synth []dns.RR
+			for _, rr := range rrs {
+				c := dns.Copy(rr)
+				c.Header().Name = name
+				synth = append(synth, c)
+			}
+			return synth, true
+		}
+		// The closest encloser stops the wildcard search
+		if _, found := z.records[parent]; found {
+			break
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,72 @@
+// Copyright © by Jeff Foley 2017-2023. All rights reserved.
+// Use of this source code is governed by Apache 2 LICENSE that can be found in the LICENSE file.
+// SPDX-License-Identifier: Apache-2.0
+
+package zonefile
+
+import (
+	"context"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/owasp-amass/resolve"
+	"github.com/stretchr/testify/require"
+)
+
+const exampleZone = `
+@	IN	NS	ns1.example.com.
+ns1	IN	A	127.0.0.53
+www	IN	CNAME	web
+web	IN	A	127.0.0.80
+*.dev	IN	A	127.0.0.8
+a.b.c	IN	A	127.0.0.9
+sub	IN	NS	ns1.sub
+ns1.sub	IN	A	127.0.0.54
+`
+
+func TestZones(t *testing.T) {
+	zs := NewZones()
+	require.NoError(t, zs.LoadZoneString("example.com", exampleZone))
+	require.Error(t, zs.LoadZoneString("example.org", "www.example.com. IN A 127.0.0.1"))
+
+	tests := []struct {
+		name    string
+		qtype   uint16
+		rd      bool
+		rcode   int
+		answers int
+		auth    int
+	}{
+		{"www.example.com", dns.TypeA, true, dns.RcodeSuccess, 2, 0},
+		{"WEB.example.com", dns.TypeA, true, dns.RcodeSuccess, 1, 0},
+		{"web.example.com", dns.TypeAAAA, true, dns.RcodeSuccess, 0, 1},
+		{"foo.dev.example.com", dns.TypeA, true, dns.RcodeSuccess, 1, 0},
+		{"b.c.example.com", dns.TypeA, true, dns.RcodeSuccess, 0, 1},
+		{"nothere.example.com", dns.TypeA, true, dns.RcodeNameError, 0, 1},
+		{"www.sub.example.com", dns.TypeA, false, dns.RcodeSuccess, 0, 1},
+		{"www.example.org", dns.TypeA, true, dns.RcodeNameError, 0, 0},
+		{"www.example.org", dns.TypeA, false, dns.RcodeRefused, 0, 0},
+	}
+
+	for _, test := range tests {
+		msg := resolve.QueryMsg(test.name, test.qtype)
+		msg.RecursionDesired = test.rd
+
+		resp, _, err := zs.Exchange(context.Background(), msg)
+		require.NoError(t, err, test.name)
+		require.Equal(t, msg.Id, resp.Id, test.name)
+		require.Equal(t, test.rcode, resp.Rcode, test.name)
+		require.Len(t, resp.Answer, test.answers, test.name)
+		require.Len(t, resp.Ns, test.auth, test.name)
+	}
+
+	p := zs.Pool()
+	defer p.Stop()
+
+	resp, err := p.QueryBlocking(context.Background(), resolve.QueryMsg("foo.dev.example.com", dns.TypeA))
+	require.NoError(t, err)
+	ans := resolve.ExtractAnswers(resp)
+	require.Len(t, ans, 1)
+	require.Equal(t, "foo.dev.example.com", ans[0].Name)
+	require.Equal(t, "127.0.0.8", ans[0].Data)
+}